@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/hashicorp/packer/common/chroot"
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
 )
@@ -23,7 +24,7 @@ func (s *StepEarlyCleanup) Run(_ context.Context, state multistep.StateBag) mult
 	}
 
 	for _, key := range cleanupKeys {
-		c := state.Get(key).(Cleanup)
+		c := state.Get(key).(chroot.Cleanup)
 		log.Printf("Running cleanup func: %s", key)
 		if err := c.CleanupFunc(state); err != nil {
 			err := fmt.Errorf("Error cleaning up: %s", err)
@@ -305,6 +305,13 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	return nil, nil
 }
 
+// PlanOutputs tells build plans about the AMI, which -force deregisters.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "ami_name"},
+	}
+}
+
 func (b *Builder) Cancel() {
 	if b.runner != nil {
 		log.Println("Cancelling the step runner...")
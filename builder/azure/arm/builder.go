@@ -303,6 +303,13 @@ func (b *Builder) isPrivateNetworkCommunication() bool {
 	return b.config.VirtualNetworkName != ""
 }
 
+// PlanOutputs tells build plans about the managed image, which -force replaces.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "managed_image_name"},
+	}
+}
+
 func (b *Builder) Cancel() {
 	if b.ctxCancel != nil {
 		log.Printf("Cancelling Azure builder...")
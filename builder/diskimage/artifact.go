@@ -0,0 +1,38 @@
+package diskimage
+
+import (
+	"fmt"
+	"os"
+)
+
+// Artifact is the result of running the disk-image builder, namely the
+// disk image in the output directory.
+type Artifact struct {
+	dir   string
+	f     []string
+	state map[string]interface{}
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return a.f
+}
+
+func (*Artifact) Id() string {
+	return "DiskImage"
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("Disk image files in directory: %s", a.dir)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return a.state[name]
+}
+
+func (a *Artifact) Destroy() error {
+	return os.RemoveAll(a.dir)
+}
@@ -0,0 +1,133 @@
+// The diskimage package builds bootable disk images without a virtual
+// machine. It partitions and formats a raw disk image, mounts it with a
+// loop device, provisions it within a chroot like the amazon-chroot
+// builder, installs a bootloader with commands, and converts it with
+// qemu-img.
+package diskimage
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"runtime"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The unique ID for this builder
+const BuilderId = "packer.disk-image"
+
+type wrappedCommandTemplate struct {
+	Command string
+}
+
+type Builder struct {
+	config *Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	c, warnings, errs := NewConfig(raws...)
+	if errs != nil {
+		return warnings, errs
+	}
+	b.config = c
+
+	return warnings, nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("The disk-image builder only works on Linux environments.")
+	}
+
+	wrappedCommand := func(command string) (string, error) {
+		ctx := b.config.ctx
+		ctx.Data = &wrappedCommandTemplate{Command: command}
+		return interpolate.Render(b.config.CommandWrapper, &ctx)
+	}
+
+	// Setup the state bag and initial state for the steps
+	state := new(multistep.BasicStateBag)
+	state.Put("config", b.config)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+	state.Put("wrappedCommand", chroot.CommandWrapper(wrappedCommand))
+
+	// Build the steps
+	steps := []multistep.Step{
+		new(stepPrepareOutputDir),
+		&StepCreateImage{},
+		&StepPartition{},
+		&StepAttachLoop{},
+		&StepFormatPartitions{},
+		&chroot.StepPreMountCommands{
+			Commands: b.config.PreMountCommands,
+		},
+		&StepMountPartitions{},
+		&chroot.StepPostMountCommands{
+			Commands: b.config.PostMountCommands,
+		},
+		&chroot.StepMountExtra{
+			ChrootMounts: b.config.ChrootMounts,
+		},
+		&chroot.StepCopyFiles{
+			Files: b.config.CopyFiles,
+		},
+		&chroot.StepChrootProvision{},
+		&StepBootloaderCommands{
+			Commands: b.config.BootloaderCommands,
+		},
+		&StepEarlyCleanup{},
+		&StepConvertImage{},
+	}
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner.Run(state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	artifact := &Artifact{
+		dir:   b.config.OutputDir,
+		f:     []string{filepath.Join(b.config.OutputDir, b.config.ImageName)},
+		state: make(map[string]interface{}),
+	}
+
+	artifact.state["diskName"] = state.Get("disk_filename").(string)
+	artifact.state["diskType"] = b.config.Format
+	artifact.state["diskSize"] = uint64(b.config.DiskSize)
+
+	return artifact, nil
+}
+
+// PlanOutputs tells build plans about the output directory, which -force
+// deletes.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output_directory", Default: "output-{{build_name}}", Local: true},
+	}
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
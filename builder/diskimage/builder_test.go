@@ -0,0 +1,153 @@
+package diskimage
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"packer_build_name": "foo",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	warnings, err := b.Prepare(testConfig())
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.DiskSize != 4096 || b.config.PartitionTable != "gpt" {
+		t.Fatalf("bad: %d %s", b.config.DiskSize, b.config.PartitionTable)
+	}
+	if len(b.config.Partitions) != 1 || b.config.Partitions[0].Mountpoint != "/" {
+		t.Fatalf("bad: %#v", b.config.Partitions)
+	}
+	if b.config.Format != "raw" || b.config.ImageName != "packer-foo.raw" || b.config.OutputDir != "output-foo" {
+		t.Fatalf("bad: %s %s %s", b.config.Format, b.config.ImageName, b.config.OutputDir)
+	}
+	if b.config.MountPath != "/mnt/packer-disk-image/{{.Device}}" {
+		t.Fatalf("bad: %s", b.config.MountPath)
+	}
+	if len(b.config.ChrootMounts) != 5 || len(b.config.CopyFiles) != 1 {
+		t.Fatalf("bad: %#v %#v", b.config.ChrootMounts, b.config.CopyFiles)
+	}
+}
+
+func TestBuilderPrepare_Partitions(t *testing.T) {
+	cases := []struct {
+		Partitions []map[string]interface{}
+		Err        bool
+	}{
+		{
+			[]map[string]interface{}{
+				{"name": "bios", "size": 1, "flags": []string{"bios_grub"}},
+				{"name": "efi", "size": 256, "filesystem": "vfat", "mountpoint": "/boot/efi/", "flags": []string{"esp"}},
+				{"name": "swap", "size": 512, "filesystem": "swap"},
+				{"name": "root", "filesystem": "ext4", "mountpoint": "/", "label": "root"},
+			},
+			false,
+		},
+		// Only the last partition may fill the disk
+		{
+			[]map[string]interface{}{
+				{"filesystem": "ext4", "mountpoint": "/"},
+				{"size": 100, "filesystem": "ext4", "mountpoint": "/home"},
+			},
+			true,
+		},
+		// There must be a root filesystem
+		{[]map[string]interface{}{{"filesystem": "ext4", "mountpoint": "/home"}}, true},
+		{[]map[string]interface{}{{"filesystem": "zfs", "mountpoint": "/"}}, true},
+		{[]map[string]interface{}{{"filesystem": "swap", "mountpoint": "/"}}, true},
+		{[]map[string]interface{}{{"filesystem": "ext4", "mountpoint": "root"}}, true},
+		{[]map[string]interface{}{{"name": "my root", "filesystem": "ext4", "mountpoint": "/"}}, true},
+		{
+			[]map[string]interface{}{
+				{"size": 100, "filesystem": "ext4", "mountpoint": "/"},
+				{"filesystem": "ext4", "mountpoint": "/"},
+			},
+			true,
+		},
+		// The partitions must fit
+		{[]map[string]interface{}{{"size": 4095, "filesystem": "ext4", "mountpoint": "/"}}, true},
+	}
+
+	for i, tc := range cases {
+		config := testConfig()
+		config["partitions"] = tc.Partitions
+
+		var b Builder
+		_, err := b.Prepare(config)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%d: bad: %v", i, err)
+		}
+	}
+}
+
+func TestBuilderPrepare_PartitionTable(t *testing.T) {
+	config := testConfig()
+	config["partition_table"] = "msdos"
+
+	var b Builder
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Only GPT partitions have names
+	config["partitions"] = []map[string]interface{}{
+		{"name": "root", "filesystem": "ext4", "mountpoint": "/"},
+	}
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	config["partition_table"] = "apm"
+	delete(config, "partitions")
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Format(t *testing.T) {
+	config := testConfig()
+	config["format"] = "qcow2"
+	config["disk_compression"] = true
+
+	var b Builder
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 || err != nil {
+		t.Fatalf("bad: %#v %v", warnings, err)
+	}
+	if b.config.ImageName != "packer-foo.qcow2" {
+		t.Fatalf("bad: %s", b.config.ImageName)
+	}
+
+	config["format"] = "raw"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) != 1 || err != nil {
+		t.Fatalf("bad: %#v %v", warnings, err)
+	}
+
+	config["format"] = "vmdk"
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
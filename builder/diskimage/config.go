@@ -0,0 +1,248 @@
+package diskimage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// mkfsCommands are the commands that format the supported filesystems,
+// with the flag of the label.
+var mkfsCommands = map[string][2]string{
+	"btrfs": {"mkfs.btrfs", "-L"},
+	"ext2":  {"mkfs.ext2", "-L"},
+	"ext3":  {"mkfs.ext3", "-L"},
+	"ext4":  {"mkfs.ext4", "-L"},
+	"swap":  {"mkswap", "-L"},
+	"vfat":  {"mkfs.vfat", "-n"},
+	"xfs":   {"mkfs.xfs", "-L"},
+}
+
+// Partition is a partition of the disk.
+type Partition struct {
+	// The name of the partition in a GPT partition table.
+	Name string `mapstructure:"name"`
+
+	// The size of the partition in megabytes. The last partition fills the
+	// rest of the disk if it is 0.
+	Size uint `mapstructure:"size"`
+
+	// The filesystem of the partition, which isn't formatted if it is empty.
+	Filesystem string `mapstructure:"filesystem"`
+
+	// The path the filesystem is mounted at in the image.
+	Mountpoint string `mapstructure:"mountpoint"`
+
+	Label        string   `mapstructure:"label"`
+	Flags        []string `mapstructure:"flags"`
+	MkfsOptions  []string `mapstructure:"mkfs_options"`
+	MountOptions []string `mapstructure:"mount_options"`
+}
+
+// Config is the configuration that is chained through the steps and
+// settable from the template.
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	DiskSize           uint        `mapstructure:"disk_size"`
+	PartitionTable     string      `mapstructure:"partition_table"`
+	Partitions         []Partition `mapstructure:"partitions"`
+	ChrootMounts       [][]string  `mapstructure:"chroot_mounts"`
+	CommandWrapper     string      `mapstructure:"command_wrapper"`
+	CopyFiles          []string    `mapstructure:"copy_files"`
+	MountPath          string      `mapstructure:"mount_path"`
+	PreMountCommands   []string    `mapstructure:"pre_mount_commands"`
+	PostMountCommands  []string    `mapstructure:"post_mount_commands"`
+	BootloaderCommands []string    `mapstructure:"bootloader_commands"`
+	Format             string      `mapstructure:"format"`
+	DiskCompression    bool        `mapstructure:"disk_compression"`
+	OutputDir          string      `mapstructure:"output_directory"`
+	ImageName          string      `mapstructure:"image_name"`
+
+	ctx interpolate.Context
+}
+
+// GetContext returns the template context of the commands of the steps.
+func (c *Config) GetContext() interpolate.Context {
+	return c.ctx
+}
+
+func NewConfig(raws ...interface{}) (*Config, []string, error) {
+	var c Config
+	err := config.Decode(&c, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"bootloader_commands",
+				"command_wrapper",
+				"post_mount_commands",
+				"pre_mount_commands",
+				"mount_path",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Accumulate any errors or warnings
+	var errs *packer.MultiError
+	var warns []string
+
+	if c.DiskSize == 0 {
+		c.DiskSize = 4096
+	}
+
+	if c.PartitionTable == "" {
+		c.PartitionTable = "gpt"
+	}
+
+	if len(c.Partitions) == 0 {
+		root := Partition{Filesystem: "ext4", Mountpoint: "/"}
+		if c.PartitionTable == "gpt" {
+			root.Name = "root"
+		}
+		c.Partitions = []Partition{root}
+	}
+
+	if len(c.ChrootMounts) == 0 {
+		c.ChrootMounts = [][]string{
+			{"proc", "proc", "/proc"},
+			{"sysfs", "sysfs", "/sys"},
+			{"bind", "/dev", "/dev"},
+			{"devpts", "devpts", "/dev/pts"},
+			{"binfmt_misc", "binfmt_misc", "/proc/sys/fs/binfmt_misc"},
+		}
+	}
+
+	if c.CopyFiles == nil {
+		c.CopyFiles = []string{"/etc/resolv.conf"}
+	}
+
+	if c.CommandWrapper == "" {
+		c.CommandWrapper = "{{.Command}}"
+	}
+
+	if c.MountPath == "" {
+		c.MountPath = "/mnt/packer-disk-image/{{.Device}}"
+	}
+
+	if c.Format == "" {
+		c.Format = "raw"
+	}
+
+	if c.OutputDir == "" {
+		c.OutputDir = fmt.Sprintf("output-%s", c.PackerBuildName)
+	}
+
+	if c.ImageName == "" {
+		c.ImageName = fmt.Sprintf("packer-%s.%s", c.PackerBuildName, c.Format)
+	}
+
+	if c.PartitionTable != "gpt" && c.PartitionTable != "msdos" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("partition_table must be one of: gpt, msdos"))
+	}
+
+	if c.PartitionTable == "msdos" && len(c.Partitions) > 4 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("An msdos partition table has at most 4 partitions."))
+	}
+
+	errs = packer.MultiErrorAppend(errs, c.preparePartitions()...)
+
+	for _, mounts := range c.ChrootMounts {
+		if len(mounts) != 3 {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("Each chroot_mounts entry should be three elements."))
+			break
+		}
+	}
+
+	if c.Format != "raw" && c.Format != "qcow2" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("format must be one of: raw, qcow2"))
+	}
+
+	if c.DiskCompression && c.Format != "qcow2" {
+		warns = append(warns, "disk_compression is unused when format is not qcow2")
+	}
+
+	if !c.PackerForce {
+		if _, err := os.Stat(c.OutputDir); err == nil {
+			errs = packer.MultiErrorAppend(
+				errs,
+				fmt.Errorf("Output directory '%s' already exists. It must not exist.", c.OutputDir))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warns, errs
+	}
+
+	return &c, warns, nil
+}
+
+// preparePartitions validates the partitions, whose mountpoints are
+// cleaned.
+func (c *Config) preparePartitions() []error {
+	var errs []error
+	var size uint
+	mountpoints := make(map[string]bool)
+
+	for i := range c.Partitions {
+		p := &c.Partitions[i]
+		size += p.Size
+
+		if p.Size == 0 && i != len(c.Partitions)-1 {
+			errs = append(errs, fmt.Errorf("Partition %d: only the last partition may have no size.", i+1))
+		}
+
+		if p.Filesystem != "" {
+			if _, ok := mkfsCommands[p.Filesystem]; !ok {
+				errs = append(errs, fmt.Errorf("Partition %d: unsupported filesystem: %s", i+1, p.Filesystem))
+			}
+		}
+
+		if p.Name != "" && c.PartitionTable != "gpt" {
+			errs = append(errs, fmt.Errorf("Partition %d: only GPT partitions have names.", i+1))
+		}
+		if strings.ContainsAny(p.Name, " '\"") {
+			errs = append(errs, fmt.Errorf("Partition %d: the name must not contain spaces or quotes.", i+1))
+		}
+
+		if p.Mountpoint == "" {
+			continue
+		}
+		if p.Filesystem == "" || p.Filesystem == "swap" {
+			errs = append(errs, fmt.Errorf("Partition %d: only filesystems can be mounted.", i+1))
+		}
+		if !path.IsAbs(p.Mountpoint) {
+			errs = append(errs, fmt.Errorf("Partition %d: the mountpoint must be absolute.", i+1))
+		}
+		p.Mountpoint = path.Clean(p.Mountpoint)
+		if mountpoints[p.Mountpoint] {
+			errs = append(errs, fmt.Errorf("Partition %d: the mountpoint %s is already used.", i+1, p.Mountpoint))
+		}
+		mountpoints[p.Mountpoint] = true
+	}
+
+	if !mountpoints["/"] {
+		errs = append(errs, errors.New("A partition must be mounted at /."))
+	}
+
+	// The partitions start at 1 MiB, and GPT has a backup at the end
+	if size+2 > c.DiskSize {
+		errs = append(errs, fmt.Errorf("The partitions don't fit in the disk of %d MB.", c.DiskSize))
+	}
+
+	return errs
+}
@@ -0,0 +1,93 @@
+package diskimage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepAttachLoop attaches the image to a loop device, whose partitions are
+// scanned by the kernel.
+//
+// Produces:
+//   device            string   - The loop device.
+//   partition_devices []string - The devices of the partitions.
+//   attach_cleanup    CleanupFunc
+type StepAttachLoop struct {
+	device string
+}
+
+func (s *StepAttachLoop) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	imagePath := state.Get("image_path").(string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ui.Say("Attaching disk image to a loop device...")
+	out, err := chroot.RunCommand(wrappedCommand,
+		fmt.Sprintf("losetup --find --show --partscan '%s'", imagePath))
+	if err != nil {
+		err := fmt.Errorf("Error attaching loop device: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.device = strings.TrimSpace(out)
+	ui.Message(fmt.Sprintf("Loop device: %s", s.device))
+
+	// The devices of the partitions may appear a bit later
+	devices := make([]string, len(config.Partitions))
+	for i := range config.Partitions {
+		devices[i] = fmt.Sprintf("%sp%d", s.device, i+1)
+		if err := waitForDevice(devices[i], 10*time.Second); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	state.Put("device", s.device)
+	state.Put("partition_devices", devices)
+	state.Put("attach_cleanup", s)
+	return multistep.ActionContinue
+}
+
+func (s *StepAttachLoop) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packer.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepAttachLoop) CleanupFunc(state multistep.StateBag) error {
+	if s.device == "" {
+		return nil
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ui.Say(fmt.Sprintf("Detaching loop device: %s", s.device))
+	if _, err := chroot.RunCommand(wrappedCommand, fmt.Sprintf("losetup --detach %s", s.device)); err != nil {
+		return fmt.Errorf("Error detaching loop device: %s", err)
+	}
+
+	s.device = ""
+	return nil
+}
+
+func waitForDevice(device string, timeout time.Duration) error {
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(100 * time.Millisecond) {
+		if _, err := os.Stat(device); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("The partition device %s didn't appear. Loop devices must support partitions.", device)
+}
@@ -0,0 +1,48 @@
+package diskimage
+
+import (
+	"context"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+type bootloaderCommandsData struct {
+	Device    string
+	MountPath string
+}
+
+// StepBootloaderCommands runs the commands that install the bootloader,
+// after provisioning and while the image is still mounted.
+type StepBootloaderCommands struct {
+	Commands []string
+}
+
+func (s *StepBootloaderCommands) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	device := state.Get("device").(string)
+	mountPath := state.Get("mount_path").(string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	if len(s.Commands) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ctx := config.ctx
+	ctx.Data = &bootloaderCommandsData{
+		Device:    device,
+		MountPath: mountPath,
+	}
+
+	ui.Say("Running bootloader commands...")
+	if err := chroot.RunLocalCommands(s.Commands, wrappedCommand, ctx, ui); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *StepBootloaderCommands) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,59 @@
+package diskimage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepConvertImage converts the raw image to the image of the artifact
+// with qemu-img, which keeps it sparse, and deletes the raw image.
+//
+// Produces:
+//   disk_filename string - The name of the image.
+type StepConvertImage struct{}
+
+func (s *StepConvertImage) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	imagePath := state.Get("image_path").(string)
+	ui := state.Get("ui").(packer.Ui)
+
+	args := convertArgs(config, imagePath)
+	ui.Say(fmt.Sprintf("Converting disk image to %s...", config.Format))
+	var stderr bytes.Buffer
+	cmd := exec.Command("qemu-img", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		err := fmt.Errorf("Error converting disk image: %s\nStderr: %s", err, stderr.String())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if err := os.Remove(imagePath); err != nil {
+		err := fmt.Errorf("Error deleting raw disk image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("disk_filename", config.ImageName)
+	return multistep.ActionContinue
+}
+
+func (s *StepConvertImage) Cleanup(state multistep.StateBag) {}
+
+func convertArgs(config *Config, imagePath string) []string {
+	args := []string{"convert", "-f", "raw", "-O", config.Format}
+	if config.Format == "qcow2" && config.DiskCompression {
+		args = append(args, "-c")
+	}
+
+	return append(args, imagePath, filepath.Join(config.OutputDir, config.ImageName))
+}
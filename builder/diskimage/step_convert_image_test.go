@@ -0,0 +1,55 @@
+package diskimage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCreateImage(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	state := testState(t)
+	config := state.Get("config").(*Config)
+	config.OutputDir = td
+
+	step := new(StepCreateImage)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	path := state.Get("image_path").(string)
+	if path != filepath.Join(td, "packer-foo.raw.build") {
+		t.Fatalf("bad: %s", path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Size() != 4096*1024*1024 {
+		t.Fatalf("bad: %d", fi.Size())
+	}
+}
+
+func TestConvertArgs(t *testing.T) {
+	config := &Config{Format: "raw", OutputDir: "output", ImageName: "disk.raw"}
+	expected := []string{"convert", "-f", "raw", "-O", "raw", "output/disk.raw.build", "output/disk.raw"}
+	if args := convertArgs(config, "output/disk.raw.build"); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	config = &Config{Format: "qcow2", DiskCompression: true, OutputDir: "output", ImageName: "disk.qcow2"}
+	expected = []string{"convert", "-f", "raw", "-O", "qcow2", "-c", "output/disk.raw", "output/disk.qcow2"}
+	if args := convertArgs(config, "output/disk.raw"); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
@@ -0,0 +1,48 @@
+package diskimage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepCreateImage creates the sparse raw image of the disk, which is
+// converted to the image of the artifact at the end.
+//
+// Produces:
+//   image_path string - The path of the raw image.
+type StepCreateImage struct{}
+
+func (s *StepCreateImage) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+
+	path, err := filepath.Abs(filepath.Join(config.OutputDir, config.ImageName+".build"))
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Creating raw disk image of %d MB...", config.DiskSize))
+	f, err := os.Create(path)
+	if err == nil {
+		err = f.Truncate(int64(config.DiskSize) * 1024 * 1024)
+		f.Close()
+	}
+	if err != nil {
+		err := fmt.Errorf("Error creating disk image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("image_path", path)
+	return multistep.ActionContinue
+}
+
+func (s *StepCreateImage) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,54 @@
+package diskimage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepFormatPartitions creates the filesystems of the partitions.
+type StepFormatPartitions struct{}
+
+func (s *StepFormatPartitions) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	devices := state.Get("partition_devices").([]string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ui.Say("Formatting partitions...")
+	for i, p := range config.Partitions {
+		if p.Filesystem == "" {
+			continue
+		}
+
+		ui.Message(fmt.Sprintf("%s: %s", devices[i], p.Filesystem))
+		if _, err := chroot.RunCommand(wrappedCommand, mkfsCommand(p, devices[i])); err != nil {
+			err := fmt.Errorf("Error formatting %s: %s", devices[i], err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepFormatPartitions) Cleanup(state multistep.StateBag) {}
+
+// mkfsCommand returns the command that formats the partition on the given
+// device.
+func mkfsCommand(p Partition, device string) string {
+	mkfs := mkfsCommands[p.Filesystem]
+	args := []string{mkfs[0]}
+	if p.Label != "" {
+		args = append(args, mkfs[1], fmt.Sprintf("'%s'", p.Label))
+	}
+	args = append(args, p.MkfsOptions...)
+	args = append(args, device)
+
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,24 @@
+package diskimage
+
+import "testing"
+
+func TestMkfsCommand(t *testing.T) {
+	cases := []struct {
+		Partition Partition
+		Expected  string
+	}{
+		{Partition{Filesystem: "ext4"}, "mkfs.ext4 /dev/loop0p1"},
+		{
+			Partition{Filesystem: "ext4", Label: "root", MkfsOptions: []string{"-O", "^metadata_csum"}},
+			"mkfs.ext4 -L 'root' -O ^metadata_csum /dev/loop0p1",
+		},
+		{Partition{Filesystem: "vfat", Label: "EFI"}, "mkfs.vfat -n 'EFI' /dev/loop0p1"},
+		{Partition{Filesystem: "swap"}, "mkswap /dev/loop0p1"},
+	}
+
+	for _, tc := range cases {
+		if command := mkfsCommand(tc.Partition, "/dev/loop0p1"); command != tc.Expected {
+			t.Fatalf("bad: %s", command)
+		}
+	}
+}
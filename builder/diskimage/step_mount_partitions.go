@@ -0,0 +1,139 @@
+package diskimage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type mountPathData struct {
+	Device string
+}
+
+// StepMountPartitions mounts the filesystems of the partitions at their
+// mountpoints, the parents first.
+//
+// Produces:
+//   mount_path           string - The path where the image is mounted.
+//   mount_device_cleanup CleanupFunc
+type StepMountPartitions struct {
+	mounts []string
+}
+
+func (s *StepMountPartitions) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	device := state.Get("device").(string)
+	devices := state.Get("partition_devices").([]string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ctx := config.ctx
+	ctx.Data = &mountPathData{Device: filepath.Base(device)}
+	mountPath, err := interpolate.Render(config.MountPath, &ctx)
+	if err != nil {
+		err := fmt.Errorf("Error preparing mount directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	mountPath, err = filepath.Abs(mountPath)
+	if err != nil {
+		err := fmt.Errorf("Error preparing mount directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Mounting partitions...")
+	s.mounts = make([]string, 0, len(config.Partitions))
+	for _, i := range mountOrder(config.Partitions) {
+		p := config.Partitions[i]
+		path := filepath.Join(mountPath, p.Mountpoint)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			err := fmt.Errorf("Error creating mount directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		command := "mount"
+		if len(p.MountOptions) > 0 {
+			command += " -o " + strings.Join(p.MountOptions, ",")
+		}
+		command = fmt.Sprintf("%s %s '%s'", command, devices[i], path)
+
+		ui.Message(fmt.Sprintf("%s: %s", p.Mountpoint, devices[i]))
+		if _, err := chroot.RunCommand(wrappedCommand, command); err != nil {
+			err := fmt.Errorf("Error mounting %s: %s", devices[i], err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.mounts = append(s.mounts, path)
+	}
+
+	state.Put("mount_path", mountPath)
+	state.Put("mount_device_cleanup", s)
+	return multistep.ActionContinue
+}
+
+func (s *StepMountPartitions) Cleanup(state multistep.StateBag) {
+	ui := state.Get("ui").(packer.Ui)
+	if err := s.CleanupFunc(state); err != nil {
+		ui.Error(err.Error())
+	}
+}
+
+func (s *StepMountPartitions) CleanupFunc(state multistep.StateBag) error {
+	if s.mounts == nil {
+		return nil
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ui.Say("Unmounting partitions...")
+	for len(s.mounts) > 0 {
+		lastIndex := len(s.mounts) - 1
+		path := s.mounts[lastIndex]
+		if _, err := chroot.RunCommand(wrappedCommand, fmt.Sprintf("umount '%s'", path)); err != nil {
+			return fmt.Errorf("Error unmounting %s: %s", path, err)
+		}
+		s.mounts = s.mounts[:lastIndex]
+	}
+
+	s.mounts = nil
+	return nil
+}
+
+// mountOrder returns the indexes of the partitions that are mounted, in
+// the order they are mounted, which is parents before children.
+func mountOrder(partitions []Partition) []int {
+	var order []int
+	for i, p := range partitions {
+		if p.Mountpoint != "" {
+			order = append(order, i)
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return depth(partitions[order[a]].Mountpoint) < depth(partitions[order[b]].Mountpoint)
+	})
+	return order
+}
+
+func depth(mountpoint string) int {
+	if mountpoint == "/" {
+		return 0
+	}
+	return strings.Count(mountpoint, "/")
+}
@@ -0,0 +1,93 @@
+package diskimage
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestMountPartitionsCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepMountPartitions)
+	if _, ok := raw.(chroot.Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestAttachLoopCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepAttachLoop)
+	if _, ok := raw.(chroot.Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestMountOrder(t *testing.T) {
+	partitions := []Partition{
+		{Mountpoint: "/boot/efi"},
+		{Filesystem: "swap"},
+		{Mountpoint: "/boot"},
+		{Mountpoint: "/"},
+		{Mountpoint: "/var"},
+	}
+
+	if order := mountOrder(partitions); !reflect.DeepEqual(order, []int{3, 2, 4, 0}) {
+		t.Fatalf("bad: %#v", order)
+	}
+}
+
+func TestStepMountPartitions(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	state := testState(t)
+	config := state.Get("config").(*Config)
+	config.MountPath = filepath.Join(td, "{{.Device}}")
+	config.Partitions = []Partition{
+		{Filesystem: "vfat", Mountpoint: "/boot/efi", MountOptions: []string{"umask=0077"}},
+		{Filesystem: "ext4", Mountpoint: "/"},
+	}
+	state.Put("device", "/dev/loop3")
+	state.Put("partition_devices", []string{"/dev/loop3p1", "/dev/loop3p2"})
+
+	// Record the commands instead of running them
+	var commands []string
+	state.Put("wrappedCommand", chroot.CommandWrapper(func(command string) (string, error) {
+		commands = append(commands, command)
+		return "true", nil
+	}))
+
+	step := new(StepMountPartitions)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+	mountPath := filepath.Join(td, "loop3")
+	if state.Get("mount_path").(string) != mountPath {
+		t.Fatalf("bad: %s", state.Get("mount_path"))
+	}
+
+	if err := step.CleanupFunc(state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		fmt.Sprintf("mount /dev/loop3p2 '%s'", mountPath),
+		fmt.Sprintf("mount -o umask=0077 /dev/loop3p1 '%s/boot/efi'", mountPath),
+		fmt.Sprintf("umount '%s/boot/efi'", mountPath),
+		fmt.Sprintf("umount '%s'", mountPath),
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Fatalf("bad: %s", strings.Join(commands, "\n"))
+	}
+}
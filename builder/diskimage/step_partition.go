@@ -0,0 +1,81 @@
+package diskimage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// partedFilesystems are the filesystem types of parted, which set the
+// type of the partitions, when they aren't the names of the filesystems.
+var partedFilesystems = map[string]string{
+	"swap": "linux-swap",
+	"vfat": "fat32",
+}
+
+// StepPartition creates the partition table of the image with parted.
+type StepPartition struct{}
+
+func (s *StepPartition) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	imagePath := state.Get("image_path").(string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ui.Say("Partitioning disk image...")
+	command := fmt.Sprintf("parted --script --align optimal '%s' %s",
+		imagePath, strings.Join(partedArgs(config), " "))
+	if _, err := chroot.RunCommand(wrappedCommand, command); err != nil {
+		err := fmt.Errorf("Error partitioning disk image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepPartition) Cleanup(state multistep.StateBag) {}
+
+// partedArgs returns the parted commands that create the partitions. The
+// first partition starts at 1 MiB, which is aligned for all disks.
+func partedArgs(config *Config) []string {
+	args := []string{"mklabel", config.PartitionTable}
+
+	start := uint(1)
+	for i, p := range config.Partitions {
+		args = append(args, "mkpart")
+		if config.PartitionTable == "gpt" {
+			name := p.Name
+			if name == "" {
+				name = fmt.Sprintf("part%d", i+1)
+			}
+			args = append(args, name)
+		} else {
+			args = append(args, "primary")
+		}
+
+		if fs, ok := partedFilesystems[p.Filesystem]; ok {
+			args = append(args, fs)
+		} else if p.Filesystem != "" {
+			args = append(args, p.Filesystem)
+		}
+
+		end := "100%"
+		if p.Size > 0 {
+			end = fmt.Sprintf("%dMiB", start+p.Size)
+		}
+		args = append(args, fmt.Sprintf("%dMiB", start), end)
+		start += p.Size
+
+		for _, flag := range p.Flags {
+			args = append(args, "set", fmt.Sprint(i+1), flag, "on")
+		}
+	}
+
+	return args
+}
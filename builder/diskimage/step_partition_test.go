@@ -0,0 +1,45 @@
+package diskimage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartedArgs(t *testing.T) {
+	config := &Config{
+		PartitionTable: "gpt",
+		Partitions: []Partition{
+			{Size: 1, Flags: []string{"bios_grub"}},
+			{Name: "efi", Size: 256, Filesystem: "vfat", Flags: []string{"esp"}},
+			{Name: "swap", Size: 512, Filesystem: "swap"},
+			{Name: "root", Filesystem: "ext4"},
+		},
+	}
+
+	expected := []string{
+		"mklabel", "gpt",
+		"mkpart", "part1", "1MiB", "2MiB", "set", "1", "bios_grub", "on",
+		"mkpart", "efi", "fat32", "2MiB", "258MiB", "set", "2", "esp", "on",
+		"mkpart", "swap", "linux-swap", "258MiB", "770MiB",
+		"mkpart", "root", "ext4", "770MiB", "100%",
+	}
+	if args := partedArgs(config); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	config = &Config{
+		PartitionTable: "msdos",
+		Partitions: []Partition{
+			{Size: 512, Filesystem: "ext2", Flags: []string{"boot"}},
+			{Filesystem: "xfs"},
+		},
+	}
+	expected = []string{
+		"mklabel", "msdos",
+		"mkpart", "primary", "ext2", "1MiB", "513MiB", "set", "1", "boot", "on",
+		"mkpart", "primary", "xfs", "513MiB", "100%",
+	}
+	if args := partedArgs(config); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
@@ -0,0 +1,38 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+)
+
+// OCIArtifact is an Artifact implementation for when the committed image is
+// exported as an OCI image layout. Its ID is the digest of the manifest of
+// the image.
+type OCIArtifact struct {
+	path   string
+	digest string
+}
+
+func (*OCIArtifact) BuilderId() string {
+	return BuilderIdOCI
+}
+
+func (a *OCIArtifact) Files() []string {
+	return []string{a.path}
+}
+
+func (a *OCIArtifact) Id() string {
+	return a.digest
+}
+
+func (a *OCIArtifact) String() string {
+	return fmt.Sprintf("Exported OCI image layout: %s (%s)", a.path, a.digest)
+}
+
+func (a *OCIArtifact) State(name string) interface{} {
+	return nil
+}
+
+func (a *OCIArtifact) Destroy() error {
+	return os.RemoveAll(a.path)
+}
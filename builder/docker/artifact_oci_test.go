@@ -0,0 +1,24 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestOCIArtifact_impl(t *testing.T) {
+	var _ packer.Artifact = new(OCIArtifact)
+}
+
+func TestOCIArtifact(t *testing.T) {
+	a := &OCIArtifact{path: "image.tar", digest: "sha256:abcd"}
+	if a.Id() != "sha256:abcd" {
+		t.Fatalf("bad: %s", a.Id())
+	}
+	if a.BuilderId() != BuilderIdOCI {
+		t.Fatalf("bad: %s", a.BuilderId())
+	}
+	if files := a.Files(); len(files) != 1 || files[0] != "image.tar" {
+		t.Fatalf("bad: %#v", files)
+	}
+}
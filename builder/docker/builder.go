@@ -12,6 +12,7 @@ import (
 const (
 	BuilderId       = "packer.docker"
 	BuilderIdImport = "packer.post-processor.docker-import"
+	BuilderIdOCI    = "packer.docker.oci"
 )
 
 type Builder struct {
@@ -30,7 +31,10 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 }
 
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
-	driver := &DockerDriver{Ctx: &b.config.ctx, Ui: ui}
+	driver, err := NewDriver(b.config.Driver, ui, &b.config.ctx)
+	if err != nil {
+		return nil, err
+	}
 	if err := driver.Verify(); err != nil {
 		return nil, err
 	}
@@ -44,26 +48,42 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	steps := []multistep.Step{
 		&StepTempDir{},
 		&StepPull{},
-		&StepRun{},
-		&communicator.StepConnect{
-			Config:    &b.config.Comm,
-			Host:      commHost,
-			SSHConfig: sshConfig(&b.config.Comm),
-			CustomConnect: map[string]multistep.Step{
-				"docker": &StepConnectDocker{},
-			},
-		},
-		&common.StepProvision{},
+	}
+
+	if b.config.ProvisionerLayers {
+		log.Print("[DEBUG] A layer will be committed after each provisioner")
+		steps = append(steps,
+			new(StepLayerCache),
+			new(StepRun),
+			b.connectStep(),
+			&StepProvisionLayers{Connect: b.connectStep},
+		)
+	} else {
+		steps = append(steps,
+			new(StepRun),
+			b.connectStep(),
+			new(common.StepProvision),
+		)
+	}
+
+	// The committed image is squashed before it is exported
+	commit := []multistep.Step{new(StepCommit)}
+	if b.config.Squash {
+		commit = append(commit, new(StepSquash))
 	}
 
 	if b.config.Discard {
 		log.Print("[DEBUG] Container will be discarded")
 	} else if b.config.Commit {
 		log.Print("[DEBUG] Container will be committed")
-		steps = append(steps, new(StepCommit))
+		steps = append(steps, commit...)
 	} else if b.config.ExportPath != "" {
 		log.Printf("[DEBUG] Container will be exported to %s", b.config.ExportPath)
 		steps = append(steps, new(StepExport))
+	} else if b.config.OCIPath != "" {
+		log.Printf("[DEBUG] Image will be exported as an OCI image layout to %s", b.config.OCIPath)
+		steps = append(steps, commit...)
+		steps = append(steps, new(StepExportOCI))
 	} else {
 		return nil, errArtifactNotUsed
 	}
@@ -99,6 +119,11 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			BuilderIdValue: BuilderIdImport,
 			Driver:         driver,
 		}
+	} else if b.config.OCIPath != "" {
+		artifact = &OCIArtifact{
+			path:   b.config.OCIPath,
+			digest: state.Get("oci_digest").(string),
+		}
 	} else {
 		artifact = &ExportArtifact{path: b.config.ExportPath}
 	}
@@ -106,6 +131,25 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	return artifact, nil
 }
 
+// connectStep returns a new step that connects to the container.
+func (b *Builder) connectStep() multistep.Step {
+	return &communicator.StepConnect{
+		Config:    &b.config.Comm,
+		Host:      commHost,
+		SSHConfig: sshConfig(&b.config.Comm),
+		CustomConnect: map[string]multistep.Step{
+			"docker": &StepConnectDocker{},
+		},
+	}
+}
+
+// PlanOutputs tells build plans about the OCI image layout, which -force replaces.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "oci_path", Local: true},
+	}
+}
+
 func (b *Builder) Cancel() {
 	if b.runner != nil {
 		log.Println("Cancelling the step runner...")
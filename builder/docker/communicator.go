@@ -2,6 +2,7 @@ package docker
 
 import (
 	"archive/tar"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,6 +15,7 @@ import (
 	"syscall"
 
 	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/packer"
 )
 
@@ -256,8 +258,38 @@ func (c *Communicator) Download(src string, dst io.Writer) error {
 	return nil
 }
 
+// DownloadDir pulls a directory out of a container using `docker cp`, which
+// streams it as a tar that is extracted to the destination.
 func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
-	return fmt.Errorf("DownloadDir is not implemented for docker")
+	log.Printf("Downloading directory from container: %s:%s", c.ContainerID, src)
+	localCmd := exec.Command("docker", "cp",
+		fmt.Sprintf("%s:%s", c.ContainerID, strings.TrimRight(src, "/")), "-")
+
+	var stderr bytes.Buffer
+	localCmd.Stderr = &stderr
+	pipe, err := localCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("Failed to open pipe: %s", err)
+	}
+
+	if err := localCmd.Start(); err != nil {
+		return fmt.Errorf("Failed to start download: %s", err)
+	}
+
+	extractErr := common.ExtractDirTar(pipe, src, dst, exclude)
+	if extractErr != nil {
+		// Stop docker cp instead of waiting for it to write the rest
+		localCmd.Process.Kill()
+	}
+
+	if err := localCmd.Wait(); err != nil && extractErr == nil {
+		return fmt.Errorf("Failed to download '%s' from container: %s. %s.", src, stderr.String(), err)
+	}
+	if extractErr != nil {
+		return fmt.Errorf("Failed to download '%s' from container: %s", src, extractErr)
+	}
+
+	return nil
 }
 
 // Runs the given command and blocks until completion
@@ -0,0 +1,207 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/packer"
+)
+
+// APICommunicator is a Communicator that runs commands with the exec
+// endpoints of the Engine API, and transfers files with its archive
+// endpoints, instead of running "docker exec" and "docker cp".
+type APICommunicator struct {
+	Driver        *APIDriver
+	ContainerID   string
+	HostDir       string
+	Config        *Config
+	ContainerUser string
+}
+
+func (c *APICommunicator) Start(remote *packer.RemoteCmd) error {
+	log.Printf("Executing in container %s: %s", c.ContainerID, remote.Command)
+	config := &ExecConfig{
+		User: c.Config.ExecUser,
+		Tty:  c.Config.Pty,
+	}
+
+	return c.Driver.Exec(c.ContainerID, config, remote)
+}
+
+// Upload uploads a file to the container as a tar stream
+func (c *APICommunicator) Upload(dst string, src io.Reader, fi *os.FileInfo) error {
+	if fi == nil {
+		// The size of the file must be known for the tar header
+		tempfile, err := ioutil.TempFile(c.HostDir, "upload")
+		if err != nil {
+			return fmt.Errorf("Failed to open temp file for writing: %s", err)
+		}
+		defer os.Remove(tempfile.Name())
+		defer tempfile.Close()
+
+		if _, err := io.Copy(tempfile, src); err != nil {
+			return fmt.Errorf("Failed to copy upload file to tempfile: %s", err)
+		}
+		tempfile.Seek(0, 0)
+		info, err := tempfile.Stat()
+		if err != nil {
+			return fmt.Errorf("Error getting tempfile info: %s", err)
+		}
+		src, fi = tempfile, &info
+	}
+
+	log.Printf("Copying to %s on container %s.", dst, c.ContainerID)
+	header, err := tar.FileInfoHeader(*fi, "")
+	if err != nil {
+		return err
+	}
+	header.Name = path.Base(dst)
+
+	r, w := io.Pipe()
+	go func() {
+		archive := tar.NewWriter(w)
+		if err := archive.WriteHeader(header); err != nil {
+			w.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(archive, src); err != nil {
+			w.CloseWithError(fmt.Errorf("Failed to pipe upload: %s", err))
+			return
+		}
+		w.CloseWithError(archive.Close())
+	}()
+
+	if err := c.Driver.UploadArchive(c.ContainerID, path.Dir(dst), r); err != nil {
+		r.CloseWithError(err)
+		return fmt.Errorf("Failed to upload to '%s' in container: %s", dst, err)
+	}
+
+	return c.fixDestinationOwner(dst)
+}
+
+// UploadDir uploads a directory to the container as a tar stream. Like the
+// other communicators, the contents of src are uploaded into dst if src
+// ends with a slash, and src itself is uploaded into dst otherwise.
+func (c *APICommunicator) UploadDir(dst string, src string, exclude []string) error {
+	// The archive is extracted into the parent of the destination, so its
+	// entries are rooted at the base name of the destination. Only the
+	// contents of src are in the archive if it ends with a slash, so that
+	// the destination keeps its mode.
+	contents := strings.HasSuffix(src, "/")
+	root := path.Base(dst)
+	if !contents {
+		root = path.Join(root, filepath.Base(src))
+	}
+	parent := path.Dir(strings.TrimRight(dst, "/"))
+
+	// Create the destination, in case it doesn't exist yet
+	var stderr bytes.Buffer
+	cmd := &packer.RemoteCmd{
+		Command: fmt.Sprintf("mkdir -p '%s'", dst),
+		Stderr:  &stderr,
+	}
+	if err := c.runAsRoot(cmd); err != nil {
+		return fmt.Errorf("Failed to create '%s' in container: %s. %s", dst, err, stderr.String())
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(common.WriteDirTar(w, src, root, !contents))
+	}()
+
+	log.Printf("Copying %s to %s on container %s.", src, dst, c.ContainerID)
+	if err := c.Driver.UploadArchive(c.ContainerID, parent, r); err != nil {
+		r.CloseWithError(err)
+		return fmt.Errorf("Failed to upload to '%s' in container: %s", dst, err)
+	}
+
+	return c.fixDestinationOwner(dst)
+}
+
+// Download downloads a file from the container, which the Engine streams
+// as a tar.
+func (c *APICommunicator) Download(src string, dst io.Writer) error {
+	log.Printf("Downloading file from container: %s:%s", c.ContainerID, src)
+	r, err := c.Driver.DownloadArchive(c.ContainerID, src)
+	if err != nil {
+		return fmt.Errorf("Failed to download '%s' from container: %s", src, err)
+	}
+	defer r.Close()
+
+	archive := tar.NewReader(r)
+	if _, err := archive.Next(); err != nil {
+		return fmt.Errorf("Failed to read header from tar stream: %s", err)
+	}
+
+	numBytes, err := io.Copy(dst, archive)
+	if err != nil {
+		return fmt.Errorf("Failed to pipe download: %s", err)
+	}
+	log.Printf("Copied %d bytes for %s", numBytes, src)
+
+	return nil
+}
+
+// DownloadDir downloads a directory from the container, which the Engine
+// streams as a tar that is extracted to the destination.
+func (c *APICommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	log.Printf("Downloading directory from container: %s:%s", c.ContainerID, src)
+	r, err := c.Driver.DownloadArchive(c.ContainerID, strings.TrimRight(src, "/"))
+	if err != nil {
+		return fmt.Errorf("Failed to download '%s' from container: %s", src, err)
+	}
+	defer r.Close()
+
+	if err := common.ExtractDirTar(r, src, dst, exclude); err != nil {
+		return fmt.Errorf("Failed to download '%s' from container: %s", src, err)
+	}
+
+	return nil
+}
+
+// runAsRoot runs the command as root, and waits for it to succeed.
+func (c *APICommunicator) runAsRoot(cmd *packer.RemoteCmd) error {
+	if err := c.Driver.Exec(c.ContainerID, &ExecConfig{User: "root"}, cmd); err != nil {
+		return err
+	}
+
+	cmd.Wait()
+	if cmd.ExitStatus != 0 {
+		return fmt.Errorf("exit status %d", cmd.ExitStatus)
+	}
+
+	return nil
+}
+
+// TODO Workaround for #5307. Remove once #5409 is fixed.
+func (c *APICommunicator) fixDestinationOwner(destination string) error {
+	if !c.Config.FixUploadOwner {
+		return nil
+	}
+
+	owner := c.ContainerUser
+	if owner == "" {
+		owner = "root"
+	}
+
+	var output bytes.Buffer
+	cmd := &packer.RemoteCmd{
+		Command: fmt.Sprintf("chown -R %s %s", owner, destination),
+		Stdout:  &output,
+		Stderr:  &output,
+	}
+	if err := c.runAsRoot(cmd); err != nil {
+		return fmt.Errorf("Failed to set owner of the uploaded file: %s, %s", err, output.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestAPICommunicator_impl(t *testing.T) {
+	var _ packer.Communicator = new(APICommunicator)
+}
+
+// testArchive is a file of a tar stream.
+type testArchive struct {
+	Name     string
+	Mode     int64
+	Body     string
+	Linkname string
+}
+
+func readTestArchive(t *testing.T, r io.Reader) []testArchive {
+	var result []testArchive
+	archive := tar.NewReader(r)
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		body, _ := ioutil.ReadAll(archive)
+		result = append(result, testArchive{
+			Name:     hdr.Name,
+			Mode:     hdr.Mode & 0777,
+			Body:     string(body),
+			Linkname: hdr.Linkname,
+		})
+	}
+}
+
+func writeTestArchive(t *testing.T, w io.Writer, files []testArchive) {
+	archive := tar.NewWriter(w)
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:     f.Name,
+			Mode:     f.Mode,
+			Size:     int64(len(f.Body)),
+			Typeflag: tar.TypeReg,
+		}
+		if strings.HasSuffix(f.Name, "/") {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := archive.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		archive.Write([]byte(f.Body))
+	}
+	archive.Close()
+}
+
+func testAPICommunicator(t *testing.T, engine *fakeEngine) (*APICommunicator, func()) {
+	driver, stop := testAPIDriver(t, engine)
+	config := testConfigStruct(t)
+	config.FixUploadOwner = false
+
+	return &APICommunicator{
+		Driver:      driver,
+		ContainerID: "abcd",
+		Config:      config,
+	}, stop
+}
+
+func TestAPICommunicator_Start(t *testing.T) {
+	var execUser string
+	engine := &fakeEngine{
+		Exec: func(cmd []string, user string, stdin io.Reader, stdout, stderr io.Writer) int {
+			execUser = user
+			stdout.Write([]byte(cmd[2]))
+			return 1
+		},
+	}
+	comm, stop := testAPICommunicator(t, engine)
+	defer stop()
+	comm.Config.ExecUser = "nobody"
+
+	var stdout bytes.Buffer
+	cmd := &packer.RemoteCmd{Command: "ls", Stdout: &stdout}
+	if err := comm.Start(cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+
+	if cmd.ExitStatus != 1 || stdout.String() != "(ls)" || execUser != "nobody" {
+		t.Fatalf("bad: %d %q %s", cmd.ExitStatus, stdout.String(), execUser)
+	}
+}
+
+func TestAPICommunicator_Upload(t *testing.T) {
+	var dir string
+	var files []testArchive
+	var chown []string
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"PUT /containers/abcd/archive": func(w http.ResponseWriter, r *http.Request) {
+				dir = r.URL.Query().Get("path")
+				files = readTestArchive(t, r.Body)
+			},
+		},
+		Exec: func(cmd []string, user string, stdin io.Reader, stdout, stderr io.Writer) int {
+			chown = append(chown, user+": "+cmd[2])
+			return 0
+		},
+	}
+	comm, stop := testAPICommunicator(t, engine)
+	defer stop()
+	comm.Config.FixUploadOwner = true
+	comm.ContainerUser = "app"
+
+	if err := comm.Upload("/tmp/foo", strings.NewReader("hello"), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if dir != "/tmp" {
+		t.Fatalf("bad: %s", dir)
+	}
+	if len(files) != 1 || files[0].Name != "foo" || files[0].Body != "hello" {
+		t.Fatalf("bad: %#v", files)
+	}
+	if !reflect.DeepEqual(chown, []string{"root: (chown -R app /tmp/foo)"}) {
+		t.Fatalf("bad: %#v", chown)
+	}
+}
+
+func TestAPICommunicator_UploadDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	src := filepath.Join(td, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "sub", "file"), []byte("data"), 0600)
+	os.Symlink("sub/file", filepath.Join(src, "link"))
+
+	cases := []struct {
+		Src   string
+		Names []string
+	}{
+		{src, []string{"dst/src/", "dst/src/link", "dst/src/sub/", "dst/src/sub/file"}},
+		{src + "/", []string{"dst/link", "dst/sub/", "dst/sub/file"}},
+	}
+
+	for _, tc := range cases {
+		var dir string
+		var files []testArchive
+		var mkdir []string
+		engine := &fakeEngine{
+			Handlers: map[string]http.HandlerFunc{
+				"PUT /containers/abcd/archive": func(w http.ResponseWriter, r *http.Request) {
+					dir = r.URL.Query().Get("path")
+					files = readTestArchive(t, r.Body)
+				},
+			},
+			Exec: func(cmd []string, user string, stdin io.Reader, stdout, stderr io.Writer) int {
+				mkdir = append(mkdir, user+": "+cmd[2])
+				return 0
+			},
+		}
+		comm, stop := testAPICommunicator(t, engine)
+
+		if err := comm.UploadDir("/tmp/dst", tc.Src, nil); err != nil {
+			t.Fatalf("%s: err: %s", tc.Src, err)
+		}
+		stop()
+
+		if dir != "/tmp" {
+			t.Fatalf("%s: bad: %s", tc.Src, dir)
+		}
+		if !reflect.DeepEqual(mkdir, []string{"root: (mkdir -p '/tmp/dst')"}) {
+			t.Fatalf("%s: bad: %#v", tc.Src, mkdir)
+		}
+
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name)
+			switch filepath.Base(f.Name) {
+			case "file":
+				if f.Body != "data" || f.Mode != 0600 {
+					t.Fatalf("%s: bad: %#v", tc.Src, f)
+				}
+			case "link":
+				if f.Linkname != "sub/file" {
+					t.Fatalf("%s: bad: %#v", tc.Src, f)
+				}
+			}
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tc.Names) {
+			t.Fatalf("%s: bad: %#v", tc.Src, names)
+		}
+	}
+}
+
+func TestAPICommunicator_Download(t *testing.T) {
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"GET /containers/abcd/archive": func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("path") != "/tmp/foo" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				writeTestArchive(t, w, []testArchive{{Name: "foo", Mode: 0644, Body: "hello"}})
+			},
+		},
+	}
+	comm, stop := testAPICommunicator(t, engine)
+	defer stop()
+
+	var buf bytes.Buffer
+	if err := comm.Download("/tmp/foo", &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.String() != "hello" {
+		t.Fatalf("bad: %s", buf.String())
+	}
+
+	if err := comm.Download("/tmp/missing", &buf); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAPICommunicator_DownloadDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var path string
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"GET /containers/abcd/archive": func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Query().Get("path")
+				writeTestArchive(t, w, []testArchive{
+					{Name: "logs/", Mode: 0755},
+					{Name: "logs/app.log", Mode: 0644, Body: "log"},
+					{Name: "logs/app.tmp", Mode: 0644, Body: "tmp"},
+				})
+			},
+		},
+	}
+	comm, stop := testAPICommunicator(t, engine)
+	defer stop()
+
+	if err := comm.DownloadDir("/var/logs/", td, []string{"*.tmp"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if path != "/var/logs" {
+		t.Fatalf("bad: %s", path)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(td, "app.log"))
+	if err != nil || string(data) != "log" {
+		t.Fatalf("bad: %s %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(td, "app.tmp")); !os.IsNotExist(err) {
+		t.Fatal("app.tmp should be excluded")
+	}
+}
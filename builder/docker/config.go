@@ -13,31 +13,37 @@ import (
 )
 
 var (
-	errArtifactNotUsed     = fmt.Errorf("No instructions given for handling the artifact; expected commit, discard, or export_path")
-	errArtifactUseConflict = fmt.Errorf("Cannot specify more than one of commit, discard, and export_path")
+	errArtifactNotUsed     = fmt.Errorf("No instructions given for handling the artifact; expected commit, discard, export_path, or oci_path")
+	errArtifactUseConflict = fmt.Errorf("Cannot specify more than one of commit, discard, export_path, and oci_path")
 	errExportPathNotFile   = fmt.Errorf("export_path must be a file, not a directory")
 	errImageNotSpecified   = fmt.Errorf("Image must be specified")
+	errLayersSquash        = fmt.Errorf("Cannot specify both provisioner_layers and squash")
+	errLayersNotCommitted  = fmt.Errorf("provisioner_layers and squash require commit or oci_path")
 )
 
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 	Comm                communicator.Config `mapstructure:",squash"`
 
-	Author         string
-	Changes        []string
-	Commit         bool
-	ContainerDir   string `mapstructure:"container_dir"`
-	Discard        bool
-	ExecUser       string `mapstructure:"exec_user"`
-	ExportPath     string `mapstructure:"export_path"`
-	Image          string
-	Message        string
-	Privileged     bool `mapstructure:"privileged"`
-	Pty            bool
-	Pull           bool
-	RunCommand     []string `mapstructure:"run_command"`
-	Volumes        map[string]string
-	FixUploadOwner bool `mapstructure:"fix_upload_owner"`
+	Author            string
+	Changes           []string
+	Commit            bool
+	ContainerDir      string `mapstructure:"container_dir"`
+	Discard           bool
+	Driver            string `mapstructure:"driver"`
+	ExecUser          string `mapstructure:"exec_user"`
+	ExportPath        string `mapstructure:"export_path"`
+	Image             string
+	Message           string
+	OCIPath           string `mapstructure:"oci_path"`
+	Privileged        bool   `mapstructure:"privileged"`
+	ProvisionerLayers bool   `mapstructure:"provisioner_layers"`
+	Pty               bool
+	Pull              bool
+	RunCommand        []string `mapstructure:"run_command"`
+	Squash            bool
+	Volumes           map[string]string
+	FixUploadOwner    bool `mapstructure:"fix_upload_owner"`
 
 	// This is used to login to dockerhub to pull a private base container. For
 	// pushing to dockerhub, see the docker post-processors
@@ -89,6 +95,10 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 		c.Pull = true
 	}
 
+	if c.Driver == "" {
+		c.Driver = DriverCLI
+	}
+
 	// Default to the normal Docker type
 	if c.Comm.Type == "" {
 		c.Comm.Type = "docker"
@@ -101,15 +111,30 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	if c.Image == "" {
 		errs = packer.MultiErrorAppend(errs, errImageNotSpecified)
 	}
+	if err := ValidateDriver(c.Driver); err != nil {
+		errs = packer.MultiErrorAppend(errs, err)
+	}
 
-	if (c.ExportPath != "" && c.Commit) || (c.ExportPath != "" && c.Discard) || (c.Commit && c.Discard) {
+	artifactUses := 0
+	for _, used := range []bool{c.Commit, c.Discard, c.ExportPath != "", c.OCIPath != ""} {
+		if used {
+			artifactUses++
+		}
+	}
+	if artifactUses > 1 {
 		errs = packer.MultiErrorAppend(errs, errArtifactUseConflict)
 	}
-
-	if c.ExportPath == "" && !c.Commit && !c.Discard {
+	if artifactUses == 0 {
 		errs = packer.MultiErrorAppend(errs, errArtifactNotUsed)
 	}
 
+	if c.ProvisionerLayers && c.Squash {
+		errs = packer.MultiErrorAppend(errs, errLayersSquash)
+	}
+	if (c.ProvisionerLayers || c.Squash) && !c.Commit && c.OCIPath == "" {
+		errs = packer.MultiErrorAppend(errs, errLayersNotCommitted)
+	}
+
 	if c.ExportPath != "" {
 		if fi, err := os.Stat(c.ExportPath); err == nil && fi.IsDir() {
 			errs = packer.MultiErrorAppend(errs, errExportPathNotFile)
@@ -141,3 +141,95 @@ func TestConfigPrepare_pull(t *testing.T) {
 		t.Fatal("should not pull")
 	}
 }
+
+func TestConfigPrepare_driver(t *testing.T) {
+	raw := testConfig()
+
+	// Default
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if c.Driver != DriverCLI {
+		t.Fatalf("bad: %s", c.Driver)
+	}
+
+	// API
+	raw["driver"] = "api"
+	c, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if c.Driver != DriverAPI {
+		t.Fatalf("bad: %s", c.Driver)
+	}
+
+	// Unknown
+	raw["driver"] = "foo"
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+}
+
+func TestConfigPrepare_ociPath(t *testing.T) {
+	raw := testConfig()
+
+	// OCI path AND export (invalid)
+	raw["oci_path"] = "image.tar"
+	_, warns, errs := NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// OCI path AND commit (invalid)
+	delete(raw, "export_path")
+	raw["commit"] = true
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// OCI path only
+	delete(raw, "commit")
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if c.OCIPath != "image.tar" {
+		t.Fatalf("bad: %s", c.OCIPath)
+	}
+}
+
+func TestConfigPrepare_layers(t *testing.T) {
+	raw := testConfig()
+
+	// Layers of an export (invalid)
+	raw["provisioner_layers"] = true
+	_, warns, errs := NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// Layers AND squash (invalid)
+	delete(raw, "export_path")
+	raw["commit"] = true
+	raw["squash"] = true
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// Layers of a commit
+	delete(raw, "squash")
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if !c.ProvisionerLayers {
+		t.Fatal("should be set")
+	}
+
+	// The provisioners sent by Packer aren't interpolated
+	provisioners := []byte(`[{"type":"shell","config":{"execute_command":"sh {{ .Path }}"}}]`)
+	raw["packer_provisioners"] = provisioners
+	c, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if string(c.PackerProvisioners) != string(provisioners) {
+		t.Fatalf("bad: %s", c.PackerProvisioners)
+	}
+	delete(raw, "packer_provisioners")
+
+	// Squash of an OCI image layout
+	delete(raw, "commit")
+	delete(raw, "provisioner_layers")
+	raw["oci_path"] = "image"
+	raw["squash"] = true
+	c, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if !c.Squash {
+		t.Fatal("should be set")
+	}
+}
@@ -1,9 +1,22 @@
 package docker
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The names of the drivers, which are chosen with the driver option of the
+// builder and post-processors.
+const (
+	// DriverCLI runs the docker command.
+	DriverCLI = "cli"
+
+	// DriverAPI talks to the Docker Engine API.
+	DriverAPI = "api"
 )
 
 // Driver is the interface that has to be implemented to communicate with
@@ -19,6 +32,12 @@ type Driver interface {
 	// Export exports the container with the given ID to the given writer.
 	Export(id string, dst io.Writer) error
 
+	// ImageID returns the ID of the image with the given name.
+	ImageID(name string) (string, error)
+
+	// ImageTags returns the tags of the images in the given repository.
+	ImageTags(repo string) ([]string, error)
+
 	// Import imports a container from a tar file
 	Import(path, repo string) (string, error)
 
@@ -42,6 +61,11 @@ type Driver interface {
 	// Save an image with the given ID to the given writer.
 	SaveImage(id string, dst io.Writer) error
 
+	// Squash imports the filesystem of the container with the given ID as
+	// a new image of a single layer with the configuration of the given
+	// image, and returns the ID of the new image.
+	Squash(id string, imageId string, message string) (string, error)
+
 	// StartContainer starts a container and returns the ID for that container,
 	// along with a potential error.
 	StartContainer(*ContainerConfig) (string, error)
@@ -71,3 +95,27 @@ type ContainerConfig struct {
 type startContainerTemplate struct {
 	Image string
 }
+
+// ValidateDriver returns an error if there is no driver with the given
+// name. The empty name is the CLI driver.
+func ValidateDriver(name string) error {
+	switch name {
+	case "", DriverCLI, DriverAPI:
+		return nil
+	default:
+		return fmt.Errorf("driver must be one of: %s, %s", DriverCLI, DriverAPI)
+	}
+}
+
+// NewDriver returns the driver with the given name. The CLI driver is
+// returned if the name is empty.
+func NewDriver(name string, ui packer.Ui, ctx *interpolate.Context) (Driver, error) {
+	switch name {
+	case "", DriverCLI:
+		return &DockerDriver{Ctx: ctx, Ui: ui}, nil
+	case DriverAPI:
+		return NewAPIDriver(ui, ctx)
+	default:
+		return nil, fmt.Errorf("Unknown Docker driver: %s", name)
+	}
+}
@@ -0,0 +1,1044 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/go-homedir"
+)
+
+// DefaultDockerHost is the address of the Docker Engine that is used when
+// DOCKER_HOST isn't set.
+const DefaultDockerHost = "unix:///var/run/docker.sock"
+
+// The version of the Engine API that is used. It is the one of Docker
+// 1.12, so that older Engines are supported too.
+const apiVersion = "v1.24"
+
+// APIDriver is a Driver that talks to the Docker Engine API over HTTP,
+// instead of running the docker command and parsing its output. The errors
+// of the Engine are returned as an *APIError, and those reported while
+// pulling, pushing or importing as a *StreamError.
+type APIDriver struct {
+	Ui  packer.Ui
+	Ctx *interpolate.Context
+
+	// Host is the address of the Engine, such as
+	// "unix:///var/run/docker.sock" or "tcp://10.0.0.2:2376".
+	Host string
+
+	// TLSConfig is used to connect to the Engine, if it is set.
+	TLSConfig *tls.Config
+
+	client     *http.Client
+	clientOnce sync.Once
+
+	// The credentials of Login, which are sent along with pulls and
+	// pushes.
+	auth *apiAuthConfig
+
+	l sync.Mutex
+}
+
+// NewAPIDriver returns a driver for the Engine the docker command would
+// talk to: the one at DOCKER_HOST, or the local one. Like the docker
+// command, it uses TLS if DOCKER_TLS_VERIFY is set, with the certificates
+// in DOCKER_CERT_PATH or ~/.docker.
+func NewAPIDriver(ui packer.Ui, ctx *interpolate.Context) (*APIDriver, error) {
+	d := &APIDriver{
+		Ui:   ui,
+		Ctx:  ctx,
+		Host: os.Getenv("DOCKER_HOST"),
+	}
+	if d.Host == "" {
+		d.Host = DefaultDockerHost
+	}
+
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		certPath := os.Getenv("DOCKER_CERT_PATH")
+		if certPath == "" {
+			home, err := homedir.Dir()
+			if err != nil {
+				return nil, err
+			}
+			certPath = filepath.Join(home, ".docker")
+		}
+
+		config, err := apiTLSConfig(certPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading the Docker TLS certificates: %s", err)
+		}
+		d.TLSConfig = config
+	}
+
+	return d, nil
+}
+
+func apiTLSConfig(certPath string) (*tls.Config, error) {
+	ca, err := ioutil.ReadFile(filepath.Join(certPath, "ca.pem"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in %s", filepath.Join(certPath, "ca.pem"))
+	}
+
+	cert, err := tls.LoadX509KeyPair(
+		filepath.Join(certPath, "cert.pem"), filepath.Join(certPath, "key.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}, nil
+}
+
+// APIError is an error response of the Engine API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Docker API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true if the error is an Engine API error telling that
+// the container or image doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// StreamError is an error that the Engine reports in the middle of the
+// progress of a pull, push or import.
+type StreamError struct {
+	Code    int
+	Message string
+}
+
+func (e *StreamError) Error() string {
+	return e.Message
+}
+
+type apiAuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// jsonMessage is a message of the progress streams of the Engine.
+type jsonMessage struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// dial connects to the Engine.
+func (d *APIDriver) dial() (net.Conn, error) {
+	network, addr, err := parseDockerHost(d.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.TLSConfig == nil {
+		return conn, nil
+	}
+
+	config := d.TLSConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// parseDockerHost returns the network and address of a DOCKER_HOST.
+func parseDockerHost(host string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		return "unix", strings.TrimPrefix(host, "unix://"), nil
+	case strings.HasPrefix(host, "tcp://"):
+		return "tcp", strings.TrimPrefix(host, "tcp://"), nil
+	case !strings.Contains(host, "://"):
+		return "tcp", host, nil
+	default:
+		return "", "", fmt.Errorf("Unsupported Docker host: %s", host)
+	}
+}
+
+func (d *APIDriver) httpClient() *http.Client {
+	d.clientOnce.Do(func() {
+		// The connection, including TLS, is set up by dial, so the
+		// requests are sent as plain HTTP to a dummy host.
+		d.client = &http.Client{
+			Transport: &http.Transport{
+				Dial: func(string, string) (net.Conn, error) {
+					return d.dial()
+				},
+			},
+		}
+	})
+
+	return d.client
+}
+
+func apiURL(path string, query url.Values) string {
+	u := "http://docker/" + apiVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// do sends a request to the Engine. Error responses are returned as an
+// *APIError. The caller must close the body of the response.
+func (d *APIDriver) do(method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, apiURL(path, query), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	log.Printf("Docker API request: %s %s", method, path)
+	resp, err := d.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+
+	return resp, nil
+}
+
+// doJSON sends a request with the JSON encoding of in as the body, if it
+// isn't nil, and decodes the JSON response into out, if it isn't nil.
+func (d *APIDriver) doJSON(method, path string, query url.Values, in, out interface{}) error {
+	var body io.Reader
+	header := make(http.Header)
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.do(method, path, query, body, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func newAPIError(resp *http.Response) error {
+	data, _ := ioutil.ReadAll(resp.Body)
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	message := strings.TrimSpace(string(data))
+	if err := json.Unmarshal(data, &body); err == nil && body.Message != "" {
+		message = body.Message
+	}
+
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// registryAuth returns the X-Registry-Auth header of the credentials of
+// Login, if any.
+func (d *APIDriver) registryAuth() string {
+	auth := d.auth
+	if auth == nil {
+		auth = &apiAuthConfig{}
+	}
+
+	data, _ := json.Marshal(auth)
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+// streamProgress reads the progress messages of a pull, push or import
+// from r, showing them in the Ui, and returns the status of the last
+// message.
+func (d *APIDriver) streamProgress(r io.Reader) (string, error) {
+	bars := make(map[string]packer.ProgressBar)
+	defer func() {
+		for _, bar := range bars {
+			bar.Close()
+		}
+	}()
+
+	lastStatus := make(map[string]string)
+	var last string
+	dec := json.NewDecoder(r)
+	for {
+		var msg jsonMessage
+		if err := dec.Decode(&msg); err == io.EOF {
+			return last, nil
+		} else if err != nil {
+			return last, err
+		}
+
+		if msg.Error != "" {
+			err := &StreamError{Message: msg.Error}
+			if msg.ErrorDetail != nil {
+				err.Code = msg.ErrorDetail.Code
+			}
+			return last, err
+		}
+		last = msg.Status
+
+		// Transfers are shown as progress bars, the rest as messages
+		if msg.ID != "" && msg.ProgressDetail.Total > 0 {
+			name := fmt.Sprintf("%s: %s", msg.ID, msg.Status)
+			bar, ok := bars[name]
+			if !ok {
+				bar = packer.NewProgressBar(d.Ui, name, msg.ProgressDetail.Total)
+				bars[name] = bar
+			}
+			bar.Set(msg.ProgressDetail.Current)
+			continue
+		}
+
+		if msg.ID != "" {
+			for name, bar := range bars {
+				if strings.HasPrefix(name, msg.ID+": ") {
+					bar.Close()
+					delete(bars, name)
+				}
+			}
+
+			if lastStatus[msg.ID] == msg.Status {
+				continue
+			}
+			lastStatus[msg.ID] = msg.Status
+			d.Ui.Message(fmt.Sprintf("%s: %s", msg.ID, msg.Status))
+		} else if msg.Status != "" {
+			d.Ui.Message(msg.Status)
+		}
+	}
+}
+
+// splitImageName splits an image name into the repository and the tag or
+// digest.
+func splitImageName(name string) (string, string) {
+	if i := strings.Index(name, "@"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name[:i], name[i+1:]
+	}
+
+	return name, ""
+}
+
+func (d *APIDriver) Commit(id string, author string, changes []string, message string) (string, error) {
+	query := url.Values{"container": {id}}
+	if author != "" {
+		query.Set("author", author)
+	}
+	if message != "" {
+		query.Set("comment", message)
+	}
+	for _, change := range changes {
+		query.Add("changes", change)
+	}
+
+	log.Printf("Committing container: %s", id)
+	var result struct {
+		ID string `json:"Id"`
+	}
+	if err := d.doJSON("POST", "/commit", query, nil, &result); err != nil {
+		return "", err
+	}
+
+	return result.ID, nil
+}
+
+func (d *APIDriver) DeleteImage(id string) error {
+	log.Printf("Deleting image: %s", id)
+	return d.doJSON("DELETE", "/images/"+id, nil, nil, nil)
+}
+
+func (d *APIDriver) Export(id string, dst io.Writer) error {
+	log.Printf("Exporting container: %s", id)
+	resp, err := d.do("GET", "/containers/"+id+"/export", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return fmt.Errorf("Error exporting: %s", err)
+	}
+
+	return nil
+}
+
+// apiImageInspect is the part of the inspection of an image that the
+// driver uses.
+type apiImageInspect struct {
+	ID     string `json:"Id"`
+	Config imageConfig
+}
+
+func (d *APIDriver) inspectImage(name string) (*apiImageInspect, error) {
+	var result apiImageInspect
+	if err := d.doJSON("GET", "/images/"+name+"/json", nil, nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (d *APIDriver) ImageID(name string) (string, error) {
+	image, err := d.inspectImage(name)
+	if err != nil {
+		return "", err
+	}
+
+	return image.ID, nil
+}
+
+func (d *APIDriver) ImageTags(repo string) ([]string, error) {
+	filters, err := json.Marshal(map[string][]string{"reference": {repo}})
+	if err != nil {
+		return nil, err
+	}
+
+	var images []struct {
+		RepoTags []string
+	}
+	query := url.Values{"filters": {string(filters)}}
+	if err := d.doJSON("GET", "/images/json", query, nil, &images); err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for _, image := range images {
+		for _, name := range image.RepoTags {
+			if strings.HasPrefix(name, repo+":") {
+				tags = append(tags, strings.TrimPrefix(name, repo+":"))
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+func (d *APIDriver) Import(path string, repo string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	repo, tag := splitImageName(repo)
+	query := url.Values{"fromSrc": {"-"}, "repo": {repo}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+
+	header := http.Header{"Content-Type": {"application/x-tar"}}
+	resp, err := d.do("POST", "/images/create", query, file, header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The last message of the import is the ID of the image
+	return d.streamProgress(resp.Body)
+}
+
+type apiContainerInspect struct {
+	Config struct {
+		User string
+	}
+	NetworkSettings struct {
+		IPAddress string
+	}
+}
+
+func (d *APIDriver) inspectContainer(id string) (*apiContainerInspect, error) {
+	var result apiContainerInspect
+	if err := d.doJSON("GET", "/containers/"+id+"/json", nil, nil, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (d *APIDriver) IPAddress(id string) (string, error) {
+	result, err := d.inspectContainer(id)
+	if err != nil {
+		return "", err
+	}
+
+	return result.NetworkSettings.IPAddress, nil
+}
+
+// ContainerUser returns the user the container runs as.
+func (d *APIDriver) ContainerUser(id string) (string, error) {
+	result, err := d.inspectContainer(id)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Config.User, nil
+}
+
+func (d *APIDriver) Login(repo, user, pass string) error {
+	d.l.Lock()
+
+	auth := &apiAuthConfig{
+		Username:      user,
+		Password:      pass,
+		ServerAddress: repo,
+	}
+
+	var result struct {
+		Status string
+	}
+	if err := d.doJSON("POST", "/auth", nil, auth, &result); err != nil {
+		d.l.Unlock()
+		return err
+	}
+	if result.Status != "" {
+		d.Ui.Message(result.Status)
+	}
+
+	d.auth = auth
+	return nil
+}
+
+func (d *APIDriver) Logout(repo string) error {
+	d.auth = nil
+	d.l.Unlock()
+	return nil
+}
+
+func (d *APIDriver) Pull(image string) error {
+	repo, tag := splitImageName(image)
+	if tag == "" {
+		tag = "latest"
+	}
+
+	query := url.Values{"fromImage": {repo}, "tag": {tag}}
+	header := http.Header{"X-Registry-Auth": {d.registryAuth()}}
+	resp, err := d.do("POST", "/images/create", query, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = d.streamProgress(resp.Body)
+	return err
+}
+
+func (d *APIDriver) Push(name string) error {
+	repo, tag := splitImageName(name)
+	query := url.Values{}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+
+	header := http.Header{"X-Registry-Auth": {d.registryAuth()}}
+	resp, err := d.do("POST", "/images/"+repo+"/push", query, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = d.streamProgress(resp.Body)
+	return err
+}
+
+func (d *APIDriver) SaveImage(id string, dst io.Writer) error {
+	log.Printf("Exporting image: %s", id)
+	resp, err := d.do("GET", "/images/"+id+"/get", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(dst, resp.Body); err != nil {
+		return fmt.Errorf("Error exporting: %s", err)
+	}
+
+	return nil
+}
+
+func (d *APIDriver) Squash(id string, imageId string, message string) (string, error) {
+	image, err := d.inspectImage(imageId)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{"fromSrc": {"-"}}
+	for _, change := range image.Config.changes() {
+		query.Add("changes", change)
+	}
+	if message != "" {
+		query.Set("message", message)
+	}
+
+	// The export is streamed into the import
+	log.Printf("Squashing container: %s", id)
+	export, err := d.do("GET", "/containers/"+id+"/export", nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer export.Body.Close()
+
+	header := http.Header{"Content-Type": {"application/x-tar"}}
+	resp, err := d.do("POST", "/images/create", query, export.Body, header)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The last message of the import is the ID of the image
+	return d.streamProgress(resp.Body)
+}
+
+func (d *APIDriver) StartContainer(config *ContainerConfig) (string, error) {
+	// Build up the template data
+	var tplData startContainerTemplate
+	tplData.Image = config.Image
+	ctx := *d.Ctx
+	ctx.Data = &tplData
+
+	args := make([]string, 0, len(config.RunCommand))
+	for _, v := range config.RunCommand {
+		v, err := interpolate.Render(v, &ctx)
+		if err != nil {
+			return "", err
+		}
+
+		args = append(args, v)
+	}
+	d.Ui.Message(fmt.Sprintf(
+		"Run command: docker run %s", strings.Join(args, " ")))
+
+	create, name, err := parseRunCommand(args)
+	if err != nil {
+		return "", err
+	}
+	create.HostConfig.Privileged = create.HostConfig.Privileged || config.Privileged
+	for host, guest := range config.Volumes {
+		create.HostConfig.Binds = append(create.HostConfig.Binds,
+			fmt.Sprintf("%s:%s", host, guest))
+	}
+
+	var query url.Values
+	if name != "" {
+		query = url.Values{"name": {name}}
+	}
+
+	log.Printf("Creating container: %#v", create)
+	var result struct {
+		ID string `json:"Id"`
+	}
+	if err := d.doJSON("POST", "/containers/create", query, create, &result); err != nil {
+		return "", err
+	}
+
+	log.Println("Starting container")
+	if err := d.doJSON("POST", "/containers/"+result.ID+"/start", nil, nil, nil); err != nil {
+		return "", err
+	}
+
+	return result.ID, nil
+}
+
+func (d *APIDriver) StopContainer(id string) error {
+	if err := d.doJSON("POST", "/containers/"+id+"/kill", nil, nil, nil); err != nil {
+		return err
+	}
+
+	return d.doJSON("DELETE", "/containers/"+id, nil, nil, nil)
+}
+
+func (d *APIDriver) TagImage(id string, repo string, force bool) error {
+	repo, tag := splitImageName(repo)
+	query := url.Values{"repo": {repo}}
+	if tag != "" {
+		query.Set("tag", tag)
+	}
+	if force {
+		// Ignored by Engines newer than 1.12, like the -f flag
+		query.Set("force", "1")
+	}
+
+	return d.doJSON("POST", "/images/"+id+"/tag", query, nil, nil)
+}
+
+func (d *APIDriver) Verify() error {
+	resp, err := d.do("GET", "/_ping", nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Error connecting to Docker at %s: %s", d.Host, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func (d *APIDriver) Version() (*version.Version, error) {
+	var result struct {
+		Version string
+	}
+	if err := d.doJSON("GET", "/version", nil, nil, &result); err != nil {
+		return nil, err
+	}
+
+	return version.NewVersion(result.Version)
+}
+
+// UploadArchive extracts the tar stream r into the directory dir of the
+// container, which must exist.
+func (d *APIDriver) UploadArchive(id string, dir string, r io.Reader) error {
+	header := http.Header{"Content-Type": {"application/x-tar"}}
+	query := url.Values{"path": {dir}}
+	resp, err := d.do("PUT", "/containers/"+id+"/archive", query, r, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// DownloadArchive returns a tar stream of the file or directory at path in
+// the container, rooted at its base name. The caller must close it.
+func (d *APIDriver) DownloadArchive(id string, path string) (io.ReadCloser, error) {
+	query := url.Values{"path": {path}}
+	resp, err := d.do("GET", "/containers/"+id+"/archive", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// ExecConfig is the configuration of a command that is run in a container
+// with Exec.
+type ExecConfig struct {
+	User string
+	Tty  bool
+}
+
+// Exec starts the command in the container. It returns once the command is
+// started, and sets its exit status once it exits.
+func (d *APIDriver) Exec(id string, config *ExecConfig, cmd *packer.RemoteCmd) error {
+	create := map[string]interface{}{
+		"AttachStdin":  cmd.Stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          config.Tty,
+		"Cmd":          []string{"/bin/sh", "-c", fmt.Sprintf("(%s)", cmd.Command)},
+		"User":         config.User,
+	}
+
+	var result struct {
+		ID string `json:"Id"`
+	}
+	if err := d.doJSON("POST", "/containers/"+id+"/exec", nil, create, &result); err != nil {
+		return err
+	}
+
+	start := map[string]interface{}{
+		"Detach": false,
+		"Tty":    config.Tty,
+	}
+	conn, output, err := d.hijack("/exec/"+result.ID+"/start", start)
+	if err != nil {
+		return err
+	}
+
+	go d.runExec(result.ID, config, cmd, conn, output)
+	return nil
+}
+
+func (d *APIDriver) runExec(execID string, config *ExecConfig, cmd *packer.RemoteCmd, conn net.Conn, output io.Reader) {
+	defer conn.Close()
+
+	if cmd.Stdin != nil {
+		go func() {
+			io.Copy(conn, cmd.Stdin)
+			// Close stdin for commands that wait for it to be closed
+			if cw, ok := conn.(interface {
+				CloseWrite() error
+			}); ok {
+				cw.CloseWrite()
+			}
+		}()
+	}
+
+	stdout, stderr := cmd.Stdout, cmd.Stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+
+	var err error
+	if config.Tty {
+		_, err = io.Copy(stdout, output)
+	} else {
+		err = demuxStream(output, stdout, stderr)
+	}
+	if err != nil {
+		log.Printf("Error reading the output of exec %s: %s", execID, err)
+	}
+
+	var inspect struct {
+		ExitCode int
+	}
+	if err := d.doJSON("GET", "/exec/"+execID+"/json", nil, nil, &inspect); err != nil {
+		log.Printf("Error inspecting exec %s: %s", execID, err)
+		cmd.SetExited(254)
+		return
+	}
+
+	cmd.SetExited(inspect.ExitCode)
+}
+
+// hijack sends a request that takes over the connection for a raw stream,
+// and returns the connection along with the reader of the stream.
+func (d *APIDriver) hijack(path string, in interface{}) (net.Conn, io.Reader, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequest("POST", apiURL(path, nil), bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := d.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 400 {
+		err := newAPIError(resp)
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, nil, fmt.Errorf("Unexpected response from Docker: %s", resp.Status)
+	}
+
+	return conn, br, nil
+}
+
+// demuxStream splits the multiplexed output of a command that runs
+// without a TTY into stdout and stderr. Every frame has a header with the
+// stream it belongs to and its size.
+func demuxStream(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// apiCreateContainer is the body of a request to create a container.
+type apiCreateContainer struct {
+	Image        string
+	Cmd          []string `json:",omitempty"`
+	Entrypoint   []string `json:",omitempty"`
+	Env          []string `json:",omitempty"`
+	User         string   `json:",omitempty"`
+	WorkingDir   string   `json:",omitempty"`
+	Hostname     string   `json:",omitempty"`
+	Tty          bool
+	OpenStdin    bool
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	HostConfig   struct {
+		Binds       []string `json:",omitempty"`
+		Privileged  bool
+		NetworkMode string `json:",omitempty"`
+	}
+}
+
+// parseRunCommand translates the arguments of "docker run" into a request
+// to create the container, and returns it along with the name of the
+// container. Only the flags that make sense for a build are supported.
+func parseRunCommand(args []string) (*apiCreateContainer, string, error) {
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+	}
+
+	create := &apiCreateContainer{}
+	var name string
+
+	for len(args) > 0 {
+		arg := args[0]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		args = args[1:]
+		if arg == "--" {
+			break
+		}
+
+		// Flags with a value take it either after "=" or as the next
+		// argument
+		flag, value, hasValue := arg, "", false
+		if i := strings.Index(arg, "="); i >= 0 {
+			flag, value, hasValue = arg[:i], arg[i+1:], true
+		}
+		nextValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if len(args) == 0 {
+				return "", fmt.Errorf("Missing value for the run_command flag %s", flag)
+			}
+			v := args[0]
+			args = args[1:]
+			return v, nil
+		}
+
+		var err error
+		switch flag {
+		case "-d", "--detach":
+			// Containers are always started in the background
+		case "-i", "--interactive":
+			create.OpenStdin = true
+		case "-t", "--tty":
+			create.Tty = true
+		case "--privileged":
+			create.HostConfig.Privileged = true
+		case "-v", "--volume":
+			var v string
+			v, err = nextValue()
+			create.HostConfig.Binds = append(create.HostConfig.Binds, v)
+		case "-e", "--env":
+			var v string
+			v, err = nextValue()
+			create.Env = append(create.Env, v)
+		case "-w", "--workdir":
+			create.WorkingDir, err = nextValue()
+		case "-u", "--user":
+			create.User, err = nextValue()
+		case "-h", "--hostname":
+			create.Hostname, err = nextValue()
+		case "--net", "--network":
+			create.HostConfig.NetworkMode, err = nextValue()
+		case "--entrypoint":
+			var v string
+			v, err = nextValue()
+			create.Entrypoint = []string{v}
+		case "--name":
+			name, err = nextValue()
+		default:
+			// Combined short flags, such as -dit
+			if len(flag) > 2 && flag[1] != '-' && strings.Trim(flag[1:], "dit") == "" && !hasValue {
+				create.OpenStdin = create.OpenStdin || strings.Contains(flag, "i")
+				create.Tty = create.Tty || strings.Contains(flag, "t")
+				continue
+			}
+			return nil, "", fmt.Errorf(
+				"The run_command flag %s isn't supported by the api driver", flag)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(args) == 0 {
+		return nil, "", fmt.Errorf("The run_command has no image")
+	}
+	create.Image = args[0]
+	create.Cmd = args[1:]
+
+	return create, name, nil
+}
@@ -0,0 +1,692 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// fakeEngine is a fake Docker Engine that serves the requests of the API
+// driver.
+type fakeEngine struct {
+	// Handlers are the handlers of the requests by "METHOD /path", with the
+	// path without the API version.
+	Handlers map[string]http.HandlerFunc
+
+	// Exec runs the commands that are started with exec, and returns their
+	// exit status.
+	Exec func(cmd []string, user string, stdin io.Reader, stdout, stderr io.Writer) int
+
+	// Requests are the requests that were made, as "METHOD /path".
+	Requests []string
+
+	execs    map[string]*fakeExec
+	lastExec int
+	l        sync.Mutex
+}
+
+type fakeExec struct {
+	Cmd         []string
+	User        string
+	Tty         bool
+	AttachStdin bool
+	ExitCode    int
+}
+
+// testAPIDriver returns an API driver that talks to the fake Engine, and
+// a function that stops the Engine.
+func testAPIDriver(t *testing.T, engine *fakeEngine) (*APIDriver, func()) {
+	server := httptest.NewServer(engine)
+	driver := &APIDriver{
+		Ui:   packer.TestUi(t),
+		Ctx:  &interpolate.Context{},
+		Host: "tcp://" + server.Listener.Addr().String(),
+	}
+
+	return driver, server.Close
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	request := r.Method + " " + path
+
+	e.l.Lock()
+	e.Requests = append(e.Requests, request)
+	handler := e.Handlers[request]
+	e.l.Unlock()
+
+	switch {
+	case handler != nil:
+		handler(w, r)
+	case r.Method == "POST" && strings.HasSuffix(path, "/exec"):
+		e.createExec(w, r)
+	case r.Method == "POST" && strings.HasPrefix(path, "/exec/") && strings.HasSuffix(path, "/start"):
+		e.startExec(w, r, strings.Split(path, "/")[2])
+	case r.Method == "GET" && strings.HasPrefix(path, "/exec/") && strings.HasSuffix(path, "/json"):
+		e.l.Lock()
+		exec := e.execs[strings.Split(path, "/")[2]]
+		e.l.Unlock()
+		fmt.Fprintf(w, `{"ExitCode": %d}`, exec.ExitCode)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message": "page not found: %s"}`, request)
+	}
+}
+
+func (e *fakeEngine) createExec(w http.ResponseWriter, r *http.Request) {
+	var exec fakeExec
+	if err := json.NewDecoder(r.Body).Decode(&exec); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	e.l.Lock()
+	defer e.l.Unlock()
+	if e.execs == nil {
+		e.execs = make(map[string]*fakeExec)
+	}
+	e.lastExec++
+	id := fmt.Sprintf("exec%d", e.lastExec)
+	e.execs[id] = &exec
+
+	fmt.Fprintf(w, `{"Id": "%s"}`, id)
+}
+
+func (e *fakeEngine) startExec(w http.ResponseWriter, r *http.Request, id string) {
+	e.l.Lock()
+	exec := e.execs[id]
+	e.l.Unlock()
+
+	// The body is read before the connection is taken over
+	ioutil.ReadAll(r.Body)
+
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	buf.WriteString("HTTP/1.1 101 UPGRADED\r\n" +
+		"Content-Type: application/vnd.docker.raw-stream\r\n" +
+		"Connection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+	buf.Flush()
+
+	var stdin io.Reader = bytes.NewReader(nil)
+	if exec.AttachStdin {
+		stdin = buf
+	}
+
+	var stdout, stderr io.Writer = conn, conn
+	if !exec.Tty {
+		var l sync.Mutex
+		stdout = &frameWriter{w: conn, stream: 1, l: &l}
+		stderr = &frameWriter{w: conn, stream: 2, l: &l}
+	}
+
+	code := e.Exec(exec.Cmd, exec.User, stdin, stdout, stderr)
+
+	e.l.Lock()
+	exec.ExitCode = code
+	e.l.Unlock()
+}
+
+// frameWriter writes the frames of a multiplexed stream.
+type frameWriter struct {
+	w      io.Writer
+	stream byte
+	l      *sync.Mutex
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	header := make([]byte, 8)
+	header[0] = w.stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+	if _, err := w.w.Write(append(header, p...)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func TestAPIDriver_impl(t *testing.T) {
+	var _ Driver = new(APIDriver)
+}
+
+func TestParseDockerHost(t *testing.T) {
+	cases := []struct {
+		Host    string
+		Network string
+		Addr    string
+		Err     bool
+	}{
+		{"unix:///var/run/docker.sock", "unix", "/var/run/docker.sock", false},
+		{"tcp://10.0.0.2:2376", "tcp", "10.0.0.2:2376", false},
+		{"10.0.0.2:2375", "tcp", "10.0.0.2:2375", false},
+		{"npipe:////./pipe/docker_engine", "", "", true},
+	}
+
+	for _, tc := range cases {
+		network, addr, err := parseDockerHost(tc.Host)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: bad error: %v", tc.Host, err)
+		}
+		if network != tc.Network || addr != tc.Addr {
+			t.Fatalf("%s: bad: %s %s", tc.Host, network, addr)
+		}
+	}
+}
+
+func TestSplitImageName(t *testing.T) {
+	cases := []struct {
+		Name string
+		Repo string
+		Tag  string
+	}{
+		{"ubuntu", "ubuntu", ""},
+		{"ubuntu:16.04", "ubuntu", "16.04"},
+		{"localhost:5000/foo/bar", "localhost:5000/foo/bar", ""},
+		{"localhost:5000/foo/bar:1.0", "localhost:5000/foo/bar", "1.0"},
+		{"ubuntu@sha256:abcd", "ubuntu", "sha256:abcd"},
+	}
+
+	for _, tc := range cases {
+		repo, tag := splitImageName(tc.Name)
+		if repo != tc.Repo || tag != tc.Tag {
+			t.Fatalf("%s: bad: %s %s", tc.Name, repo, tag)
+		}
+	}
+}
+
+func TestAPIDriver_Version(t *testing.T) {
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"GET /_ping": func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("OK"))
+			},
+			"GET /version": func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"Version": "17.06.0-ce", "ApiVersion": "1.30"}`))
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	if err := driver.Verify(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	v, err := driver.Version()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v.String() != "17.6.0-ce" {
+		t.Fatalf("bad: %s", v)
+	}
+}
+
+func TestAPIDriver_errors(t *testing.T) {
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"DELETE /images/foo": func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"message": "image is being used by a container"}`))
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	err := driver.DeleteImage("foo")
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if apiErr.StatusCode != http.StatusConflict || apiErr.Message != "image is being used by a container" {
+		t.Fatalf("bad: %#v", apiErr)
+	}
+	if IsNotFound(err) {
+		t.Fatal("should not be not found")
+	}
+
+	_, err = driver.IPAddress("missing")
+	if !IsNotFound(err) {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestAPIDriver_Pull(t *testing.T) {
+	var query string
+	var auth string
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.RawQuery
+				auth = r.Header.Get("X-Registry-Auth")
+				enc := json.NewEncoder(w)
+				enc.Encode(map[string]interface{}{"status": "Pulling from library/ubuntu", "id": "16.04"})
+				enc.Encode(map[string]interface{}{"status": "Pulling fs layer", "id": "abc"})
+				enc.Encode(map[string]interface{}{
+					"status": "Downloading", "id": "abc",
+					"progressDetail": map[string]int{"current": 50, "total": 100},
+				})
+				enc.Encode(map[string]interface{}{"status": "Pull complete", "id": "abc"})
+				enc.Encode(map[string]interface{}{"status": "Status: Downloaded newer image for ubuntu:16.04"})
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	ui := &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+	driver.Ui = ui
+	if err := driver.Pull("ubuntu:16.04"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if query != "fromImage=ubuntu&tag=16.04" {
+		t.Fatalf("bad: %s", query)
+	}
+	if auth == "" {
+		t.Fatal("auth should be sent")
+	}
+
+	output := ui.Writer.(*bytes.Buffer).String()
+	for _, expected := range []string{"abc: Pull complete", "Downloaded newer image"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("bad: %s", output)
+		}
+	}
+}
+
+func TestAPIDriver_PullStreamError(t *testing.T) {
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"status": "Pulling from library/foo"}` + "\n"))
+				w.Write([]byte(`{"errorDetail": {"message": "manifest unknown"}, "error": "manifest unknown"}` + "\n"))
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	err := driver.Pull("foo")
+	if _, ok := err.(*StreamError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if err.Error() != "manifest unknown" {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestAPIDriver_LoginPush(t *testing.T) {
+	var auth apiAuthConfig
+	var query string
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"POST /auth": func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"Status": "Login Succeeded"}`))
+			},
+			"POST /images/registry.example.com/foo/push": func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.RawQuery
+				data, _ := base64.URLEncoding.DecodeString(r.Header.Get("X-Registry-Auth"))
+				json.Unmarshal(data, &auth)
+				w.Write([]byte(`{"status": "1.0: digest: sha256:abcd size: 527"}` + "\n"))
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	if err := driver.Login("registry.example.com", "user", "pass"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := driver.Push("registry.example.com/foo:1.0"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := driver.Logout("registry.example.com"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if query != "tag=1.0" {
+		t.Fatalf("bad: %s", query)
+	}
+	expected := apiAuthConfig{
+		Username:      "user",
+		Password:      "pass",
+		ServerAddress: "registry.example.com",
+	}
+	if auth != expected {
+		t.Fatalf("bad: %#v", auth)
+	}
+}
+
+func TestAPIDriver_CommitImportTag(t *testing.T) {
+	var commitQuery, tagQuery map[string][]string
+	var imported []byte
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"POST /commit": func(w http.ResponseWriter, r *http.Request) {
+				commitQuery = r.URL.Query()
+				w.Write([]byte(`{"Id": "sha256:1234"}`))
+			},
+			"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+				imported, _ = ioutil.ReadAll(r.Body)
+				w.Write([]byte(`{"status": "sha256:5678"}` + "\n"))
+			},
+			"POST /images/sha256:1234/tag": func(w http.ResponseWriter, r *http.Request) {
+				tagQuery = r.URL.Query()
+				w.WriteHeader(http.StatusCreated)
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	id, err := driver.Commit("container", "me", []string{"EXPOSE 80", "USER nobody"}, "msg")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "sha256:1234" {
+		t.Fatalf("bad: %s", id)
+	}
+	expectedCommit := map[string][]string{
+		"container": {"container"},
+		"author":    {"me"},
+		"comment":   {"msg"},
+		"changes":   {"EXPOSE 80", "USER nobody"},
+	}
+	if !reflect.DeepEqual(commitQuery, expectedCommit) {
+		t.Fatalf("bad: %#v", commitQuery)
+	}
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.Write([]byte("tarball"))
+	tf.Close()
+
+	id, err = driver.Import(tf.Name(), "foo:1.0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "sha256:5678" || string(imported) != "tarball" {
+		t.Fatalf("bad: %s %s", id, imported)
+	}
+
+	if err := driver.TagImage("sha256:1234", "foo/bar:2.0", false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expectedTag := map[string][]string{"repo": {"foo/bar"}, "tag": {"2.0"}}
+	if !reflect.DeepEqual(tagQuery, expectedTag) {
+		t.Fatalf("bad: %#v", tagQuery)
+	}
+}
+
+func TestAPIDriver_ImageIDSquash(t *testing.T) {
+	var importQuery map[string][]string
+	var imported []byte
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"GET /images/sha256:1234/json": func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"Id": "sha256:1234", "Config": {"User": "app", "Cmd": ["run"]}}`))
+			},
+			"GET /containers/container/export": func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("tarball"))
+			},
+			"POST /images/create": func(w http.ResponseWriter, r *http.Request) {
+				importQuery = r.URL.Query()
+				imported, _ = ioutil.ReadAll(r.Body)
+				w.Write([]byte(`{"status": "sha256:5678"}` + "\n"))
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	id, err := driver.ImageID("sha256:1234")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "sha256:1234" {
+		t.Fatalf("bad: %s", id)
+	}
+
+	if _, err := driver.ImageID("missing"); !IsNotFound(err) {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	id, err = driver.Squash("container", "sha256:1234", "msg")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "sha256:5678" || string(imported) != "tarball" {
+		t.Fatalf("bad: %s %s", id, imported)
+	}
+	expected := map[string][]string{
+		"fromSrc": {"-"},
+		"changes": {"USER app", `CMD ["run"]`},
+		"message": {"msg"},
+	}
+	if !reflect.DeepEqual(importQuery, expected) {
+		t.Fatalf("bad: %#v", importQuery)
+	}
+}
+
+func TestAPIDriver_ImageTags(t *testing.T) {
+	var filters string
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"GET /images/json": func(w http.ResponseWriter, r *http.Request) {
+				filters = r.URL.Query().Get("filters")
+				w.Write([]byte(`[{"RepoTags": ["packer-layer-cache/app:k1", "other:k2"]}, {"RepoTags": ["packer-layer-cache/app:k3"]}]`))
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	tags, err := driver.ImageTags("packer-layer-cache/app")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"k1", "k3"}) {
+		t.Fatalf("bad: %#v", tags)
+	}
+	if filters != `{"reference":["packer-layer-cache/app"]}` {
+		t.Fatalf("bad: %s", filters)
+	}
+}
+
+func TestAPIDriver_StartStopContainer(t *testing.T) {
+	var create apiCreateContainer
+	var name string
+	engine := &fakeEngine{
+		Handlers: map[string]http.HandlerFunc{
+			"POST /containers/create": func(w http.ResponseWriter, r *http.Request) {
+				name = r.URL.Query().Get("name")
+				json.NewDecoder(r.Body).Decode(&create)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"Id": "abcd"}`))
+			},
+			"POST /containers/abcd/start": func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			"POST /containers/abcd/kill": func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			"DELETE /containers/abcd": func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	id, err := driver.StartContainer(&ContainerConfig{
+		Image:      "ubuntu",
+		RunCommand: []string{"-d", "-i", "-t", "--name=build", "{{.Image}}", "/bin/bash"},
+		Volumes:    map[string]string{"/tmp/packer": "/packer-files"},
+		Privileged: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != "abcd" {
+		t.Fatalf("bad: %s", id)
+	}
+
+	if name != "build" {
+		t.Fatalf("bad: %s", name)
+	}
+	if create.Image != "ubuntu" || !reflect.DeepEqual(create.Cmd, []string{"/bin/bash"}) {
+		t.Fatalf("bad: %#v", create)
+	}
+	if !create.Tty || !create.OpenStdin || !create.HostConfig.Privileged {
+		t.Fatalf("bad: %#v", create)
+	}
+	if !reflect.DeepEqual(create.HostConfig.Binds, []string{"/tmp/packer:/packer-files"}) {
+		t.Fatalf("bad: %#v", create.HostConfig.Binds)
+	}
+
+	if err := driver.StopContainer("abcd"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{
+		"POST /containers/create",
+		"POST /containers/abcd/start",
+		"POST /containers/abcd/kill",
+		"DELETE /containers/abcd",
+	}
+	if !reflect.DeepEqual(engine.Requests, expected) {
+		t.Fatalf("bad: %#v", engine.Requests)
+	}
+}
+
+func TestParseRunCommand(t *testing.T) {
+	create, name, err := parseRunCommand([]string{
+		"-dit", "-v", "/a:/b", "--env=FOO=bar", "-e", "BAZ=qux",
+		"-w", "/work", "--user", "nobody", "--entrypoint", "/bin/sh",
+		"--network=host", "--name", "foo", "ubuntu", "-c", "sleep 100",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if name != "foo" {
+		t.Fatalf("bad: %s", name)
+	}
+	if create.Image != "ubuntu" || !reflect.DeepEqual(create.Cmd, []string{"-c", "sleep 100"}) {
+		t.Fatalf("bad: %#v", create)
+	}
+	if !create.Tty || !create.OpenStdin {
+		t.Fatalf("bad: %#v", create)
+	}
+	if !reflect.DeepEqual(create.Env, []string{"FOO=bar", "BAZ=qux"}) {
+		t.Fatalf("bad: %#v", create.Env)
+	}
+	if create.WorkingDir != "/work" || create.User != "nobody" {
+		t.Fatalf("bad: %#v", create)
+	}
+	if !reflect.DeepEqual(create.Entrypoint, []string{"/bin/sh"}) {
+		t.Fatalf("bad: %#v", create.Entrypoint)
+	}
+	if create.HostConfig.NetworkMode != "host" || !reflect.DeepEqual(create.HostConfig.Binds, []string{"/a:/b"}) {
+		t.Fatalf("bad: %#v", create.HostConfig)
+	}
+
+	bad := [][]string{
+		{"-p", "80:80", "ubuntu"},
+		{"-d", "-i"},
+		{"-d", "--name"},
+	}
+	for _, args := range bad {
+		if _, _, err := parseRunCommand(args); err == nil {
+			t.Fatalf("%#v: should error", args)
+		}
+	}
+}
+
+func TestAPIDriver_Exec(t *testing.T) {
+	var execCmd []string
+	var execUser string
+	engine := &fakeEngine{
+		Exec: func(cmd []string, user string, stdin io.Reader, stdout, stderr io.Writer) int {
+			execCmd, execUser = cmd, user
+			io.Copy(stdout, stdin)
+			stderr.Write([]byte("oops"))
+			return 42
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	var stdout, stderr bytes.Buffer
+	cmd := &packer.RemoteCmd{
+		Command: "cat",
+		Stdin:   strings.NewReader("hello"),
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}
+	if err := driver.Exec("abcd", &ExecConfig{User: "nobody"}, cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+
+	if cmd.ExitStatus != 42 {
+		t.Fatalf("bad: %d", cmd.ExitStatus)
+	}
+	if stdout.String() != "hello" || stderr.String() != "oops" {
+		t.Fatalf("bad: %q %q", stdout.String(), stderr.String())
+	}
+	if !reflect.DeepEqual(execCmd, []string{"/bin/sh", "-c", "(cat)"}) || execUser != "nobody" {
+		t.Fatalf("bad: %#v %s", execCmd, execUser)
+	}
+}
+
+func TestAPIDriver_ExecTty(t *testing.T) {
+	engine := &fakeEngine{
+		Exec: func(cmd []string, user string, stdin io.Reader, stdout, stderr io.Writer) int {
+			stdout.Write([]byte("out "))
+			stderr.Write([]byte("err"))
+			return 0
+		},
+	}
+	driver, stop := testAPIDriver(t, engine)
+	defer stop()
+
+	var stdout bytes.Buffer
+	cmd := &packer.RemoteCmd{Command: "true", Stdout: &stdout}
+	if err := driver.Exec("abcd", &ExecConfig{Tty: true}, cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+
+	// Everything is on stdout with a TTY
+	if cmd.ExitStatus != 0 || stdout.String() != "out err" {
+		t.Fatalf("bad: %d %q", cmd.ExitStatus, stdout.String())
+	}
+}
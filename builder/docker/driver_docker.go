@@ -2,6 +2,7 @@ package docker
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -97,6 +98,37 @@ func (d *DockerDriver) Export(id string, dst io.Writer) error {
 	return nil
 }
 
+func (d *DockerDriver) ImageID(name string) (string, error) {
+	var stderr, stdout bytes.Buffer
+	cmd := exec.Command("docker", "inspect", "--type=image", "--format", "{{.Id}}", name)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Error inspecting image: %s\n\nStderr: %s", err, stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (d *DockerDriver) ImageTags(repo string) ([]string, error) {
+	var stderr, stdout bytes.Buffer
+	cmd := exec.Command("docker", "images", "--format", "{{.Tag}}", repo)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Error listing images: %s\n\nStderr: %s", err, stderr.String())
+	}
+
+	var tags []string
+	for _, tag := range strings.Fields(stdout.String()) {
+		if tag != "<none>" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
 func (d *DockerDriver) Import(path string, repo string) (string, error) {
 	var stdout, stderr bytes.Buffer
 	cmd := exec.Command("docker", "import", "-", repo)
@@ -244,6 +276,60 @@ func (d *DockerDriver) SaveImage(id string, dst io.Writer) error {
 	return nil
 }
 
+func (d *DockerDriver) Squash(id string, imageId string, message string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", "inspect", "--type=image", "--format", "{{json .Config}}", imageId)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Error inspecting image: %s\n\nStderr: %s", err, stderr.String())
+	}
+
+	var config imageConfig
+	if err := json.Unmarshal(stdout.Bytes(), &config); err != nil {
+		return "", fmt.Errorf("Error parsing the configuration of the image: %s", err)
+	}
+
+	args := []string{"import"}
+	for _, change := range config.changes() {
+		args = append(args, "--change", change)
+	}
+	if message != "" {
+		args = append(args, "--message", message)
+	}
+	args = append(args, "-")
+
+	// The export is piped into the import
+	var exportStderr bytes.Buffer
+	stdout.Reset()
+	stderr.Reset()
+	export := exec.Command("docker", "export", id)
+	export.Stderr = &exportStderr
+	cmd = exec.Command("docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	pipe, err := export.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	cmd.Stdin = pipe
+
+	log.Printf("Squashing container %s with args: %v", id, args)
+	if err := export.Start(); err != nil {
+		return "", err
+	}
+	if err := cmd.Run(); err != nil {
+		export.Process.Kill()
+		export.Wait()
+		return "", fmt.Errorf("Error importing container: %s\n\nStderr: %s", err, stderr.String())
+	}
+	if err := export.Wait(); err != nil {
+		return "", fmt.Errorf("Error exporting: %s\nStderr: %s", err, exportStderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 func (d *DockerDriver) StartContainer(config *ContainerConfig) (string, error) {
 	// Build up the template data
 	var tplData startContainerTemplate
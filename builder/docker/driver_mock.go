@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/hashicorp/go-version"
@@ -11,12 +12,21 @@ type MockDriver struct {
 	CommitCalled      bool
 	CommitContainerId string
 	CommitImageId     string
+	CommitMessages    []string
 	CommitErr         error
 
 	DeleteImageCalled bool
 	DeleteImageId     string
+	DeleteImageIds    []string
 	DeleteImageErr    error
 
+	ImageIDCalled bool
+	ImageIDs      map[string]string
+
+	ImageTagsRepo   string
+	ImageTagsResult []string
+	ImageTagsErr    error
+
 	ImportCalled bool
 	ImportPath   string
 	ImportRepo   string
@@ -47,9 +57,17 @@ type MockDriver struct {
 	SaveImageReader io.Reader
 	SaveImageError  error
 
+	SquashCalled  bool
+	SquashId      string
+	SquashImageId string
+	SquashMessage string
+	SquashResult  string
+	SquashErr     error
+
 	TagImageCalled  bool
 	TagImageImageId string
 	TagImageRepo    string
+	TagImageRepos   []string
 	TagImageForce   bool
 	TagImageErr     error
 
@@ -67,6 +85,7 @@ type MockDriver struct {
 	PullImage    string
 	StartCalled  bool
 	StartConfig  *ContainerConfig
+	StartImages  []string
 	StopCalled   bool
 	StopID       string
 	VerifyCalled bool
@@ -78,12 +97,14 @@ type MockDriver struct {
 func (d *MockDriver) Commit(id string, author string, changes []string, message string) (string, error) {
 	d.CommitCalled = true
 	d.CommitContainerId = id
+	d.CommitMessages = append(d.CommitMessages, message)
 	return d.CommitImageId, d.CommitErr
 }
 
 func (d *MockDriver) DeleteImage(id string) error {
 	d.DeleteImageCalled = true
 	d.DeleteImageId = id
+	d.DeleteImageIds = append(d.DeleteImageIds, id)
 	return d.DeleteImageErr
 }
 
@@ -101,6 +122,19 @@ func (d *MockDriver) Export(id string, dst io.Writer) error {
 	return d.ExportError
 }
 
+func (d *MockDriver) ImageID(name string) (string, error) {
+	d.ImageIDCalled = true
+	if id, ok := d.ImageIDs[name]; ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("No such image: %s", name)
+}
+
+func (d *MockDriver) ImageTags(repo string) ([]string, error) {
+	d.ImageTagsRepo = repo
+	return d.ImageTagsResult, d.ImageTagsErr
+}
+
 func (d *MockDriver) Import(path, repo string) (string, error) {
 	d.ImportCalled = true
 	d.ImportPath = path
@@ -154,9 +188,18 @@ func (d *MockDriver) SaveImage(id string, dst io.Writer) error {
 	return d.SaveImageError
 }
 
+func (d *MockDriver) Squash(id string, imageId string, message string) (string, error) {
+	d.SquashCalled = true
+	d.SquashId = id
+	d.SquashImageId = imageId
+	d.SquashMessage = message
+	return d.SquashResult, d.SquashErr
+}
+
 func (d *MockDriver) StartContainer(config *ContainerConfig) (string, error) {
 	d.StartCalled = true
 	d.StartConfig = config
+	d.StartImages = append(d.StartImages, config.Image)
 	return d.StartID, d.StartError
 }
 
@@ -170,6 +213,7 @@ func (d *MockDriver) TagImage(id string, repo string, force bool) error {
 	d.TagImageCalled = true
 	d.TagImageImageId = id
 	d.TagImageRepo = repo
+	d.TagImageRepos = append(d.TagImageRepos, repo)
 	d.TagImageForce = force
 	return d.TagImageErr
 }
@@ -0,0 +1,323 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The media types of the OCI image format.
+const (
+	ociMediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	ociMediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	ociMediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	ociMediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// dockerSaveManifest is an image in the manifest.json of "docker save".
+type dockerSaveManifest struct {
+	Config string
+	Layers []string
+}
+
+// isOCIArchive returns true if the OCI image layout at the given path is
+// written as a tar archive instead of a directory.
+func isOCIArchive(path string) bool {
+	return strings.HasSuffix(path, ".tar")
+}
+
+// writeOCILayout converts the tarball of "docker save" with a single image
+// to an OCI image layout in the directory dir, and returns the digest of
+// the manifest. The config of the image is kept as it is, and the layers
+// are compressed with gzip. The tarball is extracted into workDir.
+func writeOCILayout(image string, dir string, workDir string) (string, error) {
+	if err := extractDockerSave(image, workDir); err != nil {
+		return "", fmt.Errorf("Error reading the saved image: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(workDir, "manifest.json"))
+	if err != nil {
+		return "", fmt.Errorf("Error reading the saved image: %s", err)
+	}
+	var images []dockerSaveManifest
+	if err := json.Unmarshal(data, &images); err != nil {
+		return "", fmt.Errorf("Error parsing the manifest of the saved image: %s", err)
+	}
+	if len(images) != 1 {
+		return "", fmt.Errorf("Expected one saved image, found %d", len(images))
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return "", err
+	}
+
+	manifest := &ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociMediaTypeManifest,
+		Layers:        make([]ociDescriptor, 0, len(images[0].Layers)),
+	}
+
+	manifest.Config, err = writeOCIBlobFile(dir, filepath.Join(workDir, images[0].Config), false)
+	if err != nil {
+		return "", err
+	}
+	manifest.Config.MediaType = ociMediaTypeConfig
+
+	for _, layer := range images[0].Layers {
+		desc, err := writeOCIBlobFile(dir, filepath.Join(workDir, filepath.FromSlash(layer)), true)
+		if err != nil {
+			return "", err
+		}
+		desc.MediaType = ociMediaTypeLayer
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+
+	data, err = json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	manifestDesc, err := writeOCIBlob(dir, bytes.NewReader(data), false)
+	if err != nil {
+		return "", err
+	}
+	manifestDesc.MediaType = ociMediaTypeManifest
+
+	index := &ociIndex{
+		SchemaVersion: 2,
+		MediaType:     ociMediaTypeIndex,
+		Manifests:     []ociDescriptor{manifestDesc},
+	}
+	data, err = json.Marshal(index)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), data, 0644); err != nil {
+		return "", err
+	}
+
+	layout := []byte(`{"imageLayoutVersion":"1.0.0"}`)
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), layout, 0644); err != nil {
+		return "", err
+	}
+
+	return manifestDesc.Digest, nil
+}
+
+func writeOCIBlobFile(dir string, path string, compress bool) (ociDescriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("Error reading the saved image: %s", err)
+	}
+	defer f.Close()
+
+	return writeOCIBlob(dir, f, compress)
+}
+
+// writeOCIBlob writes the blob read from r, compressed with gzip if
+// compress is true, into the layout in dir, and returns its descriptor
+// without the media type.
+func writeOCIBlob(dir string, r io.Reader, compress bool) (ociDescriptor, error) {
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	f, err := ioutil.TempFile(blobs, "blob")
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, hash)}
+	w := io.WriteCloser(nopWriteCloser{counter})
+	if compress {
+		w = gzip.NewWriter(counter)
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return ociDescriptor{}, err
+	}
+	if err := w.Close(); err != nil {
+		return ociDescriptor{}, err
+	}
+	if err := f.Close(); err != nil {
+		return ociDescriptor{}, err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if err := os.Rename(f.Name(), filepath.Join(blobs, sum)); err != nil {
+		return ociDescriptor{}, err
+	}
+	if err := os.Chmod(filepath.Join(blobs, sum), 0644); err != nil {
+		return ociDescriptor{}, err
+	}
+
+	return ociDescriptor{Digest: "sha256:" + sum, Size: counter.n}, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// extractDockerSave extracts the tarball of "docker save" into dst. It only
+// has directories, files, and symlinks to the layers that are shared.
+func extractDockerSave(image string, dst string) error {
+	f, err := os.Open(image)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive := tar.NewReader(f)
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("Unexpected path in the saved image: %s", hdr.Name)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := extractFile(target, archive, 0644); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			link := path.Clean(path.Join(path.Dir(name), hdr.Linkname))
+			if path.IsAbs(hdr.Linkname) || link == ".." || strings.HasPrefix(link, "../") {
+				return fmt.Errorf("Unexpected link in the saved image: %s", hdr.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractFile(target string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// tarOCILayout writes the OCI image layout in the directory dir to the tar
+// archive dst.
+func tarOCILayout(dir string, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	archive := tar.NewWriter(f)
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		blob, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer blob.Close()
+
+		_, err = io.Copy(archive, blob)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,98 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// imageConfig is the part of the configuration of an image that can be set
+// with the changes of an import. The rest of it is lost when an image is
+// squashed.
+type imageConfig struct {
+	User         string
+	ExposedPorts map[string]struct{}
+	Env          []string
+	Cmd          []string
+	Volumes      map[string]struct{}
+	WorkingDir   string
+	Entrypoint   []string
+	OnBuild      []string
+	Labels       map[string]string
+}
+
+// changes returns the Dockerfile instructions that set the configuration
+// on an imported image.
+func (c *imageConfig) changes() []string {
+	var result []string
+
+	for _, env := range c.Env {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) == 2 {
+			result = append(result, fmt.Sprintf("ENV %s=%s", parts[0], dockerfileQuote(parts[1])))
+		}
+	}
+
+	for _, k := range sortedKeys(c.Labels) {
+		result = append(result, fmt.Sprintf("LABEL %s=%s", dockerfileQuote(k), dockerfileQuote(c.Labels[k])))
+	}
+
+	ports := make([]string, 0, len(c.ExposedPorts))
+	for port := range c.ExposedPorts {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		result = append(result, "EXPOSE "+port)
+	}
+
+	volumes := make([]string, 0, len(c.Volumes))
+	for volume := range c.Volumes {
+		volumes = append(volumes, volume)
+	}
+	sort.Strings(volumes)
+	if len(volumes) > 0 {
+		result = append(result, "VOLUME "+jsonArray(volumes))
+	}
+
+	if c.User != "" {
+		result = append(result, "USER "+c.User)
+	}
+	if c.WorkingDir != "" {
+		result = append(result, "WORKDIR "+c.WorkingDir)
+	}
+	for _, onBuild := range c.OnBuild {
+		result = append(result, "ONBUILD "+onBuild)
+	}
+
+	// The entrypoint goes first, so that it doesn't reset the command
+	if len(c.Entrypoint) > 0 {
+		result = append(result, "ENTRYPOINT "+jsonArray(c.Entrypoint))
+	}
+	if len(c.Cmd) > 0 {
+		result = append(result, "CMD "+jsonArray(c.Cmd))
+	}
+
+	return result
+}
+
+// dockerfileQuote quotes the value of an ENV or LABEL instruction.
+func dockerfileQuote(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(v) + `"`
+}
+
+func jsonArray(v []string) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
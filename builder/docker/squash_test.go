@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImageConfig_changes(t *testing.T) {
+	config := &imageConfig{
+		User:         "app",
+		ExposedPorts: map[string]struct{}{"8080/tcp": {}, "53/udp": {}},
+		Env:          []string{"PATH=/usr/bin:/bin", `GREETING=say "hi" $USER`},
+		Cmd:          []string{"-c", "run"},
+		Volumes:      map[string]struct{}{"/data": {}},
+		WorkingDir:   "/app",
+		Entrypoint:   []string{"/bin/sh"},
+		Labels:       map[string]string{"version": "1.0"},
+	}
+
+	expected := []string{
+		`ENV PATH="/usr/bin:/bin"`,
+		`ENV GREETING="say \"hi\" \$USER"`,
+		`LABEL "version"="1.0"`,
+		"EXPOSE 53/udp",
+		"EXPOSE 8080/tcp",
+		`VOLUME ["/data"]`,
+		"USER app",
+		"WORKDIR /app",
+		`ENTRYPOINT ["/bin/sh"]`,
+		`CMD ["-c","run"]`,
+	}
+	if changes := config.changes(); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	if changes := new(imageConfig).changes(); len(changes) > 0 {
+		t.Fatalf("bad: %#v", changes)
+	}
+}
@@ -24,6 +24,24 @@ func (s *StepConnectDocker) Run(_ context.Context, state multistep.StateBag) mul
 		return multistep.ActionHalt
 	}
 
+	// The api driver talks to the Engine itself, without the docker command
+	if apiDriver, ok := driver.(*APIDriver); ok {
+		containerUser, err := apiDriver.ContainerUser(containerId)
+		if err != nil {
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+
+		state.Put("communicator", &APICommunicator{
+			Driver:        apiDriver,
+			ContainerID:   containerId,
+			HostDir:       tempDir,
+			Config:        config,
+			ContainerUser: containerUser,
+		})
+		return multistep.ActionContinue
+	}
+
 	containerUser, err := getContainerUser(containerId)
 	if err != nil {
 		state.Put("error", err)
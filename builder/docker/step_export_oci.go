@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepExportOCI writes the committed image as an OCI image layout, which
+// is a tar archive if the path ends with ".tar" and a directory otherwise.
+// The image is deleted from Docker afterwards.
+type StepExportOCI struct {
+	// The layout is deleted if the step starts writing it and fails
+	writing bool
+	success bool
+}
+
+func (s *StepExportOCI) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	imageId := state.Get("image_id").(string)
+	tempDir := state.Get("temp_dir").(string)
+	ui := state.Get("ui").(packer.Ui)
+
+	if _, err := os.Stat(config.OCIPath); err == nil {
+		if !config.PackerForce {
+			err := fmt.Errorf("oci_path already exists: %s", config.OCIPath)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		ui.Say("Deleting previous OCI image layout...")
+		if err := os.RemoveAll(config.OCIPath); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	// The image is saved in the format of Docker, and converted from there
+	s.writing = true
+	workDir, err := ioutil.TempDir(tempDir, "oci")
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	defer os.RemoveAll(workDir)
+
+	ui.Say("Exporting the image as an OCI image layout")
+	saved := filepath.Join(workDir, "image.tar")
+	f, err := os.Create(saved)
+	if err != nil {
+		err := fmt.Errorf("Error creating temporary file: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	err = driver.SaveImage(imageId, f)
+	f.Close()
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	layoutDir := config.OCIPath
+	if isOCIArchive(config.OCIPath) {
+		layoutDir = filepath.Join(workDir, "layout")
+	} else if err := os.MkdirAll(filepath.Dir(config.OCIPath), 0755); err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	digest, err := writeOCILayout(saved, layoutDir, filepath.Join(workDir, "image"))
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if isOCIArchive(config.OCIPath) {
+		if err := os.MkdirAll(filepath.Dir(config.OCIPath), 0755); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if err := tarOCILayout(layoutDir, config.OCIPath); err != nil {
+			err := fmt.Errorf("Error writing the OCI image archive: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	s.success = true
+	state.Put("oci_digest", digest)
+	ui.Message(fmt.Sprintf("Manifest digest: %s", digest))
+
+	return multistep.ActionContinue
+}
+
+func (s *StepExportOCI) Cleanup(state multistep.StateBag) {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	if s.writing && !s.success {
+		os.RemoveAll(config.OCIPath)
+	}
+
+	// The image only lives on in the layout
+	if imageId, ok := state.GetOk("image_id"); ok {
+		log.Printf("Deleting the committed image: %s", imageId)
+		if err := driver.DeleteImage(imageId.(string)); err != nil {
+			ui.Error(fmt.Sprintf("Error deleting the committed image: %s", err))
+		}
+	}
+}
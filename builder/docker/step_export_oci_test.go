@@ -0,0 +1,218 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+const testOCIConfig = `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+
+// testDockerSave returns a tarball like the one of "docker save", with two
+// layers where the second is a symlink to the first.
+func testDockerSave(t *testing.T) []byte {
+	var buf bytes.Buffer
+	archive := tar.NewWriter(&buf)
+
+	files := []struct {
+		Name string
+		Body string
+		Link string
+	}{
+		{"abc/", "", ""},
+		{"abc/layer.tar", "layer data", ""},
+		{"def/", "", ""},
+		{"def/layer.tar", "", "../abc/layer.tar"},
+		{"1234.json", testOCIConfig, ""},
+		{"manifest.json", `[{"Config":"1234.json","RepoTags":null,"Layers":["abc/layer.tar","def/layer.tar"]}]`, ""},
+	}
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.Name, Mode: 0644, Size: int64(len(f.Body)), Typeflag: tar.TypeReg}
+		switch {
+		case f.Link != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, f.Link
+		case f.Name[len(f.Name)-1] == '/':
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+		if err := archive.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		archive.Write([]byte(f.Body))
+	}
+	archive.Close()
+
+	return buf.Bytes()
+}
+
+func testStepExportOCIState(t *testing.T) (multistep.StateBag, string) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := testState(t)
+	state.Put("image_id", "sha256:1234")
+	state.Put("temp_dir", td)
+	driver := state.Get("driver").(*MockDriver)
+	driver.SaveImageReader = bytes.NewReader(testDockerSave(t))
+
+	return state, td
+}
+
+// readOCIBlob reads a blob of the layout and verifies its digest and size.
+func readOCIBlob(t *testing.T, layout string, desc ociDescriptor) []byte {
+	data, err := ioutil.ReadFile(filepath.Join(layout, "blobs", "sha256", desc.Digest[len("sha256:"):]))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sum := sha256.Sum256(data)
+	if "sha256:"+hex.EncodeToString(sum[:]) != desc.Digest {
+		t.Fatalf("bad digest: %s", desc.Digest)
+	}
+	if int64(len(data)) != desc.Size {
+		t.Fatalf("bad size: %d", desc.Size)
+	}
+
+	return data
+}
+
+func verifyOCILayout(t *testing.T, layout string, digest string) {
+	data, err := ioutil.ReadFile(filepath.Join(layout, "oci-layout"))
+	if err != nil || string(data) != `{"imageLayoutVersion":"1.0.0"}` {
+		t.Fatalf("bad: %s %v", data, err)
+	}
+
+	var index ociIndex
+	data, err = ioutil.ReadFile(filepath.Join(layout, "index.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest != digest {
+		t.Fatalf("bad: %#v", index)
+	}
+	if index.Manifests[0].MediaType != ociMediaTypeManifest {
+		t.Fatalf("bad: %#v", index)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(readOCIBlob(t, layout, index.Manifests[0]), &manifest); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if manifest.SchemaVersion != 2 || manifest.Config.MediaType != ociMediaTypeConfig {
+		t.Fatalf("bad: %#v", manifest)
+	}
+	if config := readOCIBlob(t, layout, manifest.Config); string(config) != testOCIConfig {
+		t.Fatalf("bad: %s", config)
+	}
+
+	if len(manifest.Layers) != 2 {
+		t.Fatalf("bad: %#v", manifest.Layers)
+	}
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != ociMediaTypeLayer {
+			t.Fatalf("bad: %#v", layer)
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(readOCIBlob(t, layout, layer)))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		data, _ := ioutil.ReadAll(gz)
+		if string(data) != "layer data" {
+			t.Fatalf("bad: %s", data)
+		}
+	}
+}
+
+func TestStepExportOCI_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExportOCI)
+}
+
+func TestStepExportOCI_directory(t *testing.T) {
+	state, td := testStepExportOCIState(t)
+	defer os.RemoveAll(td)
+	step := new(StepExportOCI)
+
+	config := state.Get("config").(*Config)
+	config.OCIPath = filepath.Join(td, "out", "image")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+
+	driver := state.Get("driver").(*MockDriver)
+	if driver.SaveImageId != "sha256:1234" {
+		t.Fatalf("bad: %s", driver.SaveImageId)
+	}
+	if driver.DeleteImageId != "sha256:1234" {
+		t.Fatal("should delete the committed image")
+	}
+
+	verifyOCILayout(t, config.OCIPath, state.Get("oci_digest").(string))
+}
+
+func TestStepExportOCI_archive(t *testing.T) {
+	state, td := testStepExportOCIState(t)
+	defer os.RemoveAll(td)
+	step := new(StepExportOCI)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.OCIPath = filepath.Join(td, "image.tar")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Extract the archive to verify it
+	layout := filepath.Join(td, "layout")
+	if err := extractDockerSave(config.OCIPath, layout); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	verifyOCILayout(t, layout, state.Get("oci_digest").(string))
+}
+
+func TestStepExportOCI_exists(t *testing.T) {
+	state, td := testStepExportOCIState(t)
+	defer os.RemoveAll(td)
+	step := new(StepExportOCI)
+
+	config := state.Get("config").(*Config)
+	config.OCIPath = filepath.Join(td, "image")
+	os.Mkdir(config.OCIPath, 0755)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+
+	// The existing path is left alone
+	if _, err := os.Stat(config.OCIPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// It is replaced with -force
+	config.PackerForce = true
+	step = new(StepExportOCI)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+
+	verifyOCILayout(t, config.OCIPath, state.Get("oci_digest").(string))
+}
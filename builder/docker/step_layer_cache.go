@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// layerCacheRepo is the repository of the layers that are committed after
+// each provisioner, tagged with their cache keys. The layers of each build
+// are kept in a repository of their own below it.
+const layerCacheRepo = "packer-layer-cache"
+
+// layerCacheNameRe matches what can't be part of a repository name.
+var layerCacheNameRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// StepLayerCache computes the cache keys of the layers of the provisioners,
+// and finds the last provisioner whose layer is cached, so the container is
+// started from that layer and only the provisioners after it are run.
+//
+// The cache key of a layer is the hash of the key of the layer below it,
+// or of the ID of the base image, and of the hash of the provisioner.
+//
+// Produces:
+//
+//	layer_keys  []string - The cache keys of the layers of the provisioners.
+//	layer_start int      - The index of the first provisioner to run.
+//	run_image   string   - The image to start the container from.
+type StepLayerCache struct{}
+
+func (s *StepLayerCache) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	baseId, err := driver.ImageID(config.Image)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// The provisioners are only hashed here, as that reads all the local
+	// files they use.
+	hashes, err := common.ProvisionerHashes(config.PackerProvisioners)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	keys := layerKeys(baseId, hashes)
+	state.Put("layer_keys", keys)
+
+	start := 0
+	for _, key := range keys {
+		if _, err := driver.ImageID(layerCacheImage(config.PackerBuildName, key)); err != nil {
+			log.Printf("Layer %s is not cached: %s", key, err)
+			break
+		}
+		start++
+	}
+
+	state.Put("layer_start", start)
+	if start > 0 {
+		ui.Say(fmt.Sprintf("Using the cached layers of %d of %d provisioners", start, len(keys)))
+		state.Put("run_image", layerCacheImage(config.PackerBuildName, keys[start-1]))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepLayerCache) Cleanup(multistep.StateBag) {}
+
+// layerKeys returns the cache keys of the layers of the provisioners with
+// the given hashes, on top of the image with the given ID.
+func layerKeys(baseId string, hashes []string) []string {
+	keys := make([]string, len(hashes))
+	prev := baseId
+	for i, hash := range hashes {
+		sum := sha256.Sum256([]byte(prev + "\n" + hash))
+		keys[i] = hex.EncodeToString(sum[:])
+		prev = keys[i]
+	}
+
+	return keys
+}
+
+// layerCacheRepository returns the repository of the cached layers of the
+// build with the given name.
+func layerCacheRepository(buildName string) string {
+	name := layerCacheNameRe.ReplaceAllString(strings.ToLower(buildName), "-")
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return layerCacheRepo
+	}
+
+	return layerCacheRepo + "/" + name
+}
+
+// layerCacheImage returns the name of the cached layer with the given key
+// of the build with the given name.
+func layerCacheImage(buildName, key string) string {
+	return layerCacheRepository(buildName) + ":" + key
+}
+
+// pruneLayerCache removes the tags of the cached layers of the build that
+// aren't one of the given keys, which are the layers of its provisioners
+// as they are now. Layers that are the parent of another image are only
+// untagged by Docker.
+func pruneLayerCache(driver Driver, buildName string, keys []string) error {
+	used := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		used[key] = true
+	}
+
+	repo := layerCacheRepository(buildName)
+	tags, err := driver.ImageTags(repo)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if used[tag] {
+			continue
+		}
+
+		log.Printf("Removing unused cached layer: %s:%s", repo, tag)
+		if err := driver.DeleteImage(repo + ":" + tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// testProvisioners returns the provisioners of a build as Packer sends them
+// to the builder, with one shell provisioner per given command.
+func testProvisioners(t *testing.T, commands ...string) []byte {
+	provisioners := make([]packer.ProvisionerConfig, len(commands))
+	for i, command := range commands {
+		provisioners[i] = packer.ProvisionerConfig{
+			Type:   "shell",
+			Config: map[string]interface{}{"inline": []interface{}{command}},
+		}
+	}
+
+	data, err := json.Marshal(provisioners)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return data
+}
+
+func TestStepLayerCache_impl(t *testing.T) {
+	var _ multistep.Step = new(StepLayerCache)
+}
+
+func TestLayerKeys(t *testing.T) {
+	keys := layerKeys("sha256:base", []string{"a", "b"})
+	if len(keys) != 2 || keys[0] == keys[1] {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	// A key depends on the layers below it
+	if other := layerKeys("sha256:other", []string{"a", "b"}); other[1] == keys[1] {
+		t.Fatal("the base image should change the keys")
+	}
+	if other := layerKeys("sha256:base", []string{"c", "b"}); other[1] == keys[1] {
+		t.Fatal("the provisioners before should change the keys")
+	}
+	if other := layerKeys("sha256:base", []string{"a", "c"}); other[0] != keys[0] {
+		t.Fatal("the provisioners after should not change the keys")
+	}
+}
+
+func TestStepLayerCache(t *testing.T) {
+	state := testState(t)
+	step := new(StepLayerCache)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.PackerProvisioners = testProvisioners(t, "a", "b", "c")
+	hashes, err := common.ProvisionerHashes(config.PackerProvisioners)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	keys := layerKeys("sha256:base", hashes)
+
+	driver := state.Get("driver").(*MockDriver)
+	driver.ImageIDs = map[string]string{
+		config.Image:                 "sha256:base",
+		layerCacheImage("", keys[0]): "sha256:a",
+		layerCacheImage("", keys[2]): "sha256:c",
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Only the first layer can be used, as the second one isn't cached
+	if start := state.Get("layer_start").(int); start != 1 {
+		t.Fatalf("bad: %d", start)
+	}
+	if image := state.Get("run_image").(string); image != layerCacheImage("", keys[0]) {
+		t.Fatalf("bad: %s", image)
+	}
+}
+
+func TestStepLayerCache_uncached(t *testing.T) {
+	state := testState(t)
+	step := new(StepLayerCache)
+	defer step.Cleanup(state)
+
+	config := state.Get("config").(*Config)
+	config.PackerProvisioners = testProvisioners(t, "a")
+
+	driver := state.Get("driver").(*MockDriver)
+	driver.ImageIDs = map[string]string{config.Image: "sha256:base"}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if start := state.Get("layer_start").(int); start != 0 {
+		t.Fatalf("bad: %d", start)
+	}
+	if _, ok := state.GetOk("run_image"); ok {
+		t.Fatal("should start from the image")
+	}
+}
+
+func TestStepLayerCache_noImage(t *testing.T) {
+	state := testState(t)
+	step := new(StepLayerCache)
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+}
+
+func TestLayerCacheRepository(t *testing.T) {
+	cases := map[string]string{
+		"":             "packer-layer-cache",
+		"docker":       "packer-layer-cache/docker",
+		"My App (1.0)": "packer-layer-cache/my-app-1-0",
+		"--":           "packer-layer-cache",
+		"ubuntu-16.04": "packer-layer-cache/ubuntu-16-04",
+	}
+
+	for name, expected := range cases {
+		if repo := layerCacheRepository(name); repo != expected {
+			t.Fatalf("%q: bad: %s", name, repo)
+		}
+	}
+}
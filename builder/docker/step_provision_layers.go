@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepProvisionLayers runs the provisioners that aren't cached one at a
+// time, and commits the layer of each of them to the cache. Every
+// provisioner after the first runs in a new container that is started from
+// the layer of the one before, so the layers stack in the history of the
+// image. The cached layers of the build that are no longer used are removed
+// once all the provisioners have run.
+//
+// Uses:
+//
+//	communicator packer.Communicator
+//	container_id string
+//	hook         packer.Hook
+//	layer_keys   []string
+//	layer_start  int
+//	ui           packer.Ui
+type StepProvisionLayers struct {
+	// Connect returns a new step that connects to the container.
+	Connect func() multistep.Step
+
+	// The steps that are run for the containers of the layers, which are
+	// cleaned up in reverse order.
+	steps []multistep.Step
+}
+
+func (s *StepProvisionLayers) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
+	keys := state.Get("layer_keys").([]string)
+	start := state.Get("layer_start").(int)
+	ui := state.Get("ui").(packer.Ui)
+
+	for i := start; i < len(keys); i++ {
+		if i > start {
+			state.Put("run_image", layerCacheImage(config.PackerBuildName, keys[i-1]))
+			if action := s.runStep(ctx, state, new(StepRun)); action != multistep.ActionContinue {
+				return action
+			}
+			if action := s.runStep(ctx, state, s.Connect()); action != multistep.ActionContinue {
+				return action
+			}
+		}
+
+		ui.Say(fmt.Sprintf("Running provisioner %d of %d", i+1, len(keys)))
+		if action := runProvisioner(state, i); action != multistep.ActionContinue {
+			return action
+		}
+
+		containerId := state.Get("container_id").(string)
+		message := fmt.Sprintf("Packer provisioner %d of %d, cache key %s", i+1, len(keys), keys[i])
+		imageId, err := driver.Commit(containerId, config.Author, nil, message)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		if err := driver.TagImage(imageId, layerCacheImage(config.PackerBuildName, keys[i]), true); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		ui.Message(fmt.Sprintf("Layer ID: %s", imageId))
+	}
+
+	// The layers of the provisioners as they were before can't be used
+	// again, unless their configuration is changed back.
+	if err := pruneLayerCache(driver, config.PackerBuildName, keys); err != nil {
+		ui.Error(fmt.Sprintf("Error removing unused cached layers: %s", err))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepProvisionLayers) Cleanup(state multistep.StateBag) {
+	for i := len(s.steps) - 1; i >= 0; i-- {
+		s.steps[i].Cleanup(state)
+	}
+	s.steps = nil
+}
+
+// runStep runs the given step, which is cleaned up with this one.
+func (s *StepProvisionLayers) runStep(ctx context.Context, state multistep.StateBag, step multistep.Step) multistep.StepAction {
+	s.steps = append(s.steps, step)
+	return step.Run(ctx, state)
+}
+
+// runProvisioner runs the provisioner with the given index, checking for
+// cancellations while it runs.
+func runProvisioner(state multistep.StateBag, i int) multistep.StepAction {
+	comm := state.Get("communicator").(packer.Communicator)
+	hook := state.Get("hook").(packer.Hook)
+	ui := state.Get("ui").(packer.Ui)
+
+	log.Printf("Running the provision hook for provisioner %d", i)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hook.Run(packer.HookProvision, ui, comm, i)
+	}()
+
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil {
+				state.Put("error", err)
+				return multistep.ActionHalt
+			}
+
+			return multistep.ActionContinue
+		case <-time.After(1 * time.Second):
+			if _, ok := state.GetOk(multistep.StateCancelled); ok {
+				log.Println("Cancelling provisioning due to interrupt...")
+				hook.Cancel()
+				return multistep.ActionHalt
+			}
+		}
+	}
+}
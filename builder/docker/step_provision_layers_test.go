@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// testLayerHook records the provisioners that are run.
+type testLayerHook struct {
+	packer.MockHook
+	indexes []interface{}
+}
+
+func (h *testLayerHook) Run(name string, ui packer.Ui, comm packer.Communicator, data interface{}) error {
+	h.indexes = append(h.indexes, data)
+	return nil
+}
+
+// testConnectStep puts a communicator in the state.
+type testConnectStep struct {
+	cleanedUp *int
+}
+
+func (s *testConnectStep) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	state.Put("communicator", new(packer.MockCommunicator))
+	return multistep.ActionContinue
+}
+
+func (s *testConnectStep) Cleanup(multistep.StateBag) {
+	*s.cleanedUp++
+}
+
+func TestStepProvisionLayers_impl(t *testing.T) {
+	var _ multistep.Step = new(StepProvisionLayers)
+}
+
+func TestStepProvisionLayers(t *testing.T) {
+	keys := []string{"k1", "k2", "k3"}
+	hook := new(testLayerHook)
+
+	state := testState(t)
+	state.Put("communicator", new(packer.MockCommunicator))
+	state.Put("container_id", "c0")
+	state.Put("hook", hook)
+	state.Put("layer_keys", keys)
+	state.Put("layer_start", 1)
+	state.Put("temp_dir", "/tmp/packer")
+
+	config := state.Get("config").(*Config)
+	config.PackerBuildName = "app"
+
+	driver := state.Get("driver").(*MockDriver)
+	driver.CommitImageId = "sha256:layer"
+	driver.ImageTagsResult = []string{"k1", "old", "k3"}
+	driver.StartID = "c1"
+
+	var cleanedUp int
+	step := &StepProvisionLayers{
+		Connect: func() multistep.Step {
+			return &testConnectStep{cleanedUp: &cleanedUp}
+		},
+	}
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// The cached provisioner is skipped
+	if !reflect.DeepEqual(hook.indexes, []interface{}{1, 2}) {
+		t.Fatalf("bad: %#v", hook.indexes)
+	}
+
+	// The last provisioner runs in a container of the layer before it
+	if !reflect.DeepEqual(driver.StartImages, []string{layerCacheImage("app", "k2")}) {
+		t.Fatalf("bad: %#v", driver.StartImages)
+	}
+	if id := state.Get("container_id").(string); id != "c1" {
+		t.Fatalf("bad: %s", id)
+	}
+
+	expected := []string{layerCacheImage("app", "k2"), layerCacheImage("app", "k3")}
+	if !reflect.DeepEqual(driver.TagImageRepos, expected) {
+		t.Fatalf("bad: %#v", driver.TagImageRepos)
+	}
+	expected = []string{
+		"Packer provisioner 2 of 3, cache key k2",
+		"Packer provisioner 3 of 3, cache key k3",
+	}
+	if !reflect.DeepEqual(driver.CommitMessages, expected) {
+		t.Fatalf("bad: %#v", driver.CommitMessages)
+	}
+
+	// The layers that are no longer used are removed
+	if driver.ImageTagsRepo != "packer-layer-cache/app" {
+		t.Fatalf("bad: %s", driver.ImageTagsRepo)
+	}
+	if !reflect.DeepEqual(driver.DeleteImageIds, []string{"packer-layer-cache/app:old"}) {
+		t.Fatalf("bad: %#v", driver.DeleteImageIds)
+	}
+
+	// The container of the layer is removed
+	step.Cleanup(state)
+	if !driver.StopCalled || driver.StopID != "c1" || cleanedUp != 1 {
+		t.Fatalf("bad: %s %d", driver.StopID, cleanedUp)
+	}
+}
+
+func TestStepProvisionLayers_cached(t *testing.T) {
+	hook := new(testLayerHook)
+
+	state := testState(t)
+	state.Put("hook", hook)
+	state.Put("layer_keys", []string{"k1"})
+	state.Put("layer_start", 1)
+
+	step := new(StepProvisionLayers)
+	defer step.Cleanup(state)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	driver := state.Get("driver").(*MockDriver)
+	if len(hook.indexes) > 0 || driver.CommitCalled {
+		t.Fatal("nothing should be run")
+	}
+}
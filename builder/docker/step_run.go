@@ -8,6 +8,8 @@ import (
 	"github.com/hashicorp/packer/packer"
 )
 
+// StepRun starts the container, from the image in run_image if there is
+// one and from the configured image otherwise.
 type StepRun struct {
 	containerId string
 }
@@ -18,8 +20,13 @@ func (s *StepRun) Run(_ context.Context, state multistep.StateBag) multistep.Ste
 	tempDir := state.Get("temp_dir").(string)
 	ui := state.Get("ui").(packer.Ui)
 
+	image := config.Image
+	if runImage, ok := state.GetOk("run_image"); ok {
+		image = runImage.(string)
+	}
+
 	runConfig := ContainerConfig{
-		Image:      config.Image,
+		Image:      image,
 		RunCommand: config.RunCommand,
 		Volumes:    make(map[string]string),
 		Privileged: config.Privileged,
@@ -0,0 +1,49 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepSquash replaces the committed image with one of a single layer, that
+// has the filesystem of the container and the configuration of the
+// committed image.
+type StepSquash struct{}
+
+func (s *StepSquash) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	containerId := state.Get("container_id").(string)
+	driver := state.Get("driver").(Driver)
+	imageId := state.Get("image_id").(string)
+	ui := state.Get("ui").(packer.Ui)
+
+	message := config.Message
+	if message == "" {
+		message = "Squashed by Packer"
+	}
+
+	ui.Say("Squashing the image")
+	squashedId, err := driver.Squash(containerId, imageId, message)
+	if err != nil {
+		err := fmt.Errorf("Error squashing the image: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	log.Printf("Deleting the unsquashed image: %s", imageId)
+	if err := driver.DeleteImage(imageId); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting the unsquashed image: %s", err))
+	}
+
+	state.Put("image_id", squashedId)
+	ui.Message(fmt.Sprintf("Image ID: %s", squashedId))
+
+	return multistep.ActionContinue
+}
+
+func (s *StepSquash) Cleanup(multistep.StateBag) {}
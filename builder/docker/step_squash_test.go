@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func testStepSquashState(t *testing.T) multistep.StateBag {
+	state := testState(t)
+	state.Put("container_id", "foo")
+	state.Put("image_id", "sha256:committed")
+	return state
+}
+
+func TestStepSquash_impl(t *testing.T) {
+	var _ multistep.Step = new(StepSquash)
+}
+
+func TestStepSquash(t *testing.T) {
+	state := testStepSquashState(t)
+	step := new(StepSquash)
+	defer step.Cleanup(state)
+
+	driver := state.Get("driver").(*MockDriver)
+	driver.SquashResult = "sha256:squashed"
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.SquashId != "foo" || driver.SquashImageId != "sha256:committed" {
+		t.Fatalf("bad: %s %s", driver.SquashId, driver.SquashImageId)
+	}
+	if driver.SquashMessage != "Squashed by Packer" {
+		t.Fatalf("bad: %s", driver.SquashMessage)
+	}
+	if driver.DeleteImageId != "sha256:committed" {
+		t.Fatal("should delete the unsquashed image")
+	}
+	if id := state.Get("image_id").(string); id != "sha256:squashed" {
+		t.Fatalf("bad: %s", id)
+	}
+}
+
+func TestStepSquash_error(t *testing.T) {
+	state := testStepSquashState(t)
+	step := new(StepSquash)
+	defer step.Cleanup(state)
+
+	driver := state.Get("driver").(*MockDriver)
+	driver.SquashErr = errors.New("foo")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if driver.DeleteImageCalled {
+		t.Fatal("should keep the committed image")
+	}
+	if id := state.Get("image_id").(string); id != "sha256:committed" {
+		t.Fatalf("bad: %s", id)
+	}
+}
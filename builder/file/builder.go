@@ -70,6 +70,13 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	return artifact, nil
 }
 
+// PlanOutputs tells build plans about the target file, which -force replaces.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "target", Local: true},
+	}
+}
+
 // Cancel cancels a possibly running Builder. This should block until
 // the builder actually cancels and cleans up after itself.
 func (b *Builder) Cancel() {
@@ -99,6 +99,13 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	return artifact, nil
 }
 
+// PlanOutputs tells build plans about the image, which -force replaces.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "image_name", Default: "packer-{{timestamp}}"},
+	}
+}
+
 // Cancel.
 func (b *Builder) Cancel() {
 	if b.runner != nil {
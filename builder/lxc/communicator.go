@@ -1,6 +1,7 @@
 package lxc
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/packer"
 )
 
@@ -128,7 +130,42 @@ func (c *LxcAttachCommunicator) Download(src string, w io.Writer) error {
 }
 
 func (c *LxcAttachCommunicator) DownloadDir(src string, dst string, exclude []string) error {
-	return fmt.Errorf("DownloadDir is not implemented for lxc")
+	// The directory is read from the rootfs, as a tar stream so that modes
+	// and symlinks are preserved and symlinks aren't followed out of it.
+	dir := filepath.Join(c.RootFs, src)
+	log.Printf("Downloading directory from rootfs: %s", dir)
+	tar, err := c.CmdWrapper(fmt.Sprintf("tar -cf - -C '%s' '%s'",
+		filepath.Dir(dir), filepath.Base(dir)))
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	tarCmd := ShellCommand(tar)
+	tarCmd.Stderr = &stderr
+	pipe, err := tarCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := tarCmd.Start(); err != nil {
+		return err
+	}
+
+	extractErr := common.ExtractDirTar(pipe, src, dst, exclude)
+	if extractErr != nil {
+		// Stop tar instead of waiting for it to write the rest
+		tarCmd.Process.Kill()
+	}
+
+	if err := tarCmd.Wait(); err != nil && extractErr == nil {
+		return fmt.Errorf("Failed to download '%s': %s. %s.", src, strings.TrimSpace(stderr.String()), err)
+	}
+	if extractErr != nil {
+		return fmt.Errorf("Failed to download '%s': %s", src, extractErr)
+	}
+
+	return nil
 }
 
 func (c *LxcAttachCommunicator) Execute(commandString string) (*exec.Cmd, error) {
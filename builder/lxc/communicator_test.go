@@ -1,6 +1,10 @@
 package lxc
 
 import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -13,3 +17,47 @@ func TestCommunicator_ImplementsCommunicator(t *testing.T) {
 		t.Fatalf("Communicator should be a communicator")
 	}
 }
+
+func TestCommunicator_DownloadDir(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not found")
+	}
+
+	rootfs, err := ioutil.TempDir("", "packer-lxc")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(rootfs)
+
+	// The path has a space, which must be quoted in the tar command
+	reports := filepath.Join(rootfs, "var", "my reports")
+	os.MkdirAll(filepath.Join(reports, "tmp"), 0755)
+	ioutil.WriteFile(filepath.Join(reports, "run.sh"), []byte("#!/bin/sh"), 0755)
+	ioutil.WriteFile(filepath.Join(reports, "tmp", "scratch"), []byte("x"), 0644)
+	os.Symlink("run.sh", filepath.Join(reports, "latest"))
+
+	dst := filepath.Join(rootfs, "downloads")
+	c := &LxcAttachCommunicator{
+		RootFs: rootfs,
+		CmdWrapper: func(command string) (string, error) {
+			return command, nil
+		},
+	}
+	if err := c.DownloadDir("/var/my reports/", dst, []string{"tmp"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	fi, err := os.Stat(filepath.Join(dst, "run.sh"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Fatalf("bad: %s", fi.Mode())
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "latest")); err != nil || link != "run.sh" {
+		t.Fatalf("bad: %s %v", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "tmp")); !os.IsNotExist(err) {
+		t.Fatal("tmp should be excluded")
+	}
+}
@@ -6,6 +6,9 @@ import (
 
 type Artifact struct {
 	id string
+
+	// The driver that deletes the image
+	driver Driver
 }
 
 func (*Artifact) BuilderId() string {
@@ -29,6 +32,5 @@ func (a *Artifact) State(name string) interface{} {
 }
 
 func (a *Artifact) Destroy() error {
-	_, err := LXDCommand("image", "delete", a.id)
-	return err
+	return a.driver.DeleteImage(a.id)
 }
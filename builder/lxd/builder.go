@@ -37,6 +37,11 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return interpolate.Render(b.config.CommandWrapper, &b.config.ctx)
 	}
 
+	driver, err := NewDriver(b.config, CommandWrapper(wrappedCommand))
+	if err != nil {
+		return nil, err
+	}
+
 	steps := []multistep.Step{
 		&stepLxdLaunch{},
 		&StepProvision{},
@@ -47,9 +52,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	state := new(multistep.BasicStateBag)
 	state.Put("config", b.config)
 	state.Put("cache", cache)
+	state.Put("driver", driver)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
-	state.Put("wrappedCommand", CommandWrapper(wrappedCommand))
 
 	// Run
 	b.runner = common.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
@@ -61,7 +66,8 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	}
 
 	artifact := &Artifact{
-		id: state.Get("imageFingerprint").(string),
+		id:     state.Get("imageFingerprint").(string),
+		driver: driver,
 	}
 
 	return artifact, nil
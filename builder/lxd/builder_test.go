@@ -68,6 +68,44 @@ func TestBuilderPrepare_ConfigFile(t *testing.T) {
 
 }
 
+func TestBuilderPrepare_Driver(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.Driver != DriverCLI {
+		t.Fatalf("bad: %s", b.config.Driver)
+	}
+
+	cases := []struct {
+		Config map[string]interface{}
+		Err    bool
+	}{
+		{map[string]interface{}{"driver": "api"}, false},
+		{map[string]interface{}{"driver": "bad"}, true},
+		{map[string]interface{}{"driver": "api", "remote": "unix:///var/lib/lxd/unix.socket"}, false},
+		{map[string]interface{}{"driver": "api", "remote": "https://lxd:8443", "publish_remote": "images"}, true},
+		{map[string]interface{}{"driver": "api", "client_cert": "client.crt", "client_key": "client.key"}, false},
+		{map[string]interface{}{"driver": "api", "client_cert": "client.crt"}, true},
+		{map[string]interface{}{"remote": "build", "publish_remote": "images"}, false},
+		{map[string]interface{}{"server_cert": "server.crt"}, true},
+	}
+
+	for _, tc := range cases {
+		config := testConfig()
+		for k, v := range tc.Config {
+			config[k] = v
+		}
+
+		b = Builder{}
+		_, err := b.Prepare(config)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%#v: bad: %v", tc.Config, err)
+		}
+	}
+}
+
 func TestBuilder_ImplementsBuilder(t *testing.T) {
 	var raw interface{}
 	raw = &Builder{}
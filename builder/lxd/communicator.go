@@ -1,14 +1,18 @@
 package lxd
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"strings"
 	"syscall"
 
+	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/packer"
 )
 
@@ -141,8 +145,43 @@ func (c *Communicator) Download(src string, w io.Writer) error {
 }
 
 func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
-	// TODO This could probably be "lxc exec <container> -- cd <src> && tar -czf - | tar -xzf - -C <dst>"
-	return fmt.Errorf("DownloadDir is not implemented for lxc")
+	// lxc file pull doesn't preserve modes and symlinks, so the directory
+	// is streamed as a tar instead, the same way it is uploaded.
+	dir := strings.TrimRight(src, "/")
+	tar, err := c.CmdWrapper(fmt.Sprintf("lxc exec %s -- tar -cf - -C '%s' '%s'",
+		c.ContainerName, path.Dir(dir), path.Base(dir)))
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	tarCmd := ShellCommand(tar)
+	tarCmd.Stderr = &stderr
+	pipe, err := tarCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting tar command: %s", tar)
+	if err := tarCmd.Start(); err != nil {
+		return err
+	}
+
+	extractErr := common.ExtractDirTar(pipe, src, dst, exclude)
+	if extractErr != nil {
+		// Stop tar instead of waiting for it to write the rest
+		tarCmd.Process.Kill()
+	}
+
+	if err := tarCmd.Wait(); err != nil && extractErr == nil {
+		log.Printf("Error running tar command: %s", err)
+		return fmt.Errorf("Failed to download '%s': %s. %s.", src, strings.TrimSpace(stderr.String()), err)
+	}
+	if extractErr != nil {
+		return fmt.Errorf("Failed to download '%s': %s", src, extractErr)
+	}
+
+	return nil
 }
 
 func (c *Communicator) Execute(commandString string) (*exec.Cmd, error) {
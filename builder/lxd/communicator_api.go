@@ -0,0 +1,140 @@
+package lxd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/packer"
+)
+
+// APICommunicator is a Communicator that runs commands with the exec
+// endpoint of the LXD API, and transfers files with its files endpoint,
+// instead of running "lxc exec" and "lxc file".
+type APICommunicator struct {
+	Driver *APIDriver
+	Name   string
+}
+
+func (c *APICommunicator) Start(cmd *packer.RemoteCmd) error {
+	log.Printf("Executing in instance %s: %s", c.Name, cmd.Command)
+	go func() {
+		exitStatus, err := c.Driver.Exec(c.Name, []string{"/bin/sh", "-c", cmd.Command},
+			cmd.Stdin, cmd.Stdout, cmd.Stderr)
+		if err != nil {
+			log.Printf("Error executing command: %s", err)
+			exitStatus = packer.CmdDisconnect
+		}
+
+		log.Printf("Command exited with '%d': '%s'", exitStatus, cmd.Command)
+		cmd.SetExited(exitStatus)
+	}()
+
+	return nil
+}
+
+// run runs the command and returns an error if it fails.
+func (c *APICommunicator) run(command string, stdin io.Reader, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	exitStatus, err := c.Driver.Exec(c.Name, []string{"/bin/sh", "-c", command}, stdin, stdout, &stderr)
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("Command %q exited with %d: %s", command, exitStatus, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+func (c *APICommunicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
+	// Files that are uploaded to a directory keep their names
+	if fi != nil {
+		body, fileType, err := c.Driver.PullFile(c.Name, dst)
+		if err == nil {
+			body.Close()
+			if fileType == "directory" {
+				dst = path.Join(dst, (*fi).Name())
+			}
+		}
+	}
+
+	mode := os.FileMode(0644)
+	if fi != nil {
+		mode = (*fi).Mode()
+	}
+
+	return c.Driver.PushFile(c.Name, dst, "file", mode, r)
+}
+
+func (c *APICommunicator) UploadDir(dst string, src string, exclude []string) error {
+	// Like other communicators, the directory itself is uploaded unless
+	// the source ends with a slash.
+	root := filepath.Base(src)
+	includeRoot := true
+	if strings.HasSuffix(src, "/") {
+		root = "."
+		includeRoot = false
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(common.WriteDirTar(w, src, root, includeRoot))
+	}()
+	defer r.Close()
+
+	dst = strings.TrimRight(dst, "/")
+	if dst == "" {
+		dst = "/"
+	}
+	return c.run(fmt.Sprintf("mkdir -p '%s' && tar -xf - -C '%s'", dst, dst), r, nil)
+}
+
+func (c *APICommunicator) Download(src string, w io.Writer) error {
+	body, fileType, err := c.Driver.PullFile(c.Name, src)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if fileType == "directory" {
+		return fmt.Errorf("Failed to download '%s': it is a directory", src)
+	}
+
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func (c *APICommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	// The directory is streamed as a tar, like with lxc exec, to preserve
+	// modes and symlinks.
+	dir := strings.TrimRight(src, "/")
+	command := fmt.Sprintf("tar -cf - -C '%s' '%s'", path.Dir(dir), path.Base(dir))
+
+	r, w := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		err := c.run(command, nil, w)
+		w.CloseWithError(err)
+		errCh <- err
+	}()
+
+	extractErr := common.ExtractDirTar(r, src, dst, exclude)
+	// Drain the rest of the stream so the command can finish
+	io.Copy(ioutil.Discard, r)
+	if err := <-errCh; err != nil && extractErr == nil {
+		return fmt.Errorf("Failed to download '%s': %s", src, err)
+	}
+	if extractErr != nil {
+		return fmt.Errorf("Failed to download '%s': %s", src, extractErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package lxd
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// testHostLXD returns a fake LXD whose instance "test" is the host: its
+// commands run on the host and its files are the ones of the host.
+func testHostLXD(t *testing.T) *fakeLXD {
+	f := &fakeLXD{
+		Exec: func(cmd []string, stdin io.Reader, stdout, stderr io.Writer) int {
+			c := exec.Command(cmd[0], cmd[1:]...)
+			c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+			if err := c.Run(); err != nil {
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					return exitErr.Sys().(interface{ ExitStatus() int }).ExitStatus()
+				}
+				return 127
+			}
+			return 0
+		},
+	}
+	f.Handlers = map[string]http.HandlerFunc{
+		"GET /1.0/instances/test/files": func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Query().Get("path")
+			fi, err := os.Stat(path)
+			if err != nil {
+				writeLXDJSON(w, http.StatusNotFound, map[string]interface{}{
+					"type": "error", "error": err.Error(), "error_code": 404,
+				})
+				return
+			}
+			if fi.IsDir() {
+				w.Header().Set("X-LXD-Type", "directory")
+				f.sync(w, []string{})
+				return
+			}
+			w.Header().Set("X-LXD-Type", "file")
+			data, _ := ioutil.ReadFile(path)
+			w.Write(data)
+		},
+		"POST /1.0/instances/test/files": func(w http.ResponseWriter, r *http.Request) {
+			mode, _ := strconv.ParseUint(r.Header.Get("X-LXD-Mode"), 8, 32)
+			data, _ := ioutil.ReadAll(r.Body)
+			if r.Header.Get("X-LXD-Type") != "file" {
+				t.Errorf("bad: %s", r.Header.Get("X-LXD-Type"))
+			}
+			if err := ioutil.WriteFile(r.URL.Query().Get("path"), data, os.FileMode(mode)); err != nil {
+				t.Errorf("err: %s", err)
+			}
+			f.sync(w, nil)
+		},
+	}
+
+	return f
+}
+
+func TestAPICommunicator_impl(t *testing.T) {
+	var _ packer.Communicator = new(APICommunicator)
+}
+
+func TestAPICommunicator_Start(t *testing.T) {
+	driver, stop := testAPIDriver(t, testHostLXD(t))
+	defer stop()
+
+	c := driver.Communicator("test")
+	var stdout bytes.Buffer
+	cmd := &packer.RemoteCmd{
+		Command: "read line; echo \"$line world\"; exit 4",
+		Stdin:   strings.NewReader("hello\n"),
+		Stdout:  &stdout,
+	}
+	if err := c.Start(cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	select {
+	case <-waitRemoteCmd(cmd):
+	case <-time.After(10 * time.Second):
+		t.Fatal("the command should exit")
+	}
+	if cmd.ExitStatus != 4 || stdout.String() != "hello world\n" {
+		t.Fatalf("bad: %d %q", cmd.ExitStatus, stdout.String())
+	}
+}
+
+func waitRemoteCmd(cmd *packer.RemoteCmd) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	return done
+}
+
+func TestAPICommunicator_UploadDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer-lxd")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "script.sh")
+	ioutil.WriteFile(src, []byte("echo hi"), 0755)
+	fi, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	driver, stop := testAPIDriver(t, testHostLXD(t))
+	defer stop()
+	c := driver.Communicator("test")
+
+	// Uploading to a directory keeps the name of the file
+	uploads := filepath.Join(dir, "uploads")
+	os.Mkdir(uploads, 0755)
+	if err := c.Upload(uploads, strings.NewReader("echo hi"), &fi); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	uploaded, err := os.Stat(filepath.Join(uploads, "script.sh"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if uploaded.Mode().Perm() != 0755 {
+		t.Fatalf("bad: %s", uploaded.Mode())
+	}
+
+	var buf bytes.Buffer
+	if err := c.Download(filepath.Join(uploads, "script.sh"), &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.String() != "echo hi" {
+		t.Fatalf("bad: %q", buf.String())
+	}
+
+	if err := c.Download(uploads, &buf); err == nil {
+		t.Fatal("downloading a directory should error")
+	}
+}
+
+func TestAPICommunicator_UploadDownloadDir(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not found")
+	}
+
+	dir, err := ioutil.TempDir("", "packer-lxd")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	reports := filepath.Join(dir, "reports")
+	os.MkdirAll(filepath.Join(reports, "sub"), 0750)
+	ioutil.WriteFile(filepath.Join(reports, "summary.txt"), []byte("ok"), 0640)
+	ioutil.WriteFile(filepath.Join(reports, "sub", "debug.log"), []byte("log"), 0644)
+
+	driver, stop := testAPIDriver(t, testHostLXD(t))
+	defer stop()
+	c := driver.Communicator("test")
+
+	// The directory itself is uploaded unless the source ends with a slash
+	uploads := filepath.Join(dir, "uploads")
+	if err := c.UploadDir(uploads, reports, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := c.UploadDir(uploads, reports+"/", nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range []string{"reports/summary.txt", "reports/sub/debug.log", "summary.txt"} {
+		if _, err := os.Stat(filepath.Join(uploads, path)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	downloads := filepath.Join(dir, "downloads")
+	if err := c.DownloadDir(reports, downloads, []string{"*.log"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(downloads, "reports", "summary.txt"))
+	if err != nil || string(data) != "ok" {
+		t.Fatalf("bad: %s %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(downloads, "reports", "sub", "debug.log")); !os.IsNotExist(err) {
+		t.Fatal("debug.log should be excluded")
+	}
+
+	// A missing directory is an error
+	if err := c.DownloadDir(filepath.Join(dir, "missing"), downloads, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -1,6 +1,11 @@
 package lxd
 
 import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -14,6 +19,57 @@ func TestCommunicator_ImplementsCommunicator(t *testing.T) {
 	}
 }
 
+func TestCommunicator_DownloadDir(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not found")
+	}
+
+	dir, err := ioutil.TempDir("", "packer-lxd")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The path has a space, which must be quoted in the tar command
+	reports := filepath.Join(dir, "my reports")
+	os.MkdirAll(reports, 0750)
+	ioutil.WriteFile(filepath.Join(reports, "summary.txt"), []byte("ok"), 0640)
+	ioutil.WriteFile(filepath.Join(reports, "debug.log"), []byte("log"), 0644)
+
+	// Run the commands of the container on the host
+	c := &Communicator{
+		ContainerName: "test",
+		CmdWrapper: func(command string) (string, error) {
+			return strings.TrimPrefix(command, "lxc exec test -- "), nil
+		},
+	}
+
+	dst := filepath.Join(dir, "downloads")
+	if err := c.DownloadDir(reports, dst, []string{"*.log"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	fi, err := os.Stat(filepath.Join(dst, "my reports"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0750 {
+		t.Fatalf("bad: %s", fi.Mode())
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dst, "my reports", "summary.txt"))
+	if err != nil || string(data) != "ok" {
+		t.Fatalf("bad: %s %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "my reports", "debug.log")); !os.IsNotExist(err) {
+		t.Fatal("debug.log should be excluded")
+	}
+
+	// A missing directory is an error
+	if err := c.DownloadDir(filepath.Join(dir, "missing"), dst, nil); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 // Acceptance tests
 // TODO Execute a command
 // TODO Upload a file
@@ -2,6 +2,7 @@ package lxd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/helper/config"
@@ -21,6 +22,22 @@ type Config struct {
 	PublishProperties   map[string]string `mapstructure:"publish_properties"`
 	LaunchConfig        map[string]string `mapstructure:"launch_config"`
 
+	// The driver, and the remotes the instance is built on and the image is
+	// published to. The remotes are lxc remote names with the CLI driver,
+	// and addresses of the API with the API driver.
+	Driver            string `mapstructure:"driver"`
+	Remote            string `mapstructure:"remote"`
+	PublishRemote     string `mapstructure:"publish_remote"`
+	ClientCert        string `mapstructure:"client_cert"`
+	ClientKey         string `mapstructure:"client_key"`
+	ServerCert        string `mapstructure:"server_cert"`
+	PublishServerCert string `mapstructure:"publish_server_cert"`
+
+	Target         string `mapstructure:"target"`
+	StoragePool    string `mapstructure:"storage_pool"`
+	Network        string `mapstructure:"network"`
+	VirtualMachine bool   `mapstructure:"virtual_machine"`
+
 	ctx interpolate.Context
 }
 
@@ -55,6 +72,26 @@ func NewConfig(raws ...interface{}) (*Config, error) {
 		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`image` is a required parameter for LXD. Please specify an image by alias or fingerprint. e.g. `ubuntu-daily:x`"))
 	}
 
+	if c.Driver == "" {
+		c.Driver = DriverCLI
+	}
+	if err := ValidateDriver(c.Driver); err != nil {
+		errs = packer.MultiErrorAppend(errs, err)
+	}
+
+	if c.Driver == DriverAPI {
+		for _, remote := range []string{c.Remote, c.PublishRemote} {
+			if remote != "" && !strings.HasPrefix(remote, "unix://") && !strings.HasPrefix(remote, "https://") {
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("With the api driver, remotes must be unix:// or https:// addresses: %s", remote))
+			}
+		}
+	} else if c.ClientCert != "" || c.ClientKey != "" || c.ServerCert != "" || c.PublishServerCert != "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("Certificates are only used by the api driver; the cli driver uses the ones of the lxc remotes"))
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("client_cert and client_key must be set together"))
+	}
+
 	if c.Profile == "" {
 		c.Profile = "default"
 	}
@@ -0,0 +1,94 @@
+package lxd
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// The names of the drivers, which are chosen with the driver option.
+const (
+	// DriverCLI runs the lxc command.
+	DriverCLI = "cli"
+
+	// DriverAPI talks to the LXD REST API.
+	DriverAPI = "api"
+)
+
+// Driver is the interface that has to be implemented to communicate with
+// LXD. It also allows the steps to be tested, since a mock driver can be
+// shimmed in.
+type Driver interface {
+	// Launch creates the instance and starts it.
+	Launch(*InstanceConfig) error
+
+	// Communicator returns the communicator of the running instance with
+	// the given name.
+	Communicator(name string) packer.Communicator
+
+	// Stop stops the instance with the given name.
+	Stop(name string) error
+
+	// Delete forcibly stops and deletes the instance with the given name.
+	Delete(name string) error
+
+	// Publish publishes the stopped instance with the given name as an
+	// image, and returns the fingerprint of the image.
+	Publish(name string, config *PublishConfig) (string, error)
+
+	// DeleteImage deletes the published image with the given fingerprint.
+	DeleteImage(fingerprint string) error
+}
+
+// InstanceConfig is the configuration of the instance that is launched.
+type InstanceConfig struct {
+	Name    string
+	Image   string
+	Profile string
+	Config  map[string]string
+
+	// The cluster member the instance is created on
+	Target string
+
+	// The storage pool of the root disk and the network of eth0, if they
+	// aren't the ones of the profile
+	StoragePool string
+	Network     string
+
+	// Whether the instance is a virtual machine instead of a container
+	VirtualMachine bool
+}
+
+// PublishConfig is the configuration of the image that is published.
+type PublishConfig struct {
+	Alias      string
+	Properties map[string]string
+}
+
+// ValidateDriver returns an error if there is no driver with the given
+// name. The empty name is the CLI driver.
+func ValidateDriver(name string) error {
+	switch name {
+	case "", DriverCLI, DriverAPI:
+		return nil
+	default:
+		return fmt.Errorf("driver must be one of: %s, %s", DriverCLI, DriverAPI)
+	}
+}
+
+// NewDriver returns the driver that is configured. The CLI driver runs
+// commands with the given wrapper.
+func NewDriver(config *Config, wrapper CommandWrapper) (Driver, error) {
+	switch config.Driver {
+	case "", DriverCLI:
+		return &CLIDriver{
+			Remote:        config.Remote,
+			PublishRemote: config.PublishRemote,
+			CmdWrapper:    wrapper,
+		}, nil
+	case DriverAPI:
+		return NewAPIDriver(config)
+	default:
+		return nil, fmt.Errorf("Unknown LXD driver: %s", config.Driver)
+	}
+}
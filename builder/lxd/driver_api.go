@@ -0,0 +1,658 @@
+package lxd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/go-homedir"
+)
+
+// imageServers are the public image servers that are known by their
+// remote names, like they are by the lxc command.
+var imageServers = map[string]string{
+	"images":       "https://images.linuxcontainers.org",
+	"ubuntu":       "https://cloud-images.ubuntu.com/releases",
+	"ubuntu-daily": "https://cloud-images.ubuntu.com/daily",
+}
+
+// fingerprintRe matches the images that are given by fingerprint instead
+// of by alias.
+var fingerprintRe = regexp.MustCompile(`^[0-9a-fA-F]{12,64}$`)
+
+// APIDriver is a Driver that talks to the LXD REST API, over the unix
+// socket of the local LXD or over HTTPS with a client certificate.
+type APIDriver struct {
+	// The remote the instance is built on.
+	client *apiClient
+
+	// The remote the image is published to, which is the same as the
+	// remote of the instance unless publish_remote is set.
+	publish *apiClient
+}
+
+// NewAPIDriver returns the API driver of the remotes of the config.
+func NewAPIDriver(config *Config) (*APIDriver, error) {
+	client, err := newAPIClient(config.Remote, config.ClientCert, config.ClientKey, config.ServerCert)
+	if err != nil {
+		return nil, err
+	}
+
+	driver := &APIDriver{client: client, publish: client}
+	if config.PublishRemote != "" && config.PublishRemote != config.Remote {
+		driver.publish, err = newAPIClient(
+			config.PublishRemote, config.ClientCert, config.ClientKey, config.PublishServerCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return driver, nil
+}
+
+// APIError is an error that is returned by the LXD API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("LXD API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true if the error is an APIError because something
+// doesn't exist.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// apiClient talks to a single LXD server.
+type apiClient struct {
+	// The path of the unix socket, for local servers
+	socket string
+
+	// The base URL of the API, which is "http://lxd" for unix sockets
+	base string
+
+	tls  *tls.Config
+	http *http.Client
+}
+
+// defaultSocket returns the path of the unix socket of the local LXD.
+func defaultSocket() string {
+	if dir := os.Getenv("LXD_DIR"); dir != "" {
+		return filepath.Join(dir, "unix.socket")
+	}
+
+	// LXD that is installed as a snap has its own directory
+	snap := "/var/snap/lxd/common/lxd/unix.socket"
+	if _, err := os.Stat(snap); err == nil {
+		return snap
+	}
+
+	return "/var/lib/lxd/unix.socket"
+}
+
+// defaultClientCert returns the paths of the certificate and key of the
+// lxc command, which LXD servers usually trust.
+func defaultClientCert() (string, string, error) {
+	dir := os.Getenv("LXD_CONF")
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", "", err
+		}
+		dir = filepath.Join(home, ".config", "lxc")
+	}
+
+	return filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"), nil
+}
+
+// newAPIClient returns a client of the LXD at the given address, which is
+// either "unix:///path/to/unix.socket" or "https://host:8443". The local
+// LXD is used if the address is empty. The client certificate and the
+// certificate of the server are only used over HTTPS.
+func newAPIClient(address, certFile, keyFile, serverCert string) (*apiClient, error) {
+	c := new(apiClient)
+
+	switch {
+	case address == "":
+		c.socket = defaultSocket()
+	case strings.HasPrefix(address, "unix://"):
+		c.socket = strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "https://"):
+		c.base = strings.TrimRight(address, "/")
+	default:
+		return nil, fmt.Errorf("LXD remote must be a unix:// or https:// address: %s", address)
+	}
+
+	transport := &http.Transport{}
+	if c.socket != "" {
+		c.base = "http://lxd"
+		transport.Dial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", c.socket)
+		}
+	} else {
+		tlsConfig, err := apiTLSConfig(certFile, keyFile, serverCert)
+		if err != nil {
+			return nil, err
+		}
+		c.tls = tlsConfig
+		transport.TLSClientConfig = tlsConfig
+	}
+	c.http = &http.Client{Transport: transport}
+
+	return c, nil
+}
+
+// apiTLSConfig returns the TLS configuration with the client certificate,
+// that trusts the given certificate of the server if there is one, since
+// LXD servers usually have self-signed certificates.
+func apiTLSConfig(certFile, keyFile, serverCert string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		var err error
+		if certFile, keyFile, err = defaultClientCert(); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading the client certificate: %s", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if serverCert != "" {
+		data, err := ioutil.ReadFile(serverCert)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading the server certificate: %s", err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("Error loading the server certificate: no PEM data in %s", serverCert)
+		}
+
+		// The certificate is pinned, whatever its name and issuer
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(certs [][]byte, _ [][]*x509.Certificate) error {
+			if len(certs) == 0 || !bytes.Equal(certs[0], block.Bytes) {
+				return fmt.Errorf("The certificate of the LXD server isn't %s", serverCert)
+			}
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+// apiResponse is the envelope of the responses of the API.
+type apiResponse struct {
+	Type       string          `json:"type"`
+	StatusCode int             `json:"status_code"`
+	Operation  string          `json:"operation"`
+	ErrorCode  int             `json:"error_code"`
+	Error      string          `json:"error"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// apiOperation is a background operation of the API.
+type apiOperation struct {
+	ID         string          `json:"id"`
+	StatusCode int             `json:"status_code"`
+	Err        string          `json:"err"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// do sends the request with the given body, which is encoded to JSON
+// unless it is a reader, and returns the response if it succeeded.
+func (c *apiClient) do(method, path string, query url.Values, body interface{}, header http.Header) (*http.Response, error) {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var r io.Reader
+	switch v := body.(type) {
+	case nil:
+	case io.Reader:
+		r = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Type", "application/json")
+	}
+
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	log.Printf("LXD API request: %s %s", method, path)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var result apiResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Error == "" {
+			return nil, &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		}
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: result.Error}
+	}
+
+	return resp, nil
+}
+
+// query sends the request and decodes the envelope of the response.
+func (c *apiClient) query(method, path string, query url.Values, body interface{}, header http.Header) (*apiResponse, error) {
+	resp, err := c.do(method, path, query, body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("Error decoding the response of %s %s: %s", method, path, err)
+	}
+	if result.Type == "error" {
+		return nil, &APIError{StatusCode: result.ErrorCode, Message: result.Error}
+	}
+
+	return &result, nil
+}
+
+// run sends the request of an operation and waits for it to finish.
+func (c *apiClient) run(method, path string, query url.Values, body interface{}, header http.Header) (*apiOperation, error) {
+	resp, err := c.query(method, path, query, body, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.wait(resp)
+}
+
+// wait waits for the operation of the response to finish, and returns an
+// error if it failed.
+func (c *apiClient) wait(resp *apiResponse) (*apiOperation, error) {
+	if resp.Type != "async" {
+		return &apiOperation{StatusCode: http.StatusOK, Metadata: resp.Metadata}, nil
+	}
+
+	result, err := c.query("GET", resp.Operation+"/wait", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var op apiOperation
+	if err := json.Unmarshal(result.Metadata, &op); err != nil {
+		return nil, fmt.Errorf("Error decoding the operation: %s", err)
+	}
+	if op.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("LXD operation failed: %s", op.Err)
+	}
+
+	return &op, nil
+}
+
+// websocket connects to the websocket of the operation with the given
+// secret.
+func (c *apiClient) websocket(operation string, secret string) (*websocket.Conn, error) {
+	dialer := &websocket.Dialer{TLSClientConfig: c.tls}
+	if c.socket != "" {
+		dialer.NetDial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", c.socket)
+		}
+	}
+
+	u := "ws" + strings.TrimPrefix(c.base, "http") + operation + "/websocket?" +
+		url.Values{"secret": {secret}}.Encode()
+	conn, _, err := dialer.Dial(u, nil)
+	return conn, err
+}
+
+func (d *APIDriver) Launch(config *InstanceConfig) error {
+	source, err := imageSource(config.Image)
+	if err != nil {
+		return err
+	}
+
+	instanceType := "container"
+	if config.VirtualMachine {
+		instanceType = "virtual-machine"
+	}
+
+	devices := make(map[string]map[string]string)
+	if config.StoragePool != "" {
+		devices["root"] = map[string]string{"type": "disk", "path": "/", "pool": config.StoragePool}
+	}
+	if config.Network != "" {
+		devices["eth0"] = map[string]string{"type": "nic", "name": "eth0", "network": config.Network}
+	}
+
+	body := map[string]interface{}{
+		"name":      config.Name,
+		"type":      instanceType,
+		"profiles":  []string{config.Profile},
+		"config":    config.Config,
+		"devices":   devices,
+		"ephemeral": false,
+		"source":    source,
+	}
+
+	var query url.Values
+	if config.Target != "" {
+		query = url.Values{"target": {config.Target}}
+	}
+
+	if _, err := d.client.run("POST", "/1.0/instances", query, body, nil); err != nil {
+		return err
+	}
+
+	return d.setState(config.Name, "start", false)
+}
+
+// imageSource returns the source of the instance for the given image,
+// which is an alias or fingerprint of an image of the LXD, or of one of
+// the known image servers if it has the prefix of its remote.
+func imageSource(image string) (map[string]string, error) {
+	source := map[string]string{"type": "image"}
+
+	if i := strings.Index(image, ":"); i >= 0 {
+		server, ok := imageServers[image[:i]]
+		if !ok {
+			return nil, fmt.Errorf("Unknown image remote %q; the api driver knows images, ubuntu and ubuntu-daily", image[:i])
+		}
+		source["mode"] = "pull"
+		source["server"] = server
+		source["protocol"] = "simplestreams"
+		image = image[i+1:]
+	}
+
+	if fingerprintRe.MatchString(image) && source["server"] == "" {
+		source["fingerprint"] = image
+	} else {
+		source["alias"] = image
+	}
+
+	return source, nil
+}
+
+func (d *APIDriver) setState(name string, action string, force bool) error {
+	body := map[string]interface{}{"action": action, "timeout": -1, "force": force}
+	_, err := d.client.run("PUT", "/1.0/instances/"+name+"/state", nil, body, nil)
+	return err
+}
+
+func (d *APIDriver) Communicator(name string) packer.Communicator {
+	return &APICommunicator{Driver: d, Name: name}
+}
+
+func (d *APIDriver) Stop(name string) error {
+	return d.setState(name, "stop", false)
+}
+
+func (d *APIDriver) Delete(name string) error {
+	// The instance may already be stopped
+	if err := d.setState(name, "stop", true); err != nil {
+		log.Printf("Error stopping instance %s: %s", name, err)
+	}
+
+	_, err := d.client.run("DELETE", "/1.0/instances/"+name, nil, nil, nil)
+	return err
+}
+
+func (d *APIDriver) Publish(name string, config *PublishConfig) (string, error) {
+	body := map[string]interface{}{
+		"source":     map[string]string{"type": "instance", "name": name},
+		"properties": config.Properties,
+	}
+	op, err := d.client.run("POST", "/1.0/images", nil, body, nil)
+	if err != nil {
+		return "", err
+	}
+	fingerprint, err := operationFingerprint(op)
+	if err != nil {
+		return "", err
+	}
+
+	if d.publish != d.client {
+		fingerprint, err = d.copyImage(fingerprint, config.Properties)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	alias := map[string]string{"name": config.Alias, "target": fingerprint}
+	if _, err := d.publish.query("POST", "/1.0/images/aliases", nil, alias, nil); err != nil {
+		return "", err
+	}
+
+	return fingerprint, nil
+}
+
+func operationFingerprint(op *apiOperation) (string, error) {
+	var result struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(op.Metadata, &result); err != nil || result.Fingerprint == "" {
+		return "", fmt.Errorf("The LXD operation didn't return the fingerprint of the image")
+	}
+
+	return result.Fingerprint, nil
+}
+
+// copyImage copies the image with the given fingerprint to the remote the
+// image is published to, and deletes it from the remote of the instance.
+// The image is streamed through Packer, so the remotes don't need to reach
+// each other.
+func (d *APIDriver) copyImage(fingerprint string, properties map[string]string) (string, error) {
+	export, err := d.client.do("GET", "/1.0/images/"+fingerprint+"/export", nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer export.Body.Close()
+
+	if mediaType, _, _ := mime.ParseMediaType(export.Header.Get("Content-Type")); strings.HasPrefix(mediaType, "multipart/") {
+		return "", fmt.Errorf("Images of separate metadata and rootfs files can't be published to another remote")
+	}
+
+	props := url.Values{}
+	for k, v := range properties {
+		props.Set(k, v)
+	}
+	header := http.Header{
+		"Content-Type":         {"application/octet-stream"},
+		"X-Lxd-Fingerprint":    {fingerprint},
+		"X-Lxd-Properties":     {props.Encode()},
+		"X-Lxd-Public":         {"0"},
+		"X-Lxd-Filename":       {fingerprint + ".tar"},
+		"X-Lxd-Compression-Ok": {"1"},
+	}
+
+	log.Printf("Copying image %s to the publish remote", fingerprint)
+	op, err := d.publish.run("POST", "/1.0/images", nil, export.Body, header)
+	if err != nil {
+		return "", err
+	}
+	copied, err := operationFingerprint(op)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := d.client.run("DELETE", "/1.0/images/"+fingerprint, nil, nil, nil); err != nil {
+		log.Printf("Error deleting the image %s from the build remote: %s", fingerprint, err)
+	}
+
+	return copied, nil
+}
+
+func (d *APIDriver) DeleteImage(fingerprint string) error {
+	_, err := d.publish.run("DELETE", "/1.0/images/"+fingerprint, nil, nil, nil)
+	return err
+}
+
+// Exec runs the command in the instance with the given name, streaming
+// its input and output over websockets, and returns its exit status.
+func (d *APIDriver) Exec(name string, command []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	body := map[string]interface{}{
+		"command":            command,
+		"environment":        map[string]string{},
+		"interactive":        false,
+		"wait-for-websocket": true,
+	}
+	resp, err := d.client.query("POST", "/1.0/instances/"+name+"/exec", nil, body, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var op struct {
+		Metadata struct {
+			FDs map[string]string `json:"fds"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(resp.Metadata, &op); err != nil {
+		return 0, fmt.Errorf("Error decoding the exec operation: %s", err)
+	}
+
+	// The command starts once all the websockets are connected
+	conns := make(map[string]*websocket.Conn)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for _, fd := range []string{"control", "0", "1", "2"} {
+		conn, err := d.client.websocket(resp.Operation, op.Metadata.FDs[fd])
+		if err != nil {
+			return 0, fmt.Errorf("Error connecting to the exec websocket %s: %s", fd, err)
+		}
+		conns[fd] = conn
+	}
+
+	go sendStream(conns["0"], stdin)
+	done := make(chan error, 2)
+	go func() { done <- recvStream(conns["1"], stdout) }()
+	go func() { done <- recvStream(conns["2"], stderr) }()
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			log.Printf("Error reading the output of the command: %s", err)
+		}
+	}
+
+	result, err := d.client.wait(resp)
+	if err != nil {
+		return 0, err
+	}
+
+	var exit struct {
+		Return int `json:"return"`
+	}
+	if err := json.Unmarshal(result.Metadata, &exit); err != nil {
+		return 0, fmt.Errorf("Error decoding the exit status: %s", err)
+	}
+
+	return exit.Return, nil
+}
+
+// sendStream writes r to the websocket as binary messages, and an empty
+// text message at the end, which is how LXD marks the end of a stream.
+func sendStream(conn *websocket.Conn, r io.Reader) {
+	if r != nil {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if werr := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte{})
+}
+
+// recvStream copies the binary messages of the websocket to w until the
+// end of the stream.
+func recvStream(conn *websocket.Conn, w io.Writer) error {
+	for {
+		mt, r, err := conn.NextReader()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+		if mt != websocket.BinaryMessage {
+			return nil
+		}
+		if w == nil {
+			w = ioutil.Discard
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+	}
+}
+
+// PushFile writes a file, directory or symlink of the given type in the
+// instance. The body of a symlink is its target.
+func (d *APIDriver) PushFile(name string, path string, fileType string, mode os.FileMode, r io.Reader) error {
+	header := http.Header{
+		"Content-Type": {"application/octet-stream"},
+		"X-Lxd-Type":   {fileType},
+		"X-Lxd-Mode":   {fmt.Sprintf("%04o", mode.Perm())},
+		"X-Lxd-Uid":    {"0"},
+		"X-Lxd-Gid":    {"0"},
+		"X-Lxd-Write":  {"overwrite"},
+	}
+	if r == nil {
+		r = bytes.NewReader(nil)
+	}
+
+	_, err := d.client.query("POST", "/1.0/instances/"+name+"/files", url.Values{"path": {path}}, r, header)
+	return err
+}
+
+// PullFile reads a file of the instance, and returns its type. The body of
+// a directory is the list of its entries.
+func (d *APIDriver) PullFile(name string, path string) (io.ReadCloser, string, error) {
+	resp, err := d.client.do("GET", "/1.0/instances/"+name+"/files", url.Values{"path": {path}}, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return resp.Body, resp.Header.Get("X-Lxd-Type"), nil
+}
@@ -0,0 +1,610 @@
+package lxd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeLXD is a fake LXD API. Requests are handled by the handler of their
+// method and path, and operations and exec are handled like LXD does.
+type fakeLXD struct {
+	Handlers map[string]http.HandlerFunc
+
+	// Exec runs the commands of the exec endpoint.
+	Exec func(cmd []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+	t      *testing.T
+	l      sync.Mutex
+	nextID int
+	ops    map[string]chan interface{}
+	execs  map[string]*fakeExec
+}
+
+type fakeExec struct {
+	cmd   []string
+	conns map[string]*websocket.Conn
+}
+
+// testAPIDriver returns an API driver of the fake LXD, which listens on a
+// unix socket, and a function that stops it.
+func testAPIDriver(t *testing.T, f *fakeLXD) (*APIDriver, func()) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	socket := filepath.Join(td, "unix.socket")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f.t = t
+	go http.Serve(l, f)
+
+	driver, err := NewAPIDriver(&Config{Remote: "unix://" + socket})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return driver, func() {
+		l.Close()
+		os.RemoveAll(td)
+	}
+}
+
+func writeLXDJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// sync responds with the metadata of a synchronous request.
+func (f *fakeLXD) sync(w http.ResponseWriter, metadata interface{}) {
+	writeLXDJSON(w, http.StatusOK, map[string]interface{}{
+		"type": "sync", "status_code": 200, "metadata": metadata,
+	})
+}
+
+// operation responds with an operation, which finishes with the given
+// metadata, or fails if it is an error.
+func (f *fakeLXD) operation(w http.ResponseWriter, result interface{}) {
+	id := f.newOperation()
+	f.ops[id] <- result
+	f.async(w, id, nil)
+}
+
+func (f *fakeLXD) newOperation() string {
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	f.nextID++
+	id := fmt.Sprintf("op%d", f.nextID)
+	if f.ops == nil {
+		f.ops = make(map[string]chan interface{})
+	}
+	f.ops[id] = make(chan interface{}, 1)
+	return id
+}
+
+func (f *fakeLXD) async(w http.ResponseWriter, id string, metadata interface{}) {
+	writeLXDJSON(w, http.StatusAccepted, map[string]interface{}{
+		"type":        "async",
+		"status_code": 100,
+		"operation":   "/1.0/operations/" + id,
+		"metadata":    map[string]interface{}{"id": id, "metadata": metadata},
+	})
+}
+
+func (f *fakeLXD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := f.Handlers[r.Method+" "+r.URL.Path]; ok {
+		h(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/1.0/"), "/")
+	switch {
+	case r.Method == "GET" && len(parts) == 3 && parts[0] == "operations" && parts[2] == "wait":
+		f.l.Lock()
+		ch := f.ops[parts[1]]
+		f.l.Unlock()
+		if ch == nil {
+			break
+		}
+
+		result := <-ch
+		op := map[string]interface{}{"id": parts[1], "status_code": 200, "metadata": result}
+		if err, ok := result.(error); ok {
+			op = map[string]interface{}{"id": parts[1], "status_code": 400, "err": err.Error()}
+		}
+		f.sync(w, op)
+		return
+	case r.Method == "GET" && len(parts) == 3 && parts[0] == "operations" && parts[2] == "websocket":
+		f.execWebsocket(w, r, parts[1])
+		return
+	case r.Method == "POST" && len(parts) == 3 && parts[0] == "instances" && parts[2] == "exec":
+		f.startExec(w, r)
+		return
+	}
+
+	writeLXDJSON(w, http.StatusNotFound, map[string]interface{}{
+		"type": "error", "error": "not found", "error_code": 404,
+	})
+}
+
+func (f *fakeLXD) startExec(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Command          []string `json:"command"`
+		WaitForWebsocket bool     `json:"wait-for-websocket"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.WaitForWebsocket {
+		f.t.Errorf("bad exec: %v %#v", err, req)
+	}
+
+	id := f.newOperation()
+	f.l.Lock()
+	if f.execs == nil {
+		f.execs = make(map[string]*fakeExec)
+	}
+	f.execs[id] = &fakeExec{cmd: req.Command, conns: make(map[string]*websocket.Conn)}
+	f.l.Unlock()
+
+	fds := map[string]string{"control": "control", "0": "0", "1": "1", "2": "2"}
+	f.async(w, id, map[string]interface{}{"fds": fds})
+}
+
+func (f *fakeLXD) execWebsocket(w http.ResponseWriter, r *http.Request, id string) {
+	conn, err := new(websocket.Upgrader).Upgrade(w, r, nil)
+	if err != nil {
+		f.t.Errorf("err: %s", err)
+		return
+	}
+
+	f.l.Lock()
+	exec := f.execs[id]
+	exec.conns[r.URL.Query().Get("secret")] = conn
+	ready := len(exec.conns) == 4
+	f.l.Unlock()
+
+	// The command runs once all the websockets are connected
+	if ready {
+		go f.runExec(id, exec)
+	}
+}
+
+func (f *fakeLXD) runExec(id string, exec *fakeExec) {
+	stdin, stdinW := io.Pipe()
+	go func() {
+		stdinW.CloseWithError(recvStream(exec.conns["0"], stdinW))
+	}()
+
+	stdout := &wsWriter{exec.conns["1"]}
+	stderr := &wsWriter{exec.conns["2"]}
+	code := f.Exec(exec.cmd, stdin, stdout, stderr)
+
+	// An empty text message ends the output
+	exec.conns["1"].WriteMessage(websocket.TextMessage, []byte{})
+	exec.conns["2"].WriteMessage(websocket.TextMessage, []byte{})
+
+	f.l.Lock()
+	ch := f.ops[id]
+	f.l.Unlock()
+	ch <- map[string]interface{}{"return": code}
+}
+
+type wsWriter struct {
+	conn *websocket.Conn
+}
+
+func (w *wsWriter) Write(p []byte) (int, error) {
+	if err := w.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func TestAPIDriver_impl(t *testing.T) {
+	var _ Driver = new(APIDriver)
+}
+
+func TestNewAPIClient(t *testing.T) {
+	c, err := newAPIClient("unix:///run/lxd.socket", "", "", "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if c.socket != "/run/lxd.socket" || c.base != "http://lxd" {
+		t.Fatalf("bad: %#v", c)
+	}
+
+	os.Setenv("LXD_DIR", "/srv/lxd")
+	defer os.Unsetenv("LXD_DIR")
+	if c, err = newAPIClient("", "", "", ""); err != nil || c.socket != "/srv/lxd/unix.socket" {
+		t.Fatalf("bad: %#v %v", c, err)
+	}
+
+	if _, err := newAPIClient("tcp://lxd:8443", "", "", ""); err == nil {
+		t.Fatal("should error")
+	}
+
+	// The client certificate is required over HTTPS
+	os.Setenv("LXD_CONF", "/nonexistent")
+	defer os.Unsetenv("LXD_CONF")
+	if _, err := newAPIClient("https://lxd:8443", "", "", ""); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestImageSource(t *testing.T) {
+	cases := []struct {
+		Image    string
+		Expected map[string]string
+	}{
+		{"my-image", map[string]string{"type": "image", "alias": "my-image"}},
+		{"08fababf6f27", map[string]string{"type": "image", "fingerprint": "08fababf6f27"}},
+		{"ubuntu-daily:x", map[string]string{
+			"type":     "image",
+			"alias":    "x",
+			"mode":     "pull",
+			"server":   "https://cloud-images.ubuntu.com/daily",
+			"protocol": "simplestreams",
+		}},
+	}
+
+	for _, tc := range cases {
+		source, err := imageSource(tc.Image)
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.Image, err)
+		}
+		if !reflect.DeepEqual(source, tc.Expected) {
+			t.Fatalf("%s: bad: %#v", tc.Image, source)
+		}
+	}
+
+	if _, err := imageSource("unknown:x"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestAPIDriver_LaunchStopDelete(t *testing.T) {
+	var instance map[string]interface{}
+	var target string
+	var actions []string
+	var deleted bool
+	f := &fakeLXD{}
+	f.Handlers = map[string]http.HandlerFunc{
+		"POST /1.0/instances": func(w http.ResponseWriter, r *http.Request) {
+			target = r.URL.Query().Get("target")
+			json.NewDecoder(r.Body).Decode(&instance)
+			f.operation(w, nil)
+		},
+		"PUT /1.0/instances/packer/state": func(w http.ResponseWriter, r *http.Request) {
+			var state struct {
+				Action string
+				Force  bool
+			}
+			json.NewDecoder(r.Body).Decode(&state)
+			actions = append(actions, fmt.Sprintf("%s %v", state.Action, state.Force))
+			if state.Force {
+				f.operation(w, fmt.Errorf("The instance is already stopped"))
+				return
+			}
+			f.operation(w, nil)
+		},
+		"DELETE /1.0/instances/packer": func(w http.ResponseWriter, r *http.Request) {
+			deleted = true
+			f.operation(w, nil)
+		},
+	}
+	driver, stop := testAPIDriver(t, f)
+	defer stop()
+
+	err := driver.Launch(&InstanceConfig{
+		Name:           "packer",
+		Image:          "my-image",
+		Profile:        "default",
+		Config:         map[string]string{"limits.cpu": "2"},
+		Target:         "node2",
+		StoragePool:    "fast",
+		Network:        "lxdbr1",
+		VirtualMachine: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if target != "node2" {
+		t.Fatalf("bad: %s", target)
+	}
+	expected := map[string]interface{}{
+		"name":      "packer",
+		"type":      "virtual-machine",
+		"profiles":  []interface{}{"default"},
+		"config":    map[string]interface{}{"limits.cpu": "2"},
+		"ephemeral": false,
+		"source":    map[string]interface{}{"type": "image", "alias": "my-image"},
+		"devices": map[string]interface{}{
+			"root": map[string]interface{}{"type": "disk", "path": "/", "pool": "fast"},
+			"eth0": map[string]interface{}{"type": "nic", "name": "eth0", "network": "lxdbr1"},
+		},
+	}
+	if !reflect.DeepEqual(instance, expected) {
+		t.Fatalf("bad: %#v", instance)
+	}
+
+	if err := driver.Stop("packer"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Deleting a stopped instance works
+	if err := driver.Delete("packer"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !deleted {
+		t.Fatal("should delete")
+	}
+
+	if !reflect.DeepEqual(actions, []string{"start false", "stop false", "stop true"}) {
+		t.Fatalf("bad: %#v", actions)
+	}
+}
+
+func TestAPIDriver_errors(t *testing.T) {
+	f := &fakeLXD{}
+	f.Handlers = map[string]http.HandlerFunc{
+		"POST /1.0/instances": func(w http.ResponseWriter, r *http.Request) {
+			f.operation(w, fmt.Errorf("Failed to get image"))
+		},
+	}
+	driver, stop := testAPIDriver(t, f)
+	defer stop()
+
+	err := driver.Launch(&InstanceConfig{Name: "packer", Image: "missing"})
+	if err == nil || !strings.Contains(err.Error(), "Failed to get image") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	if err := driver.Stop("missing"); !IsNotFound(err) {
+		t.Fatalf("bad: %#v", err)
+	}
+}
+
+func TestAPIDriver_Publish(t *testing.T) {
+	var image map[string]interface{}
+	var alias map[string]string
+	var deleted string
+	f := &fakeLXD{}
+	f.Handlers = map[string]http.HandlerFunc{
+		"POST /1.0/images": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&image)
+			f.operation(w, map[string]string{"fingerprint": "abcdef"})
+		},
+		"POST /1.0/images/aliases": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&alias)
+			f.sync(w, nil)
+		},
+		"DELETE /1.0/images/abcdef": func(w http.ResponseWriter, r *http.Request) {
+			deleted = "abcdef"
+			f.operation(w, nil)
+		},
+	}
+	driver, stop := testAPIDriver(t, f)
+	defer stop()
+
+	fingerprint, err := driver.Publish("packer", &PublishConfig{
+		Alias:      "my-image",
+		Properties: map[string]string{"description": "built"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fingerprint != "abcdef" {
+		t.Fatalf("bad: %s", fingerprint)
+	}
+
+	expected := map[string]interface{}{
+		"source":     map[string]interface{}{"type": "instance", "name": "packer"},
+		"properties": map[string]interface{}{"description": "built"},
+	}
+	if !reflect.DeepEqual(image, expected) {
+		t.Fatalf("bad: %#v", image)
+	}
+	if !reflect.DeepEqual(alias, map[string]string{"name": "my-image", "target": "abcdef"}) {
+		t.Fatalf("bad: %#v", alias)
+	}
+
+	if err := driver.DeleteImage("abcdef"); err != nil || deleted != "abcdef" {
+		t.Fatalf("bad: %s %v", deleted, err)
+	}
+}
+
+func TestAPIDriver_PublishRemote(t *testing.T) {
+	var buildDeleted bool
+	build := &fakeLXD{}
+	build.Handlers = map[string]http.HandlerFunc{
+		"POST /1.0/images": func(w http.ResponseWriter, r *http.Request) {
+			build.operation(w, map[string]string{"fingerprint": "abcdef"})
+		},
+		"GET /1.0/images/abcdef/export": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("image tarball"))
+		},
+		"DELETE /1.0/images/abcdef": func(w http.ResponseWriter, r *http.Request) {
+			buildDeleted = true
+			build.operation(w, nil)
+		},
+	}
+	driver, stop := testAPIDriver(t, build)
+	defer stop()
+
+	var uploaded []byte
+	var properties string
+	var alias map[string]string
+	publish := &fakeLXD{}
+	publish.Handlers = map[string]http.HandlerFunc{
+		"POST /1.0/images": func(w http.ResponseWriter, r *http.Request) {
+			uploaded, _ = ioutil.ReadAll(r.Body)
+			properties = r.Header.Get("X-LXD-Properties")
+			publish.operation(w, map[string]string{"fingerprint": "abcdef"})
+		},
+		"POST /1.0/images/aliases": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&alias)
+			publish.sync(w, nil)
+		},
+	}
+	publishDriver, stopPublish := testAPIDriver(t, publish)
+	defer stopPublish()
+	driver.publish = publishDriver.client
+
+	fingerprint, err := driver.Publish("packer", &PublishConfig{
+		Alias:      "my-image",
+		Properties: map[string]string{"os": "ubuntu"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if fingerprint != "abcdef" || string(uploaded) != "image tarball" || properties != "os=ubuntu" {
+		t.Fatalf("bad: %s %s %s", fingerprint, uploaded, properties)
+	}
+	if alias["name"] != "my-image" {
+		t.Fatalf("bad: %#v", alias)
+	}
+	if !buildDeleted {
+		t.Fatal("the image should be deleted from the build remote")
+	}
+}
+
+func TestAPIDriver_Exec(t *testing.T) {
+	f := &fakeLXD{
+		Exec: func(cmd []string, stdin io.Reader, stdout, stderr io.Writer) int {
+			input, _ := ioutil.ReadAll(stdin)
+			fmt.Fprintf(stdout, "%s: %s", strings.Join(cmd, " "), input)
+			stderr.Write([]byte("warning"))
+			return 3
+		},
+	}
+	driver, stop := testAPIDriver(t, f)
+	defer stop()
+
+	var stdout, stderr bytes.Buffer
+	code, err := driver.Exec("packer", []string{"cat"}, strings.NewReader("input"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if code != 3 || stdout.String() != "cat: input" || stderr.String() != "warning" {
+		t.Fatalf("bad: %d %q %q", code, stdout.String(), stderr.String())
+	}
+}
+
+// testCertificate writes a self-signed certificate and its key to dir.
+func testCertificate(t *testing.T, dir string, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return certFile, keyFile
+}
+
+func TestAPIDriver_https(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	var clientCerts int
+	f := &fakeLXD{}
+	f.Handlers = map[string]http.HandlerFunc{
+		"PUT /1.0/instances/packer/state": func(w http.ResponseWriter, r *http.Request) {
+			clientCerts = len(r.TLS.PeerCertificates)
+			f.sync(w, nil)
+		},
+	}
+	server := httptest.NewUnstartedServer(f)
+	server.TLS = new(tls.Config)
+	server.TLS.ClientAuth = tls.RequireAnyClientCert
+	server.StartTLS()
+	defer server.Close()
+	f.t = t
+
+	clientCert, clientKey := testCertificate(t, td, "client")
+	serverCert := filepath.Join(td, "server.crt")
+	ioutil.WriteFile(serverCert,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.TLS.Certificates[0].Certificate[0]}), 0644)
+	otherCert, _ := testCertificate(t, td, "other")
+
+	driver, err := NewAPIDriver(&Config{
+		Remote:     server.URL,
+		ClientCert: clientCert,
+		ClientKey:  clientKey,
+		ServerCert: serverCert,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := driver.Stop("packer"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if clientCerts != 1 {
+		t.Fatalf("bad: %d", clientCerts)
+	}
+
+	// Another server certificate is refused
+	driver, err = NewAPIDriver(&Config{
+		Remote:     server.URL,
+		ClientCert: clientCert,
+		ClientKey:  clientKey,
+		ServerCert: otherCert,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := driver.Stop("packer"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,129 @@
+package lxd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// CLIDriver is a Driver that runs the lxc command.
+type CLIDriver struct {
+	// The names of the lxc remotes the instance is built on and the image
+	// is published to. The default remote is used if they are empty.
+	Remote        string
+	PublishRemote string
+
+	// CmdWrapper wraps the commands of the communicator.
+	CmdWrapper CommandWrapper
+}
+
+// qualify prefixes the name with the remote, if there is one.
+func qualify(remote string, name string) string {
+	if remote == "" {
+		return name
+	}
+	return remote + ":" + name
+}
+
+// publishRemote returns the remote the image is published to, which is the
+// remote of the instance unless it is set.
+func (d *CLIDriver) publishRemote() string {
+	if d.PublishRemote != "" {
+		return d.PublishRemote
+	}
+	return d.Remote
+}
+
+func (d *CLIDriver) Launch(config *InstanceConfig) error {
+	_, err := LXDCommand(d.launchArgs(config)...)
+	return err
+}
+
+func (d *CLIDriver) launchArgs(config *InstanceConfig) []string {
+	args := []string{
+		"launch", "--ephemeral=false", fmt.Sprintf("--profile=%s", config.Profile),
+		config.Image, qualify(d.Remote, config.Name),
+	}
+
+	if config.VirtualMachine {
+		args = append(args, "--vm")
+	}
+	if config.Target != "" {
+		args = append(args, fmt.Sprintf("--target=%s", config.Target))
+	}
+	if config.StoragePool != "" {
+		args = append(args, fmt.Sprintf("--storage=%s", config.StoragePool))
+	}
+	if config.Network != "" {
+		args = append(args, fmt.Sprintf("--network=%s", config.Network))
+	}
+
+	keys := make([]string, 0, len(config.Config))
+	for k := range config.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--config", fmt.Sprintf("%s=%s", k, config.Config[k]))
+	}
+
+	return args
+}
+
+func (d *CLIDriver) Communicator(name string) packer.Communicator {
+	return &Communicator{
+		ContainerName: qualify(d.Remote, name),
+		CmdWrapper:    d.CmdWrapper,
+	}
+}
+
+func (d *CLIDriver) Stop(name string) error {
+	_, err := LXDCommand("stop", qualify(d.Remote, name))
+	return err
+}
+
+func (d *CLIDriver) Delete(name string) error {
+	_, err := LXDCommand("delete", "--force", qualify(d.Remote, name))
+	return err
+}
+
+func (d *CLIDriver) Publish(name string, config *PublishConfig) (string, error) {
+	stdoutString, err := LXDCommand(d.publishArgs(name, config)...)
+	if err != nil {
+		return "", err
+	}
+
+	r := regexp.MustCompile("([0-9a-fA-F]+)$")
+	matches := r.FindStringSubmatch(stdoutString)
+	if matches == nil {
+		return "", fmt.Errorf("Unexpected output of lxc publish: %s", stdoutString)
+	}
+
+	return matches[1], nil
+}
+
+func (d *CLIDriver) publishArgs(name string, config *PublishConfig) []string {
+	args := []string{"publish", qualify(d.Remote, name)}
+	if remote := d.publishRemote(); remote != "" {
+		args = append(args, remote+":")
+	}
+	args = append(args, "--alias", config.Alias)
+
+	keys := make([]string, 0, len(config.Properties))
+	for k := range config.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, fmt.Sprintf("%s=%s", k, config.Properties[k]))
+	}
+
+	return args
+}
+
+func (d *CLIDriver) DeleteImage(fingerprint string) error {
+	_, err := LXDCommand("image", "delete", qualify(d.publishRemote(), fingerprint))
+	return err
+}
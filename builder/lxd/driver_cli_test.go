@@ -0,0 +1,59 @@
+package lxd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCLIDriver_impl(t *testing.T) {
+	var _ Driver = new(CLIDriver)
+}
+
+func TestCLIDriver_launchArgs(t *testing.T) {
+	d := &CLIDriver{Remote: "cluster"}
+	args := d.launchArgs(&InstanceConfig{
+		Name:           "packer",
+		Image:          "ubuntu:18.04",
+		Profile:        "default",
+		Config:         map[string]string{"security.nesting": "true", "limits.cpu": "2"},
+		Target:         "node2",
+		StoragePool:    "fast",
+		Network:        "lxdbr1",
+		VirtualMachine: true,
+	})
+
+	expected := []string{
+		"launch", "--ephemeral=false", "--profile=default", "ubuntu:18.04", "cluster:packer",
+		"--vm", "--target=node2", "--storage=fast", "--network=lxdbr1",
+		"--config", "limits.cpu=2", "--config", "security.nesting=true",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestCLIDriver_publishArgs(t *testing.T) {
+	config := &PublishConfig{
+		Alias:      "my-image",
+		Properties: map[string]string{"os": "ubuntu", "description": "built"},
+	}
+
+	d := &CLIDriver{}
+	expected := []string{"publish", "packer", "--alias", "my-image", "description=built", "os=ubuntu"}
+	if args := d.publishArgs("packer", config); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	// The image is published to the remote of the instance by default
+	d = &CLIDriver{Remote: "build"}
+	expected = []string{"publish", "build:packer", "build:", "--alias", "my-image", "description=built", "os=ubuntu"}
+	if args := d.publishArgs("packer", config); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	d = &CLIDriver{Remote: "build", PublishRemote: "images"}
+	expected = []string{"publish", "build:packer", "images:", "--alias", "my-image", "description=built", "os=ubuntu"}
+	if args := d.publishArgs("packer", config); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
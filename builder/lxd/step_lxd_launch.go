@@ -15,23 +15,22 @@ type stepLxdLaunch struct{}
 
 func (s *stepLxdLaunch) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
 
-	name := config.ContainerName
-	image := config.Image
-	profile := fmt.Sprintf("--profile=%s", config.Profile)
-
-	launch_args := []string{
-		"launch", "--ephemeral=false", profile, image, name,
-	}
-
-	for k, v := range config.LaunchConfig {
-		launch_args = append(launch_args, "--config", fmt.Sprintf("%s=%s", k, v))
+	launchConfig := &InstanceConfig{
+		Name:           config.ContainerName,
+		Image:          config.Image,
+		Profile:        config.Profile,
+		Config:         config.LaunchConfig,
+		Target:         config.Target,
+		StoragePool:    config.StoragePool,
+		Network:        config.Network,
+		VirtualMachine: config.VirtualMachine,
 	}
 
 	ui.Say("Creating container...")
-	_, err := LXDCommand(launch_args...)
-	if err != nil {
+	if err := driver.Launch(launchConfig); err != nil {
 		err := fmt.Errorf("Error creating container: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
@@ -55,14 +54,11 @@ func (s *stepLxdLaunch) Run(_ context.Context, state multistep.StateBag) multist
 
 func (s *stepLxdLaunch) Cleanup(state multistep.StateBag) {
 	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
 
-	cleanup_args := []string{
-		"delete", "--force", config.ContainerName,
-	}
-
 	ui.Say("Unregistering and deleting deleting container...")
-	if _, err := LXDCommand(cleanup_args...); err != nil {
+	if err := driver.Delete(config.ContainerName); err != nil {
 		ui.Error(fmt.Sprintf("Error deleting container: %s", err))
 	}
 }
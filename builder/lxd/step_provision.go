@@ -15,13 +15,10 @@ func (s *StepProvision) Run(_ context.Context, state multistep.StateBag) multist
 	hook := state.Get("hook").(packer.Hook)
 	config := state.Get("config").(*Config)
 	ui := state.Get("ui").(packer.Ui)
-	wrappedCommand := state.Get("wrappedCommand").(CommandWrapper)
+	driver := state.Get("driver").(Driver)
 
 	// Create our communicator
-	comm := &Communicator{
-		ContainerName: config.ContainerName,
-		CmdWrapper:    wrappedCommand,
-	}
+	comm := driver.Communicator(config.ContainerName)
 
 	// Provision
 	log.Println("Running the provision hook")
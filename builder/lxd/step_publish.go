@@ -3,7 +3,6 @@ package lxd
 import (
 	"context"
 	"fmt"
-	"regexp"
 
 	"github.com/hashicorp/packer/helper/multistep"
 	"github.com/hashicorp/packer/packer"
@@ -13,33 +12,25 @@ type stepPublish struct{}
 
 func (s *stepPublish) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
 	config := state.Get("config").(*Config)
+	driver := state.Get("driver").(Driver)
 	ui := state.Get("ui").(packer.Ui)
 
-	name := config.ContainerName
-	stop_args := []string{
-		// We created the container with "--ephemeral=false" so we know it is safe to stop.
-		"stop", name,
-	}
-
 	ui.Say("Stopping container...")
-	_, err := LXDCommand(stop_args...)
-	if err != nil {
+	// We created the container with "--ephemeral=false" so we know it is safe to stop.
+	if err := driver.Stop(config.ContainerName); err != nil {
 		err := fmt.Errorf("Error stopping container: %s", err)
 		state.Put("error", err)
 		ui.Error(err.Error())
 		return multistep.ActionHalt
 	}
 
-	publish_args := []string{
-		"publish", name, "--alias", config.OutputImage,
-	}
-
-	for k, v := range config.PublishProperties {
-		publish_args = append(publish_args, fmt.Sprintf("%s=%s", k, v))
+	publishConfig := &PublishConfig{
+		Alias:      config.OutputImage,
+		Properties: config.PublishProperties,
 	}
 
 	ui.Say("Publishing container...")
-	stdoutString, err := LXDCommand(publish_args...)
+	fingerprint, err := driver.Publish(config.ContainerName, publishConfig)
 	if err != nil {
 		err := fmt.Errorf("Error publishing container: %s", err)
 		state.Put("error", err)
@@ -47,9 +38,6 @@ func (s *stepPublish) Run(_ context.Context, state multistep.StateBag) multistep
 		return multistep.ActionHalt
 	}
 
-	r := regexp.MustCompile("([0-9a-fA-F]+)$")
-	fingerprint := r.FindAllStringSubmatch(stdoutString, -1)[0][0]
-
 	ui.Say(fmt.Sprintf("Created image: %s", fingerprint))
 
 	state.Put("imageFingerprint", fingerprint)
@@ -251,6 +251,13 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	return parallelscommon.NewArtifact(b.config.OutputDir)
 }
 
+// PlanOutputs tells build plans about the output directory, which -force deletes.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output_directory", Default: "output-{{build_name}}", Local: true},
+	}
+}
+
 func (b *Builder) Cancel() {
 	if b.runner != nil {
 		log.Println("Cancelling the step runner...")
@@ -0,0 +1,41 @@
+package rootfs
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Artifact is the root filesystem, either a tarball or a directory.
+type Artifact struct {
+	path string
+	dir  bool
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return []string{a.path}
+}
+
+func (a *Artifact) Id() string {
+	return a.path
+}
+
+func (a *Artifact) String() string {
+	if a.dir {
+		return fmt.Sprintf("Root filesystem directory: %s", a.path)
+	}
+	return fmt.Sprintf("Root filesystem tarball: %s", a.path)
+}
+
+func (a *Artifact) State(name string) interface{} {
+	return nil
+}
+
+func (a *Artifact) Destroy() error {
+	log.Printf("Deleting %s", a.path)
+	return os.RemoveAll(a.path)
+}
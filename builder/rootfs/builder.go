@@ -0,0 +1,147 @@
+// The rootfs package builds root filesystems without a virtual machine. It
+// unpacks a directory or a tarball, like the output of debootstrap or the
+// root filesystem of a cloud image, provisions it within a chroot or a
+// systemd-nspawn container, and saves it as a tarball or a directory.
+package rootfs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The unique ID for this builder
+const BuilderId = "packer.rootfs"
+
+type wrappedCommandTemplate struct {
+	Command string
+}
+
+type Builder struct {
+	config *Config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	c, warnings, errs := NewConfig(raws...)
+	if errs != nil {
+		return warnings, errs
+	}
+	b.config = c
+
+	return warnings, nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	if runtime.GOOS != "linux" {
+		return nil, errors.New("The rootfs builder only works on Linux environments.")
+	}
+
+	wrappedCommand := func(command string) (string, error) {
+		ctx := b.config.ctx
+		ctx.Data = &wrappedCommandTemplate{Command: command}
+		return interpolate.Render(b.config.CommandWrapper, &ctx)
+	}
+
+	// Setup the state bag and initial state for the steps
+	state := new(multistep.BasicStateBag)
+	state.Put("config", b.config)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+	state.Put("wrappedCommand", chroot.CommandWrapper(wrappedCommand))
+
+	// Build the steps
+	steps := []multistep.Step{
+		&StepPrepareRootfs{},
+		&StepExtractSource{},
+	}
+
+	if b.config.Isolation == IsolationChroot {
+		steps = append(steps,
+			&chroot.StepMountExtra{
+				ChrootMounts: b.config.ChrootMounts,
+			},
+			&chroot.StepCopyFiles{
+				Files: b.config.CopyFiles,
+			},
+			&chroot.StepChrootProvision{},
+		)
+	} else {
+		steps = append(steps,
+			&chroot.StepCopyFiles{
+				Files: b.config.CopyFiles,
+			},
+			&chroot.StepChrootProvision{
+				ChrootCommand: nspawnCommand(b.config.NspawnArgs),
+			},
+		)
+	}
+
+	steps = append(steps,
+		&StepEarlyCleanup{},
+		&StepExport{},
+	)
+
+	// Run!
+	b.runner = common.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner.Run(state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, errors.New("Build was cancelled.")
+	}
+
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, errors.New("Build was halted.")
+	}
+
+	artifact := &Artifact{
+		path: b.config.OutputPath,
+		dir:  b.config.OutputType == OutputDirectory,
+	}
+
+	return artifact, nil
+}
+
+// PlanOutputs tells build plans about the tarball or directory of the
+// root filesystem, which -force replaces.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	def := "output-{{build_name}}"
+	if b.config.OutputType != OutputDirectory {
+		def += ".tar"
+	}
+	return []packer.PlanOutputKey{
+		{Key: "output_path", Default: def, Local: true},
+	}
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
+
+// nspawnCommand returns the ChrootCommand that runs commands in a
+// systemd-nspawn container of the root filesystem, with the given extra
+// arguments of systemd-nspawn.
+func nspawnCommand(args []string) chroot.ChrootCommand {
+	return func(dir string, command string) string {
+		parts := []string{"systemd-nspawn", "--quiet", "--register=no", fmt.Sprintf("--directory=%s", dir)}
+		parts = append(parts, args...)
+		return fmt.Sprintf("%s /bin/sh -c \"%s\"", strings.Join(parts, " "), command)
+	}
+}
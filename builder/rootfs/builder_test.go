@@ -0,0 +1,174 @@
+package rootfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig(t *testing.T) map[string]interface{} {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+
+	return map[string]interface{}{
+		"source":            tf.Name(),
+		"packer_build_name": "foo",
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare_Defaults(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["source"].(string))
+
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Isolation != IsolationChroot {
+		t.Fatalf("bad: %s", b.config.Isolation)
+	}
+	if len(b.config.ChrootMounts) != 5 {
+		t.Fatalf("bad: %#v", b.config.ChrootMounts)
+	}
+	if len(b.config.CopyFiles) != 1 || b.config.CopyFiles[0] != "/etc/resolv.conf" {
+		t.Fatalf("bad: %#v", b.config.CopyFiles)
+	}
+	if b.config.OutputType != OutputTar || b.config.OutputPath != "output-foo.tar" {
+		t.Fatalf("bad: %s %s", b.config.OutputType, b.config.OutputPath)
+	}
+	if b.config.CommandWrapper != "{{.Command}}" {
+		t.Fatalf("bad: %s", b.config.CommandWrapper)
+	}
+
+	// The output directory has no extension
+	config["output_type"] = "directory"
+	b = Builder{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if b.config.OutputPath != "output-foo" {
+		t.Fatalf("bad: %s", b.config.OutputPath)
+	}
+}
+
+func TestBuilderPrepare_Source(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["source"].(string))
+
+	// A directory is a source
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	config["source"] = td
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	config["source"] = "/nonexistent"
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	delete(config, "source")
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Isolation(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["source"].(string))
+
+	config["isolation"] = "nspawn"
+	config["nspawn_args"] = []string{"--bind-ro=/etc/hosts"}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+	if len(b.config.ChrootMounts) != 0 {
+		t.Fatalf("bad: %#v", b.config.ChrootMounts)
+	}
+	if len(b.config.CopyFiles) != 0 {
+		t.Fatalf("bad: %#v", b.config.CopyFiles)
+	}
+
+	// Mounts are done by systemd-nspawn
+	config["chroot_mounts"] = [][]string{{"bind", "/dev", "/dev"}}
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) != 1 || err != nil {
+		t.Fatalf("bad: %#v %v", warnings, err)
+	}
+
+	config["isolation"] = "vm"
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_ChrootMounts(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["source"].(string))
+
+	config["chroot_mounts"] = [][]string{{"bind", "/dev"}}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_OutputType(t *testing.T) {
+	var b Builder
+	config := testConfig(t)
+	defer os.Remove(config["source"].(string))
+
+	config["output_type"] = "qcow2"
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// The root filesystem is built in the output directory
+	config["output_type"] = "directory"
+	config["rootfs_dir"] = "/var/tmp/rootfs"
+	b = Builder{}
+	warnings, err := b.Prepare(config)
+	if len(warnings) != 1 || err != nil {
+		t.Fatalf("bad: %#v %v", warnings, err)
+	}
+}
+
+func TestNspawnCommand(t *testing.T) {
+	command := nspawnCommand([]string{"--bind-ro=/etc/hosts"})("/var/rootfs", "apt-get update")
+	expected := `systemd-nspawn --quiet --register=no --directory=/var/rootfs --bind-ro=/etc/hosts /bin/sh -c "apt-get update"`
+	if command != expected {
+		t.Fatalf("bad: %s", command)
+	}
+}
@@ -0,0 +1,55 @@
+package rootfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer/common/chroot"
+)
+
+// mountsUnder returns the mount points that are in the given directory,
+// or are the directory itself.
+func mountsUnder(dir string) ([]string, error) {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		// Spaces in mount points are escaped
+		mountPoint := strings.Replace(fields[1], `\040`, " ", -1)
+		if mountPoint == dir || strings.HasPrefix(mountPoint, dir+"/") {
+			mounts = append(mounts, mountPoint)
+		}
+	}
+
+	return mounts, scanner.Err()
+}
+
+// removeRootfs deletes the directory of a root filesystem. It refuses to
+// if anything is still mounted in it, since deleting it would delete the
+// files of the mounts.
+func removeRootfs(wrappedCommand chroot.CommandWrapper, dir string) error {
+	dir = filepath.Clean(dir)
+	mounts, err := mountsUnder(dir)
+	if err != nil {
+		return fmt.Errorf("Error reading the mounts: %s", err)
+	}
+	if len(mounts) > 0 {
+		return fmt.Errorf("Not deleting %s, which still has mounts: %s", dir, strings.Join(mounts, ", "))
+	}
+
+	_, err = chroot.RunCommand(wrappedCommand, fmt.Sprintf("rm -rf --one-file-system '%s'", dir))
+	return err
+}
@@ -0,0 +1,148 @@
+package rootfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// The isolations of the commands of the provisioners.
+const (
+	IsolationChroot = "chroot"
+	IsolationNspawn = "nspawn"
+)
+
+// The types of the artifact.
+const (
+	OutputTar       = "tar"
+	OutputDirectory = "directory"
+)
+
+// Config is the configuration that is chained through the steps and
+// settable from the template.
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	Source         string     `mapstructure:"source"`
+	Isolation      string     `mapstructure:"isolation"`
+	RootfsDir      string     `mapstructure:"rootfs_dir"`
+	ChrootMounts   [][]string `mapstructure:"chroot_mounts"`
+	CopyFiles      []string   `mapstructure:"copy_files"`
+	NspawnArgs     []string   `mapstructure:"nspawn_args"`
+	CommandWrapper string     `mapstructure:"command_wrapper"`
+	OutputType     string     `mapstructure:"output_type"`
+	OutputPath     string     `mapstructure:"output_path"`
+
+	ctx interpolate.Context
+}
+
+func NewConfig(raws ...interface{}) (*Config, []string, error) {
+	var c Config
+	err := config.Decode(&c, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"command_wrapper",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Accumulate any errors or warnings
+	var errs *packer.MultiError
+	var warns []string
+
+	if c.Isolation == "" {
+		c.Isolation = IsolationChroot
+	}
+
+	if c.ChrootMounts == nil {
+		c.ChrootMounts = make([][]string, 0)
+	}
+
+	if c.Isolation == IsolationNspawn && len(c.ChrootMounts) > 0 {
+		warns = append(warns, "chroot_mounts is unused when isolation is nspawn")
+	}
+
+	if len(c.ChrootMounts) == 0 && c.Isolation == IsolationChroot {
+		c.ChrootMounts = [][]string{
+			{"proc", "proc", "/proc"},
+			{"sysfs", "sysfs", "/sys"},
+			{"bind", "/dev", "/dev"},
+			{"devpts", "devpts", "/dev/pts"},
+			{"binfmt_misc", "binfmt_misc", "/proc/sys/fs/binfmt_misc"},
+		}
+	}
+
+	// systemd-nspawn sets up the resolv.conf of the container itself
+	if c.CopyFiles == nil && c.Isolation == IsolationChroot {
+		c.CopyFiles = []string{"/etc/resolv.conf"}
+	}
+
+	if c.CommandWrapper == "" {
+		c.CommandWrapper = "{{.Command}}"
+	}
+
+	if c.OutputType == "" {
+		c.OutputType = OutputTar
+	}
+
+	if c.OutputPath == "" {
+		c.OutputPath = fmt.Sprintf("output-%s", c.PackerBuildName)
+		if c.OutputType == OutputTar {
+			c.OutputPath += ".tar"
+		}
+	}
+
+	if c.Source == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("source is required."))
+	} else if _, err := os.Stat(c.Source); err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("source is invalid: %s", err))
+	}
+
+	switch c.Isolation {
+	case IsolationChroot, IsolationNspawn:
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("isolation must be one of: %s, %s", IsolationChroot, IsolationNspawn))
+	}
+
+	if c.Isolation == IsolationChroot && len(c.NspawnArgs) > 0 {
+		warns = append(warns, "nspawn_args is unused when isolation is chroot")
+	}
+
+	for _, mounts := range c.ChrootMounts {
+		if len(mounts) != 3 {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("Each chroot_mounts entry should be three elements."))
+			break
+		}
+	}
+
+	switch c.OutputType {
+	case OutputTar:
+	case OutputDirectory:
+		if c.RootfsDir != "" {
+			warns = append(warns, "rootfs_dir is unused when output_type is directory")
+		}
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("output_type must be one of: %s, %s", OutputTar, OutputDirectory))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, warns, errs
+	}
+
+	return &c, warns, nil
+}
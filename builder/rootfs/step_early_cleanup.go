@@ -0,0 +1,43 @@
+package rootfs
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepEarlyCleanup removes the copied files and unmounts the additional
+// paths before the root filesystem is exported.
+type StepEarlyCleanup struct{}
+
+func (s *StepEarlyCleanup) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get("ui").(packer.Ui)
+	cleanupKeys := []string{
+		"copy_files_cleanup",
+		"mount_extra_cleanup",
+	}
+
+	for _, key := range cleanupKeys {
+		// Paths are only mounted within a chroot
+		raw, ok := state.GetOk(key)
+		if !ok {
+			continue
+		}
+
+		log.Printf("Running cleanup func: %s", key)
+		if err := raw.(chroot.Cleanup).CleanupFunc(state); err != nil {
+			err := fmt.Errorf("Error cleaning up: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepEarlyCleanup) Cleanup(state multistep.StateBag) {}
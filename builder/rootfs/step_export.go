@@ -0,0 +1,71 @@
+package rootfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepExport saves the root filesystem as a tarball, which is compressed
+// according to the extension of its name. Nothing is done if the output is
+// a directory, since the root filesystem was built there.
+type StepExport struct{}
+
+func (s *StepExport) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	mountPath := state.Get("mount_path").(string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	if config.OutputType != OutputTar {
+		return multistep.ActionContinue
+	}
+
+	output, err := filepath.Abs(config.OutputPath)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	if _, err := os.Stat(output); err == nil {
+		if !config.PackerForce {
+			err := fmt.Errorf(
+				"Output file exists: %s\n\n"+
+					"Use the force flag to delete it prior to building.",
+				output)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		ui.Say("Deleting previous output file...")
+		os.Remove(output)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		err := fmt.Errorf("Error creating output directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Exporting root filesystem to %s...", output))
+	command := fmt.Sprintf("tar --numeric-owner -cpaf '%s' -C '%s' .", output, mountPath)
+	if _, err := chroot.RunCommand(wrappedCommand, command); err != nil {
+		os.Remove(output)
+		err := fmt.Errorf("Error exporting root filesystem: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepExport) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,84 @@
+package rootfs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepExtractSource_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExtractSource)
+}
+
+func TestStepExport_impl(t *testing.T) {
+	var _ multistep.Step = new(StepExport)
+}
+
+// TestStepExport extracts a tarball, copies the result as a directory and
+// exports it again.
+func TestStepExport(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not found")
+	}
+
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	src := filepath.Join(td, "src")
+	os.MkdirAll(filepath.Join(src, "etc"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "etc", "hostname"), []byte("rootfs"), 0600)
+	os.Symlink("etc/hostname", filepath.Join(src, "hostname"))
+	source := filepath.Join(td, "source.tar.gz")
+	if out, err := exec.Command("tar", "-czf", source, "-C", src, ".").CombinedOutput(); err != nil {
+		t.Fatalf("err: %s: %s", err, out)
+	}
+
+	for i, source := range []string{source, src} {
+		rootfs := filepath.Join(td, "rootfs", fmt.Sprint(i))
+		os.MkdirAll(rootfs, 0755)
+		output := filepath.Join(td, "output", fmt.Sprint(i)+".tar")
+
+		state := testState(t, &Config{Source: source, OutputType: OutputTar, OutputPath: output})
+		state.Put("mount_path", rootfs)
+		for _, step := range []multistep.Step{new(StepExtractSource), new(StepExport)} {
+			if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("%s: bad action: %#v: %s", source, action, state.Get("error"))
+			}
+		}
+
+		fi, err := os.Stat(filepath.Join(rootfs, "etc", "hostname"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if fi.Mode().Perm() != 0600 {
+			t.Fatalf("bad: %s", fi.Mode())
+		}
+		if target, err := os.Readlink(filepath.Join(rootfs, "hostname")); err != nil || target != "etc/hostname" {
+			t.Fatalf("bad: %s %v", target, err)
+		}
+
+		out, err := exec.Command("tar", "-tf", output).CombinedOutput()
+		if err != nil {
+			t.Fatalf("err: %s: %s", err, out)
+		}
+		if !strings.Contains(string(out), "./etc/hostname\n") {
+			t.Fatalf("bad: %s", out)
+		}
+
+		// The output isn't overwritten unless forced
+		state.Put("mount_path", rootfs)
+		if action := new(StepExport).Run(context.Background(), state); action != multistep.ActionHalt {
+			t.Fatalf("bad action: %#v", action)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package rootfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepExtractSource copies the source directory, or extracts the source
+// tarball, into the directory of the root filesystem. Ownership and modes
+// are preserved, which usually requires to run as root.
+type StepExtractSource struct{}
+
+func (s *StepExtractSource) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	mountPath := state.Get("mount_path").(string)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	source, err := filepath.Abs(config.Source)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	fi, err := os.Stat(source)
+	if err != nil {
+		err := fmt.Errorf("Error reading source: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	var command string
+	if fi.IsDir() {
+		ui.Say(fmt.Sprintf("Copying source directory: %s", source))
+		command = fmt.Sprintf("cp -a '%s/.' '%s'", source, mountPath)
+	} else {
+		// tar detects the compression of the tarball
+		ui.Say(fmt.Sprintf("Extracting source tarball: %s", source))
+		command = fmt.Sprintf("tar --numeric-owner -xpf '%s' -C '%s'", source, mountPath)
+	}
+
+	if _, err := chroot.RunCommand(wrappedCommand, command); err != nil {
+		err := fmt.Errorf("Error extracting source: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepExtractSource) Cleanup(state multistep.StateBag) {}
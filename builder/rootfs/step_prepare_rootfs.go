@@ -0,0 +1,119 @@
+package rootfs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// StepPrepareRootfs creates the directory the root filesystem is unpacked
+// into, which is the output directory if the output is a directory. The
+// directory is deleted at the end, unless it is the output of a successful
+// build.
+//
+// Produces:
+//   mount_path string - The directory of the root filesystem.
+type StepPrepareRootfs struct {
+	dir    string
+	tmpDir string
+	output bool
+}
+
+func (s *StepPrepareRootfs) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*Config)
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	var dir string
+	switch {
+	case config.OutputType == OutputDirectory:
+		dir = config.OutputPath
+		if _, err := os.Stat(dir); err == nil {
+			if !config.PackerForce {
+				err := fmt.Errorf(
+					"Output directory exists: %s\n\n"+
+						"Use the force flag to delete it prior to building.",
+					dir)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			ui.Say("Deleting previous output directory...")
+			if err := removeRootfs(wrappedCommand, dir); err != nil {
+				err := fmt.Errorf("Error deleting output directory: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+		s.output = true
+	case config.RootfsDir != "":
+		dir = config.RootfsDir
+		if _, err := os.Stat(dir); err == nil {
+			err := fmt.Errorf("rootfs_dir already exists: %s", dir)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	default:
+		tmpDir, err := ioutil.TempDir("", "packer-rootfs")
+		if err != nil {
+			err := fmt.Errorf("Error creating temporary directory: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+		s.tmpDir = tmpDir
+		dir = filepath.Join(tmpDir, "rootfs")
+	}
+
+	// The commands of the chroot need the absolute path
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Say(fmt.Sprintf("Creating root filesystem directory: %s", dir))
+	if _, err := chroot.RunCommand(wrappedCommand, fmt.Sprintf("mkdir -p -m 0755 '%s'", dir)); err != nil {
+		err := fmt.Errorf("Error creating root filesystem directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	s.dir = dir
+
+	state.Put("mount_path", dir)
+	return multistep.ActionContinue
+}
+
+func (s *StepPrepareRootfs) Cleanup(state multistep.StateBag) {
+	if s.tmpDir != "" {
+		defer os.Remove(s.tmpDir)
+	}
+	if s.dir == "" {
+		return
+	}
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if s.output && !cancelled && !halted {
+		return
+	}
+
+	ui := state.Get("ui").(packer.Ui)
+	wrappedCommand := state.Get("wrappedCommand").(chroot.CommandWrapper)
+
+	ui.Say("Deleting root filesystem directory...")
+	if err := removeRootfs(wrappedCommand, s.dir); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting root filesystem directory: %s", err))
+	}
+}
@@ -0,0 +1,131 @@
+package rootfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepPrepareRootfs_impl(t *testing.T) {
+	var _ multistep.Step = new(StepPrepareRootfs)
+}
+
+func TestStepPrepareRootfs_tempDir(t *testing.T) {
+	state := testState(t, &Config{OutputType: OutputTar})
+	step := new(StepPrepareRootfs)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	dir := state.Get("mount_path").(string)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !fi.IsDir() || fi.Mode().Perm() != 0755 {
+		t.Fatalf("bad: %s", fi.Mode())
+	}
+
+	// The root filesystem of a tarball is always deleted
+	step.Cleanup(state)
+	if _, err := os.Stat(filepath.Dir(dir)); !os.IsNotExist(err) {
+		t.Fatalf("should be deleted: %s", dir)
+	}
+}
+
+func TestStepPrepareRootfs_rootfsDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	state := testState(t, &Config{OutputType: OutputTar, RootfsDir: td})
+	step := new(StepPrepareRootfs)
+
+	// The directory must not exist
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	step.Cleanup(state)
+	if _, err := os.Stat(td); err != nil {
+		t.Fatal("rootfs_dir should not be deleted")
+	}
+
+	dir := filepath.Join(td, "rootfs")
+	state = testState(t, &Config{OutputType: OutputTar, RootfsDir: dir})
+	step = new(StepPrepareRootfs)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if state.Get("mount_path").(string) != dir {
+		t.Fatalf("bad: %s", state.Get("mount_path"))
+	}
+	step.Cleanup(state)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatal("should be deleted")
+	}
+}
+
+func TestStepPrepareRootfs_outputDirectory(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	config := &Config{OutputType: OutputDirectory, OutputPath: td}
+	state := testState(t, config)
+	step := new(StepPrepareRootfs)
+
+	// The output must not exist, unless forced
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	ioutil.WriteFile(filepath.Join(td, "previous"), []byte("data"), 0644)
+	config.PackerForce = true
+	state = testState(t, config)
+	step = new(StepPrepareRootfs)
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, err := os.Stat(filepath.Join(td, "previous")); !os.IsNotExist(err) {
+		t.Fatal("the previous output should be deleted")
+	}
+
+	// The output of a successful build is kept
+	step.Cleanup(state)
+	if _, err := os.Stat(td); err != nil {
+		t.Fatal("the output should be kept")
+	}
+
+	state.Put(multistep.StateHalted, true)
+	step.Cleanup(state)
+	if _, err := os.Stat(td); !os.IsNotExist(err) {
+		t.Fatal("the output of a failed build should be deleted")
+	}
+}
+
+func TestMountsUnder(t *testing.T) {
+	if _, err := os.Stat("/proc/mounts"); err != nil {
+		t.Skip("/proc/mounts not found")
+	}
+
+	mounts, err := mountsUnder("/proc")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(mounts) == 0 || mounts[0] != "/proc" {
+		t.Fatalf("bad: %#v", mounts)
+	}
+
+	if err := removeRootfs(nil, "/proc/"); err == nil {
+		t.Fatal("should not delete mounts")
+	}
+}
@@ -0,0 +1,24 @@
+package rootfs
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/packer/common/chroot"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+func testState(t *testing.T, config *Config) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", config)
+	state.Put("hook", &packer.MockHook{})
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	state.Put("wrappedCommand", chroot.CommandWrapper(func(command string) (string, error) {
+		return command, nil
+	}))
+	return state
+}
@@ -14,13 +14,21 @@ const BuilderId = "mitchellh.virtualbox"
 // Artifact is the result of running the VirtualBox builder, namely a set
 // of files associated with the resulting machine.
 type artifact struct {
-	dir string
-	f   []string
+	dir   string
+	f     []string
+	state map[string]interface{}
 }
 
 // NewArtifact returns a VirtualBox artifact containing the files
 // in the given directory.
 func NewArtifact(dir string) (packer.Artifact, error) {
+	return NewArtifactWithState(dir, nil)
+}
+
+// NewArtifactWithState is like NewArtifact, but the artifact also
+// exposes the given values through State, such as the name of a VM
+// that was left registered and the snapshot taken of it.
+func NewArtifactWithState(dir string, state map[string]interface{}) (packer.Artifact, error) {
 	files := make([]string, 0, 5)
 	visit := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -38,8 +46,9 @@ func NewArtifact(dir string) (packer.Artifact, error) {
 	}
 
 	return &artifact{
-		dir: dir,
-		f:   files,
+		dir:   dir,
+		f:     files,
+		state: state,
 	}, nil
 }
 
@@ -60,7 +69,7 @@ func (a *artifact) String() string {
 }
 
 func (a *artifact) State(name string) interface{} {
-	return nil
+	return a.state[name]
 }
 
 func (a *artifact) Destroy() error {
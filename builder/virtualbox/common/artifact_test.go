@@ -41,3 +41,29 @@ func TestNewArtifact(t *testing.T) {
 		t.Fatalf("should length 1: %d", len(a.Files()))
 	}
 }
+
+func TestNewArtifactWithState(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	a, err := NewArtifactWithState(td, map[string]interface{}{
+		"vmName":       "web",
+		"snapshotName": "provisioned",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if a.State("vmName") != "web" {
+		t.Fatalf("bad: %#v", a.State("vmName"))
+	}
+	if a.State("snapshotName") != "provisioned" {
+		t.Fatalf("bad: %#v", a.State("snapshotName"))
+	}
+	if a.State("foo") != nil {
+		t.Fatalf("bad: %#v", a.State("foo"))
+	}
+}
@@ -16,12 +16,20 @@ import (
 // versions out of the builder steps, so sometimes the methods are
 // extremely specific.
 type Driver interface {
+	// Clone a registered VM, or one of its snapshots, into a new VM that
+	// is registered with the given name. A linked clone shares the disks
+	// of the snapshot instead of copying them.
+	Clone(name string, source string, snapshot string, linked bool) error
+
 	// Create a SATA controller.
 	CreateSATAController(vm string, controller string, portcount int) error
 
 	// Create a SCSI controller.
 	CreateSCSIController(vm string, controller string) error
 
+	// Take a snapshot of a VM with the given name.
+	CreateSnapshot(vm string, snapshot string) error
+
 	// Delete a VM by name
 	Delete(string) error
 
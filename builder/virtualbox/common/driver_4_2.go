@@ -18,6 +18,22 @@ type VBox42Driver struct {
 	VBoxManagePath string
 }
 
+func (d *VBox42Driver) Clone(name string, source string, snapshot string, linked bool) error {
+	return d.VBoxManage(cloneArgs(name, source, snapshot, linked)...)
+}
+
+func cloneArgs(name string, source string, snapshot string, linked bool) []string {
+	args := []string{"clonevm", source, "--name", name, "--register"}
+	if snapshot != "" {
+		args = append(args, "--snapshot", snapshot)
+	}
+	if linked {
+		args = append(args, "--options", "link")
+	}
+
+	return args
+}
+
 func (d *VBox42Driver) CreateSATAController(vmName string, name string, portcount int) error {
 	version, err := d.Version()
 	if err != nil {
@@ -51,6 +67,10 @@ func (d *VBox42Driver) CreateSCSIController(vmName string, name string) error {
 	return d.VBoxManage(command...)
 }
 
+func (d *VBox42Driver) CreateSnapshot(vmName string, snapshot string) error {
+	return d.VBoxManage("snapshot", vmName, "take", snapshot)
+}
+
 func (d *VBox42Driver) Delete(name string) error {
 	return packer.Retry(1, 1, 5, func(i uint) (bool, error) {
 		if err := d.VBoxManage("unregistervm", name, "--delete"); err != nil {
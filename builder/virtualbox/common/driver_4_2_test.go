@@ -1,9 +1,27 @@
 package common
 
 import (
+	"reflect"
 	"testing"
 )
 
 func TestVBox42Driver_impl(t *testing.T) {
 	var _ Driver = new(VBox42Driver)
 }
+
+func TestVBox42Driver_cloneArgs(t *testing.T) {
+	args := cloneArgs("packer", "base", "", false)
+	expected := []string{"clonevm", "base", "--name", "packer", "--register"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	args = cloneArgs("packer", "base", "clean", true)
+	expected = []string{
+		"clonevm", "base", "--name", "packer", "--register",
+		"--snapshot", "clean", "--options", "link",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
@@ -5,6 +5,13 @@ import "sync"
 type DriverMock struct {
 	sync.Mutex
 
+	CloneCalled   bool
+	CloneName     string
+	CloneSource   string
+	CloneSnapshot string
+	CloneLinked   bool
+	CloneErr      error
+
 	CreateSATAControllerVM         string
 	CreateSATAControllerController string
 	CreateSATAControllerErr        error
@@ -13,6 +20,11 @@ type DriverMock struct {
 	CreateSCSIControllerController string
 	CreateSCSIControllerErr        error
 
+	CreateSnapshotCalled   bool
+	CreateSnapshotVM       string
+	CreateSnapshotSnapshot string
+	CreateSnapshotErr      error
+
 	DeleteCalled bool
 	DeleteName   string
 	DeleteErr    error
@@ -47,6 +59,15 @@ type DriverMock struct {
 	VersionErr    error
 }
 
+func (d *DriverMock) Clone(name string, source string, snapshot string, linked bool) error {
+	d.CloneCalled = true
+	d.CloneName = name
+	d.CloneSource = source
+	d.CloneSnapshot = snapshot
+	d.CloneLinked = linked
+	return d.CloneErr
+}
+
 func (d *DriverMock) CreateSATAController(vm string, controller string, portcount int) error {
 	d.CreateSATAControllerVM = vm
 	d.CreateSATAControllerController = vm
@@ -59,6 +80,13 @@ func (d *DriverMock) CreateSCSIController(vm string, controller string) error {
 	return d.CreateSCSIControllerErr
 }
 
+func (d *DriverMock) CreateSnapshot(vm string, snapshot string) error {
+	d.CreateSnapshotCalled = true
+	d.CreateSnapshotVM = vm
+	d.CreateSnapshotSnapshot = snapshot
+	return d.CreateSnapshotErr
+}
+
 func (d *DriverMock) Delete(name string) error {
 	d.DeleteCalled = true
 	d.DeleteName = name
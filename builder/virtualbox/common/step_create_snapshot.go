@@ -0,0 +1,60 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step cleans up forwarded ports and takes a snapshot of the VM, so
+// that later builds can clone it.
+//
+// Uses:
+//   driver Driver
+//   ui packer.Ui
+//   vmName string
+//
+// Produces:
+type StepCreateSnapshot struct {
+	Name           string
+	SkipNatMapping bool
+}
+
+func (s *StepCreateSnapshot) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Name == "" {
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	vmName := state.Get("vmName").(string)
+
+	// Clear out the Packer-created forwarding rule, so that builds cloning
+	// the snapshot can create theirs
+	sshPort := state.Get("sshHostPort")
+	if !s.SkipNatMapping && sshPort != 0 {
+		ui.Message(fmt.Sprintf(
+			"Deleting forwarded port mapping for the communicator (SSH, WinRM, etc) (host port %d)", sshPort))
+		command := []string{"modifyvm", vmName, "--natpf1", "delete", "packercomm"}
+		if err := driver.VBoxManage(command...); err != nil {
+			err := fmt.Errorf("Error deleting port forwarding rule: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Say(fmt.Sprintf("Taking snapshot of the virtual machine: %s", s.Name))
+	if err := driver.CreateSnapshot(vmName, s.Name); err != nil {
+		err := fmt.Errorf("Error taking snapshot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCreateSnapshot) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCreateSnapshot_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateSnapshot)
+}
+
+func TestStepCreateSnapshot(t *testing.T) {
+	state := testState(t)
+	step := &StepCreateSnapshot{Name: "provisioned"}
+
+	state.Put("sshHostPort", 2222)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	if driver.CreateSnapshotVM != "foo" || driver.CreateSnapshotSnapshot != "provisioned" {
+		t.Fatalf("bad: %#v", driver)
+	}
+
+	// Test that the port forwarding rule was deleted
+	expected := []string{"modifyvm", "foo", "--natpf1", "delete", "packercomm"}
+	if len(driver.VBoxManageCalls) != 1 || !reflect.DeepEqual(driver.VBoxManageCalls[0], expected) {
+		t.Fatalf("bad: %#v", driver.VBoxManageCalls)
+	}
+}
+
+func TestStepCreateSnapshot_noName(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateSnapshot)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if driver.CreateSnapshotCalled {
+		t.Fatal("should not take a snapshot")
+	}
+}
+
+func TestStepCreateSnapshot_error(t *testing.T) {
+	state := testState(t)
+	step := &StepCreateSnapshot{Name: "provisioned", SkipNatMapping: true}
+
+	state.Put("sshHostPort", 2222)
+	state.Put("vmName", "foo")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.CreateSnapshotErr = errors.New("error")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
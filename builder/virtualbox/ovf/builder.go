@@ -70,19 +70,35 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			GuestAdditionsSHA256: b.config.GuestAdditionsSHA256,
 			Ctx:                  b.config.ctx,
 		},
-		&common.StepDownload{
-			Checksum:     b.config.Checksum,
-			ChecksumType: b.config.ChecksumType,
-			Description:  "OVF/OVA",
-			Extension:    "ova",
-			ResultKey:    "vm_path",
-			TargetPath:   b.config.TargetPath,
-			Url:          []string{b.config.SourcePath},
-		},
-		&StepImport{
-			Name:        b.config.VMName,
-			ImportFlags: b.config.ImportFlags,
-		},
+	}
+
+	// The VM is either imported from an OVF or cloned from a registered VM.
+	if b.config.SourceVM != "" {
+		steps = append(steps, &StepCloneVM{
+			Name:     b.config.VMName,
+			SourceVM: b.config.SourceVM,
+			Snapshot: b.config.SourceSnapshot,
+			Linked:   b.config.LinkedClone,
+		})
+	} else {
+		steps = append(steps,
+			&common.StepDownload{
+				Checksum:     b.config.Checksum,
+				ChecksumType: b.config.ChecksumType,
+				Description:  "OVF/OVA",
+				Extension:    "ova",
+				ResultKey:    "vm_path",
+				TargetPath:   b.config.TargetPath,
+				Url:          []string{b.config.SourcePath},
+			},
+			&StepImport{
+				Name:        b.config.VMName,
+				ImportFlags: b.config.ImportFlags,
+			},
+		)
+	}
+
+	steps = append(steps,
 		&vboxcommon.StepAttachGuestAdditions{
 			GuestAdditionsMode: b.config.GuestAdditionsMode,
 		},
@@ -137,6 +153,10 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Commands: b.config.VBoxManagePost,
 			Ctx:      b.config.ctx,
 		},
+		&vboxcommon.StepCreateSnapshot{
+			Name:           b.config.TargetSnapshot,
+			SkipNatMapping: b.config.SSHSkipNatMapping,
+		},
 		&vboxcommon.StepExport{
 			Format:         b.config.Format,
 			OutputDir:      b.config.OutputDir,
@@ -144,7 +164,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			SkipNatMapping: b.config.SSHSkipNatMapping,
 			SkipExport:     b.config.SkipExport,
 		},
-	}
+	)
 
 	// Run the steps.
 	b.runner = common.NewRunnerWithPauseFn(steps, b.config.PackerConfig, ui, state)
@@ -164,7 +184,22 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return nil, errors.New("Build was halted.")
 	}
 
-	return vboxcommon.NewArtifact(b.config.OutputDir)
+	artifactState := make(map[string]interface{})
+	if b.config.KeepRegistered {
+		artifactState["vmName"] = state.Get("vmName").(string)
+	}
+	if b.config.TargetSnapshot != "" {
+		artifactState["snapshotName"] = b.config.TargetSnapshot
+	}
+
+	return vboxcommon.NewArtifactWithState(b.config.OutputDir, artifactState)
+}
+
+// PlanOutputs tells build plans about the output directory, which -force deletes.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output_directory", Default: "output-{{build_name}}", Local: true},
+	}
 }
 
 // Cancel.
@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
+	"github.com/mitchellh/mapstructure"
 )
 
 // Config is the configuration structure for the builder.
@@ -36,8 +37,12 @@ type Config struct {
 	GuestAdditionsURL    string   `mapstructure:"guest_additions_url"`
 	ImportFlags          []string `mapstructure:"import_flags"`
 	ImportOpts           string   `mapstructure:"import_opts"`
+	LinkedClone          bool     `mapstructure:"linked_clone"`
 	SourcePath           string   `mapstructure:"source_path"`
+	SourceSnapshot       string   `mapstructure:"source_snapshot"`
+	SourceVM             string   `mapstructure:"source_vm"`
 	TargetPath           string   `mapstructure:"target_path"`
+	TargetSnapshot       string   `mapstructure:"target_snapshot"`
 	VMName               string   `mapstructure:"vm_name"`
 	KeepRegistered       bool     `mapstructure:"keep_registered"`
 	SkipExport           bool     `mapstructure:"skip_export"`
@@ -47,7 +52,10 @@ type Config struct {
 
 func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	c := new(Config)
+
+	var md mapstructure.Metadata
 	err := config.Decode(c, &config.DecodeOpts{
+		Metadata:           &md,
 		Interpolate:        true,
 		InterpolateContext: &c.ctx,
 		InterpolateFilter: &interpolate.RenderFilter{
@@ -96,8 +104,13 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 	c.ChecksumType = strings.ToLower(c.ChecksumType)
 	c.Checksum = strings.ToLower(c.Checksum)
 
-	if c.SourcePath == "" {
-		errs = packer.MultiErrorAppend(errs, fmt.Errorf("source_path is required"))
+	if c.SourceVM != "" {
+		if c.SourcePath != "" {
+			errs = packer.MultiErrorAppend(errs,
+				fmt.Errorf("Only one of source_path or source_vm can be specified"))
+		}
+	} else if c.SourcePath == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("source_path or source_vm is required"))
 	} else {
 		c.SourcePath, err = common.ValidatedURL(c.SourcePath)
 		if err != nil {
@@ -111,6 +124,33 @@ func NewConfig(raws ...interface{}) (*Config, []string, error) {
 
 	}
 
+	if c.SourceVM == "" && c.SourceSnapshot != "" {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("source_snapshot can only be used with source_vm"))
+	}
+
+	if c.LinkedClone && c.SourceSnapshot == "" {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("linked_clone requires a source_snapshot"))
+	}
+
+	// The snapshot is taken instead of exporting, and it is only useful if
+	// the VM stays registered.
+	if c.TargetSnapshot != "" {
+		for _, k := range md.Keys {
+			switch {
+			case k == "skip_export" && !c.SkipExport:
+				errs = packer.MultiErrorAppend(errs,
+					fmt.Errorf("target_snapshot can't be used with skip_export set to false"))
+			case k == "keep_registered" && !c.KeepRegistered:
+				errs = packer.MultiErrorAppend(errs,
+					fmt.Errorf("target_snapshot can't be used with keep_registered set to false"))
+			}
+		}
+		c.SkipExport = true
+		c.KeepRegistered = true
+	}
+
 	validMode := false
 	validModes := []string{
 		vboxcommon.GuestAdditionsModeDisable,
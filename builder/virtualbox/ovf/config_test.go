@@ -128,3 +128,79 @@ func TestNewConfig_shutdown_timeout(t *testing.T) {
 		t.Fatalf("bad: %s", err)
 	}
 }
+
+func TestNewConfig_sourceVM(t *testing.T) {
+	// Good
+	c := testConfig(t)
+	delete(c, "source_path")
+	c["source_vm"] = "base"
+	_, warns, err := NewConfig(c)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// Bad, both sources are set
+	c = testConfig(t)
+	c["source_vm"] = "base"
+	_, _, err = NewConfig(c)
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	// Bad, a snapshot without a source VM
+	c = testConfig(t)
+	c["source_snapshot"] = "clean"
+	_, _, err = NewConfig(c)
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestNewConfig_linkedClone(t *testing.T) {
+	c := testConfig(t)
+	delete(c, "source_path")
+	c["source_vm"] = "base"
+	c["linked_clone"] = true
+	_, _, err := NewConfig(c)
+	if err == nil {
+		t.Fatal("should error without a snapshot")
+	}
+
+	c["source_snapshot"] = "clean"
+	_, _, err = NewConfig(c)
+	if err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestNewConfig_targetSnapshot(t *testing.T) {
+	c := testConfig(t)
+	c["target_snapshot"] = "provisioned"
+	config, _, err := NewConfig(c)
+	if err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if !config.SkipExport || !config.KeepRegistered {
+		t.Fatalf("the snapshot should replace the export: %#v", config)
+	}
+}
+
+func TestNewConfig_targetSnapshotConflicts(t *testing.T) {
+	for _, key := range []string{"skip_export", "keep_registered"} {
+		c := testConfig(t)
+		c["target_snapshot"] = "provisioned"
+		c[key] = false
+		if _, _, err := NewConfig(c); err == nil {
+			t.Fatalf("%s set to false should error", key)
+		}
+
+		c[key] = true
+		if _, _, err := NewConfig(c); err != nil {
+			t.Fatalf("%s set to true: %s", key, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ovf
+
+import (
+	"context"
+	"fmt"
+
+	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step clones a registered VM, or one of its snapshots, instead of
+// importing an OVF.
+type StepCloneVM struct {
+	Name     string
+	SourceVM string
+	Snapshot string
+	Linked   bool
+
+	vmName string
+}
+
+func (s *StepCloneVM) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	driver := state.Get("driver").(vboxcommon.Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	if s.Snapshot != "" {
+		ui.Say(fmt.Sprintf("Cloning VM %s from snapshot: %s", s.SourceVM, s.Snapshot))
+	} else {
+		ui.Say(fmt.Sprintf("Cloning VM: %s", s.SourceVM))
+	}
+	if err := driver.Clone(s.Name, s.SourceVM, s.Snapshot, s.Linked); err != nil {
+		err := fmt.Errorf("Error cloning VM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	s.vmName = s.Name
+	state.Put("vmName", s.Name)
+	return multistep.ActionContinue
+}
+
+func (s *StepCloneVM) Cleanup(state multistep.StateBag) {
+	if s.vmName == "" {
+		return
+	}
+
+	driver := state.Get("driver").(vboxcommon.Driver)
+	ui := state.Get("ui").(packer.Ui)
+	config := state.Get("config").(*Config)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if (config.KeepRegistered) && (!cancelled && !halted) {
+		ui.Say("Keeping virtual machine registered with VirtualBox host (keep_registered = true)")
+		return
+	}
+
+	ui.Say("Deregistering and deleting cloned VM...")
+	if err := driver.Delete(s.vmName); err != nil {
+		ui.Error(fmt.Sprintf("Error deleting VM: %s", err))
+	}
+}
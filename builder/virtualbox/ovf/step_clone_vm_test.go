@@ -0,0 +1,95 @@
+package ovf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	vboxcommon "github.com/hashicorp/packer/builder/virtualbox/common"
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCloneVM_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCloneVM)
+}
+
+func TestStepCloneVM(t *testing.T) {
+	state := testState(t)
+	c := testConfig(t)
+	delete(c, "source_path")
+	c["source_vm"] = "base"
+	c["source_snapshot"] = "clean"
+	c["linked_clone"] = true
+	config, _, err := NewConfig(c)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state.Put("config", config)
+	step := &StepCloneVM{
+		Name:     "bar",
+		SourceVM: config.SourceVM,
+		Snapshot: config.SourceSnapshot,
+		Linked:   config.LinkedClone,
+	}
+
+	driver := state.Get("driver").(*vboxcommon.DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Test driver
+	if driver.CloneName != "bar" || driver.CloneSource != "base" ||
+		driver.CloneSnapshot != "clean" || !driver.CloneLinked {
+		t.Fatalf("bad: %#v", driver)
+	}
+
+	// Test output state
+	if name, ok := state.GetOk("vmName"); !ok {
+		t.Fatal("vmName should be set")
+	} else if name != "bar" {
+		t.Fatalf("bad: %#v", name)
+	}
+
+	// Test cleanup
+	config.KeepRegistered = true
+	step.Cleanup(state)
+
+	if driver.DeleteCalled {
+		t.Fatal("delete should not be called")
+	}
+
+	config.KeepRegistered = false
+	step.Cleanup(state)
+	if !driver.DeleteCalled {
+		t.Fatal("delete should be called")
+	}
+	if driver.DeleteName != "bar" {
+		t.Fatalf("bad: %#v", driver.DeleteName)
+	}
+}
+
+func TestStepCloneVM_error(t *testing.T) {
+	state := testState(t)
+	step := &StepCloneVM{Name: "bar", SourceVM: "base"}
+
+	driver := state.Get("driver").(*vboxcommon.DriverMock)
+	driver.CloneErr = errors.New("error")
+
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+
+	// Nothing was cloned, so nothing is deleted
+	step.Cleanup(state)
+	if driver.DeleteCalled {
+		t.Fatal("delete should not be called")
+	}
+}
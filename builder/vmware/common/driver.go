@@ -22,8 +22,9 @@ import (
 type Driver interface {
 	// Clone clones the VMX and the disk to the destination path. The
 	// destination is a path to the VMX file. The disk will be copied
-	// to that same directory.
-	Clone(dst string, src string, cloneType bool) error
+	// to that same directory. If a snapshot is given, the clone is made
+	// from that snapshot of the source instead of its current state.
+	Clone(dst string, src string, cloneType bool, snapshot string) error
 
 	// CompactDisk compacts a virtual disk.
 	CompactDisk(string) error
@@ -31,6 +32,10 @@ type Driver interface {
 	// CreateDisk creates a virtual disk with the given size.
 	CreateDisk(string, string, string, string) error
 
+	// CreateSnapshot takes a snapshot with the given name of the VM
+	// specified by the path to the VMX given.
+	CreateSnapshot(string, string) error
+
 	// Checks if the VMX file at the given path is running.
 	IsRunning(string) (bool, error)
 
@@ -24,7 +24,7 @@ type Fusion5Driver struct {
 	SSHConfig *SSHConfig
 }
 
-func (d *Fusion5Driver) Clone(dst, src string, linked bool) error {
+func (d *Fusion5Driver) Clone(dst, src string, linked bool, snapshot string) error {
 	return errors.New("Cloning is not supported with Fusion 5. Please use Fusion 6+.")
 }
 
@@ -51,6 +51,15 @@ func (d *Fusion5Driver) CreateDisk(output string, size string, adapter_type stri
 	return nil
 }
 
+func (d *Fusion5Driver) CreateSnapshot(vmxPath string, name string) error {
+	cmd := exec.Command(d.vmrunPath(), "-T", "fusion", "snapshot", vmxPath, name)
+	if _, _, err := runAndLog(cmd); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (d *Fusion5Driver) IsRunning(vmxPath string) (bool, error) {
 	vmxPath, err := filepath.Abs(vmxPath)
 	if err != nil {
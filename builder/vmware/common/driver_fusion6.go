@@ -18,7 +18,7 @@ type Fusion6Driver struct {
 	Fusion5Driver
 }
 
-func (d *Fusion6Driver) Clone(dst, src string, linked bool) error {
+func (d *Fusion6Driver) Clone(dst, src string, linked bool, snapshot string) error {
 
 	var cloneType string
 	if linked {
@@ -31,6 +31,9 @@ func (d *Fusion6Driver) Clone(dst, src string, linked bool) error {
 		"-T", "fusion",
 		"clone", src, dst,
 		cloneType)
+	if snapshot != "" {
+		cmd.Args = append(cmd.Args, "-snapshot="+snapshot)
+	}
 	if _, _, err := runAndLog(cmd); err != nil {
 		if strings.Contains(err.Error(), "parameters was invalid") {
 			return fmt.Errorf(
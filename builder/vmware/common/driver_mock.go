@@ -10,11 +10,12 @@ import (
 type DriverMock struct {
 	sync.Mutex
 
-	CloneCalled bool
-	CloneDst    string
-	CloneSrc    string
-	Linked      bool
-	CloneErr    error
+	CloneCalled   bool
+	CloneDst      string
+	CloneSrc      string
+	Linked        bool
+	CloneSnapshot string
+	CloneErr      error
 
 	CompactDiskCalled bool
 	CompactDiskPath   string
@@ -27,6 +28,11 @@ type DriverMock struct {
 	CreateDiskTypeId      string
 	CreateDiskErr         error
 
+	CreateSnapshotCalled  bool
+	CreateSnapshotVMXPath string
+	CreateSnapshotName    string
+	CreateSnapshotErr     error
+
 	IsRunningCalled bool
 	IsRunningPath   string
 	IsRunningResult bool
@@ -108,11 +114,12 @@ func (m NetworkMapperMock) DeviceIntoName(device string) (string, error) {
 	return "", nil
 }
 
-func (d *DriverMock) Clone(dst string, src string, linked bool) error {
+func (d *DriverMock) Clone(dst string, src string, linked bool, snapshot string) error {
 	d.CloneCalled = true
 	d.CloneDst = dst
 	d.CloneSrc = src
 	d.Linked = linked
+	d.CloneSnapshot = snapshot
 	return d.CloneErr
 }
 
@@ -131,6 +138,13 @@ func (d *DriverMock) CreateDisk(output string, size string, adapterType string,
 	return d.CreateDiskErr
 }
 
+func (d *DriverMock) CreateSnapshot(vmxPath string, name string) error {
+	d.CreateSnapshotCalled = true
+	d.CreateSnapshotVMXPath = vmxPath
+	d.CreateSnapshotName = name
+	return d.CreateSnapshotErr
+}
+
 func (d *DriverMock) IsRunning(path string) (bool, error) {
 	d.Lock()
 	defer d.Unlock()
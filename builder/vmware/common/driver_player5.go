@@ -25,7 +25,7 @@ type Player5Driver struct {
 	SSHConfig *SSHConfig
 }
 
-func (d *Player5Driver) Clone(dst, src string, linked bool) error {
+func (d *Player5Driver) Clone(dst, src string, linked bool, snapshot string) error {
 	return errors.New("Cloning is not supported with VMWare Player version 5. Please use VMWare Player version 6, or greater.")
 }
 
@@ -78,6 +78,10 @@ func (d *Player5Driver) CreateDisk(output string, size string, adapter_type stri
 	return nil
 }
 
+func (d *Player5Driver) CreateSnapshot(vmxPath string, name string) error {
+	return errors.New("Snapshots are not supported with VMware Player.")
+}
+
 func (d *Player5Driver) IsRunning(vmxPath string) (bool, error) {
 	vmxPath, err := filepath.Abs(vmxPath)
 	if err != nil {
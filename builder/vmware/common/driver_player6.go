@@ -13,7 +13,7 @@ type Player6Driver struct {
 	Player5Driver
 }
 
-func (d *Player6Driver) Clone(dst, src string, linked bool) error {
+func (d *Player6Driver) Clone(dst, src string, linked bool, snapshot string) error {
 	// TODO(rasa) check if running player+, not just player
 
 	var cloneType string
@@ -27,6 +27,9 @@ func (d *Player6Driver) Clone(dst, src string, linked bool) error {
 		"-T", "ws",
 		"clone", src, dst,
 		cloneType)
+	if snapshot != "" {
+		cmd.Args = append(cmd.Args, "-snapshot="+snapshot)
+	}
 
 	if _, _, err := runAndLog(cmd); err != nil {
 		return err
@@ -13,7 +13,7 @@ type Workstation10Driver struct {
 	Workstation9Driver
 }
 
-func (d *Workstation10Driver) Clone(dst, src string, linked bool) error {
+func (d *Workstation10Driver) Clone(dst, src string, linked bool, snapshot string) error {
 
 	var cloneType string
 	if linked {
@@ -26,6 +26,9 @@ func (d *Workstation10Driver) Clone(dst, src string, linked bool) error {
 		"-T", "ws",
 		"clone", src, dst,
 		cloneType)
+	if snapshot != "" {
+		cmd.Args = append(cmd.Args, "-snapshot="+snapshot)
+	}
 
 	if _, _, err := runAndLog(cmd); err != nil {
 		return err
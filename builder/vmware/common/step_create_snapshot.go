@@ -0,0 +1,47 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+// This step takes a snapshot of the VM, so that later builds can clone it,
+// if a snapshot name is set.
+//
+// Uses:
+//   driver Driver
+//   ui     packer.Ui
+//   vmx_path string
+//
+// Produces:
+//   <nothing>
+type StepCreateSnapshot struct {
+	Name string
+}
+
+func (s *StepCreateSnapshot) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Name == "" {
+		log.Println("No target snapshot, skipping snapshot step...")
+		return multistep.ActionContinue
+	}
+
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	vmxPath := state.Get("vmx_path").(string)
+
+	ui.Say(fmt.Sprintf("Taking snapshot of the virtual machine: %s", s.Name))
+	if err := driver.CreateSnapshot(vmxPath, s.Name); err != nil {
+		err := fmt.Errorf("Error taking snapshot: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepCreateSnapshot) Cleanup(state multistep.StateBag) {}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+)
+
+func TestStepCreateSnapshot_impl(t *testing.T) {
+	var _ multistep.Step = new(StepCreateSnapshot)
+}
+
+func TestStepCreateSnapshot(t *testing.T) {
+	state := testState(t)
+	step := &StepCreateSnapshot{Name: "provisioned"}
+
+	state.Put("vmx_path", "foo.vmx")
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should NOT have error")
+	}
+
+	// Test the driver
+	if !driver.CreateSnapshotCalled {
+		t.Fatal("should've called")
+	}
+	if driver.CreateSnapshotVMXPath != "foo.vmx" || driver.CreateSnapshotName != "provisioned" {
+		t.Fatalf("bad: %#v", driver)
+	}
+}
+
+func TestStepCreateSnapshot_skip(t *testing.T) {
+	state := testState(t)
+	step := new(StepCreateSnapshot)
+
+	driver := state.Get("driver").(*DriverMock)
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	// Test the driver
+	if driver.CreateSnapshotCalled {
+		t.Fatal("should not have called")
+	}
+}
+
+func TestStepCreateSnapshot_error(t *testing.T) {
+	state := testState(t)
+	step := &StepCreateSnapshot{Name: "provisioned"}
+
+	state.Put("vmx_path", "foo.vmx")
+
+	driver := state.Get("driver").(*DriverMock)
+	driver.CreateSnapshotErr = errors.New("error")
+
+	// Test the run
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
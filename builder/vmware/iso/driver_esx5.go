@@ -41,7 +41,7 @@ type ESX5Driver struct {
 	vmId      string
 }
 
-func (d *ESX5Driver) Clone(dst, src string, linked bool) error {
+func (d *ESX5Driver) Clone(dst, src string, linked bool, snapshot string) error {
 	return errors.New("Cloning is not supported with the ESX driver.")
 }
 
@@ -54,6 +54,10 @@ func (d *ESX5Driver) CreateDisk(diskPathLocal string, size string, adapter_type
 	return d.sh("vmkfstools", "-c", size, "-d", typeId, "-a", adapter_type, diskPath)
 }
 
+func (d *ESX5Driver) CreateSnapshot(vmxPathLocal string, name string) error {
+	return d.sh("vim-cmd", "vmsvc/snapshot.create", d.vmId, name)
+}
+
 func (d *ESX5Driver) IsRunning(string) (bool, error) {
 	state, err := d.run(nil, "vim-cmd", "vmsvc/power.getstate", d.vmId)
 	if err != nil {
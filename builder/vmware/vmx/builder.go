@@ -70,6 +70,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Path:      b.config.SourcePath,
 			VMName:    b.config.VMName,
 			Linked:    b.config.Linked,
+			Snapshot:  b.config.SourceSnapshot,
 		},
 		&vmwcommon.StepConfigureVMX{
 			CustomData: b.config.VMXData,
@@ -126,6 +127,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			RemoveEthernetInterfaces: b.config.VMXConfig.VMXRemoveEthernet,
 			VNCEnabled:               !b.config.DisableVNC,
 		},
+		&vmwcommon.StepCreateSnapshot{
+			Name: b.config.TargetSnapshot,
+		},
 	}
 
 	// Run the steps.
@@ -149,6 +153,13 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	return vmwcommon.NewLocalArtifact(b.config.VMName, b.config.OutputDir)
 }
 
+// PlanOutputs tells build plans about the output directory, which -force deletes.
+func (b *Builder) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output_directory", Default: "output-{{build_name}}", Local: true},
+	}
+}
+
 // Cancel.
 func (b *Builder) Cancel() {
 	if b.runner != nil {
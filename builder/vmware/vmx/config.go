@@ -30,6 +30,8 @@ type Config struct {
 	RemoteType     string `mapstructure:"remote_type"`
 	SkipCompaction bool   `mapstructure:"skip_compaction"`
 	SourcePath     string `mapstructure:"source_path"`
+	SourceSnapshot string `mapstructure:"source_snapshot"`
+	TargetSnapshot string `mapstructure:"target_snapshot"`
 	VMName         string `mapstructure:"vm_name"`
 
 	ctx interpolate.Context
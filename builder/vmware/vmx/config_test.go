@@ -58,3 +58,15 @@ func TestNewConfig_sourcePath(t *testing.T) {
 	_, warns, errs = NewConfig(c)
 	testConfigOk(t, warns, errs)
 }
+
+func TestNewConfig_snapshots(t *testing.T) {
+	c := testConfig(t)
+	c["source_snapshot"] = "clean"
+	c["target_snapshot"] = "provisioned"
+	config, warns, errs := NewConfig(c)
+	testConfigOk(t, warns, errs)
+
+	if config.SourceSnapshot != "clean" || config.TargetSnapshot != "provisioned" {
+		t.Fatalf("bad: %#v", config)
+	}
+}
@@ -18,6 +18,7 @@ type StepCloneVMX struct {
 	Path      string
 	VMName    string
 	Linked    bool
+	Snapshot  string
 }
 
 func (s *StepCloneVMX) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
@@ -28,8 +29,11 @@ func (s *StepCloneVMX) Run(_ context.Context, state multistep.StateBag) multiste
 	vmxPath := filepath.Join(s.OutputDir, s.VMName+".vmx")
 	ui.Say("Cloning source VM...")
 	log.Printf("Cloning from: %s", s.Path)
+	if s.Snapshot != "" {
+		log.Printf("Cloning from snapshot: %s", s.Snapshot)
+	}
 	log.Printf("Cloning to: %s", vmxPath)
-	if err := driver.Clone(vmxPath, s.Path, s.Linked); err != nil {
+	if err := driver.Clone(vmxPath, s.Path, s.Linked, s.Snapshot); err != nil {
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
@@ -65,6 +65,7 @@ func TestStepCloneVMX(t *testing.T) {
 	step.OutputDir = td
 	step.Path = sourcePath
 	step.VMName = "foo"
+	step.Snapshot = "clean"
 
 	driver := state.Get("driver").(*vmwcommon.DriverMock)
 
@@ -80,6 +81,9 @@ func TestStepCloneVMX(t *testing.T) {
 	if !driver.CloneCalled {
 		t.Fatal("should call clone")
 	}
+	if driver.CloneSnapshot != "clean" {
+		t.Fatalf("bad snapshot: %#v", driver.CloneSnapshot)
+	}
 
 	// Test that we have our paths
 	if vmxPath, ok := state.GetOk("vmx_path"); !ok {
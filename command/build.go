@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,20 +24,50 @@ type BuildCommand struct {
 }
 
 func (c *BuildCommand) Run(args []string) int {
-	var cfgColor, cfgDebug, cfgForce, cfgParallel bool
-	var cfgOnError string
+	var cfgColor, cfgDebug, cfgDryRun, cfgForce, cfgParallel bool
+	var cfgParallelBuilds int
+	var cfgOnError, cfgOnErrorScript, cfgLogDir string
 	flags := c.Meta.FlagSet("build", FlagSetBuildFilter|FlagSetVars)
 	flags.Usage = func() { c.Ui.Say(c.Help()) }
 	flags.BoolVar(&cfgColor, "color", true, "")
 	flags.BoolVar(&cfgDebug, "debug", false, "")
+	flags.BoolVar(&cfgDryRun, "dry-run", false, "")
 	flags.BoolVar(&cfgForce, "force", false, "")
-	flagOnError := enumflag.New(&cfgOnError, "cleanup", "abort", "ask")
+	flags.StringVar(&cfgLogDir, "log-dir", "", "")
+	flagOnError := enumflag.New(&cfgOnError, "cleanup", "abort", "ask", "shell", "run-cleanup-provisioner")
 	flags.Var(flagOnError, "on-error", "")
+	flags.StringVar(&cfgOnErrorScript, "on-error-script", "", "")
 	flags.BoolVar(&cfgParallel, "parallel", true, "")
+	flags.IntVar(&cfgParallelBuilds, "parallel-builds", 0, "")
 	if err := flags.Parse(args); err != nil {
 		return 1
 	}
 
+	if cfgParallelBuilds < 0 {
+		c.Ui.Error("-parallel-builds must not be negative")
+		return 1
+	}
+
+	if cfgDryRun && cfgLogDir != "" {
+		c.Ui.Error("-dry-run and -log-dir can't be used together")
+		return 1
+	}
+
+	if (cfgOnError == "run-cleanup-provisioner") != (cfgOnErrorScript != "") {
+		c.Ui.Error("-on-error=run-cleanup-provisioner and -on-error-script must be used together")
+		return 1
+	}
+
+	// The script is run by the builders, possibly from another directory
+	if cfgOnErrorScript != "" {
+		path, err := filepath.Abs(cfgOnErrorScript)
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Invalid -on-error-script: %s", err))
+			return 1
+		}
+		cfgOnErrorScript = path
+	}
+
 	args = flags.Args()
 	if len(args) != 1 {
 		flags.Usage()
@@ -59,15 +90,55 @@ func (c *BuildCommand) Run(args []string) int {
 		return 1
 	}
 
-	// Get the builds we care about
-	buildNames := c.Meta.BuildNames(core)
+	// Get the builds we care about, ordered so that every build comes
+	// after the builds it depends on
+	var dependencies map[string][]string
+	buildNames, err := c.Meta.BuildNames(core)
+	if err == nil {
+		buildNames, dependencies, err = sortBuildNames(core, buildNames)
+	}
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	// Set up the transcripts of the builds, if requested
+	var logs *buildLogs
+	var interrupted bool
+	if cfgLogDir != "" {
+		logs, err = newBuildLogs(cfgLogDir, args[0])
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+		defer func() {
+			if err := logs.Close(interrupted); err != nil {
+				c.Ui.Error(fmt.Sprintf("Error writing build summary: %s", err))
+			}
+		}()
+	}
+
 	builds := make([]packer.Build, 0, len(buildNames))
 	for _, n := range buildNames {
+		// The plugins of the build are started by core.Build, so that
+		// is when their logs are tied to the transcript.
+		if logs != nil {
+			t, err := logs.Open(n)
+			if err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+			packer.SetPluginTranscript(t)
+		}
+
 		b, err := core.Build(n)
+		packer.SetPluginTranscript(nil)
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf(
 				"Failed to initialize build '%s': %s",
 				n, err))
+			if logs != nil {
+				logs.Finished(n, nil, err)
+			}
 			continue
 		}
 
@@ -104,12 +175,23 @@ func (c *BuildCommand) Run(args []string) int {
 			}
 		}
 
+		if logs != nil {
+			if t := logs.Transcript(b); t != nil {
+				ui = &packer.TranscriptUi{
+					Ui:         ui,
+					Transcript: t,
+				}
+			}
+		}
+
 		buildUis[b] = ui
 	}
 
 	log.Printf("Build debug mode: %v", cfgDebug)
+	log.Printf("Dry run: %v", cfgDryRun)
 	log.Printf("Force build: %v", cfgForce)
 	log.Printf("On error: %v", cfgOnError)
+	log.Printf("Parallel builds: %d", cfgParallelBuilds)
 
 	// Set the debug and force mode and prepare all the builds
 	for _, b := range builds {
@@ -117,30 +199,74 @@ func (c *BuildCommand) Run(args []string) int {
 		b.SetDebug(cfgDebug)
 		b.SetForce(cfgForce)
 		b.SetOnError(cfgOnError)
+		b.SetOnErrorScript(cfgOnErrorScript)
+		b.SetTranscript(logs != nil && logs.Transcript(b.Name()) != nil)
+
+		// Builds that depend on other builds can only be prepared once
+		// the artifacts they interpolate are available. Their configuration
+		// is checked now on a copy of the build, so that mistakes in it
+		// don't wait for the builds they depend on.
+		if deps := dependencies[b.Name()]; len(deps) > 0 {
+			log.Printf("Checking build '%s' before its dependencies finish", b.Name())
+			check, err := core.Build(b.Name())
+			if err == nil {
+				check.SetForce(cfgForce)
+				check.SetOnError(cfgOnError)
+				check.SetOnErrorScript(cfgOnErrorScript)
+				_, err = prepareBuild(check, deps)
+			}
+			if err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+
+			log.Printf("Deferring prepare of build '%s' until its dependencies finish", b.Name())
+			continue
+		}
 
-		warnings, err := b.Prepare()
+		warnings, err := prepareBuild(b, dependencies[b.Name()])
 		if err != nil {
 			c.Ui.Error(err.Error())
 			return 1
 		}
-		if len(warnings) > 0 {
-			ui := buildUis[b.Name()]
-			ui.Say(fmt.Sprintf("Warnings for build '%s':\n", b.Name()))
-			for _, warning := range warnings {
-				ui.Say(fmt.Sprintf("* %s", warning))
-			}
-			ui.Say("")
+		sayBuildWarnings(buildUis[b.Name()], b.Name(), warnings)
+	}
+
+	// Show what the builds would do, without running them
+	if cfgDryRun {
+		c.sayBuildPlans(builds, dependencies)
+
+		// Builds that failed to initialize have no plan
+		if len(builds) != len(buildNames) {
+			return 1
 		}
+		return 0
 	}
 
 	// Run all the builds in parallel and wait for them to complete
 	var interruptWg, wg sync.WaitGroup
-	interrupted := false
 	var artifacts = struct {
 		sync.RWMutex
 		m map[string][]packer.Artifact
 	}{m: make(map[string][]packer.Artifact)}
-	errors := make(map[string]error)
+	var errors = struct {
+		sync.RWMutex
+		m map[string]error
+	}{m: make(map[string]error)}
+
+	// A zero limit means no limit on the number of builds that run at once
+	var slots chan struct{}
+	if cfgParallelBuilds > 0 {
+		slots = make(chan struct{}, cfgParallelBuilds)
+	}
+
+	// Each build closes its channel once it is done, which releases the
+	// builds that depend on it.
+	done := make(map[string]chan struct{}, len(builds))
+	for _, b := range builds {
+		done[b.Name()] = make(chan struct{})
+	}
+
 	// ctx := context.Background()
 	for _, b := range builds {
 		// Increment the waitgroup so we wait for this item to finish properly
@@ -168,13 +294,79 @@ func (c *BuildCommand) Run(args []string) int {
 			defer wg.Done()
 
 			name := b.Name()
-			log.Printf("Starting build run: %s", name)
+			defer close(done[name])
 			ui := buildUis[name]
+
+			if deps := dependencies[name]; len(deps) > 0 {
+				log.Printf("Build '%s' waiting on dependencies: %s", name, strings.Join(deps, ", "))
+				var err error
+				upstream := make(map[string]packer.Artifact, len(deps))
+				for _, dep := range deps {
+					ch, ok := done[dep]
+					if !ok {
+						err = fmt.Errorf("dependency '%s' could not be initialized", dep)
+						break
+					}
+					<-ch
+
+					artifacts.RLock()
+					depArtifacts := artifacts.m[dep]
+					artifacts.RUnlock()
+					if len(depArtifacts) == 0 {
+						err = fmt.Errorf("dependency '%s' did not produce an artifact", dep)
+						break
+					}
+
+					// The last artifact is the one at the end of the
+					// post-processor chains, if there are any.
+					upstream[dep] = depArtifacts[len(depArtifacts)-1]
+				}
+
+				var warnings []string
+				if err == nil {
+					log.Printf("Preparing build: %s", name)
+					b.SetUpstreamArtifacts(upstream)
+					warnings, err = b.Prepare()
+					sayBuildWarnings(ui, name, warnings)
+				}
+				if err != nil {
+					ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
+					errors.Lock()
+					errors.m[name] = err
+					errors.Unlock()
+					if logs != nil {
+						logs.Finished(name, nil, err)
+					}
+					return
+				}
+			}
+
+			// Only take a slot once the dependencies are done, so that
+			// waiting builds don't hold up the ones they wait on.
+			if slots != nil {
+				log.Printf("Build '%s' waiting for a free slot", name)
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			if interrupted {
+				log.Printf("Interrupted, not starting build: %s", name)
+				return
+			}
+
+			log.Printf("Starting build run: %s", name)
+			if logs != nil {
+				logs.Started(name)
+			}
 			runArtifacts, err := b.Run(ui, c.Cache)
+			if logs != nil {
+				logs.Finished(name, runArtifacts, err)
+			}
 
 			if err != nil {
 				ui.Error(fmt.Sprintf("Build '%s' errored: %s", name, err))
-				errors[name] = err
+				errors.Lock()
+				errors.m[name] = err
+				errors.Unlock()
 			} else {
 				ui.Say(fmt.Sprintf("Build '%s' finished.", name))
 				artifacts.Lock()
@@ -212,11 +404,11 @@ func (c *BuildCommand) Run(args []string) int {
 		return 1
 	}
 
-	if len(errors) > 0 {
-		c.Ui.Machine("error-count", strconv.FormatInt(int64(len(errors)), 10))
+	if len(errors.m) > 0 {
+		c.Ui.Machine("error-count", strconv.FormatInt(int64(len(errors.m)), 10))
 
 		c.Ui.Error("\n==> Some builds didn't complete successfully and had errors:")
-		for name, err := range errors {
+		for name, err := range errors.m {
 			// Create a UI for the machine readable stuff to be targeted
 			ui := &packer.TargetedUI{
 				Target: name,
@@ -277,7 +469,7 @@ func (c *BuildCommand) Run(args []string) int {
 		c.Ui.Say("\n==> Builds finished but no artifacts were created.")
 	}
 
-	if len(errors) > 0 {
+	if len(errors.m) > 0 {
 		// If any errors occurred, exit with a non-zero exit status
 		return 1
 	}
@@ -285,23 +477,102 @@ func (c *BuildCommand) Run(args []string) int {
 	return 0
 }
 
+// sortBuildNames orders the given build names so that every build comes
+// after the builds it depends on, and returns the dependencies of each
+// build. It is an error for a build to depend on a build that isn't in
+// the given list, for example because it was excluded with -only/-except.
+func sortBuildNames(core *packer.Core, names []string) ([]string, map[string][]string, error) {
+	selected := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		selected[n] = struct{}{}
+	}
+
+	dependencies := make(map[string][]string, len(names))
+	for _, n := range names {
+		deps, err := core.BuildDependencies(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, dep := range deps {
+			if _, ok := selected[dep]; !ok {
+				return nil, nil, fmt.Errorf(
+					"Build '%s' depends on build '%s', which is not selected to run",
+					n, dep)
+			}
+		}
+		dependencies[n] = deps
+	}
+
+	result := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(names))
+	var visit func(n string)
+	visit = func(n string) {
+		if visited[n] {
+			return
+		}
+		visited[n] = true
+		for _, dep := range dependencies[n] {
+			visit(dep)
+		}
+		result = append(result, n)
+	}
+	for _, n := range names {
+		visit(n)
+	}
+
+	return result, dependencies, nil
+}
+
+// prepareBuild prepares a build. The artifacts of the builds it depends on
+// are replaced by placeholders, so that its configuration can be checked
+// before those have run. Errors that only the real artifacts can resolve
+// are left out.
+func prepareBuild(b packer.Build, deps []string) ([]string, error) {
+	if len(deps) == 0 {
+		return b.Prepare()
+	}
+
+	b.SetUpstreamArtifacts(packer.UpstreamPlaceholders(deps))
+	warnings, err := b.Prepare()
+	return warnings, packer.WithoutUpstreamPlaceholderErrors(err)
+}
+
+// sayBuildWarnings outputs the warnings from preparing a build.
+func sayBuildWarnings(ui packer.Ui, name string, warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Warnings for build '%s':\n", name))
+	for _, warning := range warnings {
+		ui.Say(fmt.Sprintf("* %s", warning))
+	}
+	ui.Say("")
+}
+
 func (*BuildCommand) Help() string {
 	helpText := `
 Usage: packer build [options] TEMPLATE
 
   Will execute multiple builds in parallel as defined in the template.
-  The various artifacts created by the template will be outputted.
+  Builds that depend on other builds using "depends_on" wait for them to
+  finish first. The various artifacts created by the template will be
+  outputted.
 
 Options:
 
   -color=false               Disable color output (on by default)
   -debug                     Debug mode enabled for builds
+  -dry-run                   Show what the builds would do, without running them
   -except=foo,bar,baz        Build all builds other than these
   -only=foo,bar,baz          Build only the specified builds
   -force                     Force a build to continue if artifacts exist, deletes existing artifacts
+  -log-dir=path              Write a transcript of every build and a summary of the run to this directory
   -machine-readable          Machine-readable output
-  -on-error=[cleanup|abort|ask] If the build fails do: clean up (default), abort, or ask
+  -on-error=[cleanup|abort|ask|shell|run-cleanup-provisioner] If the build fails do: clean up (default), abort, ask, open a shell on the machine and ask, or run the -on-error-script and clean up
+  -on-error-script=path      Local script to run with -on-error=run-cleanup-provisioner
   -parallel=false            Disable parallelization (on by default)
+  -parallel-builds=0         Number of builds to run in parallel. 0 means no limit (Default: 0)
   -var 'key=value'           Variable for templates, can be used multiple times.
   -var-file=path             JSON file containing user variables.
 `
@@ -321,12 +592,16 @@ func (*BuildCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
 		"-color":            complete.PredictNothing,
 		"-debug":            complete.PredictNothing,
+		"-dry-run":          complete.PredictNothing,
 		"-except":           complete.PredictNothing,
 		"-only":             complete.PredictNothing,
 		"-force":            complete.PredictNothing,
+		"-log-dir":          complete.PredictDirs("*"),
 		"-machine-readable": complete.PredictNothing,
 		"-on-error":         complete.PredictNothing,
+		"-on-error-script":  complete.PredictFiles("*"),
 		"-parallel":         complete.PredictNothing,
+		"-parallel-builds":  complete.PredictNothing,
 		"-var":              complete.PredictNothing,
 		"-var-file":         complete.PredictNothing,
 	}
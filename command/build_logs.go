@@ -0,0 +1,179 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// The name of the file in the log directory that summarizes the run.
+const buildLogsSummaryFile = "summary.txt"
+
+// Characters that are replaced in build names to get a transcript file name.
+var buildLogsUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// buildLogs writes a transcript for every build, and a summary of the
+// whole run, to a directory. It is used by the -log-dir flag of the build
+// command.
+type buildLogs struct {
+	dir      string
+	template string
+	start    time.Time
+
+	l       sync.Mutex
+	results map[string]*buildLogsResult
+}
+
+// buildLogsResult is what is known about a single build in the run.
+type buildLogsResult struct {
+	transcript *packer.Transcript
+	file       string
+	start      time.Time
+	end        time.Time
+	artifacts  []packer.Artifact
+	err        error
+}
+
+func newBuildLogs(dir, template string) (*buildLogs, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating log directory: %s", err)
+	}
+
+	return &buildLogs{
+		dir:      dir,
+		template: template,
+		start:    time.Now(),
+		results:  make(map[string]*buildLogsResult),
+	}, nil
+}
+
+// Open creates the transcript for the build with the given name.
+func (l *buildLogs) Open(name string) (*packer.Transcript, error) {
+	file := buildLogsUnsafeChars.ReplaceAllString(name, "_") + ".log"
+	f, err := os.Create(filepath.Join(l.dir, file))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating transcript for build '%s': %s", name, err)
+	}
+
+	t := packer.NewTranscript(name, f)
+	t.Record("build", fmt.Sprintf("Transcript of build '%s' of %s", name, l.template))
+
+	l.l.Lock()
+	defer l.l.Unlock()
+	l.results[name] = &buildLogsResult{
+		transcript: t,
+		file:       file,
+	}
+
+	return t, nil
+}
+
+// Transcript returns the transcript of the build with the given name, or
+// nil if it wasn't opened.
+func (l *buildLogs) Transcript(name string) *packer.Transcript {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	if r, ok := l.results[name]; ok {
+		return r.transcript
+	}
+
+	return nil
+}
+
+// Started records that the build with the given name started running.
+func (l *buildLogs) Started(name string) {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	if r, ok := l.results[name]; ok {
+		r.start = time.Now()
+		r.transcript.Record("build", "Build started")
+	}
+}
+
+// Finished records the result of the build with the given name.
+func (l *buildLogs) Finished(name string, artifacts []packer.Artifact, err error) {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	r, ok := l.results[name]
+	if !ok {
+		r = &buildLogsResult{}
+		l.results[name] = r
+	}
+
+	r.end = time.Now()
+	r.artifacts = artifacts
+	r.err = err
+
+	if r.transcript != nil {
+		if err != nil {
+			r.transcript.Record("build", fmt.Sprintf("Build errored: %s", err))
+		} else {
+			r.transcript.Record("build", "Build finished")
+		}
+	}
+}
+
+// Close writes the summary of the run and closes all transcripts.
+func (l *buildLogs) Close(interrupted bool) error {
+	l.l.Lock()
+	defer l.l.Unlock()
+
+	names := make([]string, 0, len(l.results))
+	for name := range l.results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var summary bytes.Buffer
+	fmt.Fprintf(&summary, "Template: %s\n", l.template)
+	fmt.Fprintf(&summary, "Started: %s\n", l.start.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&summary, "Finished: %s\n", time.Now().UTC().Format(time.RFC3339))
+	if interrupted {
+		fmt.Fprintf(&summary, "Interrupted: true\n")
+	}
+
+	for _, name := range names {
+		r := l.results[name]
+
+		status := "succeeded"
+		switch {
+		case r.err != nil:
+			status = "errored"
+		case r.end.IsZero():
+			status = "did not finish"
+		}
+
+		fmt.Fprintf(&summary, "\nBuild '%s': %s\n", name, status)
+		if !r.start.IsZero() && !r.end.IsZero() {
+			fmt.Fprintf(&summary, "  Duration: %s\n", r.end.Sub(r.start).Round(time.Second))
+		}
+		if r.file != "" {
+			fmt.Fprintf(&summary, "  Transcript: %s\n", r.file)
+		}
+		if r.err != nil {
+			fmt.Fprintf(&summary, "  Error: %s\n", r.err)
+		}
+		for _, artifact := range r.artifacts {
+			if artifact != nil {
+				fmt.Fprintf(&summary, "  Artifact: %s\n", artifact.Id())
+			}
+		}
+
+		if r.transcript != nil {
+			r.transcript.Close()
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(l.dir, buildLogsSummaryFile), summary.Bytes(), 0644)
+}
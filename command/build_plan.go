@@ -0,0 +1,159 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// sayBuildPlans outputs what the given builds would do when they are run.
+// The builds that have no dependencies must be prepared already.
+func (c *BuildCommand) sayBuildPlans(builds []packer.Build, dependencies map[string][]string) {
+	for _, b := range builds {
+		pb, ok := b.(packer.PlannedBuild)
+		if !ok {
+			c.Ui.Error(fmt.Sprintf("Build '%s' can't show what it would do.", b.Name()))
+			continue
+		}
+
+		c.Ui.Say(formatBuildPlan(pb.Plan(), dependencies[b.Name()]))
+	}
+}
+
+// formatBuildPlan formats the plan of a build for humans. The plans of
+// builds with dependencies are shown unprepared, with the artifacts of
+// their dependencies left as they are in the template.
+func formatBuildPlan(plan *packer.BuildPlan, deps []string) string {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "==> Plan for build '%s':\n", plan.Name)
+
+	if len(deps) > 0 {
+		fmt.Fprintf(&out, "\n    Depends on: %s\n", strings.Join(deps, ", "))
+		out.WriteString("    The build is only prepared once they finish. Its\n")
+		out.WriteString("    configuration was checked with placeholders for their\n")
+		out.WriteString("    artifacts.\n")
+	}
+
+	fmt.Fprintf(&out, "\n    Builder: %s\n", plan.BuilderType)
+	writePlanConfig(&out, plan.Builder, "      ")
+
+	out.WriteString("\n    Provisioners:\n")
+	if len(plan.Provisioners) == 0 {
+		out.WriteString("      (none)\n")
+	}
+	for i, p := range plan.Provisioners {
+		fmt.Fprintf(&out, "      %d. %s%s\n", i+1, p.Type, provisionerPlanNotes(p))
+		writePlanConfig(&out, p.Config, "         ")
+	}
+
+	if p := plan.ErrorCleanupProvisioner; p != nil {
+		fmt.Fprintf(&out, "\n    Error cleanup provisioner: %s%s\n", p.Type, provisionerPlanNotes(p))
+		writePlanConfig(&out, p.Config, "      ")
+	}
+
+	out.WriteString("\n    Post-processors:\n")
+	if len(plan.PostProcessors) == 0 {
+		out.WriteString("      (none)\n")
+	}
+	for i, chain := range plan.PostProcessors {
+		fmt.Fprintf(&out, "      Chain %d:\n", i+1)
+		for j, pp := range chain {
+			input := "the builder artifact"
+			if j > 0 {
+				input = fmt.Sprintf("the artifact of %s", chain[j-1].Type)
+			}
+
+			var notes []string
+			if pp.KeepInputArtifact {
+				notes = append(notes, fmt.Sprintf("keeps %s", input))
+			} else {
+				notes = append(notes, fmt.Sprintf(
+					"deletes %s unless the post-processor keeps it", input))
+			}
+			if pp.MaxRetries > 0 {
+				notes = append(notes, fmt.Sprintf(
+					"retried up to %d times, %s apart", pp.MaxRetries, pp.RetryBackoff))
+			}
+
+			fmt.Fprintf(&out, "        %d. %s (%s)\n", j+1, pp.Type, strings.Join(notes, ", "))
+			writePlanConfig(&out, pp.Config, "           ")
+		}
+	}
+
+	out.WriteString("\n    Artifacts:\n")
+	if plan.KeepBuilderArtifact {
+		out.WriteString("      The builder artifact is kept.\n")
+	} else {
+		out.WriteString("      The builder artifact is deleted, unless the first post-processor\n")
+		out.WriteString("      of a chain keeps it.\n")
+	}
+	if len(plan.PostProcessors) > 0 {
+		out.WriteString("      The artifact at the end of every chain is kept.\n")
+	}
+
+	if len(plan.Outputs) > 0 {
+		out.WriteString("\n    Outputs:\n")
+		for _, o := range plan.Outputs {
+			fmt.Fprintf(&out, "      %s %s: %s (%s)\n",
+				o.Component, o.Key, o.Value, planOutputNotes(o))
+		}
+	}
+
+	return out.String()
+}
+
+func provisionerPlanNotes(p *packer.ProvisionerPlan) string {
+	var notes []string
+	if p.Override {
+		notes = append(notes, "overridden for this build")
+	}
+	if p.PauseBefore > 0 {
+		notes = append(notes, fmt.Sprintf("paused %s before", p.PauseBefore))
+	}
+	if p.MaxRetries > 0 {
+		notes = append(notes, fmt.Sprintf(
+			"retried up to %d times, %s apart", p.MaxRetries, p.RetryBackoff))
+	}
+
+	if len(notes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(notes, ", "))
+}
+
+// planOutputNotes tells what happens to an output when the build runs.
+func planOutputNotes(o *packer.PlanOutput) string {
+	switch {
+	case o.Builder && o.Exists && o.Force:
+		return "exists, deleted because of -force"
+	case o.Builder && o.Exists:
+		return "exists, the build fails unless -force is set"
+	case o.Builder && !o.Local && o.Force:
+		return "replaced if it exists, because of -force"
+	case o.Builder && !o.Local:
+		return "the build fails if it exists, unless -force is set"
+	case o.Exists:
+		return "exists, overwritten"
+	default:
+		return "created"
+	}
+}
+
+// writePlanConfig writes the configuration as indented JSON, which sorts
+// the keys.
+func writePlanConfig(out *bytes.Buffer, config map[string]interface{}, indent string) {
+	if len(config) == 0 {
+		return
+	}
+
+	out.WriteString(indent)
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(indent, "  ")
+	if err := enc.Encode(config); err != nil {
+		fmt.Fprintf(out, "%#v\n", config)
+	}
+}
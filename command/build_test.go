@@ -2,8 +2,10 @@ package command
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer/builder/file"
@@ -37,6 +39,33 @@ func TestBuildOnlyFileCommaFlags(t *testing.T) {
 	}
 }
 
+func TestBuildOnlyFilePattern(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-only=ch*",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	if !fileExists("chocolate.txt") {
+		t.Error("Expected to find chocolate.txt")
+	}
+	if fileExists("vanilla.txt") {
+		t.Error("Expected NOT to find vanilla.txt")
+	}
+	if !fileExists("cherry.txt") {
+		t.Error("Expected to find cherry.txt")
+	}
+}
+
 func TestBuildStdin(t *testing.T) {
 	c := &BuildCommand{
 		Meta: testMetaFile(t),
@@ -122,6 +151,249 @@ func TestBuildExceptFileCommaFlags(t *testing.T) {
 	}
 }
 
+func TestBuildDependsOn(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-parallel=false",
+		filepath.Join(testFixture("build-depends-on"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	contents, err := ioutil.ReadFile("fudge.txt")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(contents) != "chocolate.txt" {
+		t.Fatalf("bad: %q", contents)
+	}
+}
+
+func TestBuildDependsOnNotSelected(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-only=fudge",
+		filepath.Join(testFixture("build-depends-on"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+	if fileExists("fudge.txt") {
+		t.Error("Expected NOT to find fudge.txt")
+	}
+}
+
+func TestBuildDependsOnInvalid(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-parallel=false",
+		filepath.Join(testFixture("build-depends-on-invalid"), "template.json"),
+	}
+
+	defer cleanup()
+
+	// The configuration of fudge is checked before chocolate runs
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+	if fileExists("chocolate.txt") {
+		t.Error("Expected NOT to find chocolate.txt")
+	}
+}
+
+func TestBuildLogDir(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{
+		"-only=chocolate,vanilla",
+		"-log-dir=" + dir,
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	for _, name := range []string{"chocolate", "vanilla"} {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, name+".log"))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !strings.Contains(string(contents), "[build] Build finished") {
+			t.Fatalf("bad transcript for %s: %s", name, contents)
+		}
+		if !strings.Contains(string(contents), "Build '"+name+"' finished.") {
+			t.Fatalf("bad transcript for %s: %s", name, contents)
+		}
+	}
+
+	if fileExists(filepath.Join(dir, "cherry.log")) {
+		t.Error("Expected NOT to find cherry.log")
+	}
+
+	summary, err := ioutil.ReadFile(filepath.Join(dir, buildLogsSummaryFile))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, expected := range []string{
+		"Build 'chocolate': succeeded",
+		"Transcript: chocolate.log",
+		"Build 'vanilla': succeeded",
+	} {
+		if !strings.Contains(string(summary), expected) {
+			t.Fatalf("expected %q in summary: %s", expected, summary)
+		}
+	}
+}
+
+func TestBuildMatrixParallelBuilds(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-parallel-builds=1",
+		"-except=flavor-cherry",
+		filepath.Join(testFixture("build-matrix"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	for _, f := range []string{"chocolate", "vanilla"} {
+		contents, err := ioutil.ReadFile(f + ".txt")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if string(contents) != f {
+			t.Fatalf("bad: %q", contents)
+		}
+	}
+	if fileExists("cherry.txt") {
+		t.Error("Expected NOT to find cherry.txt")
+	}
+}
+
+func TestBuildExceptFilePattern(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-except=/^(vanilla|cherry)$/",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	if !fileExists("chocolate.txt") {
+		t.Error("Expected to find chocolate.txt")
+	}
+	if fileExists("vanilla.txt") {
+		t.Error("Expected NOT to find vanilla.txt")
+	}
+	if fileExists("cherry.txt") {
+		t.Error("Expected NOT to find cherry.txt")
+	}
+}
+
+func TestBuildExceptFileInvalidPattern(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-except=[bad",
+		filepath.Join(testFixture("build-only"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+
+	for _, f := range []string{"chocolate.txt", "vanilla.txt", "cherry.txt"} {
+		if fileExists(f) {
+			t.Errorf("Expected NOT to find %s", f)
+		}
+	}
+}
+
+func TestBuildDryRun(t *testing.T) {
+	c := &BuildCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		"-dry-run",
+		filepath.Join(testFixture("build-dry-run"), "template.json"),
+	}
+
+	defer cleanup()
+
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	if fileExists("chocolate.txt") || fileExists("fudge.txt") {
+		t.Fatal("Expected NOT to run any build")
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	for _, expected := range []string{
+		"Plan for build 'chocolate'",
+		`"content": "chocolate <sensitive>"`,
+		"file target: chocolate.txt (created)",
+		"Plan for build 'fudge'",
+		"Depends on: chocolate",
+		"{{upstream `chocolate` `files`}}",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in output: %s", expected, out)
+		}
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Fatalf("sensitive value in output: %s", out)
+	}
+	if strings.Index(out, "'chocolate'") > strings.Index(out, "'fudge'") {
+		t.Fatalf("builds out of order: %s", out)
+	}
+}
+
 // fileExists returns true if the filename is found
 func fileExists(filename string) bool {
 	if _, err := os.Stat(filename); err == nil {
@@ -159,4 +431,5 @@ func cleanup() {
 	os.RemoveAll("chocolate.txt")
 	os.RemoveAll("vanilla.txt")
 	os.RemoveAll("cherry.txt")
+	os.RemoveAll("fudge.txt")
 }
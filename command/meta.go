@@ -30,6 +30,10 @@ type Meta struct {
 	Ui         packer.Ui
 	Version    string
 
+	// Plugins returns the builders, provisioners and post-processors that
+	// Packer knows about.
+	Plugins func() []PluginComponent
+
 	// These are set by command-line flags
 	flagBuildExcept []string
 	flagBuildOnly   []string
@@ -50,55 +54,67 @@ func (m *Meta) Core(tpl *template.Template) (*packer.Core, error) {
 		return nil, fmt.Errorf("Error initializing core: %s", err)
 	}
 
+	for _, warn := range core.Warnings() {
+		m.Ui.Say(fmt.Sprintf("Warning: %s", warn))
+	}
+
 	return core, nil
 }
 
 // BuildNames returns the list of builds that are in the given core
 // that we care about taking into account the only and except flags.
-func (m *Meta) BuildNames(c *packer.Core) []string {
-	// TODO: test
-
+// Both flags accept the build name patterns of template.MatchBuildName.
+// It is an error for a pattern to be invalid.
+func (m *Meta) BuildNames(c *packer.Core) ([]string, error) {
 	// Filter the "only"
 	if len(m.flagBuildOnly) > 0 {
-		// Build a set of all the available names
-		nameSet := make(map[string]struct{})
-		for _, n := range c.BuildNames() {
-			nameSet[n] = struct{}{}
-		}
-
-		// Build our result set which we pre-allocate some sane number
-		result := make([]string, 0, len(m.flagBuildOnly))
-		for _, n := range m.flagBuildOnly {
-			if _, ok := nameSet[n]; ok {
-				result = append(result, n)
-			}
-		}
-
-		return result
+		return m.filterBuildNames(c.BuildNames(), "only", m.flagBuildOnly, true)
 	}
 
 	// Filter the "except"
 	if len(m.flagBuildExcept) > 0 {
-		// Build a set of the things we don't want
-		nameSet := make(map[string]struct{})
-		for _, n := range m.flagBuildExcept {
-			nameSet[n] = struct{}{}
+		return m.filterBuildNames(c.BuildNames(), "except", m.flagBuildExcept, false)
+	}
+
+	// We care about everything
+	return c.BuildNames(), nil
+}
+
+// filterBuildNames returns the names that match any of the patterns if
+// keep is true, or the names that match none of them otherwise. Patterns
+// that match nothing are reported to the Ui.
+func (m *Meta) filterBuildNames(names []string, flag string, patterns []string, keep bool) ([]string, error) {
+	matched := make(map[string]struct{})
+	for _, p := range patterns {
+		if _, err := template.MatchBuildName(p, ""); err != nil {
+			return nil, fmt.Errorf("Invalid -%s pattern '%s': %s", flag, p, err)
 		}
 
-		// Build our result set which is the names of all builds except
-		// those in the given set.
-		names := c.BuildNames()
-		result := make([]string, 0, len(names))
+		found := false
 		for _, n := range names {
-			if _, ok := nameSet[n]; !ok {
-				result = append(result, n)
+			ok, err := template.MatchBuildName(p, n)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid -%s pattern '%s': %s", flag, p, err)
 			}
+			if ok {
+				matched[n] = struct{}{}
+				found = true
+			}
+		}
+
+		if !found {
+			m.Ui.Say(fmt.Sprintf("Warning: -%s '%s' matches no build", flag, p))
 		}
-		return result
 	}
 
-	// We care about everything
-	return c.BuildNames()
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		if _, ok := matched[n]; ok == keep {
+			result = append(result, n)
+		}
+	}
+
+	return result, nil
 }
 
 // FlagSet returns a FlagSet with the common flags that every
@@ -0,0 +1,559 @@
+package command
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/packer/packer"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+// The origins of a PluginComponent.
+const (
+	// The component is compiled into Packer.
+	PluginOriginInternal = "internal"
+
+	// The component was discovered in one of the plugin directories.
+	PluginOriginDiscovered = "discovered"
+
+	// The component is configured in the Packer configuration file.
+	PluginOriginConfig = "config"
+)
+
+// PluginComponent is a builder, provisioner or post-processor that Packer
+// knows about, either compiled into Packer or provided by a plugin.
+type PluginComponent struct {
+	Kind   string
+	Name   string
+	Path   string
+	Origin string
+}
+
+// The names of the binaries that are installed by "packer plugins install".
+var pluginFileRegexp = regexp.MustCompile(
+	`^packer-(builder|plugin|post-processor|provisioner)-.+$`)
+
+// PluginsCommand groups the subcommands that manage plugins.
+type PluginsCommand struct {
+	Meta
+}
+
+func (c *PluginsCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
+
+func (*PluginsCommand) Help() string {
+	helpText := `
+Usage: packer plugins <subcommand> [options] [args]
+
+  Lists, installs and verifies the plugins that provide builders,
+  provisioners and post-processors.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*PluginsCommand) Synopsis() string {
+	return "list, install and verify plugins"
+}
+
+// PluginsListCommand lists the components that Packer knows about.
+type PluginsListCommand struct {
+	Meta
+}
+
+func (c *PluginsListCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("plugins list", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tNAME\tORIGIN\tPATH")
+	for _, p := range sortedPlugins(c.Plugins()) {
+		c.Ui.Machine("plugin", p.Kind, p.Name, p.Origin, p.Path)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Kind, p.Name, p.Origin, p.Path)
+	}
+	w.Flush()
+
+	c.Ui.Say(strings.TrimSpace(buf.String()))
+	return 0
+}
+
+func (*PluginsListCommand) Help() string {
+	helpText := `
+Usage: packer plugins list
+
+  Lists all builders, provisioners and post-processors that Packer knows
+  about, with the plugin they are provided by. The origin of a component is
+  "internal" if it is compiled into Packer, "discovered" if it was found in
+  one of the plugin directories and "config" if it is set in the Packer
+  configuration file.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*PluginsListCommand) Synopsis() string {
+	return "list all builders, provisioners and post-processors"
+}
+
+func (*PluginsListCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (*PluginsListCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{}
+}
+
+// PluginsInstallCommand installs plugin binaries into the plugin directory
+// of the Packer configuration directory.
+type PluginsInstallCommand struct {
+	Meta
+}
+
+func (c *PluginsInstallCommand) Run(args []string) int {
+	var checksum string
+	var force bool
+	flags := c.Meta.FlagSet("plugins install", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	flags.StringVar(&checksum, "sha256", "", "")
+	flags.BoolVar(&force, "force", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	args = flags.Args()
+	if len(args) != 1 {
+		flags.Usage()
+		return 1
+	}
+	source := args[0]
+
+	remote := isPluginURL(source)
+	if remote && checksum == "" {
+		c.Ui.Error("A SHA-256 checksum is required to install a plugin " +
+			"from a URL. Set it with -sha256.")
+		return 1
+	}
+
+	configDir, err := packer.ConfigDir()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error finding the Packer configuration directory: %s", err))
+		return 1
+	}
+	dir := filepath.Join(configDir, "plugins")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating plugin directory: %s", err))
+		return 1
+	}
+
+	// Fetch the source next to where it's installed, hashing it on the way
+	f, err := ioutil.TempFile(dir, ".install-")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error creating temporary file: %s", err))
+		return 1
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	if err := c.fetch(source, io.MultiWriter(f, h)); err != nil {
+		c.Ui.Error(fmt.Sprintf("Error fetching plugin from %s: %s", source, err))
+		return 1
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if checksum != "" && !strings.EqualFold(checksum, actual) {
+		c.Ui.Error(fmt.Sprintf(
+			"Checksum mismatch for %s: expected %s, got %s",
+			source, strings.ToLower(checksum), actual))
+		return 1
+	}
+	if checksum == "" {
+		c.Ui.Say(fmt.Sprintf("SHA-256 of %s: %s", source, actual))
+	}
+
+	installed, err := installPlugins(f.Name(), pluginSourceName(source), dir, force)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error installing plugin: %s", err))
+		return 1
+	}
+
+	for _, p := range installed {
+		c.Ui.Machine("plugin-installed", p)
+		c.Ui.Say(fmt.Sprintf("Installed %s", p))
+	}
+
+	return 0
+}
+
+// fetch copies the plugin source, a local path or URL, to w.
+func (c *PluginsInstallCommand) fetch(source string, w io.Writer) error {
+	if !isPluginURL(source) {
+		f, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(w, f)
+		return err
+	}
+
+	resp, err := cleanhttp.DefaultClient().Get(source)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("bad response: %s", resp.Status)
+	}
+
+	r := packer.TrackProgress(c.Ui, pluginSourceName(source), resp.ContentLength, resp.Body)
+	_, err = io.Copy(w, r)
+	r.Close()
+	return err
+}
+
+func (*PluginsInstallCommand) Help() string {
+	helpText := `
+Usage: packer plugins install [options] SOURCE
+
+  Installs a plugin into the plugin directory of the Packer configuration
+  directory, where Packer discovers it. SOURCE is the path or URL of a
+  plugin binary, or of a .zip, .tar.gz or .tgz archive. All the plugin
+  binaries in an archive are installed. Plugin binaries are named
+  packer-TYPE-NAME or packer-plugin-NAME.
+
+Options:
+
+  -sha256=HASH    The SHA-256 checksum of SOURCE. Required for URLs.
+  -force          Replace plugins that are already installed.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*PluginsInstallCommand) Synopsis() string {
+	return "install a plugin from a local file or a URL"
+}
+
+func (*PluginsInstallCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (*PluginsInstallCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-sha256": complete.PredictNothing,
+		"-force":  complete.PredictNothing,
+	}
+}
+
+// PluginsVerifyCommand checks that the external plugins can be started and
+// speak the plugin protocol of this version of Packer.
+type PluginsVerifyCommand struct {
+	Meta
+}
+
+func (c *PluginsVerifyCommand) Run(args []string) int {
+	flags := c.Meta.FlagSet("plugins verify", FlagSetNone)
+	flags.Usage = func() { c.Ui.Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	failed := 0
+	for _, p := range sortedPlugins(c.Plugins()) {
+		if p.Origin == PluginOriginInternal {
+			continue
+		}
+
+		if err := c.verify(p); err != nil {
+			failed++
+			c.Ui.Machine("plugin-verify", p.Kind, p.Name, "failed", err.Error())
+			c.Ui.Error(fmt.Sprintf("%s %s (%s): %s", p.Kind, p.Name, p.Path, err))
+			continue
+		}
+
+		c.Ui.Machine("plugin-verify", p.Kind, p.Name, "ok")
+		c.Ui.Say(fmt.Sprintf("%s %s (%s): ok", p.Kind, p.Name, p.Path))
+	}
+
+	if failed > 0 {
+		c.Ui.Error(fmt.Sprintf("\n%d plugin(s) failed to start.", failed))
+		return 1
+	}
+
+	return 0
+}
+
+// verify starts the plugin of the component.
+func (c *PluginsVerifyCommand) verify(p PluginComponent) error {
+	var found bool
+	var err error
+
+	components := c.CoreConfig.Components
+	switch p.Kind {
+	case "builder":
+		var b packer.Builder
+		b, err = components.Builder(p.Name)
+		found = b != nil
+	case "post-processor":
+		var pp packer.PostProcessor
+		pp, err = components.PostProcessor(p.Name)
+		found = pp != nil
+	case "provisioner":
+		var prov packer.Provisioner
+		prov, err = components.Provisioner(p.Name)
+		found = prov != nil
+	default:
+		return fmt.Errorf("unknown plugin type: %s", p.Kind)
+	}
+
+	if err == nil && !found {
+		err = errors.New("not found")
+	}
+
+	return err
+}
+
+func (*PluginsVerifyCommand) Help() string {
+	helpText := `
+Usage: packer plugins verify
+
+  Starts every plugin that isn't compiled into Packer, to check that it
+  works with this version of Packer. Exits with a non-zero exit status if
+  any plugin fails to start.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (*PluginsVerifyCommand) Synopsis() string {
+	return "check that all external plugins can be started"
+}
+
+func (*PluginsVerifyCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (*PluginsVerifyCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{}
+}
+
+// sortedPlugins returns the plugins sorted by kind and name.
+func sortedPlugins(plugins []PluginComponent) []PluginComponent {
+	result := make([]PluginComponent, len(plugins))
+	copy(result, plugins)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func isPluginURL(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// pluginSourceName returns the file name of the plugin source.
+func pluginSourceName(source string) string {
+	if isPluginURL(source) {
+		u, _ := url.Parse(source)
+		return path.Base(u.Path)
+	}
+
+	return filepath.Base(source)
+}
+
+// installPlugins installs the plugins in the file, which is either an
+// archive or a plugin binary, into dir. It returns the paths of the
+// installed plugins.
+func installPlugins(file, name, dir string, force bool) ([]string, error) {
+	var installed []string
+	install := func(base string, r io.Reader) error {
+		target, err := installPlugin(dir, base, r, force)
+		if err != nil {
+			return err
+		}
+
+		installed = append(installed, target)
+		return nil
+	}
+
+	var err error
+	switch lower := strings.ToLower(name); {
+	case strings.HasSuffix(lower, ".zip"):
+		err = installZipPlugins(file, install)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		err = installTarPlugins(file, install)
+	default:
+		if !isPluginFile(name) {
+			return nil, fmt.Errorf(
+				"%s is not a plugin, plugins are named packer-TYPE-NAME "+
+					"or packer-plugin-NAME", name)
+		}
+
+		var f *os.File
+		f, err = os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		err = install(name, f)
+	}
+	if err != nil {
+		return installed, err
+	}
+
+	if len(installed) == 0 {
+		return nil, fmt.Errorf("%s contains no plugins", name)
+	}
+
+	return installed, nil
+}
+
+func installZipPlugins(file string, install func(string, io.Reader) error) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		base := path.Base(zf.Name)
+		if !zf.Mode().IsRegular() || !isPluginFile(base) {
+			continue
+		}
+
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = install(base, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func installTarPlugins(file string, install func(string, io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		base := path.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !isPluginFile(base) {
+			continue
+		}
+
+		if err := install(base, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// installPlugin writes the plugin binary to dir. The binary is written to
+// a temporary file first, so that a plugin is never half installed.
+func installPlugin(dir, name string, r io.Reader, force bool) (string, error) {
+	target := filepath.Join(dir, name)
+	if _, err := os.Stat(target); err == nil && !force {
+		return "", fmt.Errorf(
+			"%s is already installed, use -force to replace it", target)
+	}
+
+	f, err := ioutil.TempFile(dir, ".install-"+name)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS == "windows" {
+		// Windows can't rename over an existing file
+		os.Remove(target)
+	}
+	if err := os.Rename(f.Name(), target); err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+func isPluginFile(name string) bool {
+	if runtime.GOOS == "windows" && strings.ToLower(filepath.Ext(name)) != ".exe" {
+		return false
+	}
+
+	return pluginFileRegexp.MatchString(name)
+}
@@ -0,0 +1,225 @@
+package command
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPluginHome points the Packer configuration directory to a temporary
+// directory, and returns the plugin directory in it along with a function
+// to clean up.
+func testPluginHome(t *testing.T) (string, func()) {
+	home, err := ioutil.TempDir("", "packer-plugins")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	oldHome := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+
+	return filepath.Join(home, ".packer.d", "plugins"), func() {
+		os.Setenv("HOME", oldHome)
+		os.RemoveAll(home)
+	}
+}
+
+func testPluginZip(t *testing.T, dir string, files map[string]string) string {
+	path := filepath.Join(dir, "plugins.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		w.Write([]byte(contents))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return path
+}
+
+func TestPluginsList(t *testing.T) {
+	m := testMeta(t)
+	m.Plugins = func() []PluginComponent {
+		return []PluginComponent{
+			{Kind: "provisioner", Name: "shell", Path: "/bin/packer", Origin: PluginOriginInternal},
+			{Kind: "builder", Name: "acme", Path: "/plugins/packer-plugin-acme", Origin: PluginOriginDiscovered},
+		}
+	}
+	c := &PluginsListCommand{Meta: m}
+
+	if code := c.Run(nil); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	out, _ := outputCommand(t, c.Meta)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("bad: %s", out)
+	}
+	if !strings.HasPrefix(lines[1], "builder") || !strings.Contains(lines[1], "discovered") {
+		t.Fatalf("bad: %s", out)
+	}
+	if !strings.HasPrefix(lines[2], "provisioner") || !strings.Contains(lines[2], "internal") {
+		t.Fatalf("bad: %s", out)
+	}
+}
+
+func TestPluginsInstall_binary(t *testing.T) {
+	dir, cleanup := testPluginHome(t)
+	defer cleanup()
+
+	src := filepath.Join(filepath.Dir(dir), "packer-builder-acme")
+	os.MkdirAll(filepath.Dir(src), 0755)
+	if err := ioutil.WriteFile(src, []byte("acme"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{src}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, "packer-builder-acme"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Fatalf("bad mode: %s", fi.Mode())
+	}
+
+	// Installing again must not replace it, unless forced
+	c = &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{src}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	c = &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"-force", src}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+}
+
+func TestPluginsInstall_notPlugin(t *testing.T) {
+	dir, cleanup := testPluginHome(t)
+	defer cleanup()
+
+	src := filepath.Join(filepath.Dir(dir), "acme")
+	os.MkdirAll(filepath.Dir(src), 0755)
+	if err := ioutil.WriteFile(src, []byte("acme"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{src}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+}
+
+func TestPluginsInstall_zip(t *testing.T) {
+	dir, cleanup := testPluginHome(t)
+	defer cleanup()
+
+	os.MkdirAll(dir, 0755)
+	src := testPluginZip(t, filepath.Dir(dir), map[string]string{
+		"acme/packer-plugin-acme":     "acme",
+		"acme/packer-provisioner-foo": "foo",
+		"acme/README.md":              "readme",
+	})
+
+	c := &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{src}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "packer-plugin-acme"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "acme" {
+		t.Fatalf("bad: %s", data)
+	}
+}
+
+func TestPluginsInstall_url(t *testing.T) {
+	_, cleanup := testPluginHome(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("acme"))
+	}))
+	defer ts.Close()
+
+	src := ts.URL + "/packer-builder-acme"
+	sum := sha256.Sum256([]byte("acme"))
+	checksum := hex.EncodeToString(sum[:])
+
+	// A checksum is required for URLs
+	c := &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{src}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// A wrong checksum is refused
+	c = &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"-sha256=" + strings.Repeat("0", 64), src}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if _, errOut := outputCommand(t, c.Meta); !strings.Contains(errOut, "Checksum mismatch") {
+		t.Fatalf("bad: %s", errOut)
+	}
+
+	c = &PluginsInstallCommand{Meta: testMeta(t)}
+	if code := c.Run([]string{"-sha256=" + strings.ToUpper(checksum), src}); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+}
+
+func TestPluginsVerify(t *testing.T) {
+	m := testMeta(t)
+	m.Plugins = func() []PluginComponent {
+		return []PluginComponent{
+			{Kind: "builder", Name: "test", Origin: PluginOriginDiscovered},
+			{Kind: "builder", Name: "internal", Origin: PluginOriginInternal},
+		}
+	}
+	c := &PluginsVerifyCommand{Meta: m}
+	if code := c.Run(nil); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	m = testMeta(t)
+	m.Plugins = func() []PluginComponent {
+		return []PluginComponent{
+			{Kind: "builder", Name: "missing", Origin: PluginOriginConfig},
+		}
+	}
+	c = &PluginsVerifyCommand{Meta: m}
+	if code := c.Run(nil); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+}
@@ -57,7 +57,15 @@ func (c *ValidateCommand) Run(args []string) int {
 	warnings := make(map[string][]string)
 
 	// Get the builds we care about
-	buildNames := c.Meta.BuildNames(core)
+	var dependencies map[string][]string
+	buildNames, err := c.Meta.BuildNames(core)
+	if err == nil {
+		buildNames, dependencies, err = sortBuildNames(core, buildNames)
+	}
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
 	builds := make([]packer.Build, 0, len(buildNames))
 	for _, n := range buildNames {
 		b, err := core.Build(n)
@@ -74,7 +82,7 @@ func (c *ValidateCommand) Run(args []string) int {
 	// Check the configuration of all builds
 	for _, b := range builds {
 		log.Printf("Preparing build: %s", b.Name())
-		warns, err := b.Prepare()
+		warns, err := prepareBuild(b, dependencies[b.Name()])
 		if len(warns) > 0 {
 			warnings[b.Name()] = warns
 		}
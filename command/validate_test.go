@@ -42,3 +42,27 @@ func TestValidateCommandBadVersion(t *testing.T) {
 	}
 	t.Log(stdout)
 }
+
+func TestValidateCommandDependsOn(t *testing.T) {
+	c := &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+
+	args := []string{
+		filepath.Join(testFixture("build-depends-on"), "template.json"),
+	}
+	if code := c.Run(args); code != 0 {
+		fatalCommand(t, c.Meta)
+	}
+
+	// The configuration of a build that depends on another is checked
+	c = &ValidateCommand{
+		Meta: testMetaFile(t),
+	}
+	args = []string{
+		filepath.Join(testFixture("build-depends-on-invalid"), "template.json"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad exit code: %d", code)
+	}
+}
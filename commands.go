@@ -35,6 +35,30 @@ func init() {
 			}, nil
 		},
 
+		"plugins": func() (cli.Command, error) {
+			return &command.PluginsCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"plugins install": func() (cli.Command, error) {
+			return &command.PluginsInstallCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"plugins list": func() (cli.Command, error) {
+			return &command.PluginsListCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
+		"plugins verify": func() (cli.Command, error) {
+			return &command.PluginsVerifyCommand{
+				Meta: *CommandMeta,
+			}, nil
+		},
+
 		"push": func() (cli.Command, error) {
 			return &command.PushCommand{
 				Meta: *CommandMeta,
@@ -1,3 +1,5 @@
+// Package chroot contains the communicator and steps of the builders that
+// provision a root filesystem by chrooting into it, like amazon-chroot.
 package chroot
 
 import (
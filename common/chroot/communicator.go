@@ -20,11 +20,25 @@ import (
 type Communicator struct {
 	Chroot     string
 	CmdWrapper CommandWrapper
+
+	// ChrootCommand returns the command that runs the given shell command
+	// within the chroot. Commands are run with chroot if it is nil.
+	ChrootCommand ChrootCommand
 }
 
+// ChrootCommand is a type that given the path of a chroot and a shell
+// command, returns the command that runs it within the chroot.
+type ChrootCommand func(chroot string, command string) string
+
 func (c *Communicator) Start(cmd *packer.RemoteCmd) error {
-	command, err := c.CmdWrapper(
-		fmt.Sprintf("chroot %s /bin/sh -c \"%s\"", c.Chroot, cmd.Command))
+	chrootCommand := c.ChrootCommand
+	if chrootCommand == nil {
+		chrootCommand = func(chroot string, command string) string {
+			return fmt.Sprintf("chroot %s /bin/sh -c \"%s\"", chroot, command)
+		}
+	}
+
+	command, err := c.CmdWrapper(chrootCommand(c.Chroot, cmd.Command))
 	if err != nil {
 		return err
 	}
@@ -64,7 +78,7 @@ func (c *Communicator) Start(cmd *packer.RemoteCmd) error {
 func (c *Communicator) Upload(dst string, r io.Reader, fi *os.FileInfo) error {
 	dst = filepath.Join(c.Chroot, dst)
 	log.Printf("Uploading to chroot dir: %s", dst)
-	tf, err := ioutil.TempFile("", "packer-amazon-chroot")
+	tf, err := ioutil.TempFile("", "packer-chroot")
 	if err != nil {
 		return fmt.Errorf("Error preparing shell script: %s", err)
 	}
@@ -119,7 +133,7 @@ func (c *Communicator) UploadDir(dst string, src string, exclude []string) error
 }
 
 func (c *Communicator) DownloadDir(src string, dst string, exclude []string) error {
-	return fmt.Errorf("DownloadDir is not implemented for amazon-chroot")
+	return fmt.Errorf("DownloadDir is not implemented for chroot")
 }
 
 func (c *Communicator) Download(src string, w io.Writer) error {
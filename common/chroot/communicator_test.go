@@ -0,0 +1,44 @@
+package chroot
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestCommunicator_ImplementsCommunicator(t *testing.T) {
+	var raw interface{}
+	raw = &Communicator{}
+	if _, ok := raw.(packer.Communicator); !ok {
+		t.Fatalf("Communicator should be a communicator")
+	}
+}
+
+func TestCommunicator_ChrootCommand(t *testing.T) {
+	c := &Communicator{
+		Chroot: "/var/chroot",
+		CmdWrapper: func(command string) (string, error) {
+			return command, nil
+		},
+		// Run the commands on the host
+		ChrootCommand: func(chroot string, command string) string {
+			return fmt.Sprintf("echo %s; /bin/sh -c \"%s\"", chroot, command)
+		},
+	}
+
+	var stdout bytes.Buffer
+	cmd := &packer.RemoteCmd{
+		Command: "echo hello; exit 3",
+		Stdout:  &stdout,
+	}
+	if err := c.Start(cmd); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cmd.Wait()
+
+	if cmd.ExitStatus != 3 || stdout.String() != "/var/chroot\nhello\n" {
+		t.Fatalf("bad: %d %q", cmd.ExitStatus, stdout.String())
+	}
+}
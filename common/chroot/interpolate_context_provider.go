@@ -0,0 +1,9 @@
+package chroot
+
+import "github.com/hashicorp/packer/template/interpolate"
+
+// interpolateContextProvider is the config of a builder, whose template
+// context the commands of the steps are rendered with.
+type interpolateContextProvider interface {
+	GetContext() interpolate.Context
+}
@@ -0,0 +1,28 @@
+package chroot
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+// RunCommand runs the command with the command wrapper and returns its
+// output. The error contains the standard error of the command if it
+// fails.
+func RunCommand(wrappedCommand CommandWrapper, command string) (string, error) {
+	command, err := wrappedCommand(command)
+	if err != nil {
+		return "", fmt.Errorf("Error wrapping command: %s", err)
+	}
+
+	log.Printf("Executing: %s", command)
+	var stdout, stderr bytes.Buffer
+	cmd := ShellCommand(command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s\nStderr: %s", err, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,25 @@
+package chroot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	wrapper := func(command string) (string, error) {
+		return "/bin/sh -c '" + command + "'", nil
+	}
+
+	out, err := RunCommand(wrapper, "echo hello")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if out != "hello\n" {
+		t.Fatalf("bad: %q", out)
+	}
+
+	_, err = RunCommand(wrapper, "echo failed >&2; exit 1")
+	if err == nil || !strings.Contains(err.Error(), "Stderr: failed") {
+		t.Fatalf("bad: %v", err)
+	}
+}
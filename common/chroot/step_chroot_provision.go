@@ -10,6 +10,8 @@ import (
 
 // StepChrootProvision provisions the instance within a chroot.
 type StepChrootProvision struct {
+	// ChrootCommand is the ChrootCommand of the communicator.
+	ChrootCommand ChrootCommand
 }
 
 func (s *StepChrootProvision) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
@@ -20,8 +22,9 @@ func (s *StepChrootProvision) Run(_ context.Context, state multistep.StateBag) m
 
 	// Create our communicator
 	comm := &Communicator{
-		Chroot:     mountPath,
-		CmdWrapper: wrappedCommand,
+		Chroot:        mountPath,
+		CmdWrapper:    wrappedCommand,
+		ChrootCommand: s.ChrootCommand,
 	}
 
 	// Provision
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 
 	"github.com/hashicorp/packer/helper/multistep"
@@ -12,29 +13,46 @@ import (
 )
 
 // StepCopyFiles copies some files from the host into the chroot environment.
+// Files of the chroot that are overwritten are moved aside, and put back
+// when the copies are cleaned up.
 //
 // Produces:
 //   copy_files_cleanup CleanupFunc - A function to clean up the copied files
 //   early.
 type StepCopyFiles struct {
-	files []string
+	Files []string
+
+	files   []string
+	backups map[string]string
 }
 
 func (s *StepCopyFiles) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
-	config := state.Get("config").(*Config)
 	mountPath := state.Get("mount_path").(string)
 	ui := state.Get("ui").(packer.Ui)
 	wrappedCommand := state.Get("wrappedCommand").(CommandWrapper)
 	stderr := new(bytes.Buffer)
 
-	s.files = make([]string, 0, len(config.CopyFiles))
-	if len(config.CopyFiles) > 0 {
+	s.files = make([]string, 0, len(s.Files))
+	s.backups = make(map[string]string)
+	if len(s.Files) > 0 {
 		ui.Say("Copying files from host to chroot...")
-		for _, path := range config.CopyFiles {
+		for _, path := range s.Files {
 			ui.Message(path)
 			chrootPath := filepath.Join(mountPath, path)
 			log.Printf("Copying '%s' to '%s'", path, chrootPath)
 
+			if _, err := os.Lstat(chrootPath); err == nil {
+				backupPath := chrootPath + ".packer-backup"
+				log.Printf("Moving '%s' to '%s'", chrootPath, backupPath)
+				if _, err := RunCommand(wrappedCommand, fmt.Sprintf("mv %s %s", chrootPath, backupPath)); err != nil {
+					err := fmt.Errorf("Error moving aside %s: %s", chrootPath, err)
+					state.Put("error", err)
+					ui.Error(err.Error())
+					return multistep.ActionHalt
+				}
+				s.backups[chrootPath] = backupPath
+			}
+
 			cmdText, err := wrappedCommand(fmt.Sprintf("cp --remove-destination %s %s", path, chrootPath))
 			if err != nil {
 				err := fmt.Errorf("Error building copy command: %s", err)
@@ -86,6 +104,14 @@ func (s *StepCopyFiles) CleanupFunc(state multistep.StateBag) error {
 		}
 	}
 
+	for file, backup := range s.backups {
+		log.Printf("Restoring: %s", file)
+		if _, err := RunCommand(wrappedCommand, fmt.Sprintf("mv %s %s", backup, file)); err != nil {
+			return fmt.Errorf("Error restoring %s: %s", file, err)
+		}
+	}
+
 	s.files = nil
+	s.backups = nil
 	return nil
 }
@@ -0,0 +1,85 @@
+package chroot
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestCopyFilesCleanupFunc_ImplementsCleanupFunc(t *testing.T) {
+	var raw interface{}
+	raw = new(StepCopyFiles)
+	if _, ok := raw.(Cleanup); !ok {
+		t.Fatalf("cleanup func should be a CleanupFunc")
+	}
+}
+
+func TestStepCopyFiles_restore(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	host := filepath.Join(td, "host")
+	mountPath := filepath.Join(td, "chroot")
+	for _, dir := range []string{host, mountPath + host} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	existing := filepath.Join(host, "existing")
+	added := filepath.Join(host, "added")
+	for _, path := range []string{existing, added} {
+		if err := ioutil.WriteFile(path, []byte("host"), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := ioutil.WriteFile(mountPath+existing, []byte("chroot"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("mount_path", mountPath)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	state.Put("wrappedCommand", CommandWrapper(func(command string) (string, error) {
+		return command, nil
+	}))
+
+	step := &StepCopyFiles{Files: []string{existing, added}}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v: %s", action, state.Get("error"))
+	}
+
+	for _, path := range []string{existing, added} {
+		data, err := ioutil.ReadFile(mountPath + path)
+		if err != nil || string(data) != "host" {
+			t.Fatalf("%s should be copied: %q %v", path, data, err)
+		}
+	}
+
+	if err := step.CleanupFunc(state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(mountPath + existing)
+	if err != nil || string(data) != "chroot" {
+		t.Fatalf("the original file should be restored: %q %v", data, err)
+	}
+	if _, err := os.Stat(mountPath + added); !os.IsNotExist(err) {
+		t.Fatalf("the added file should be removed: %v", err)
+	}
+	if _, err := os.Stat(mountPath + existing + ".packer-backup"); !os.IsNotExist(err) {
+		t.Fatalf("the backup should be gone: %v", err)
+	}
+}
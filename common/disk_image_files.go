@@ -0,0 +1,41 @@
+package common
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// The extents of split and flat vmdks, which are read through the
+// descriptor of the disk instead. ESXi names the extent of a flat disk
+// "<name>-flat.vmdk".
+var vmdkExtentRe = regexp.MustCompile(`(-(s|f)[0-9]{3}|-flat)\.vmdk$`)
+
+// DiskImageFiles returns the disk images of an artifact. The artifacts of
+// the qemu and disk-image builders name their disk, the disks of other
+// artifacts are recognized by their extension.
+func DiskImageFiles(artifact packer.Artifact) []string {
+	if name, ok := artifact.State("diskName").(string); ok && name != "" {
+		for _, f := range artifact.Files() {
+			if filepath.Base(f) == name {
+				return []string{f}
+			}
+		}
+	}
+
+	var disks []string
+	for _, f := range artifact.Files() {
+		switch strings.ToLower(filepath.Ext(f)) {
+		case ".img", ".qcow2", ".raw", ".vdi", ".vhd", ".vhdx":
+			disks = append(disks, f)
+		case ".vmdk":
+			if !vmdkExtentRe.MatchString(f) {
+				disks = append(disks, f)
+			}
+		}
+	}
+
+	return disks
+}
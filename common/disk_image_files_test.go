@@ -0,0 +1,43 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestDiskImageFiles(t *testing.T) {
+	// The disk of qemu artifacts is named
+	artifact := &packer.MockArtifact{
+		FilesValue:  []string{"output/packer-qemu", "output/efivars.fd"},
+		StateValues: map[string]interface{}{"diskName": "packer-qemu"},
+	}
+	if disks := DiskImageFiles(artifact); !reflect.DeepEqual(disks, []string{"output/packer-qemu"}) {
+		t.Fatalf("bad: %#v", disks)
+	}
+
+	// The extents of vmdks are not disks
+	artifact = &packer.MockArtifact{
+		FilesValue: []string{
+			"output/disk.vmdk", "output/disk-s001.vmdk", "output/disk-s002.vmdk",
+			"output/packer.vmx", "output/data.raw",
+		},
+	}
+	if disks := DiskImageFiles(artifact); !reflect.DeepEqual(disks, []string{"output/disk.vmdk", "output/data.raw"}) {
+		t.Fatalf("bad: %#v", disks)
+	}
+
+	// The extent of an ESXi flat vmdk is not a disk either
+	artifact = &packer.MockArtifact{
+		FilesValue: []string{"output/disk.vmdk", "output/disk-flat.vmdk"},
+	}
+	if disks := DiskImageFiles(artifact); !reflect.DeepEqual(disks, []string{"output/disk.vmdk"}) {
+		t.Fatalf("bad: %#v", disks)
+	}
+
+	artifact = &packer.MockArtifact{FilesValue: []string{"package.txt"}}
+	if disks := DiskImageFiles(artifact); len(disks) != 0 {
+		t.Fatalf("bad: %#v", disks)
+	}
+}
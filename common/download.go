@@ -205,6 +205,16 @@ func (d *DownloadClient) Get() (string, error) {
 	return finalPath, err
 }
 
+// Progress returns the number of bytes downloaded so far and the total
+// size of the download. ok is false if the download hasn't started yet.
+func (d *DownloadClient) Progress() (current, total uint64, ok bool) {
+	if d.downloader == nil {
+		return 0, 0, false
+	}
+
+	return d.downloader.Progress(), d.downloader.Total(), true
+}
+
 func (d *DownloadClient) PercentProgress() int {
 	if d.downloader == nil {
 		return -1
@@ -0,0 +1,211 @@
+package common
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractDirTar extracts a tar stream of the remote directory src into the
+// local directory dst, for communicators that download directories as a
+// tar stream. The entries of the stream must be rooted at the base name of
+// src, the way "tar -cf - -C /parent src" and "docker cp" create them.
+//
+// Like directory uploads, the trailing slash of src matters: if src is
+// "/foo", its contents are extracted to dst/foo, and if it is "/foo/",
+// they are extracted to dst directly.
+//
+// Entries whose path relative to src, or whose base name, match one of the
+// exclude patterns aren't extracted. The patterns use the syntax of
+// filepath.Match with forward slashes, and excluding a directory excludes
+// everything in it. Modes, modification times and symlinks are preserved.
+func ExtractDirTar(r io.Reader, src string, dst string, exclude []string) error {
+	for _, pattern := range exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid exclude pattern '%s': %s", pattern, err)
+		}
+	}
+
+	base := path.Base(strings.TrimRight(filepath.ToSlash(src), "/"))
+	root := dst
+	if !strings.HasSuffix(src, "/") {
+		root = filepath.Join(dst, base)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	// The modes of directories are set once everything is extracted, so
+	// that read-only directories can be extracted into.
+	type dirMode struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dirMode
+	var excluded []string
+
+	archive := tar.NewReader(r)
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read the tar stream: %s", err)
+		}
+
+		rel, err := extractRelPath(hdr.Name, base)
+		if err != nil {
+			return err
+		}
+
+		if rel != "" && extractExcluded(rel, exclude, excluded) {
+			log.Printf("Excluding from download: %s", rel)
+			if hdr.Typeflag == tar.TypeDir {
+				excluded = append(excluded, rel)
+			}
+			continue
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(rel))
+		mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+		// Don't follow the symlinks of the stream out of the destination
+		if rel != "" {
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+			if err != nil {
+				return err
+			}
+			if parent != realRoot && !strings.HasPrefix(parent, realRoot+string(filepath.Separator)) {
+				return fmt.Errorf("Refusing to extract outside of the destination: %s", rel)
+			}
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			// The destination keeps its mode if only the contents of src
+			// are extracted into it
+			if rel != "" || root != dst {
+				dirs = append(dirs, dirMode{target, hdr})
+			}
+			continue
+		case tar.TypeReg, tar.TypeRegA:
+			if err := extractFile(target, archive, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			os.RemoveAll(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			continue
+		case tar.TypeLink:
+			linkRel, err := extractRelPath(hdr.Linkname, base)
+			if err != nil {
+				return err
+			}
+			os.RemoveAll(target)
+			if err := os.Link(filepath.Join(root, filepath.FromSlash(linkRel)), target); err != nil {
+				return err
+			}
+			continue
+		default:
+			log.Printf("Skipping unsupported file type %q: %s", hdr.Typeflag, rel)
+			continue
+		}
+
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	// Set the modes of the directories, the deepest ones first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		mode := d.hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err := os.Chmod(d.path, mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.hdr.ModTime, d.hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractRelPath returns the path of the tar entry with the given name,
+// relative to the downloaded directory with the given base name.
+func extractRelPath(name string, base string) (string, error) {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if name == base {
+		return "", nil
+	}
+
+	rel := strings.TrimPrefix(name, base+"/")
+	if rel == name || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("Unexpected path in the tar stream: %s", name)
+	}
+
+	return rel, nil
+}
+
+// extractExcluded returns true if the given relative path matches one of
+// the exclude patterns, or is in one of the excluded directories.
+func extractExcluded(rel string, exclude []string, excludedDirs []string) bool {
+	for _, dir := range excludedDirs {
+		if strings.HasPrefix(rel, dir+"/") {
+			return true
+		}
+	}
+
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func extractFile(target string, r io.Reader, mode os.FileMode) error {
+	// Replace whatever is there, which may be a symlink or read-only
+	if _, err := os.Lstat(target); err == nil {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0200)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
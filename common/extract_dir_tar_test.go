@@ -0,0 +1,184 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testTarEntry struct {
+	Name     string
+	Type     byte
+	Mode     int64
+	Contents string
+	Linkname string
+}
+
+func testDirTar(t *testing.T, entries []testTarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.Name,
+			Typeflag: e.Type,
+			Mode:     e.Mode,
+			Size:     int64(len(e.Contents)),
+			Linkname: e.Linkname,
+			ModTime:  time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := w.Write([]byte(e.Contents)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &buf
+}
+
+var testReportsTar = []testTarEntry{
+	{Name: "reports/", Type: tar.TypeDir, Mode: 0750},
+	{Name: "reports/summary.txt", Type: tar.TypeReg, Mode: 0640, Contents: "ok"},
+	{Name: "reports/run.sh", Type: tar.TypeReg, Mode: 0755, Contents: "#!/bin/sh"},
+	{Name: "reports/latest", Type: tar.TypeSymlink, Linkname: "summary.txt"},
+	{Name: "reports/tmp/", Type: tar.TypeDir, Mode: 0755},
+	{Name: "reports/tmp/scratch", Type: tar.TypeReg, Mode: 0644, Contents: "x"},
+	{Name: "reports/junit/", Type: tar.TypeDir, Mode: 0755},
+	{Name: "reports/junit/a.xml", Type: tar.TypeReg, Mode: 0644, Contents: "<a/>"},
+	{Name: "reports/junit/a.log", Type: tar.TypeReg, Mode: 0644, Contents: "log"},
+}
+
+func TestExtractDirTar(t *testing.T) {
+	dst, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dst)
+
+	r := testDirTar(t, testReportsTar)
+	if err := ExtractDirTar(r, "/var/reports", dst, []string{"tmp", "*.log"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	root := filepath.Join(dst, "reports")
+	fi, err := os.Stat(root)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0750 {
+		t.Fatalf("bad: %s", fi.Mode())
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "summary.txt"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "ok" {
+		t.Fatalf("bad: %s", data)
+	}
+
+	fi, err = os.Stat(filepath.Join(root, "run.sh"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Fatalf("bad: %s", fi.Mode())
+	}
+	if !fi.ModTime().Equal(time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("bad: %s", fi.ModTime())
+	}
+
+	link, err := os.Readlink(filepath.Join(root, "latest"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if link != "summary.txt" {
+		t.Fatalf("bad: %s", link)
+	}
+
+	for _, excluded := range []string{"tmp", "junit/a.log"} {
+		if _, err := os.Lstat(filepath.Join(root, excluded)); !os.IsNotExist(err) {
+			t.Fatalf("%s should be excluded", excluded)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "junit", "a.xml")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestExtractDirTar_contents(t *testing.T) {
+	dst, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dst)
+	os.Chmod(dst, 0700)
+
+	r := testDirTar(t, testReportsTar)
+	if err := ExtractDirTar(r, "/var/reports/", dst, []string{"junit/*"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "summary.txt")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "junit", "a.xml")); !os.IsNotExist(err) {
+		t.Fatal("junit/a.xml should be excluded")
+	}
+
+	// The destination keeps its mode
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Fatalf("bad: %s", fi.Mode())
+	}
+}
+
+func TestExtractDirTar_outside(t *testing.T) {
+	dst, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dst)
+
+	cases := [][]testTarEntry{
+		{
+			{Name: "reports/../../evil", Type: tar.TypeReg, Mode: 0644, Contents: "x"},
+		},
+		{
+			{Name: "other/evil", Type: tar.TypeReg, Mode: 0644, Contents: "x"},
+		},
+		{
+			{Name: "reports/escape", Type: tar.TypeSymlink, Linkname: dst},
+			{Name: "reports/escape/evil", Type: tar.TypeReg, Mode: 0644, Contents: "x"},
+		},
+	}
+
+	for i, entries := range cases {
+		r := testDirTar(t, entries)
+		if err := ExtractDirTar(r, "reports", filepath.Join(dst, "out"), nil); err == nil {
+			t.Fatalf("%d: should error", i)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "evil")); !os.IsNotExist(err) {
+		t.Fatal("should not extract outside of the destination")
+	}
+}
+
+func TestExtractDirTar_badPattern(t *testing.T) {
+	r := testDirTar(t, testReportsTar)
+	if err := ExtractDirTar(r, "reports", "unused", []string{"["}); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// InteractiveShell lets the user work on the machine from their terminal.
+// If the communicator supports interactive shells, such as SSH, a shell is
+// attached to the terminal of the Ui until it exits. Otherwise, such as
+// for WinRM, the commands entered by the user are run one at a time, until
+// they type 'exit'.
+func InteractiveShell(ui packer.Ui, comm packer.Communicator) error {
+	if sc, ok := comm.(packer.ShellCommunicator); ok {
+		err := terminalShell(ui, sc)
+		if err == nil {
+			return nil
+		}
+
+		ui.Error(fmt.Sprintf("Couldn't open an interactive shell: %s", err))
+	}
+
+	ui.Say("Running commands on the machine one at a time. Type 'exit' to stop.")
+	return commandLoop(ui, comm)
+}
+
+// terminalShell runs the login shell on the machine, attached to the
+// terminal of the user, until it exits.
+func terminalShell(ui packer.Ui, comm packer.ShellCommunicator) error {
+	term, err := packer.UiTerminal(ui)
+	if err != nil {
+		return err
+	}
+
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm"
+	}
+
+	cmd := &packer.RemoteCmd{
+		Stdin:  term,
+		Stdout: term,
+		Stderr: term,
+	}
+
+	width, height := term.Size()
+	if err := comm.StartShell(cmd, termType, width, height); err != nil {
+		term.Close()
+		return err
+	}
+
+	cmd.Wait()
+	if err := term.Close(); err != nil {
+		return err
+	}
+
+	ui.Say(fmt.Sprintf("Shell exited with status %d", cmd.ExitStatus))
+	return nil
+}
+
+// commandLoop runs the commands entered by the user on the machine, until
+// the user types 'exit'.
+func commandLoop(ui packer.Ui, comm packer.Communicator) error {
+	for {
+		line, err := ui.Ask("Command:")
+		if err != nil {
+			return err
+		}
+
+		command := strings.TrimSpace(line)
+		switch command {
+		case "":
+			continue
+		case "exit":
+			return nil
+		}
+
+		cmd := &packer.RemoteCmd{Command: command}
+		if err := cmd.StartWithUi(comm, ui); err != nil {
+			ui.Error(fmt.Sprintf("Error running command: %s", err))
+			continue
+		}
+
+		if cmd.ExitStatus != 0 {
+			ui.Error(fmt.Sprintf("Command exited with status %d", cmd.ExitStatus))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func TestInteractiveShell_commandLoop(t *testing.T) {
+	var out bytes.Buffer
+	ui := &packer.BasicUi{
+		Reader: strings.NewReader("\nuname -a\nexit\n"),
+		Writer: &out,
+	}
+	comm := &packer.MockCommunicator{
+		StartStdout:     "Linux\n",
+		StartExitStatus: 1,
+	}
+
+	// The mock communicator doesn't support interactive shells, so the
+	// commands are run one at a time.
+	if err := InteractiveShell(ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !comm.StartCalled || comm.StartCmd.Command != "uname -a" {
+		t.Fatalf("bad: %#v", comm.StartCmd)
+	}
+	if !strings.Contains(out.String(), "Linux") {
+		t.Fatalf("bad: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "status 1") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
@@ -1,10 +1,13 @@
 package common
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/exec"
 	"reflect"
 	"strings"
 	"time"
@@ -22,7 +25,22 @@ func newRunner(steps []multistep.Step, config PackerConfig, ui packer.Ui) (multi
 		}
 	case "ask":
 		for i, step := range steps {
-			steps[i] = askStep{step, ui}
+			steps[i] = askStep{step, ui, false}
+		}
+	case "shell":
+		for i, step := range steps {
+			steps[i] = askStep{step, ui, true}
+		}
+	case "run-cleanup-provisioner":
+		for i, step := range steps {
+			steps[i] = cleanupScriptStep{step, ui, config}
+		}
+	}
+
+	// Mark the step boundaries, so that they show up in build transcripts
+	if config.PackerStepMarkers {
+		for i, step := range steps {
+			steps[i] = markedStep{step, ui}
 		}
 	}
 
@@ -57,6 +75,39 @@ func typeName(i interface{}) string {
 	return reflect.Indirect(reflect.ValueOf(i)).Type().Name()
 }
 
+// stepName returns the human readable name of the step, looking through
+// the wrappers added by the runner.
+func stepName(step multistep.Step) string {
+	if wrapped, ok := step.(interface {
+		InnerStepName() string
+	}); ok {
+		return wrapped.InnerStepName()
+	}
+
+	return typeName(step)
+}
+
+// markedStep outputs the machine-readable "step" message before the step
+// is run and before it is cleaned up.
+type markedStep struct {
+	step multistep.Step
+	ui   packer.Ui
+}
+
+func (s markedStep) InnerStepName() string {
+	return stepName(s.step)
+}
+
+func (s markedStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	s.ui.Machine("step", "run", s.InnerStepName())
+	return s.step.Run(ctx, state)
+}
+
+func (s markedStep) Cleanup(state multistep.StateBag) {
+	s.ui.Machine("step", "cleanup", s.InnerStepName())
+	s.step.Cleanup(state)
+}
+
 type abortStep struct {
 	step multistep.Step
 	ui   packer.Ui
@@ -86,9 +137,12 @@ func (s abortStep) Cleanup(state multistep.StateBag) {
 	s.step.Cleanup(state)
 }
 
+// askStep asks the user what to do when the step fails. If shell is true,
+// a shell on the machine is opened right away, before asking.
 type askStep struct {
-	step multistep.Step
-	ui   packer.Ui
+	step  multistep.Step
+	ui    packer.Ui
+	shell bool
 }
 
 func (s askStep) InnerStepName() string {
@@ -108,7 +162,7 @@ func (s askStep) Run(ctx context.Context, state multistep.StateBag) (action mult
 			s.ui.Error(fmt.Sprintf("%s", err))
 		}
 
-		switch ask(s.ui, typeName(s.step), state) {
+		switch ask(s.ui, typeName(s.step), state, s.shell) {
 		case askCleanup:
 			return
 		case askAbort:
@@ -129,14 +183,37 @@ const (
 	askCleanup askResponse = iota
 	askAbort
 	askRetry
+	askShell
 )
 
-func ask(ui packer.Ui, name string, state multistep.StateBag) askResponse {
+func ask(ui packer.Ui, name string, state multistep.StateBag, shellFirst bool) askResponse {
 	ui.Say(fmt.Sprintf("Step %q failed", name))
 
+	// A shell can be opened if the machine is reachable, and the user is
+	// at a terminal.
+	comm, _ := state.Get("communicator").(packer.Communicator)
+	shell := comm != nil && packer.UiIsTerminal(ui)
+
+	if shell && shellFirst {
+		askInteractiveShell(ui, comm)
+	}
+
+	for {
+		response := askWait(ui, state, shell)
+		if response != askShell {
+			return response
+		}
+
+		askInteractiveShell(ui, comm)
+	}
+}
+
+// askWait asks the user what to do, until they answer or the build is
+// cancelled.
+func askWait(ui packer.Ui, state multistep.StateBag, shell bool) askResponse {
 	result := make(chan askResponse)
 	go func() {
-		result <- askPrompt(ui)
+		result <- askPrompt(ui, shell)
 	}()
 
 	for {
@@ -151,9 +228,14 @@ func ask(ui packer.Ui, name string, state multistep.StateBag) askResponse {
 	}
 }
 
-func askPrompt(ui packer.Ui) askResponse {
+func askPrompt(ui packer.Ui, shell bool) askResponse {
+	query := "[c] Clean up and exit, [a] abort without cleanup, or [r] retry step (build may fail even if retry succeeds)?"
+	if shell {
+		query = "[c] Clean up and exit, [a] abort without cleanup, [r] retry step (build may fail even if retry succeeds), or [s] open a shell on the machine?"
+	}
+
 	for {
-		line, err := ui.Ask("[c] Clean up and exit, [a] abort without cleanup, or [r] retry step (build may fail even if retry succeeds)?")
+		line, err := ui.Ask(query)
 		if err != nil {
 			log.Printf("Error asking for input: %s", err)
 		}
@@ -166,7 +248,136 @@ func askPrompt(ui packer.Ui) askResponse {
 			return askAbort
 		case 'r':
 			return askRetry
+		case 's':
+			if shell {
+				return askShell
+			}
 		}
 		ui.Say(fmt.Sprintf("Incorrect input: %#v", line))
 	}
 }
+
+func askInteractiveShell(ui packer.Ui, comm packer.Communicator) {
+	ui.Say("Opening a shell on the machine. Exit it to get back to the choices.")
+	if err := InteractiveShell(ui, comm); err != nil {
+		ui.Error(fmt.Sprintf("Error in shell: %s", err))
+	}
+}
+
+// cleanupScriptStep runs the local script configured with -on-error-script
+// when the step fails, before anything is cleaned up. The state of the
+// build is exported to the script as environment variables.
+type cleanupScriptStep struct {
+	step   multistep.Step
+	ui     packer.Ui
+	config PackerConfig
+}
+
+func (s cleanupScriptStep) InnerStepName() string {
+	return typeName(s.step)
+}
+
+func (s cleanupScriptStep) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	action := s.step.Run(ctx, state)
+	if action != multistep.ActionHalt {
+		return action
+	}
+
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return action
+	}
+
+	if s.config.PackerOnErrorScript == "" {
+		return action
+	}
+
+	s.ui.Say(fmt.Sprintf("Step %q failed, running %s before cleaning up",
+		typeName(s.step), s.config.PackerOnErrorScript))
+	if err := runCleanupScript(ctx, s.ui, s.config, typeName(s.step), state); err != nil {
+		s.ui.Error(fmt.Sprintf("Error running %s: %s", s.config.PackerOnErrorScript, err))
+	}
+
+	return action
+}
+
+func (s cleanupScriptStep) Cleanup(state multistep.StateBag) {
+	s.step.Cleanup(state)
+}
+
+// runCleanupScript runs the script configured with -on-error-script,
+// showing its output as it runs.
+func runCleanupScript(ctx context.Context, ui packer.Ui, config PackerConfig, name string, state multistep.StateBag) error {
+	cmd := exec.CommandContext(ctx, config.PackerOnErrorScript)
+	cmd.Env = append(os.Environ(), cleanupScriptEnv(config, name, state)...)
+
+	r, w := io.Pipe()
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			ui.Message(scanner.Text())
+		}
+	}()
+
+	err := cmd.Run()
+	w.Close()
+	<-outputDone
+
+	return err
+}
+
+// cleanupScriptEnv returns the environment variables for the cleanup
+// script. Every value of the state bag that is a string, number or boolean
+// is exported as PACKER_STATE_<KEY>, with the key in upper case, and
+// anything but letters and digits replaced by underscores.
+func cleanupScriptEnv(config PackerConfig, name string, state multistep.StateBag) []string {
+	env := []string{
+		"PACKER_BUILD_NAME=" + config.PackerBuildName,
+		"PACKER_BUILDER_TYPE=" + config.PackerBuilderType,
+		"PACKER_FAILED_STEP=" + name,
+	}
+
+	if err, ok := state.GetOk("error"); ok {
+		env = append(env, fmt.Sprintf("PACKER_ERROR=%s", err))
+	}
+
+	keyed, ok := state.(interface {
+		Keys() []string
+	})
+	if !ok {
+		return env
+	}
+
+	for _, key := range keyed.Keys() {
+		value := reflect.ValueOf(state.Get(key))
+		switch value.Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+		default:
+			continue
+		}
+
+		env = append(env, fmt.Sprintf("PACKER_STATE_%s=%v", stateEnvName(key), value.Interface()))
+	}
+
+	return env
+}
+
+func stateEnvName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,150 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/helper/multistep"
+	"github.com/hashicorp/packer/packer"
+)
+
+type testFailingStep struct {
+	runs int
+}
+
+func (s *testFailingStep) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	s.runs++
+	state.Put("error", errors.New("it broke"))
+	return multistep.ActionHalt
+}
+
+func (s *testFailingStep) Cleanup(multistep.StateBag) {}
+
+func TestNewRunner_stepMarkers(t *testing.T) {
+	steps := []multistep.Step{new(testFailingStep)}
+	newRunner(steps, PackerConfig{}, new(packer.MachineReadableUi))
+	if _, ok := steps[0].(markedStep); ok {
+		t.Fatal("steps should only be marked for transcripts")
+	}
+
+	newRunner(steps, PackerConfig{PackerStepMarkers: true}, new(packer.MachineReadableUi))
+	if _, ok := steps[0].(markedStep); !ok {
+		t.Fatalf("bad: %#v", steps[0])
+	}
+}
+
+func TestCleanupScriptEnv(t *testing.T) {
+	state := new(multistep.BasicStateBag)
+	state.Put("error", errors.New("it broke"))
+	state.Put("instance_id", "i-1234")
+	state.Put("ssh-port", 2222)
+	state.Put("ui", &packer.BasicUi{})
+
+	config := PackerConfig{
+		PackerBuildName:   "foo",
+		PackerBuilderType: "amazon-ebs",
+	}
+
+	expected := []string{
+		"PACKER_BUILD_NAME=foo",
+		"PACKER_BUILDER_TYPE=amazon-ebs",
+		"PACKER_FAILED_STEP=StepFoo",
+		"PACKER_ERROR=it broke",
+		"PACKER_STATE_INSTANCE_ID=i-1234",
+		"PACKER_STATE_SSH_PORT=2222",
+	}
+	actual := cleanupScriptEnv(config, "StepFoo", state)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestCleanupScriptStep(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test script is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "cleanup.sh")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$PACKER_FAILED_STEP: $PACKER_STATE_INSTANCE_ID\"\n"), 0755)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var out bytes.Buffer
+	ui := &packer.BasicUi{Reader: new(bytes.Buffer), Writer: &out}
+	inner := new(testFailingStep)
+	step := cleanupScriptStep{
+		step:   inner,
+		ui:     ui,
+		config: PackerConfig{PackerOnErrorScript: script},
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("instance_id", "i-1234")
+	if action := step.Run(context.Background(), state); action != multistep.ActionHalt {
+		t.Fatalf("bad: %#v", action)
+	}
+
+	if !strings.Contains(out.String(), "testFailingStep: i-1234") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestCleanupScriptStep_cancelled(t *testing.T) {
+	var out bytes.Buffer
+	ui := &packer.BasicUi{Reader: new(bytes.Buffer), Writer: &out}
+	step := cleanupScriptStep{
+		step:   new(testFailingStep),
+		ui:     ui,
+		config: PackerConfig{PackerOnErrorScript: "/does-not-exist"},
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put(multistep.StateCancelled, true)
+	step.Run(context.Background(), state)
+
+	if out.Len() != 0 {
+		t.Fatalf("nothing should run: %s", out.String())
+	}
+}
+
+func TestAskPrompt(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Shell    bool
+		Expected askResponse
+	}{
+		{"c\n", false, askCleanup},
+		{"\n", false, askCleanup},
+		{"a\n", true, askAbort},
+		{"r\n", true, askRetry},
+		{"s\n", true, askShell},
+		{"s\nr\n", false, askRetry},
+	}
+
+	for _, tc := range cases {
+		var out bytes.Buffer
+		ui := &packer.BasicUi{Reader: strings.NewReader(tc.Input), Writer: &out}
+		if actual := askPrompt(ui, tc.Shell); actual != tc.Expected {
+			t.Fatalf("%q: bad: %#v", tc.Input, actual)
+		}
+
+		if tc.Shell != strings.Contains(out.String(), "[s]") {
+			t.Fatalf("%q: bad: %s", tc.Input, out.String())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PrepareOutputDir creates the output directory of a post-processor, which
+// must not exist or be empty unless the build is forced, in which case it
+// is deleted first. It is never deleted if one of the input files of the
+// post-processor is in it, since they would be deleted along with it.
+func PrepareOutputDir(dir string, force bool, inputs []string) error {
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+		if !force {
+			return fmt.Errorf(
+				"Output directory exists and is not empty: %s\n\n"+
+					"Use the force flag to delete it prior to building.", dir)
+		}
+		for _, input := range inputs {
+			if pathInDir(input, dir) {
+				return fmt.Errorf(
+					"Output directory %s contains the input file %s, so it can't be "+
+						"deleted. Please use another output directory.", dir, input)
+			}
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("Error deleting output directory: %s", err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error creating output directory: %s", err)
+	}
+
+	return nil
+}
+
+// pathInDir returns true if the path is inside the directory, following
+// symlinks where they exist.
+func pathInDir(path string, dir string) bool {
+	path, dir = realPath(path), realPath(dir)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+
+	return path
+}
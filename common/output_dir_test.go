@@ -0,0 +1,59 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareOutputDir(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "output")
+	if err := PrepareOutputDir(dir, false, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		t.Fatalf("output directory should be created: %v", err)
+	}
+
+	// A directory that isn't empty is only deleted when forced
+	old := filepath.Join(dir, "old.vmdk")
+	ioutil.WriteFile(old, []byte("old"), 0644)
+	if err := PrepareOutputDir(dir, false, nil); err == nil {
+		t.Fatal("should error")
+	}
+	if err := PrepareOutputDir(dir, true, []string{filepath.Join(td, "disk.raw")}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("output directory should be emptied")
+	}
+}
+
+func TestPrepareOutputDir_input(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	input := filepath.Join(td, "disks", "disk.raw")
+	os.MkdirAll(filepath.Dir(input), 0755)
+	ioutil.WriteFile(input, []byte("disk"), 0644)
+
+	// The input isn't deleted along with the output directory
+	for _, dir := range []string{td, filepath.Join(td, "disks")} {
+		if err := PrepareOutputDir(dir, true, []string{input}); err == nil {
+			t.Fatalf("%s: should error", dir)
+		}
+	}
+	if _, err := os.Stat(input); err != nil {
+		t.Fatalf("input should be kept: %s", err)
+	}
+}
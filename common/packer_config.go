@@ -4,10 +4,16 @@ package common
 // are sent by packer, properly tagged already so mapstructure can load
 // them. Embed this structure into your configuration class to get it.
 type PackerConfig struct {
-	PackerBuildName   string            `mapstructure:"packer_build_name"`
-	PackerBuilderType string            `mapstructure:"packer_builder_type"`
-	PackerDebug       bool              `mapstructure:"packer_debug"`
-	PackerForce       bool              `mapstructure:"packer_force"`
-	PackerOnError     string            `mapstructure:"packer_on_error"`
-	PackerUserVars    map[string]string `mapstructure:"packer_user_variables"`
+	PackerBuildName     string            `mapstructure:"packer_build_name"`
+	PackerBuilderType   string            `mapstructure:"packer_builder_type"`
+	PackerDebug         bool              `mapstructure:"packer_debug"`
+	PackerForce         bool              `mapstructure:"packer_force"`
+	PackerOnError       string            `mapstructure:"packer_on_error"`
+	PackerOnErrorScript string            `mapstructure:"packer_on_error_script"`
+	PackerStepMarkers   bool              `mapstructure:"packer_step_markers"`
+	PackerUserVars      map[string]string `mapstructure:"packer_user_variables"`
+
+	// The provisioners of the build, which are only sent to builders. See
+	// ProvisionerHashes.
+	PackerProvisioners []byte `mapstructure:"packer_provisioners"`
 }
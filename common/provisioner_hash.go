@@ -0,0 +1,111 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// provisionerHashDirKeys are the configuration keys of the provisioners
+// that name local directories, which are hashed with all their contents.
+// Directories named by other keys aren't, as they are usually remote.
+var provisionerHashDirKeys = map[string]bool{
+	"manifest_dir": true,
+	"module_paths": true,
+	"playbook_dir": true,
+	"role_paths":   true,
+	"source":       true,
+	"sources":      true,
+}
+
+// ProvisionerHashes returns the hashes of the provisioners that Packer sent
+// to the builder in PackerConfig.PackerProvisioners, in order. A hash
+// changes when the configuration of the provisioner or the local files it
+// names change, so builders can reuse what a provisioner did in a previous
+// build.
+func ProvisionerHashes(data []byte) ([]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var provisioners []packer.ProvisionerConfig
+	if err := json.Unmarshal(data, &provisioners); err != nil {
+		return nil, fmt.Errorf("Error decoding the provisioners of the build: %s", err)
+	}
+
+	result := make([]string, len(provisioners))
+	for i, p := range provisioners {
+		result[i] = provisionerHash(p)
+	}
+
+	return result, nil
+}
+
+// provisionerHash returns the hash of the type of the provisioner, its
+// configuration and the contents of the local files it names.
+func provisionerHash(p packer.ProvisionerConfig) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", p.Type)
+
+	// Maps are marshalled with sorted keys, so this is stable
+	data, err := json.Marshal(p.Config)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%#v", p.Config))
+	}
+	h.Write(data)
+
+	keys := make([]string, 0, len(p.Config))
+	for k := range p.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k != "destination" {
+			hashFiles(h, p.Config[k], provisionerHashDirKeys[k])
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashFiles writes the contents of the local files named by the strings
+// in the configuration value to h, and those of the directories too if
+// dirs is true.
+func hashFiles(h hash.Hash, value interface{}, dirs bool) {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return
+		}
+		info, err := os.Stat(v)
+		if err != nil || (info.IsDir() && !dirs) {
+			return
+		}
+		filepath.Walk(v, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				fmt.Fprintf(h, "%s: %s\n", path, err)
+				return nil
+			}
+			fmt.Fprintf(h, "%s %s\n", path, info.Mode())
+			if info.Mode().IsRegular() {
+				if f, err := os.Open(path); err == nil {
+					io.Copy(h, f)
+					f.Close()
+				}
+			}
+			return nil
+		})
+	case []interface{}:
+		for _, inner := range v {
+			hashFiles(h, inner, dirs)
+		}
+	}
+}
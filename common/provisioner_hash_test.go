@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testProvisionerHash(t *testing.T, pType string, config map[string]interface{}) string {
+	data, err := json.Marshal([]packer.ProvisionerConfig{{Type: pType, Config: config}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	hashes, err := ProvisionerHashes(data)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(hashes) != 1 {
+		t.Fatalf("bad: %#v", hashes)
+	}
+
+	return hashes[0]
+}
+
+func TestProvisionerHashes(t *testing.T) {
+	base := testProvisionerHash(t, "shell", map[string]interface{}{
+		"inline": []interface{}{"apt-get install vim"},
+	})
+
+	if testProvisionerHash(t, "shell", map[string]interface{}{
+		"inline": []interface{}{"apt-get install vim"},
+	}) != base {
+		t.Fatal("the same configuration should hash the same")
+	}
+	if testProvisionerHash(t, "shell", map[string]interface{}{
+		"inline": []interface{}{"apt-get install emacs"},
+	}) == base {
+		t.Fatal("a different configuration should hash differently")
+	}
+	if testProvisionerHash(t, "other", map[string]interface{}{
+		"inline": []interface{}{"apt-get install vim"},
+	}) == base {
+		t.Fatal("the type is part of the hash")
+	}
+
+	if hashes, err := ProvisionerHashes(nil); err != nil || len(hashes) != 0 {
+		t.Fatalf("bad: %#v %s", hashes, err)
+	}
+	if _, err := ProvisionerHashes([]byte("{")); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestProvisionerHashes_files(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	script := filepath.Join(td, "script.sh")
+	dir := filepath.Join(td, "files")
+	os.Mkdir(dir, 0755)
+	ioutil.WriteFile(script, []byte("echo 1"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "file"), []byte("a"), 0644)
+
+	hash := func(config map[string]interface{}) string {
+		return testProvisionerHash(t, "shell", config)
+	}
+
+	scriptConfig := map[string]interface{}{"scripts": []interface{}{script}}
+	sourceConfig := map[string]interface{}{"source": dir, "destination": "/tmp"}
+	otherConfig := map[string]interface{}{"remote_folder": dir}
+	scriptHash, sourceHash, otherHash := hash(scriptConfig), hash(sourceConfig), hash(otherConfig)
+
+	ioutil.WriteFile(script, []byte("echo 2"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "file"), []byte("b"), 0644)
+
+	if hash(scriptConfig) == scriptHash {
+		t.Fatal("the hash of the script should change")
+	}
+	if hash(sourceConfig) == sourceHash {
+		t.Fatal("the hash of the source should change")
+	}
+	if hash(otherConfig) != otherHash {
+		t.Fatal("directories of other keys should not be hashed")
+	}
+}
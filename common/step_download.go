@@ -150,9 +150,17 @@ func (s *StepDownload) download(config *DownloadConfig, state multistep.StateBag
 		downloadCompleteCh <- err
 	}()
 
-	progressTicker := time.NewTicker(5 * time.Second)
+	progressTicker := time.NewTicker(1 * time.Second)
 	defer progressTicker.Stop()
 
+	// The progress bar is started once the downloader knows the total size
+	var progress packer.ProgressBar
+	defer func() {
+		if progress != nil {
+			progress.Close()
+		}
+	}()
+
 	for {
 		select {
 		case err := <-downloadCompleteCh:
@@ -160,12 +168,20 @@ func (s *StepDownload) download(config *DownloadConfig, state multistep.StateBag
 				return "", err, true
 			}
 
+			if current, _, ok := download.Progress(); ok && progress != nil {
+				progress.Set(int64(current))
+			}
+
 			return path, nil, true
 		case <-progressTicker.C:
-			progress := download.PercentProgress()
-			if progress >= 0 {
-				ui.Message(fmt.Sprintf("Download progress: %d%%", progress))
+			current, total, ok := download.Progress()
+			if !ok {
+				continue
+			}
+			if progress == nil {
+				progress = packer.NewProgressBar(ui, "Download", int64(total))
 			}
+			progress.Set(int64(current))
 		case <-time.After(1 * time.Second):
 			if _, ok := state.GetOk(multistep.StateCancelled); ok {
 				ui.Say("Interrupt received. Cancelling download...")
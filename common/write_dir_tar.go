@@ -0,0 +1,68 @@
+package common
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// WriteDirTar writes the local directory src to w as a tar stream, for
+// communicators that upload directories as a tar stream. The entries are
+// rooted at root, and the entry of src itself is only written if
+// includeRoot is true. Modes and symlinks are preserved.
+func WriteDirTar(w io.Writer, src string, root string, includeRoot bool) error {
+	archive := tar.NewWriter(w)
+
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." && !includeRoot {
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(root, filepath.ToSlash(rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(archive, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteDirTar(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	if err := os.Mkdir(filepath.Join(td, "sub"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "sub", "a"), []byte("foo"), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		includeRoot bool
+		expected    []string
+	}{
+		{false, []string{"dst/sub/", "dst/sub/a"}},
+		{true, []string{"dst/", "dst/sub/", "dst/sub/a"}},
+	}
+
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		if err := WriteDirTar(&buf, td, "dst", tc.includeRoot); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		var names []string
+		archive := tar.NewReader(&buf)
+		for {
+			hdr, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			names = append(names, hdr.Name)
+
+			if hdr.Name == "dst/sub/a" {
+				if hdr.Mode&0777 != 0600 {
+					t.Fatalf("bad mode: %o", hdr.Mode)
+				}
+				data, _ := ioutil.ReadAll(archive)
+				if string(data) != "foo" {
+					t.Fatalf("bad contents: %q", data)
+				}
+			}
+		}
+
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Fatalf("includeRoot %t: bad: %#v", tc.includeRoot, names)
+		}
+	}
+}
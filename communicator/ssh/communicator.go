@@ -10,6 +10,7 @@ import (
 	"log"
 	"net"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -111,6 +112,61 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 		return
 	}
 
+	c.waitSession(session, cmd)
+	return
+}
+
+// StartShell starts the login shell of the user on the machine, attached
+// to a pseudo-terminal, for an interactive session.
+func (c *comm) StartShell(cmd *packer.RemoteCmd, term string, width, height int) error {
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+
+	session.Stdout = cmd.Stdout
+	session.Stderr = cmd.Stderr
+
+	// The input is copied separately, so that waiting for the session
+	// doesn't wait for more input once the shell has exited.
+	if cmd.Stdin != nil {
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			session.Close()
+			return err
+		}
+
+		go func() {
+			defer stdin.Close()
+			io.Copy(stdin, cmd.Stdin)
+		}()
+	}
+
+	termModes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+
+	if err := session.RequestPty(term, height, width, termModes); err != nil {
+		session.Close()
+		return err
+	}
+
+	log.Printf("[DEBUG] starting interactive shell")
+	if err := session.Shell(); err != nil {
+		session.Close()
+		return err
+	}
+
+	c.waitSession(session, cmd)
+	return nil
+}
+
+// waitSession keeps the session alive while the command runs, and waits
+// for it to end in the background, setting the exit status of the
+// command.
+func (c *comm) waitSession(session *ssh.Session, cmd *packer.RemoteCmd) {
 	go func() {
 		if c.config.KeepAliveInterval <= 0 {
 			return
@@ -146,7 +202,6 @@ func (c *comm) Start(cmd *packer.RemoteCmd) (err error) {
 		}
 		cmd.SetExited(exitStatus)
 	}()
-	return
 }
 
 func (c *comm) Upload(path string, input io.Reader, fi *os.FileInfo) error {
@@ -168,74 +223,142 @@ func (c *comm) UploadDir(dst string, src string, excl []string) error {
 
 func (c *comm) DownloadDir(src string, dst string, excl []string) error {
 	log.Printf("[DEBUG] Download dir '%s' to '%s'", src, dst)
+	for _, pattern := range excl {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid exclude pattern '%s': %s", pattern, err)
+		}
+	}
+
 	scpFunc := func(w io.Writer, stdoutR *bufio.Reader) error {
-		dirStack := []string{dst}
-		for {
-			fmt.Fprint(w, "\x00")
+		return scpDownloadDir(w, stdoutR, src, dst, excl)
+	}
+	return c.scpSession("scp -vrf "+src, scpFunc)
+}
 
-			// read file info
-			fi, err := stdoutR.ReadString('\n')
-			if err != nil {
-				return err
-			}
+// scpDownloadDir receives the directory src from the SCP source on the other
+// end into dst. Entries whose path relative to src, or whose base name,
+// match one of the exclude patterns are skipped, along with everything in
+// excluded directories.
+func scpDownloadDir(w io.Writer, stdoutR *bufio.Reader, src string, dst string, excl []string) error {
+	dirStack := []string{dst}
 
-			if len(fi) < 0 {
-				return fmt.Errorf("empty response from server")
-			}
+	// The depth of the directory stack at which an excluded directory
+	// was entered, or zero if the current directory isn't excluded.
+	excludedDepth := 0
 
-			switch fi[0] {
-			case '\x01', '\x02':
-				return fmt.Errorf("%s", fi[1:])
-			case 'C', 'D':
-				break
-			case 'E':
-				dirStack = dirStack[:len(dirStack)-1]
-				if len(dirStack) == 0 {
-					fmt.Fprint(w, "\x00")
-					return nil
-				}
-				continue
-			default:
-				return fmt.Errorf("unexpected server response (%x)", fi[0])
-			}
+	for {
+		fmt.Fprint(w, "\x00")
+
+		// read file info
+		fi, err := stdoutR.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		if len(fi) < 0 {
+			return fmt.Errorf("empty response from server")
+		}
 
-			var mode int64
-			var size int64
-			var name string
-			log.Printf("[DEBUG] Download dir str:%s", fi)
-			n, err := fmt.Sscanf(fi[1:], "%o %d %s", &mode, &size, &name)
-			if err != nil || n != 3 {
-				return fmt.Errorf("can't parse server response (%s)", fi)
+		switch fi[0] {
+		case '\x01', '\x02':
+			return fmt.Errorf("%s", fi[1:])
+		case 'C', 'D':
+			break
+		case 'E':
+			if excludedDepth == len(dirStack) {
+				excludedDepth = 0
 			}
-			if size < 0 {
-				return fmt.Errorf("negative file size")
+			dirStack = dirStack[:len(dirStack)-1]
+			if len(dirStack) == 0 {
+				fmt.Fprint(w, "\x00")
+				return nil
 			}
+			continue
+		default:
+			return fmt.Errorf("unexpected server response (%x)", fi[0])
+		}
 
-			log.Printf("[DEBUG] Download dir mode:%0o size:%d name:%s", mode, size, name)
+		var mode int64
+		var size int64
+		var name string
+		log.Printf("[DEBUG] Download dir str:%s", fi)
+		n, err := fmt.Sscanf(fi[1:], "%o %d %s", &mode, &size, &name)
+		if err != nil || n != 3 {
+			return fmt.Errorf("can't parse server response (%s)", fi)
+		}
+		if size < 0 {
+			return fmt.Errorf("negative file size")
+		}
+
+		log.Printf("[DEBUG] Download dir mode:%0o size:%d name:%s", mode, size, name)
 
-			dst = filepath.Join(dirStack...)
-			switch fi[0] {
-			case 'D':
+		excluded := excludedDepth != 0
+		if !excluded {
+			rel := scpRelPath(src, dirStack[1:], name)
+			if rel != "" && scpExcluded(rel, excl) {
+				log.Printf("[DEBUG] Excluding from download: %s", rel)
+				excluded = true
+			}
+		}
+
+		dst = filepath.Join(dirStack...)
+		switch fi[0] {
+		case 'D':
+			if excluded && excludedDepth == 0 {
+				excludedDepth = len(dirStack) + 1
+			}
+			if !excluded {
 				err = os.MkdirAll(filepath.Join(dst, name), os.FileMode(mode))
 				if err != nil {
 					return err
 				}
-				dirStack = append(dirStack, name)
-				continue
-			case 'C':
-				fmt.Fprint(w, "\x00")
+			}
+			dirStack = append(dirStack, name)
+			continue
+		case 'C':
+			fmt.Fprint(w, "\x00")
+			if excluded {
+				_, err = io.CopyN(ioutil.Discard, stdoutR, size)
+			} else {
 				err = scpDownloadFile(filepath.Join(dst, name), stdoutR, size, os.FileMode(mode))
-				if err != nil {
-					return err
-				}
 			}
-
-			if err := checkSCPStatus(stdoutR); err != nil {
+			if err != nil {
 				return err
 			}
 		}
+
+		if err := checkSCPStatus(stdoutR); err != nil {
+			return err
+		}
 	}
-	return c.scpSession("scp -vrf "+src, scpFunc)
+}
+
+// scpRelPath returns the path of an entry relative to the downloaded
+// directory src, given the names of the directories it is in. The
+// directory src itself is the first of them, unless src is a pattern that
+// matches the entries at the top.
+func scpRelPath(src string, dirs []string, name string) string {
+	parts := append(append([]string{}, dirs...), name)
+	if !strings.ContainsAny(src, "*?[") {
+		parts = parts[1:]
+	}
+
+	return path.Join(parts...)
+}
+
+// scpExcluded returns true if the relative path, or its base name, matches
+// one of the exclude patterns.
+func scpExcluded(rel string, excl []string) bool {
+	for _, pattern := range excl {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (c *comm) Download(path string, output io.Writer) error {
@@ -512,6 +635,10 @@ func (c *comm) sftpDownloadSession(path string, output io.Writer) error {
 			return err
 		}
 
+		if fi, err := f.Stat(); err == nil {
+			packer.SetDownloadFileInfo(output, fi)
+		}
+
 		return nil
 	}
 
@@ -650,6 +777,15 @@ func (c *comm) scpDownloadSession(path string, output io.Writer) error {
 			return err
 		}
 
+		// SCP sends the permissions of the file, but not its times
+		if perm, err := strconv.ParseUint(mode[1:], 8, 32); err == nil {
+			packer.SetDownloadFileInfo(output, &scpFileInfo{
+				name: filepath.Base(path),
+				size: size,
+				mode: os.FileMode(perm),
+			})
+		}
+
 		fmt.Fprint(w, "\x00")
 
 		return checkSCPStatus(stdoutR)
@@ -926,3 +1062,17 @@ func scpUploadDir(root string, fs []os.FileInfo, w io.Writer, r *bufio.Reader) e
 
 	return nil
 }
+
+// scpFileInfo is the metadata of a file that SCP sends along with it.
+type scpFileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (fi *scpFileInfo) Name() string       { return fi.name }
+func (fi *scpFileInfo) Size() int64        { return fi.size }
+func (fi *scpFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *scpFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *scpFileInfo) IsDir() bool        { return false }
+func (fi *scpFileInfo) Sys() interface{}   { return nil }
@@ -3,9 +3,15 @@
 package ssh
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -225,3 +231,41 @@ func TestHandshakeTimeout(t *testing.T) {
 		t.Fatalf("Expected handshake timeout, got: %s", err)
 	}
 }
+
+func TestSCPDownloadDir_exclude(t *testing.T) {
+	dst, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dst)
+
+	stream := strings.Join([]string{
+		"D0755 0 foo\n",
+		"C0644 3 keep.txt\n", "abc\x00",
+		"C0644 3 skip.log\n", "xyz\x00",
+		"D0755 0 cache\n",
+		"C0644 3 keep.txt\n", "xyz\x00",
+		"E\n",
+		"E\n",
+	}, "")
+
+	r := bufio.NewReader(strings.NewReader(stream))
+	err = scpDownloadDir(ioutil.Discard, r, "/foo", dst, []string{"*.log", "cache"})
+	if err != io.EOF {
+		t.Fatalf("bad: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dst, "foo", "keep.txt"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "abc" {
+		t.Fatalf("bad: %s", data)
+	}
+
+	for _, name := range []string{"skip.log", "cache"} {
+		if _, err := os.Stat(filepath.Join(dst, "foo", name)); !os.IsNotExist(err) {
+			t.Fatalf("%s should be excluded: %v", name, err)
+		}
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/packer/command"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/packer/plugin"
+	packrpc "github.com/hashicorp/packer/packer/rpc"
 	"github.com/kardianos/osext"
 )
 
@@ -21,6 +23,10 @@ import (
 // without being confused with spaces in the path to the command itself.
 const PACKERSPACE = "-PACKERSPACE-"
 
+// PACKERCOMPONENT separates the path to a plugin that serves a set of
+// components from the name of the component to use.
+const PACKERCOMPONENT = "-PACKERCOMPONENT-"
+
 type config struct {
 	DisableCheckpoint          bool `json:"disable_checkpoint"`
 	DisableCheckpointSignature bool `json:"disable_checkpoint_signature"`
@@ -30,6 +36,26 @@ type config struct {
 	Builders       map[string]string
 	PostProcessors map[string]string `json:"post-processors"`
 	Provisioners   map[string]string
+
+	// The plugins that were discovered, as opposed to being set in the
+	// configuration file, mapped to the index of the directory they were
+	// found in. Later directories take precedence.
+	discovered map[string]int
+
+	// The plugins that serve a set of components. They are only started to
+	// ask for their components when a component is first looked up.
+	multi        []multiPlugin
+	resolveMulti sync.Once
+
+	// The index of the directory that is being searched.
+	discoverDir int
+}
+
+// multiPlugin is a plugin that serves a set of components, found in the
+// directory with the given index.
+type multiPlugin struct {
+	path string
+	dir  int
 }
 
 // Decodes configuration in JSON format from the given io.Reader into
@@ -91,6 +117,7 @@ func (c *config) Discover() error {
 // implementations from the defined plugins.
 func (c *config) LoadBuilder(name string) (packer.Builder, error) {
 	log.Printf("Loading builder: %s\n", name)
+	c.resolveMulti.Do(c.discoverComponents)
 	bin, ok := c.Builders[name]
 	if !ok {
 		log.Printf("Builder not found: %s\n", name)
@@ -111,6 +138,7 @@ func (c *config) LoadHook(name string) (packer.Hook, error) {
 // packer.PostProcessor implementations from defined plugins.
 func (c *config) LoadPostProcessor(name string) (packer.PostProcessor, error) {
 	log.Printf("Loading post-processor: %s", name)
+	c.resolveMulti.Do(c.discoverComponents)
 	bin, ok := c.PostProcessors[name]
 	if !ok {
 		log.Printf("Post-processor not found: %s", name)
@@ -124,6 +152,7 @@ func (c *config) LoadPostProcessor(name string) (packer.PostProcessor, error) {
 // packer.Provisioner implementations from defined plugins.
 func (c *config) LoadProvisioner(name string) (packer.Provisioner, error) {
 	log.Printf("Loading provisioner: %s\n", name)
+	c.resolveMulti.Do(c.discoverComponents)
 	bin, ok := c.Provisioners[name]
 	if !ok {
 		log.Printf("Provisioner not found: %s\n", name)
@@ -135,6 +164,7 @@ func (c *config) LoadProvisioner(name string) (packer.Provisioner, error) {
 
 func (c *config) discover(path string) error {
 	var err error
+	c.discoverDir++
 
 	if !filepath.IsAbs(path) {
 		path, err = filepath.Abs(path)
@@ -143,6 +173,13 @@ func (c *config) discover(path string) error {
 		}
 	}
 
+	// Plugins that serve a set of components come first, so that a plugin
+	// for a single component in the same directory takes precedence.
+	err = c.discoverMulti(filepath.Join(path, "packer-plugin-*"))
+	if err != nil {
+		return err
+	}
+
 	err = c.discoverSingle(
 		filepath.Join(path, "packer-builder-*"), &c.Builders)
 	if err != nil {
@@ -192,11 +229,139 @@ func (c *config) discoverSingle(glob string, m *map[string]string) error {
 		plugin := file[len(prefix):]
 		log.Printf("[DEBUG] Discovered plugin: %s = %s", plugin, match)
 		(*m)[plugin] = match
+		c.markDiscovered(match, c.discoverDir)
 	}
 
 	return nil
 }
 
+// discoverMulti discovers the plugins that serve a set of components. The
+// plugins are only recorded here; they are started to ask for their
+// components by discoverComponents, when a component is first looked up.
+func (c *config) discoverMulti(glob string) error {
+	matches, err := filepath.Glob(glob)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		// On Windows, ignore any plugins that don't end in .exe.
+		if runtime.GOOS == "windows" && strings.ToLower(filepath.Ext(match)) != ".exe" {
+			log.Printf(
+				"[DEBUG] Ignoring plugin match %s, no exe extension",
+				match)
+			continue
+		}
+
+		log.Printf("[DEBUG] Discovered plugin: %s", match)
+		c.multi = append(c.multi, multiPlugin{path: match, dir: c.discoverDir})
+	}
+
+	return nil
+}
+
+// discoverComponents starts each of the plugins found by discoverMulti to
+// ask it for its components. A component replaces a plugin of the same name
+// that is internal or that was found in an earlier directory, but not one
+// that is set in the configuration file or that was found in the same or a
+// later directory.
+func (c *config) discoverComponents() {
+	for _, m := range []*map[string]string{&c.Builders, &c.PostProcessors, &c.Provisioners} {
+		if *m == nil {
+			*m = make(map[string]string)
+		}
+	}
+
+	for _, multi := range c.multi {
+		client := c.pluginClient(multi.path)
+		components, err := client.Components()
+		client.Kill()
+		if err != nil {
+			log.Printf("[ERR] Error asking plugin %s for its components: %s", multi.path, err)
+			continue
+		}
+
+		for _, component := range components {
+			var m map[string]string
+			switch component.Kind {
+			case packrpc.ComponentBuilder:
+				m = c.Builders
+			case packrpc.ComponentPostProcessor:
+				m = c.PostProcessors
+			case packrpc.ComponentProvisioner:
+				m = c.Provisioners
+			default:
+				log.Printf("[WARN] Plugin %s has a %s named %s, which is ignored",
+					multi.path, component.Kind, component.Name)
+				continue
+			}
+
+			if existing, ok := m[component.Name]; ok && !strings.Contains(existing, PACKERSPACE) {
+				dir, ok := c.discovered[existing]
+				if !ok || (dir >= multi.dir && !strings.Contains(existing, PACKERCOMPONENT)) {
+					log.Printf("[DEBUG] Ignoring %s %s of plugin %s, already set to %s",
+						component.Kind, component.Name, multi.path, existing)
+					continue
+				}
+			}
+
+			log.Printf("[DEBUG] Discovered %s: %s = %s", component.Kind, component.Name, multi.path)
+			m[component.Name] = multi.path + PACKERCOMPONENT + component.Name
+			c.markDiscovered(m[component.Name], multi.dir)
+		}
+	}
+}
+
+func (c *config) markDiscovered(plugin string, dir int) {
+	if c.discovered == nil {
+		c.discovered = make(map[string]int)
+	}
+
+	c.discovered[plugin] = dir
+}
+
+// Plugins returns the builders, provisioners and post-processors that are
+// compiled into Packer, discovered or set in the configuration file.
+func (c *config) Plugins() []command.PluginComponent {
+	c.resolveMulti.Do(c.discoverComponents)
+
+	kinds := map[string]map[string]string{
+		"builder":        c.Builders,
+		"post-processor": c.PostProcessors,
+		"provisioner":    c.Provisioners,
+	}
+
+	var result []command.PluginComponent
+	for kind, m := range kinds {
+		for name, plugin := range m {
+			p := command.PluginComponent{
+				Kind: kind,
+				Name: name,
+				Path: strings.Split(plugin, PACKERCOMPONENT)[0],
+			}
+
+			switch {
+			case strings.Contains(plugin, PACKERSPACE):
+				p.Origin = command.PluginOriginInternal
+				p.Path = strings.Split(plugin, PACKERSPACE)[0]
+			case c.isDiscovered(plugin):
+				p.Origin = command.PluginOriginDiscovered
+			default:
+				p.Origin = command.PluginOriginConfig
+			}
+
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
+
+func (c *config) isDiscovered(plugin string) bool {
+	_, ok := c.discovered[plugin]
+	return ok
+}
+
 func (c *config) discoverInternal() error {
 	// Get the packer binary path
 	packerPath, err := osext.Executable()
@@ -238,6 +403,13 @@ func (c *config) discoverInternal() error {
 }
 
 func (c *config) pluginClient(path string) *plugin.Client {
+	// Check for a component of a plugin that serves a set of components
+	var component string
+	if idx := strings.Index(path, PACKERCOMPONENT); idx >= 0 {
+		component = path[idx+len(PACKERCOMPONENT):]
+		path = path[:idx]
+	}
+
 	originalPath := path
 
 	// First attempt to find the executable by consulting the PATH.
@@ -275,5 +447,14 @@ func (c *config) pluginClient(path string) *plugin.Client {
 	config.Managed = true
 	config.MinPort = c.PluginMinPort
 	config.MaxPort = c.PluginMaxPort
+	config.Component = component
+
+	// If the plugin is started for a build that has a transcript, record
+	// the logs of the plugin there and tag them with the build name.
+	if t := packer.PluginTranscript(); t != nil {
+		config.Stderr = t.Writer("log")
+		config.LogTag = t.Name
+	}
+
 	return plugin.NewClient(&config)
 }
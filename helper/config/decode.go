@@ -46,6 +46,8 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 			config.InterpolateContext.BuildType = ctx.BuildType
 			config.InterpolateContext.TemplatePath = ctx.TemplatePath
 			config.InterpolateContext.UserVariables = ctx.UserVariables
+			config.InterpolateContext.Matrix = ctx.Matrix
+			config.InterpolateContext.UpstreamArtifacts = ctx.UpstreamArtifacts
 		}
 		ctx = config.InterpolateContext
 
@@ -108,10 +110,12 @@ func Decode(target interface{}, config *DecodeOpts, raws ...interface{}) error {
 // detecting things like user variables from the raw configuration params.
 func DetectContext(raws ...interface{}) (*interpolate.Context, error) {
 	var s struct {
-		BuildName    string            `mapstructure:"packer_build_name"`
-		BuildType    string            `mapstructure:"packer_builder_type"`
-		TemplatePath string            `mapstructure:"packer_template_path"`
-		Vars         map[string]string `mapstructure:"packer_user_variables"`
+		BuildName    string                       `mapstructure:"packer_build_name"`
+		Matrix       map[string]string            `mapstructure:"packer_matrix"`
+		BuildType    string                       `mapstructure:"packer_builder_type"`
+		TemplatePath string                       `mapstructure:"packer_template_path"`
+		Vars         map[string]string            `mapstructure:"packer_user_variables"`
+		Upstream     map[string]map[string]string `mapstructure:"packer_upstream_artifacts"`
 	}
 
 	for _, r := range raws {
@@ -121,10 +125,12 @@ func DetectContext(raws ...interface{}) (*interpolate.Context, error) {
 	}
 
 	return &interpolate.Context{
-		BuildName:     s.BuildName,
-		BuildType:     s.BuildType,
-		TemplatePath:  s.TemplatePath,
-		UserVariables: s.Vars,
+		BuildName:         s.BuildName,
+		BuildType:         s.BuildType,
+		Matrix:            s.Matrix,
+		TemplatePath:      s.TemplatePath,
+		UserVariables:     s.Vars,
+		UpstreamArtifacts: s.Upstream,
 	}, nil
 }
 
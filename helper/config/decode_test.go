@@ -90,6 +90,40 @@ func TestDecode(t *testing.T) {
 			nil,
 		},
 
+		"matrix": {
+			[]interface{}{
+				map[string]interface{}{
+					"name": "{{matrix `region`}}",
+				},
+				map[string]interface{}{
+					"packer_matrix": map[string]string{
+						"region": "foo",
+					},
+				},
+			},
+			&Target{
+				Name: "foo",
+			},
+			nil,
+		},
+
+		"upstream artifacts": {
+			[]interface{}{
+				map[string]interface{}{
+					"name": "{{upstream `base` `id`}}",
+				},
+				map[string]interface{}{
+					"packer_upstream_artifacts": map[string]map[string]string{
+						"base": {"id": "foo"},
+					},
+				},
+			},
+			&Target{
+				Name: "foo",
+			},
+			nil,
+		},
+
 		"build type": {
 			[]interface{}{
 				map[string]interface{}{
@@ -1,6 +1,9 @@
 package multistep
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 // Add context to state bag to prevent changing step signature
 
@@ -45,3 +48,17 @@ func (b *BasicStateBag) Put(k string, v interface{}) {
 	// Write the data
 	b.data[k] = v
 }
+
+// Keys returns the keys of all the values in the state bag, sorted.
+func (b *BasicStateBag) Keys() []string {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	keys := make([]string, 0, len(b.data))
+	for k := range b.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -1,6 +1,7 @@
 package multistep
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -28,3 +29,18 @@ func TestBasicStateBag(t *testing.T) {
 		t.Fatalf("bad")
 	}
 }
+
+func TestBasicStateBag_Keys(t *testing.T) {
+	b := new(BasicStateBag)
+	if len(b.Keys()) != 0 {
+		t.Fatalf("bad: %#v", b.Keys())
+	}
+
+	b.Put("foo", "bar")
+	b.Put("bar", 42)
+
+	expected := []string{"bar", "foo"}
+	if !reflect.DeepEqual(b.Keys(), expected) {
+		t.Fatalf("bad: %#v", b.Keys())
+	}
+}
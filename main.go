@@ -154,6 +154,23 @@ func wrappedMain() int {
 		)
 	}
 
+	// Export the spans of the run if an exporter is configured. This
+	// works whether or not checkpoint is enabled.
+	if !inPlugin {
+		exporter, err := packer.SpanExporterFromEnv()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error configuring span exporter: %s\n", err)
+			return 1
+		}
+		if exporter != nil {
+			if packer.CheckpointReporter == nil {
+				packer.CheckpointReporter = packer.NewExportingReporter(exporter)
+			} else {
+				packer.CheckpointReporter.SetExporter(exporter)
+			}
+		}
+	}
+
 	cacheDir := os.Getenv("PACKER_CACHE_DIR")
 	if cacheDir == "" {
 		cacheDir = "packer_cache"
@@ -204,8 +221,9 @@ func wrappedMain() int {
 			},
 			Version: version.Version,
 		},
-		Cache: cache,
-		Ui:    ui,
+		Cache:   cache,
+		Ui:      ui,
+		Plugins: config.Plugins,
 	}
 
 	cli := &cli.CLI{
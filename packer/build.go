@@ -3,7 +3,10 @@ package packer
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+
+	"github.com/hashicorp/packer/template/interpolate"
 )
 
 const (
@@ -24,15 +27,43 @@ const (
 	// force build is enabled.
 	ForceConfigKey = "packer_force"
 
+	// This key contains a map[string]string of the values of the matrix
+	// combination that the build was expanded from, if any.
+	MatrixConfigKey = "packer_matrix"
+
 	// This key determines what to do when a normal multistep step fails
 	// - "cleanup" - run cleanup steps
 	// - "abort" - exit without cleanup
 	// - "ask" - ask the user
+	// - "shell" - open a shell on the machine, and ask the user
+	// - "run-cleanup-provisioner" - run the on-error script, and clean up
 	OnErrorConfigKey = "packer_on_error"
 
+	// This key contains the path to the local script that is run when a
+	// step fails with the "run-cleanup-provisioner" on-error mode.
+	OnErrorScriptConfigKey = "packer_on_error_script"
+
+	// This key is set to "true" when the builder should mark the boundaries
+	// of its steps with machine-readable "step" messages, which is only
+	// done if a transcript of the build is recorded or its spans are
+	// exported.
+	StepMarkersConfigKey = "packer_step_markers"
+
+	// This key contains the JSON encoded []ProvisionerConfig of the
+	// provisioners of the build, in order, so builders can reuse what a
+	// provisioner did in a previous build. It is only sent to the builder,
+	// as a []byte so that it isn't interpolated with the configuration of
+	// the builder.
+	ProvisionersConfigKey = "packer_provisioners"
+
 	// TemplatePathKey is the path to the template that configured this build
 	TemplatePathKey = "packer_template_path"
 
+	// This key contains a map[string]map[string]string of the artifacts of
+	// the builds this build depends on, keyed by build name, for use by the
+	// "upstream" template function.
+	UpstreamArtifactsConfigKey = "packer_upstream_artifacts"
+
 	// This key contains a map[string]string of the user variables for
 	// template processing.
 	UserVariablesConfigKey = "packer_user_variables"
@@ -78,7 +109,24 @@ type Build interface {
 	// - "cleanup" - run cleanup steps
 	// - "abort" - exit without cleanup
 	// - "ask" - ask the user
+	// - "shell" - open a shell on the machine, and ask the user
+	// - "run-cleanup-provisioner" - run the on-error script, and clean up
 	SetOnError(string)
+
+	// SetOnErrorScript sets the path to the local script that is run when
+	// a step fails with the "run-cleanup-provisioner" on-error mode.
+	SetOnErrorScript(string)
+
+	// SetTranscript sets whether a transcript of the build is recorded,
+	// which the builder then marks the boundaries of its steps for. This
+	// must be called prior to Prepare.
+	SetTranscript(bool)
+
+	// SetUpstreamArtifacts sets the artifacts of the builds that this
+	// build depends on, keyed by build name. They are exposed to the
+	// configuration of the build through the "upstream" template
+	// function. This must be called prior to Prepare.
+	SetUpstreamArtifacts(map[string]Artifact)
 }
 
 // A build struct represents a single build job, the result of which should
@@ -86,19 +134,25 @@ type Build interface {
 // multiple files, of course, but it should be for only a single provider
 // (such as VirtualBox, EC2, etc.).
 type coreBuild struct {
-	name           string
-	builder        Builder
-	builderConfig  interface{}
-	builderType    string
-	hooks          map[string][]Hook
-	postProcessors [][]coreBuildPostProcessor
-	provisioners   []coreBuildProvisioner
-	templatePath   string
-	variables      map[string]string
+	name               string
+	builder            Builder
+	builderConfig      interface{}
+	builderType        string
+	cleanupProvisioner coreBuildProvisioner
+	hooks              map[string][]Hook
+	matrix             map[string]string
+	postProcessors     [][]coreBuildPostProcessor
+	provisioners       []coreBuildProvisioner
+	sensitive          []string
+	templatePath       string
+	upstream           map[string]Artifact
+	variables          map[string]string
 
 	debug         bool
 	force         bool
 	onError       string
+	onErrorScript string
+	transcript    bool
 	l             sync.Mutex
 	prepareCalled bool
 }
@@ -147,9 +201,31 @@ func (b *coreBuild) Prepare() (warn []string, err error) {
 		TemplatePathKey:        b.templatePath,
 		UserVariablesConfigKey: b.variables,
 	}
+	if len(b.matrix) > 0 {
+		packerConfig[MatrixConfigKey] = b.matrix
+	}
+	if b.onErrorScript != "" {
+		packerConfig[OnErrorScriptConfigKey] = b.onErrorScript
+	}
+	if b.transcript || CheckpointReporter.Exporting() {
+		packerConfig[StepMarkersConfigKey] = true
+	}
+	if len(b.upstream) > 0 {
+		packerConfig[UpstreamArtifactsConfigKey] = b.upstreamArtifacts()
+	}
+
+	// Only the builder is told about the provisioners of the build
+	builderPackerConfig := packerConfig
+	if len(b.provisioners) > 0 {
+		builderPackerConfig = make(map[string]interface{}, len(packerConfig)+1)
+		for k, v := range packerConfig {
+			builderPackerConfig[k] = v
+		}
+		builderPackerConfig[ProvisionersConfigKey] = b.provisionerConfigs()
+	}
 
 	// Prepare the builder
-	warn, err = b.builder.Prepare(b.builderConfig, packerConfig)
+	warn, err = b.builder.Prepare(b.builderConfig, builderPackerConfig)
 	if err != nil {
 		log.Printf("Build '%s' prepare failure: %s\n", b.name, err)
 		return
@@ -166,6 +242,17 @@ func (b *coreBuild) Prepare() (warn []string, err error) {
 		}
 	}
 
+	// Prepare the error-cleanup-provisioner
+	if b.cleanupProvisioner.pType != "" {
+		configs := make([]interface{}, len(b.cleanupProvisioner.config), len(b.cleanupProvisioner.config)+1)
+		copy(configs, b.cleanupProvisioner.config)
+		configs = append(configs, packerConfig)
+
+		if err = b.cleanupProvisioner.provisioner.Prepare(configs...); err != nil {
+			return
+		}
+	}
+
 	// Prepare the post-processors
 	for _, ppSeq := range b.postProcessors {
 		for _, corePP := range ppSeq {
@@ -179,12 +266,48 @@ func (b *coreBuild) Prepare() (warn []string, err error) {
 	return
 }
 
+// hookedProvisioner wraps the given provisioner for use within the
+// provision hook, enabling step-by-step debugging if requested.
+func (b *coreBuild) hookedProvisioner(p coreBuildProvisioner) *HookedProvisioner {
+	var pConfig interface{}
+	if len(p.config) > 0 {
+		pConfig = p.config[0]
+	}
+
+	provisioner := p.provisioner
+	if b.debug {
+		provisioner = &DebuggedProvisioner{Provisioner: provisioner}
+	}
+
+	return &HookedProvisioner{
+		Provisioner: provisioner,
+		Config:      pConfig,
+		TypeName:    p.pType,
+	}
+}
+
 // Runs the actual build. Prepare must be called prior to running this.
 func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 	if !b.prepareCalled {
 		panic("Prepare must be called first")
 	}
 
+	// All the spans of the build are nested in one span
+	span := CheckpointReporter.AddTraceSpan(b.name, "build")
+	artifacts, err := b.run(originalUi, cache, span)
+	span.End(err)
+
+	return artifacts, err
+}
+
+func (b *coreBuild) run(originalUi Ui, cache Cache, buildSpan *TelemetrySpan) ([]Artifact, error) {
+	// The spans of the steps of the builder, which the provisioners are
+	// nested in.
+	var steps *stepSpans
+	if buildSpan != nil {
+		steps = &stepSpans{}
+	}
+
 	// Copy the hooks
 	hooks := make(map[string][]Hook)
 	for hookName, hookList := range b.hooks {
@@ -196,32 +319,23 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 	if len(b.provisioners) > 0 {
 		hookedProvisioners := make([]*HookedProvisioner, len(b.provisioners))
 		for i, p := range b.provisioners {
-			var pConfig interface{}
-			if len(p.config) > 0 {
-				pConfig = p.config[0]
-			}
-			if b.debug {
-				hookedProvisioners[i] = &HookedProvisioner{
-					&DebuggedProvisioner{Provisioner: p.provisioner},
-					pConfig,
-					p.pType,
-				}
-			} else {
-				hookedProvisioners[i] = &HookedProvisioner{
-					p.provisioner,
-					pConfig,
-					p.pType,
-				}
-			}
+			hookedProvisioners[i] = b.hookedProvisioner(p)
 		}
 
 		if _, ok := hooks[HookProvision]; !ok {
 			hooks[HookProvision] = make([]Hook, 0, 1)
 		}
 
-		hooks[HookProvision] = append(hooks[HookProvision], &ProvisionHook{
+		provisionHook := &ProvisionHook{
 			Provisioners: hookedProvisioners,
-		})
+			Transcript:   UiTranscript(originalUi),
+			spans:        steps,
+		}
+		if b.cleanupProvisioner.pType != "" {
+			provisionHook.ErrorCleanupProvisioner = b.hookedProvisioner(b.cleanupProvisioner)
+		}
+
+		hooks[HookProvision] = append(hooks[HookProvision], provisionHook)
 	}
 
 	hook := &DispatchHook{Mapping: hooks}
@@ -234,8 +348,14 @@ func (b *coreBuild) Run(originalUi Ui, cache Cache) ([]Artifact, error) {
 	}
 
 	log.Printf("Running builder: %s", b.builderType)
-	ts := CheckpointReporter.AddSpan(b.builderType, "builder", b.builderConfig)
-	builderArtifact, err := b.builder.Run(builderUi, hook, cache)
+	ts := buildSpan.AddSpan(b.builderType, "builder", b.builderConfig)
+	var runUi Ui = builderUi
+	if steps != nil {
+		steps.parent = ts
+		runUi = &stepSpanUi{Ui: builderUi, spans: steps}
+	}
+	builderArtifact, err := b.builder.Run(runUi, hook, cache)
+	steps.End()
 	ts.End(err)
 	if err != nil {
 		return nil, err
@@ -261,7 +381,7 @@ PostProcessorRunSeqLoop:
 			}
 
 			builderUi.Say(fmt.Sprintf("Running post-processor: %s", corePP.processorType))
-			ts := CheckpointReporter.AddSpan(corePP.processorType, "post-processor", corePP.config)
+			ts := buildSpan.AddSpan(corePP.processorType, "post-processor", corePP.config)
 			artifact, keep, err := corePP.processor.PostProcess(ppUi, priorArtifact)
 			ts.End(err)
 			if err != nil {
@@ -349,7 +469,105 @@ func (b *coreBuild) SetOnError(val string) {
 	b.onError = val
 }
 
+func (b *coreBuild) SetOnErrorScript(val string) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.onErrorScript = val
+}
+
+func (b *coreBuild) SetTranscript(val bool) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.transcript = val
+}
+
+func (b *coreBuild) SetUpstreamArtifacts(val map[string]Artifact) {
+	if b.prepareCalled {
+		panic("prepare has already been called")
+	}
+
+	b.upstream = val
+}
+
+// upstreamArtifacts flattens the upstream artifacts into the values that
+// the "upstream" template function can read. Artifact state can't be
+// enumerated, so only the state keys that the configuration of this build
+// actually references are resolved.
+func (b *coreBuild) upstreamArtifacts() map[string]map[string]string {
+	stateKeys := make(map[string][]string)
+	var walk func(interface{})
+	walk = func(raw interface{}) {
+		switch v := raw.(type) {
+		case string:
+			for _, args := range interpolate.FunctionCalls(v, "upstream") {
+				if len(args) == 2 && strings.HasPrefix(args[1], "state.") {
+					stateKeys[args[0]] = append(stateKeys[args[0]], args[1])
+				}
+			}
+		case map[string]interface{}:
+			for _, inner := range v {
+				walk(inner)
+			}
+		case []interface{}:
+			for _, inner := range v {
+				walk(inner)
+			}
+		}
+	}
+
+	walk(b.builderConfig)
+	for _, p := range b.provisioners {
+		for _, c := range p.config {
+			walk(c)
+		}
+	}
+	for _, c := range b.cleanupProvisioner.config {
+		walk(c)
+	}
+	for _, ppSeq := range b.postProcessors {
+		for _, pp := range ppSeq {
+			walk(pp.config)
+		}
+	}
+
+	result := make(map[string]map[string]string, len(b.upstream))
+	for name, artifact := range b.upstream {
+		if artifact == nil {
+			continue
+		}
+
+		values := map[string]string{
+			"builder_id": artifact.BuilderId(),
+			"files":      strings.Join(artifact.Files(), ","),
+			"id":         artifact.Id(),
+			"string":     artifact.String(),
+		}
+		for _, key := range stateKeys[name] {
+			if state := artifact.State(strings.TrimPrefix(key, "state.")); state != nil {
+				values[key] = fmt.Sprintf("%v", state)
+			}
+		}
+
+		result[name] = values
+	}
+
+	return result
+}
+
 // Cancels the build if it is running.
 func (b *coreBuild) Cancel() {
 	b.builder.Cancel()
+
+	// Post-processors can't be cancelled, but don't wait for their retries
+	for _, ppSeq := range b.postProcessors {
+		for _, pp := range ppSeq {
+			if retried, ok := pp.processor.(*RetriedPostProcessor); ok {
+				retried.Cancel()
+			}
+		}
+	}
 }
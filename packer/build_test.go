@@ -38,6 +38,20 @@ func testDefaultPackerConfig() map[string]interface{} {
 		UserVariablesConfigKey: make(map[string]string),
 	}
 }
+
+// testBuilderPackerConfig returns the packer configuration that the
+// builder of testBuild gets, which also has the provisioners of the build.
+func testBuilderPackerConfig(packerConfig map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{
+		ProvisionersConfigKey: testBuild().provisionerConfigs(),
+	}
+	for k, v := range packerConfig {
+		result[k] = v
+	}
+
+	return result
+}
+
 func TestBuild_Name(t *testing.T) {
 	build := testBuild()
 	if build.Name() != "test" {
@@ -55,7 +69,7 @@ func TestBuild_Prepare(t *testing.T) {
 	if !builder.PrepareCalled {
 		t.Fatal("should be called")
 	}
-	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, packerConfig}) {
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, testBuilderPackerConfig(packerConfig)}) {
 		t.Fatalf("bad: %#v", builder.PrepareConfig)
 	}
 
@@ -130,7 +144,7 @@ func TestBuild_Prepare_Debug(t *testing.T) {
 	if !builder.PrepareCalled {
 		t.Fatalf("should be called")
 	}
-	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, packerConfig}) {
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, testBuilderPackerConfig(packerConfig)}) {
 		t.Fatalf("bad: %#v", builder.PrepareConfig)
 	}
 
@@ -144,6 +158,36 @@ func TestBuild_Prepare_Debug(t *testing.T) {
 	}
 }
 
+func TestBuild_Prepare_OnErrorScript(t *testing.T) {
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[OnErrorConfigKey] = "run-cleanup-provisioner"
+	packerConfig[OnErrorScriptConfigKey] = "/foo.sh"
+
+	build := testBuild()
+	builder := build.builder.(*MockBuilder)
+
+	build.SetOnError("run-cleanup-provisioner")
+	build.SetOnErrorScript("/foo.sh")
+	build.Prepare()
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, testBuilderPackerConfig(packerConfig)}) {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+}
+
+func TestBuild_Prepare_Transcript(t *testing.T) {
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[StepMarkersConfigKey] = true
+
+	build := testBuild()
+	builder := build.builder.(*MockBuilder)
+
+	build.SetTranscript(true)
+	build.Prepare()
+	if !reflect.DeepEqual(builder.PrepareConfig, []interface{}{42, testBuilderPackerConfig(packerConfig)}) {
+		t.Fatalf("bad: %#v", builder.PrepareConfig)
+	}
+}
+
 func TestBuildPrepare_variables_default(t *testing.T) {
 	packerConfig := testDefaultPackerConfig()
 	packerConfig[UserVariablesConfigKey] = map[string]string{
@@ -166,7 +210,44 @@ func TestBuildPrepare_variables_default(t *testing.T) {
 		t.Fatal("prepare should be called")
 	}
 
-	if !reflect.DeepEqual(builder.PrepareConfig[1], packerConfig) {
+	if !reflect.DeepEqual(builder.PrepareConfig[1], testBuilderPackerConfig(packerConfig)) {
+		t.Fatalf("prepare bad: %#v", builder.PrepareConfig[1])
+	}
+}
+
+func TestBuildPrepare_upstreamArtifacts(t *testing.T) {
+	packerConfig := testDefaultPackerConfig()
+	packerConfig[UpstreamArtifactsConfigKey] = map[string]map[string]string{
+		"base": {
+			"builder_id": "bid",
+			"files":      "a,b",
+			"id":         "base-id",
+			"string":     "string",
+			"state.disk": "disk.qcow2",
+		},
+	}
+
+	build := testBuild()
+	build.builderConfig = map[string]interface{}{
+		"disk_image": "{{upstream `base` `state.disk`}}",
+		"unknown":    "{{upstream `base` `state.missing`}}",
+	}
+	build.SetUpstreamArtifacts(map[string]Artifact{
+		"base": &MockArtifact{
+			IdValue: "base-id",
+			StateValues: map[string]interface{}{
+				"disk":  "disk.qcow2",
+				"other": "unreferenced",
+			},
+		},
+	})
+	builder := build.builder.(*MockBuilder)
+
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(builder.PrepareConfig[1], testBuilderPackerConfig(packerConfig)) {
 		t.Fatalf("prepare bad: %#v", builder.PrepareConfig[1])
 	}
 }
@@ -12,6 +12,7 @@ type MockBuilder struct {
 	PrepareWarnings []string
 	RunErrResult    bool
 	RunNilResult    bool
+	OutputKeys      []PlanOutputKey
 
 	PrepareCalled bool
 	PrepareConfig []interface{}
@@ -56,3 +57,7 @@ func (tb *MockBuilder) Run(ui Ui, h Hook, c Cache) (Artifact, error) {
 func (tb *MockBuilder) Cancel() {
 	tb.CancelCalled = true
 }
+
+func (tb *MockBuilder) PlanOutputs() []PlanOutputKey {
+	return tb.OutputKeys
+}
@@ -1,6 +1,7 @@
 package packer
 
 import (
+	"errors"
 	"io"
 	"os"
 	"strings"
@@ -84,6 +85,106 @@ type Communicator interface {
 	DownloadDir(src string, dst string, exclude []string) error
 }
 
+// ProgressCommunicator is implemented by communicators that know how far a
+// file transfer has actually gotten on the other end, such as the
+// communicator that plugins use, which streams the data over RPC. It is
+// optional; UploadWithProgress and DownloadWithProgress should be used to
+// track the progress of a transfer with any Communicator.
+type ProgressCommunicator interface {
+	// UploadWithProgress uploads a file like Upload, moving the progress
+	// bar as the data is consumed on the other end.
+	UploadWithProgress(string, io.Reader, *os.FileInfo, ProgressBar) error
+
+	// DownloadWithProgress downloads a file like Download, moving the
+	// progress bar as the data is written to the writer.
+	DownloadWithProgress(string, io.Writer, ProgressBar) error
+
+	// UploadDirWithProgress uploads a directory like UploadDir, moving the
+	// progress bar by the bytes of the directory's data that are consumed
+	// on the other end.
+	UploadDirWithProgress(string, string, []string, ProgressBar) error
+
+	// DownloadDirWithProgress downloads a directory like DownloadDir,
+	// moving the progress bar by the bytes of the directory's data that
+	// are received.
+	DownloadDirWithProgress(string, string, []string, ProgressBar) error
+}
+
+// FileInfoWriter is implemented by the writers of downloads that take the
+// metadata of the downloaded file, such as its mode and modification
+// time. Communicators that know the metadata pass it with
+// SetDownloadFileInfo before the download returns. A zero modification
+// time means that it isn't known. It is optional.
+type FileInfoWriter interface {
+	io.Writer
+	SetFileInfo(os.FileInfo)
+}
+
+// SetDownloadFileInfo passes the metadata of a downloaded file to the
+// writer of the download, if it takes it.
+func SetDownloadFileInfo(w io.Writer, fi os.FileInfo) {
+	if fw, ok := w.(FileInfoWriter); ok {
+		fw.SetFileInfo(fi)
+	}
+}
+
+// ErrShellNotSupported is returned when an interactive shell is started
+// with a communicator that doesn't support them.
+var ErrShellNotSupported = errors.New("The communicator doesn't support interactive shells")
+
+// ShellCommunicator is implemented by communicators that can start an
+// interactive shell on the machine, attached to a pseudo-terminal, such as
+// SSH. It is optional.
+type ShellCommunicator interface {
+	// StartShell starts the login shell on the machine, attached to a
+	// pseudo-terminal of the given type and size. The input and output of
+	// the shell are those of the RemoteCmd, whose Command is ignored.
+	// Otherwise, it behaves like Start.
+	StartShell(cmd *RemoteCmd, term string, width, height int) error
+}
+
+// UploadWithProgress uploads a file with the communicator, moving the
+// progress bar as the data is transferred. The progress bar isn't closed.
+func UploadWithProgress(c Communicator, path string, r io.Reader, fi *os.FileInfo, bar ProgressBar) error {
+	if pc, ok := c.(ProgressCommunicator); ok {
+		return pc.UploadWithProgress(path, r, fi, bar)
+	}
+
+	return c.Upload(path, &progressReader{Reader: r, bar: bar}, fi)
+}
+
+// DownloadWithProgress downloads a file with the communicator, moving the
+// progress bar as the data is transferred. The progress bar isn't closed.
+func DownloadWithProgress(c Communicator, path string, w io.Writer, bar ProgressBar) error {
+	if pc, ok := c.(ProgressCommunicator); ok {
+		return pc.DownloadWithProgress(path, w, bar)
+	}
+
+	return c.Download(path, &progressWriter{Writer: w, bar: bar})
+}
+
+// UploadDirWithProgress uploads a directory with the communicator. The
+// progress bar only moves if the communicator knows how far the transfer
+// has gotten, and it isn't closed.
+func UploadDirWithProgress(c Communicator, dst string, src string, exclude []string, bar ProgressBar) error {
+	if pc, ok := c.(ProgressCommunicator); ok {
+		return pc.UploadDirWithProgress(dst, src, exclude, bar)
+	}
+
+	return c.UploadDir(dst, src, exclude)
+}
+
+// DownloadDirWithProgress downloads a directory with the communicator. The
+// progress bar only moves if the communicator knows how far the transfer
+// has gotten, and it isn't closed.
+func DownloadDirWithProgress(c Communicator, src string, dst string, exclude []string, bar ProgressBar) error {
+	if pc, ok := c.(ProgressCommunicator); ok {
+		return pc.DownloadDirWithProgress(src, dst, exclude, bar)
+	}
+
+	return c.DownloadDir(src, dst, exclude)
+}
+
 // StartWithUi runs the remote command and streams the output to any
 // configured Writers for stdout/stderr, while also writing each line
 // as it comes to a Ui.
@@ -60,3 +60,43 @@ func TestRemoteCmd_Wait(t *testing.T) {
 		t.Fatal("never got exit notification")
 	}
 }
+
+func TestUploadWithProgress(t *testing.T) {
+	defer func(old time.Duration) { progressLineInterval = old }(progressLineInterval)
+	progressLineInterval = 0
+
+	c := new(MockCommunicator)
+	bufferUi := testUi()
+	bar := NewProgressBar(bufferUi, "foo", 0)
+	if err := UploadWithProgress(c, "bar", strings.NewReader("hello"), nil, bar); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.UploadData != "hello" {
+		t.Fatalf("bad: %s", c.UploadData)
+	}
+	if actual := readWriter(bufferUi); !strings.Contains(actual, "foo: 5 B") {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestDownloadWithProgress(t *testing.T) {
+	defer func(old time.Duration) { progressLineInterval = old }(progressLineInterval)
+	progressLineInterval = 0
+
+	c := &MockCommunicator{DownloadData: "hello"}
+	bufferUi := testUi()
+	bar := NewProgressBar(bufferUi, "foo", 0)
+
+	var buf bytes.Buffer
+	if err := DownloadWithProgress(c, "bar", &buf, bar); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if buf.String() != "hello" {
+		t.Fatalf("bad: %s", buf.String())
+	}
+	if actual := readWriter(bufferUi); !strings.Contains(actual, "foo: 5 B") {
+		t.Fatalf("bad: %q", actual)
+	}
+}
@@ -1,6 +1,7 @@
 package packer
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 
@@ -19,6 +20,7 @@ type Core struct {
 	variables  map[string]string
 	builds     map[string]*template.Builder
 	version    string
+	warnings   []string
 }
 
 // CoreConfig is the structure for initializing a new Core. Once a CoreConfig
@@ -71,7 +73,9 @@ func NewCore(c *CoreConfig) (*Core, error) {
 	// to do this at this point with the variables.
 	result.builds = make(map[string]*template.Builder)
 	for _, b := range c.Template.Builders {
-		v, err := interpolate.Render(b.Name, result.Context())
+		ctx := result.Context()
+		ctx.Matrix = b.Matrix
+		v, err := interpolate.Render(b.Name, ctx)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"Error interpolating builder '%s': %s",
@@ -84,6 +88,11 @@ func NewCore(c *CoreConfig) (*Core, error) {
 	return result, nil
 }
 
+// Warnings returns the warnings from validating the template.
+func (c *Core) Warnings() []string {
+	return c.warnings
+}
+
 // BuildNames returns the builds that are available in this configured core.
 func (c *Core) BuildNames() []string {
 	r := make([]string, 0, len(c.builds))
@@ -95,6 +104,27 @@ func (c *Core) BuildNames() []string {
 	return r
 }
 
+// BuildDependencies returns the names of the builds that the build with
+// the given name depends on, in the order they are listed in the template.
+func (c *Core) BuildDependencies(n string) ([]string, error) {
+	configBuilder, ok := c.builds[n]
+	if !ok {
+		return nil, fmt.Errorf("no such build found: %s", n)
+	}
+
+	result := make([]string, 0, len(configBuilder.DependsOn))
+	for _, rawDep := range configBuilder.DependsOn {
+		for name, b := range c.builds {
+			if b.Name == rawDep {
+				result = append(result, name)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
 // Build returns the Build object for the given name.
 func (c *Core) Build(n string) (Build, error) {
 	// Setup the builder
@@ -124,40 +154,21 @@ func (c *Core) Build(n string) (Build, error) {
 			continue
 		}
 
-		// Get the provisioner
-		provisioner, err := c.components.Provisioner(rawP.Type)
+		cbp, err := c.generateCoreBuildProvisioner(rawP, rawName)
 		if err != nil {
-			return nil, fmt.Errorf(
-				"error initializing provisioner '%s': %s",
-				rawP.Type, err)
-		}
-		if provisioner == nil {
-			return nil, fmt.Errorf(
-				"provisioner type not found: %s", rawP.Type)
+			return nil, err
 		}
 
-		// Get the configuration
-		config := make([]interface{}, 1, 2)
-		config[0] = rawP.Config
-		if rawP.Override != nil {
-			if override, ok := rawP.Override[rawName]; ok {
-				config = append(config, override)
-			}
-		}
+		provisioners = append(provisioners, cbp)
+	}
 
-		// If we're pausing, we wrap the provisioner in a special pauser.
-		if rawP.PauseBefore > 0 {
-			provisioner = &PausedProvisioner{
-				PauseBefore: rawP.PauseBefore,
-				Provisioner: provisioner,
-			}
+	// Setup the error-cleanup-provisioner for this build
+	var cleanupProvisioner coreBuildProvisioner
+	if rawP := c.Template.ErrorCleanupProvisioner; rawP != nil && !rawP.Skip(rawName) {
+		cleanupProvisioner, err = c.generateCoreBuildProvisioner(rawP, rawName)
+		if err != nil {
+			return nil, err
 		}
-
-		provisioners = append(provisioners, coreBuildProvisioner{
-			pType:       rawP.Type,
-			provisioner: provisioner,
-			config:      config,
-		})
 	}
 
 	// Setup the post-processors
@@ -182,6 +193,16 @@ func (c *Core) Build(n string) (Build, error) {
 					"post-processor type not found: %s", rawP.Type)
 			}
 
+			// If we're retrying, we wrap the post-processor in a special
+			// retrier.
+			if rawP.MaxRetries > 0 {
+				postProcessor = &RetriedPostProcessor{
+					MaxRetries:    rawP.MaxRetries,
+					RetryBackoff:  rawP.RetryBackoff,
+					PostProcessor: postProcessor,
+				}
+			}
+
 			current = append(current, coreBuildPostProcessor{
 				processor:         postProcessor,
 				processorType:     rawP.Type,
@@ -198,17 +219,76 @@ func (c *Core) Build(n string) (Build, error) {
 		postProcessors = append(postProcessors, current)
 	}
 
+	// The values of the sensitive variables are masked in build plans
+	var sensitive []string
+	for _, n := range c.Template.SensitiveVariables {
+		if v := c.variables[n]; v != "" {
+			sensitive = append(sensitive, v)
+		}
+	}
+
 	// TODO hooks one day
 
 	return &coreBuild{
-		name:           n,
-		builder:        builder,
-		builderConfig:  configBuilder.Config,
-		builderType:    configBuilder.Type,
-		postProcessors: postProcessors,
-		provisioners:   provisioners,
-		templatePath:   c.Template.Path,
-		variables:      c.variables,
+		name:               n,
+		builder:            builder,
+		cleanupProvisioner: cleanupProvisioner,
+		builderConfig:      configBuilder.Config,
+		builderType:        configBuilder.Type,
+		matrix:             configBuilder.Matrix,
+		postProcessors:     postProcessors,
+		provisioners:       provisioners,
+		sensitive:          sensitive,
+		templatePath:       c.Template.Path,
+		variables:          c.variables,
+	}, nil
+}
+
+// generateCoreBuildProvisioner sets up the provisioner from the template
+// for the build with the given raw name.
+func (c *Core) generateCoreBuildProvisioner(rawP *template.Provisioner, rawName string) (coreBuildProvisioner, error) {
+	// Get the provisioner
+	provisioner, err := c.components.Provisioner(rawP.Type)
+	if err != nil {
+		return coreBuildProvisioner{}, fmt.Errorf(
+			"error initializing provisioner '%s': %s",
+			rawP.Type, err)
+	}
+	if provisioner == nil {
+		return coreBuildProvisioner{}, fmt.Errorf(
+			"provisioner type not found: %s", rawP.Type)
+	}
+
+	// Get the configuration
+	config := make([]interface{}, 1, 2)
+	config[0] = rawP.Config
+	if rawP.Override != nil {
+		if override, ok := rawP.Override[rawName]; ok {
+			config = append(config, override)
+		}
+	}
+
+	// If we're retrying, we wrap the provisioner in a special retrier.
+	if rawP.MaxRetries > 0 {
+		provisioner = &RetriedProvisioner{
+			MaxRetries:   rawP.MaxRetries,
+			RetryBackoff: rawP.RetryBackoff,
+			Provisioner:  provisioner,
+		}
+	}
+
+	// If we're pausing, we wrap the provisioner in a special pauser.
+	if rawP.PauseBefore > 0 {
+		provisioner = &PausedProvisioner{
+			PauseBefore: rawP.PauseBefore,
+			Provisioner: provisioner,
+		}
+	}
+
+	return coreBuildProvisioner{
+		pType:       rawP.Type,
+		provisioner: provisioner,
+		config:      config,
 	}, nil
 }
 
@@ -227,9 +307,11 @@ func (c *Core) Context() *interpolate.Context {
 func (c *Core) validate() error {
 	// First validate the template in general, we can't do anything else
 	// unless the template itself is valid.
-	if err := c.Template.Validate(); err != nil {
+	err := c.Template.Validate()
+	if err != nil {
 		return err
 	}
+	c.warnings = c.Template.Warnings()
 
 	// Validate the minimum version is satisfied
 	if c.Template.MinVersion != "" {
@@ -254,7 +336,6 @@ func (c *Core) validate() error {
 	}
 
 	// Validate variables are set
-	var err error
 	for n, v := range c.Template.Variables {
 		if v.Required {
 			if _, ok := c.variables[n]; !ok {
@@ -264,6 +345,14 @@ func (c *Core) validate() error {
 		}
 	}
 
+	// Validate the plugins the template requires are installed, so that
+	// a missing plugin is reported with a hint on how to install it.
+	for _, p := range c.Template.RequiredPlugins {
+		if perr := c.validateRequiredPlugin(p); perr != nil {
+			err = multierror.Append(err, perr)
+		}
+	}
+
 	// TODO: validate all builders exist
 	// TODO: ^^ provisioner
 	// TODO: ^^ post-processor
@@ -271,6 +360,57 @@ func (c *Core) validate() error {
 	return err
 }
 
+// validateRequiredPlugin returns an error if the required plugin isn't
+// installed or can't be loaded.
+func (c *Core) validateRequiredPlugin(p *template.RequiredPlugin) error {
+	var found bool
+	var err error
+	switch p.Type {
+	case "builder":
+		if c.components.Builder != nil {
+			var b Builder
+			b, err = c.components.Builder(p.Name)
+			found = b != nil
+		}
+	case "post-processor":
+		if c.components.PostProcessor != nil {
+			var pp PostProcessor
+			pp, err = c.components.PostProcessor(p.Name)
+			found = pp != nil
+		}
+	case "provisioner":
+		if c.components.Provisioner != nil {
+			var prov Provisioner
+			prov, err = c.components.Provisioner(p.Name)
+			found = prov != nil
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf(
+			"The %s plugin '%s' required by this template can't be loaded: %s",
+			p.Type, p.Name, err)
+	}
+	if found {
+		return nil
+	}
+
+	msg := fmt.Sprintf(
+		"The %s plugin '%s' required by this template is not installed.",
+		p.Type, p.Name)
+	if p.Source != "" {
+		install := "packer plugins install"
+		if p.SHA256 != "" {
+			install += " -sha256=" + p.SHA256
+		}
+		msg += fmt.Sprintf(" Install it with:\n\n  %s %s", install, p.Source)
+	} else {
+		msg += " Run 'packer plugins list' to see the installed plugins."
+	}
+
+	return errors.New(msg)
+}
+
 func (c *Core) init() error {
 	if c.variables == nil {
 		c.variables = make(map[string]string)
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	configHelper "github.com/hashicorp/packer/helper/config"
@@ -50,6 +51,38 @@ func TestCoreBuildNames(t *testing.T) {
 	}
 }
 
+func TestCoreBuildDependencies(t *testing.T) {
+	tpl, err := template.ParseFile(fixtureDir("build-names-depends-on.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	core, err := NewCore(&CoreConfig{Template: tpl})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	deps, err := core.BuildDependencies("build-child")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(deps, []string{"base"}) {
+		t.Fatalf("bad: %#v", deps)
+	}
+
+	deps, err = core.BuildDependencies("base")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(deps) != 0 {
+		t.Fatalf("bad: %#v", deps)
+	}
+
+	if _, err := core.BuildDependencies("nope"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestCoreBuild_basic(t *testing.T) {
 	config := TestCoreConfig(t)
 	testCoreTemplate(t, config, fixtureDir("build-basic.json"))
@@ -171,6 +204,38 @@ func TestCoreBuild_buildNameVar(t *testing.T) {
 	}
 }
 
+func TestCoreBuild_matrixVar(t *testing.T) {
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-var-matrix.json"))
+	b := TestBuilder(t, config, "test")
+	core := TestCore(t, config)
+
+	expected := []string{"test-eu-west-1", "test-us-east-1"}
+	if names := core.BuildNames(); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	build, err := core.Build("test-eu-west-1")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Interpolate the config
+	var result map[string]interface{}
+	err = configHelper.Decode(&result, nil, b.PrepareConfig...)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result["value"] != "eu-west-1" {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
 func TestCoreBuild_buildTypeVar(t *testing.T) {
 	config := TestCoreConfig(t)
 	testCoreTemplate(t, config, fixtureDir("build-var-build-type.json"))
@@ -314,6 +379,42 @@ func TestCoreBuild_provSkipInclude(t *testing.T) {
 	}
 }
 
+func TestCoreBuild_provErrorCleanup(t *testing.T) {
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("build-prov-error-cleanup.json"))
+	TestBuilder(t, config, "test")
+	p := TestProvisioner(t, config, "test")
+	core := TestCore(t, config)
+
+	cases := map[string]bool{
+		"test": false,
+		"foo":  true,
+	}
+
+	for name, expected := range cases {
+		build, err := core.Build(name)
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+
+		actual := build.(*coreBuild).cleanupProvisioner.provisioner != nil
+		if actual != expected {
+			t.Fatalf("%s: bad: %#v", name, actual)
+		}
+	}
+
+	build, err := core.Build("foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := build.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.PrepCalled {
+		t.Fatal("provisioner not prepared")
+	}
+}
+
 func TestCoreBuild_provOverride(t *testing.T) {
 	config := TestCoreConfig(t)
 	testCoreTemplate(t, config, fixtureDir("build-prov-override.json"))
@@ -522,6 +623,41 @@ func TestCoreValidate(t *testing.T) {
 	}
 }
 
+func TestCoreValidate_requiredPlugins(t *testing.T) {
+	config := TestCoreConfig(t)
+	testCoreTemplate(t, config, fixtureDir("validate-required-plugins.json"))
+
+	_, err := NewCore(config)
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	// The installed builder is fine, the missing plugins are reported
+	// with their source if they have one.
+	if strings.Contains(err.Error(), "'test'") {
+		t.Fatalf("bad: %s", err)
+	}
+	expected := []string{
+		"The provisioner plugin 'acme-agent' required by this template is not installed. Install it with:\n\n  packer plugins install -sha256=abcd https://example.com/packer-plugin-acme.zip",
+		"The post-processor plugin 'acme-upload' required by this template is not installed. Run 'packer plugins list'",
+	}
+	for _, e := range expected {
+		if !strings.Contains(err.Error(), e) {
+			t.Fatalf("missing %q in: %s", e, err)
+		}
+	}
+}
+
+func TestCoreValidate_requiredPluginsInstalled(t *testing.T) {
+	config := TestCoreConfig(t)
+	config.Components = *testComponentFinder()
+	testCoreTemplate(t, config, fixtureDir("validate-required-plugins.json"))
+
+	if _, err := NewCore(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func testComponentFinder() *ComponentFinder {
 	builderFactory := func(n string) (Builder, error) { return new(MockBuilder), nil }
 	ppFactory := func(n string) (PostProcessor, error) { return new(MockPostProcessor), nil }
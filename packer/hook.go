@@ -4,7 +4,10 @@ import (
 	"sync"
 )
 
-// This is the hook that should be fired for provisioners to run.
+// This is the hook that should be fired for provisioners to run. With nil
+// data all the provisioners are run, and with an integer only the
+// provisioner with that index is, for builders that do something between
+// the provisioners.
 const HookProvision = "packer_provision"
 
 // A Hook is used to hook into an arbitrarily named location in a build,
@@ -0,0 +1,331 @@
+package packer
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// SensitiveValue replaces the values in a build plan that must not be
+// shown, such as passwords.
+const SensitiveValue = "<sensitive>"
+
+// A PlannedBuild is a Build that can describe what it would do when it is
+// run, without running it.
+type PlannedBuild interface {
+	Plan() *BuildPlan
+}
+
+// BuildPlan describes what a build would do when it is run.
+//
+// The configurations in the plan are rendered as far as they can be
+// before the build runs. Values that are only known to the components
+// while the build runs, such as "{{ .Path }}", and values that fail to
+// render, such as the artifacts of builds that haven't run yet, are left
+// as they are in the template. The values of sensitive variables and of
+// keys that look like they hold secrets are replaced by SensitiveValue.
+type BuildPlan struct {
+	Name        string
+	BuilderType string
+	Builder     map[string]interface{}
+
+	// Provisioners are the provisioners that run for this build, in order,
+	// with their overrides for this build applied.
+	Provisioners            []*ProvisionerPlan
+	ErrorCleanupProvisioner *ProvisionerPlan
+
+	// PostProcessors are the post-processor chains that run for this
+	// build. Every chain starts from the artifact of the builder.
+	PostProcessors [][]*PostProcessorPlan
+
+	// KeepBuilderArtifact is true if the artifact of the builder is
+	// kept, either because there are no post-processors or because the
+	// first post-processor of a chain keeps its input artifact.
+	KeepBuilderArtifact bool
+
+	// Outputs are the files, directories and images that the build
+	// creates, which -force deletes or replaces if they already exist.
+	Outputs []*PlanOutput
+}
+
+// ProvisionerPlan describes a provisioner of a build plan.
+type ProvisionerPlan struct {
+	Type         string
+	Config       map[string]interface{}
+	Override     bool
+	PauseBefore  time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// PostProcessorPlan describes a post-processor of a build plan.
+type PostProcessorPlan struct {
+	Type              string
+	Config            map[string]interface{}
+	KeepInputArtifact bool
+	MaxRetries        int
+	RetryBackoff      time.Duration
+}
+
+// PlanOutput is something that a build creates.
+type PlanOutput struct {
+	// Component is the type of the builder or post-processor that creates
+	// the output, and Key is the configuration key that names it.
+	Component string
+	Key       string
+	Value     string
+
+	// Builder is true for the outputs of the builder, and false for those
+	// of the post-processors.
+	Builder bool
+
+	// Local is true for files and directories on this machine, and false
+	// for images and the like that are created elsewhere. Exists tells
+	// whether a local output already exists.
+	Local  bool
+	Exists bool
+
+	// Force is true if the output is deleted or replaced when it already
+	// exists because -force is set. Without -force, builders fail when
+	// their output exists. Most post-processors overwrite their output
+	// either way.
+	Force bool
+}
+
+// PlanOutputKey is a configuration key of a builder or post-processor that
+// names something the component creates.
+type PlanOutputKey struct {
+	Key string
+
+	// Default is the value of the key when it isn't set. It may be a
+	// template, which is rendered like the configuration.
+	Default string
+
+	// Local is true if the key names a file or directory on this
+	// machine.
+	Local bool
+
+	// Force is true if a post-processor deletes or replaces the output
+	// with -force, and fails without it, like builders do.
+	Force bool
+}
+
+// PlanOutputter is implemented by builders and post-processors that can
+// tell which configuration keys name what they create, so that build plans
+// can list it. It is optional; the outputs of components that don't
+// implement it are left out of the plan. The outputs of builders are
+// deleted or replaced with -force, and those of post-processors are
+// overwritten whether -force is set or not, unless they set Force.
+type PlanOutputter interface {
+	PlanOutputs() []PlanOutputKey
+}
+
+// planOutputKeys returns the output keys of the given builder or
+// post-processor, if it can tell them.
+func planOutputKeys(component interface{}) []PlanOutputKey {
+	if r, ok := component.(*RetriedPostProcessor); ok {
+		component = r.PostProcessor
+	}
+	if p, ok := component.(PlanOutputter); ok {
+		return p.PlanOutputs()
+	}
+
+	return nil
+}
+
+// sensitiveKeyRe matches the configuration keys that look like they hold
+// secrets.
+var sensitiveKeyRe = regexp.MustCompile(`(?i)(password|passphrase|secret|token|(^|_)key$)`)
+
+// Plan returns what the build would do when it is run.
+func (b *coreBuild) Plan() *BuildPlan {
+	ctx := &interpolate.Context{
+		BuildName:     b.name,
+		BuildType:     b.builderType,
+		Matrix:        b.matrix,
+		TemplatePath:  b.templatePath,
+		UserVariables: b.variables,
+	}
+	if len(b.upstream) > 0 {
+		ctx.UpstreamArtifacts = b.upstreamArtifacts()
+	}
+
+	plan := &BuildPlan{
+		Name:                b.name,
+		BuilderType:         b.builderType,
+		Builder:             b.planConfig(ctx, b.builderConfig),
+		KeepBuilderArtifact: len(b.postProcessors) == 0,
+	}
+	plan.Outputs = b.planOutputs(ctx, b.builderType, plan.Builder, planOutputKeys(b.builder), true)
+
+	for _, p := range b.provisioners {
+		plan.Provisioners = append(plan.Provisioners, b.planProvisioner(ctx, p))
+	}
+	if b.cleanupProvisioner.pType != "" {
+		plan.ErrorCleanupProvisioner = b.planProvisioner(ctx, b.cleanupProvisioner)
+	}
+
+	for _, ppSeq := range b.postProcessors {
+		chain := make([]*PostProcessorPlan, 0, len(ppSeq))
+		for _, corePP := range ppSeq {
+			pp := &PostProcessorPlan{
+				Type:              corePP.processorType,
+				Config:            b.planConfig(ctx, corePP.config),
+				KeepInputArtifact: corePP.keepInputArtifact,
+			}
+			if r, ok := corePP.processor.(*RetriedPostProcessor); ok {
+				pp.MaxRetries = r.MaxRetries
+				pp.RetryBackoff = retryBackoff(r.RetryBackoff)
+			}
+			chain = append(chain, pp)
+
+			plan.Outputs = append(plan.Outputs,
+				b.planOutputs(ctx, pp.Type, pp.Config, planOutputKeys(corePP.processor), false)...)
+		}
+
+		if chain[0].KeepInputArtifact {
+			plan.KeepBuilderArtifact = true
+		}
+		plan.PostProcessors = append(plan.PostProcessors, chain)
+	}
+
+	return plan
+}
+
+func (b *coreBuild) planProvisioner(ctx *interpolate.Context, p coreBuildProvisioner) *ProvisionerPlan {
+	result := &ProvisionerPlan{
+		Type:     p.pType,
+		Config:   b.planConfig(ctx, p.config...),
+		Override: len(p.config) > 1,
+	}
+
+	// Unwrap the provisioner to find out how it is paused and retried
+	provisioner := p.provisioner
+	if paused, ok := provisioner.(*PausedProvisioner); ok {
+		result.PauseBefore = paused.PauseBefore
+		provisioner = paused.Provisioner
+	}
+	if retried, ok := provisioner.(*RetriedProvisioner); ok {
+		result.MaxRetries = retried.MaxRetries
+		result.RetryBackoff = retryBackoff(retried.RetryBackoff)
+	}
+
+	return result
+}
+
+// planConfig merges the given raw configurations, the later ones
+// overriding the keys of the earlier ones, and renders the result.
+func (b *coreBuild) planConfig(ctx *interpolate.Context, raws ...interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for _, raw := range raws {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+
+	result := make(map[string]interface{}, len(merged))
+	for k, v := range merged {
+		result[k] = b.planValue(ctx, k, v)
+	}
+
+	return result
+}
+
+// planValue renders the value of the given configuration key, masking
+// what is sensitive.
+func (b *coreBuild) planValue(ctx *interpolate.Context, key string, raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case string:
+		if v != "" && sensitiveKeyRe.MatchString(key) {
+			return SensitiveValue
+		}
+		return b.maskSensitive(planRender(ctx, v))
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, inner := range v {
+			result[k] = b.planValue(ctx, k, inner)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, inner := range v {
+			result[i] = b.planValue(ctx, key, inner)
+		}
+		return result
+	default:
+		return raw
+	}
+}
+
+// planRender renders the template string v, leaving it as it is if it
+// can't be rendered before the build runs.
+func planRender(ctx *interpolate.Context, v string) string {
+	if !strings.Contains(v, "{{") || interpolate.ReferencesData(v) {
+		return v
+	}
+
+	result, err := interpolate.Render(v, ctx)
+	if err != nil {
+		return v
+	}
+
+	return result
+}
+
+// maskSensitive replaces the values of the sensitive variables in v.
+func (b *coreBuild) maskSensitive(v string) string {
+	// Replace the longest values first, in case one contains another
+	values := make([]string, len(b.sensitive))
+	copy(values, b.sensitive)
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	for _, value := range values {
+		if value != "" {
+			v = strings.Replace(v, value, SensitiveValue, -1)
+		}
+	}
+
+	return v
+}
+
+// planOutputs returns the given outputs of the builder or post-processor
+// of the given type, with the given rendered configuration.
+func (b *coreBuild) planOutputs(ctx *interpolate.Context, component string, config map[string]interface{}, outputs []PlanOutputKey, builder bool) []*PlanOutput {
+	var result []*PlanOutput
+	for _, o := range outputs {
+		value, _ := config[o.Key].(string)
+		if value == "" {
+			value = b.maskSensitive(planRender(ctx, o.Default))
+		}
+		if value == "" {
+			continue
+		}
+
+		output := &PlanOutput{
+			Component: component,
+			Key:       o.Key,
+			Value:     value,
+			Builder:   builder,
+			Local:     o.Local,
+			Force:     (builder || o.Force) && b.force,
+		}
+		if o.Local && !strings.Contains(value, "{{") {
+			_, err := os.Stat(value)
+			output.Exists = err == nil
+		}
+
+		result = append(result, output)
+	}
+
+	return result
+}
@@ -0,0 +1,169 @@
+package packer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testQemuOutputKeys = []PlanOutputKey{{Key: "output_directory", Default: "output-{{build_name}}", Local: true}}
+
+func TestBuildPlan(t *testing.T) {
+	build := testBuild()
+	build.builder = &MockBuilder{OutputKeys: testQemuOutputKeys}
+	build.builderType = "qemu"
+	build.builderConfig = map[string]interface{}{
+		"iso_url":      "{{user `mirror`}}/{{build_name}}.iso",
+		"ssh_password": "{{user `password`}}",
+		"boot_command": []interface{}{"root<enter>{{user `password`}}<enter>"},
+	}
+	build.variables = map[string]string{
+		"mirror":   "http://example.com",
+		"password": "hunter2",
+	}
+	build.sensitive = []string{"hunter2"}
+	build.provisioners = []coreBuildProvisioner{
+		{
+			"shell",
+			&PausedProvisioner{
+				PauseBefore: time.Second,
+				Provisioner: &RetriedProvisioner{MaxRetries: 2, Provisioner: &MockProvisioner{}},
+			},
+			[]interface{}{
+				map[string]interface{}{
+					"inline":          []interface{}{"echo {{user `mirror`}}"},
+					"execute_command": "{{ .Vars }} sudo {{ .Path }}",
+				},
+				map[string]interface{}{
+					"inline": []interface{}{"echo override"},
+				},
+			},
+		},
+	}
+	build.postProcessors = [][]coreBuildPostProcessor{
+		{
+			{
+				&RetriedPostProcessor{PostProcessor: &MockPostProcessor{
+					OutputKeys: []PlanOutputKey{{Key: "output", Default: "packer_{{.BuildName}}_{{.BuilderType}}", Local: true}},
+				}},
+				"compress", map[string]interface{}{"output": "{{build_name}}.tar.gz"}, true,
+			},
+			{
+				&MockPostProcessor{
+					OutputKeys: []PlanOutputKey{{Key: "output", Default: "packer_{{ .BuildName }}_{{.Provider}}.box", Local: true}},
+				},
+				"vagrant", map[string]interface{}{}, false,
+			},
+			// Post-processors that can't tell their outputs have none
+			{&MockPostProcessor{}, "manifest", map[string]interface{}{"output": "manifest.json"}, false},
+		},
+	}
+
+	plan := build.Plan()
+
+	expectedBuilder := map[string]interface{}{
+		"iso_url":      "http://example.com/test.iso",
+		"ssh_password": SensitiveValue,
+		"boot_command": []interface{}{"root<enter>" + SensitiveValue + "<enter>"},
+	}
+	if !reflect.DeepEqual(plan.Builder, expectedBuilder) {
+		t.Fatalf("bad: %#v", plan.Builder)
+	}
+
+	if len(plan.Provisioners) != 1 {
+		t.Fatalf("bad: %#v", plan.Provisioners)
+	}
+	p := plan.Provisioners[0]
+	expectedProvisioner := map[string]interface{}{
+		"inline":          []interface{}{"echo override"},
+		"execute_command": "{{ .Vars }} sudo {{ .Path }}",
+	}
+	if !reflect.DeepEqual(p.Config, expectedProvisioner) {
+		t.Fatalf("bad: %#v", p.Config)
+	}
+	if !p.Override || p.PauseBefore != time.Second || p.MaxRetries != 2 {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	if !plan.KeepBuilderArtifact {
+		t.Fatal("the builder artifact should be kept")
+	}
+
+	var outputs []string
+	for _, o := range plan.Outputs {
+		outputs = append(outputs, o.Component+" "+o.Key+" "+o.Value)
+	}
+	expectedOutputs := []string{
+		"qemu output_directory output-test",
+		"compress output test.tar.gz",
+		"vagrant output packer_{{ .BuildName }}_{{.Provider}}.box",
+	}
+	if !reflect.DeepEqual(outputs, expectedOutputs) {
+		t.Fatalf("bad: %#v", outputs)
+	}
+	if !plan.Outputs[0].Builder || plan.Outputs[1].Builder {
+		t.Fatalf("bad: %#v", plan.Outputs)
+	}
+}
+
+func TestBuildPlan_outputExists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	build := testBuild()
+	build.builder = &MockBuilder{OutputKeys: testQemuOutputKeys}
+	build.builderType = "qemu"
+	build.builderConfig = map[string]interface{}{
+		"output_directory": dir,
+	}
+	build.postProcessors = nil
+	build.force = true
+
+	plan := build.Plan()
+	if len(plan.Outputs) != 1 {
+		t.Fatalf("bad: %#v", plan.Outputs)
+	}
+	if o := plan.Outputs[0]; !o.Exists || !o.Force || !o.Local {
+		t.Fatalf("bad: %#v", o)
+	}
+
+	build.builderConfig = map[string]interface{}{
+		"output_directory": filepath.Join(dir, "missing"),
+	}
+	if o := build.Plan().Outputs[0]; o.Exists {
+		t.Fatalf("bad: %#v", o)
+	}
+}
+
+func TestBuildPlan_postProcessorForce(t *testing.T) {
+	build := testBuild()
+	build.postProcessors = [][]coreBuildPostProcessor{
+		{
+			{
+				&MockPostProcessor{OutputKeys: []PlanOutputKey{{Key: "output_directory", Local: true, Force: true}}},
+				"ovf", map[string]interface{}{"output_directory": "ovf"}, false,
+			},
+			{
+				&MockPostProcessor{OutputKeys: []PlanOutputKey{{Key: "output", Local: true}}},
+				"compress", map[string]interface{}{"output": "out.tar.gz"}, false,
+			},
+		},
+	}
+
+	for _, force := range []bool{false, true} {
+		build.force = force
+		outputs := build.Plan().Outputs
+		if len(outputs) != 2 {
+			t.Fatalf("bad: %#v", outputs)
+		}
+		if outputs[0].Force != force || outputs[1].Force {
+			t.Fatalf("force %t: bad: %#v %#v", force, outputs[0], outputs[1])
+		}
+	}
+}
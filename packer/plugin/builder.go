@@ -35,9 +35,25 @@ func (b *cmdBuilder) Cancel() {
 		b.checkExit(r, nil)
 	}()
 
+	if !b.client.cancelSupported() {
+		return
+	}
+
 	b.builder.Cancel()
 }
 
+func (b *cmdBuilder) PlanOutputs() []packer.PlanOutputKey {
+	defer func() {
+		r := recover()
+		b.checkExit(r, nil)
+	}()
+
+	if p, ok := b.builder.(packer.PlanOutputter); ok {
+		return p.PlanOutputs()
+	}
+	return nil
+}
+
 func (c *cmdBuilder) checkExit(p interface{}, cb func()) {
 	if c.client.Exited() && cb != nil {
 		cb()
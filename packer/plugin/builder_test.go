@@ -3,6 +3,8 @@ package plugin
 import (
 	"os/exec"
 	"testing"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 func TestBuilder_NoExist(t *testing.T) {
@@ -24,3 +26,23 @@ func TestBuilder_Good(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 }
+
+func TestBuilder_PrepareUpstreamArtifacts(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("builder")})
+	defer c.Kill()
+
+	b, err := c.Builder()
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// The packer configuration of a build that depends on another one
+	packerConfig := map[string]interface{}{
+		packer.UpstreamArtifactsConfigKey: map[string]map[string]string{
+			"base": {"id": "ami-1234"},
+		},
+	}
+	if _, err := b.Prepare(packerConfig); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
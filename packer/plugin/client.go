@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/hashicorp/packer/packer"
 	packrpc "github.com/hashicorp/packer/packer/rpc"
+	"github.com/hashicorp/packer/version"
 )
 
 // If this is true, then the "unexpected EOF" panic will not be
@@ -37,6 +39,9 @@ type Client struct {
 	doneLogging chan struct{}
 	l           sync.Mutex
 	address     net.Addr
+
+	// The capabilities supported by both the plugin and us
+	capabilities []string
 }
 
 // ClientConfig is the configuration used to initialize a new
@@ -65,6 +70,15 @@ type ClientConfig struct {
 	// If non-nil, then the stderr of the client will be written to here
 	// (as well as the log).
 	Stderr io.Writer
+
+	// LogTag, if set, is prepended to the log lines of the subprocess,
+	// for example to tell the builds the plugins belong to apart.
+	LogTag string
+
+	// Component is the name of the component to use, for plugins that
+	// serve a set of components. If empty, the plugin is expected to serve
+	// a single component.
+	Component string
 }
 
 // This makes sure all the managed subprocesses are killed and properly
@@ -131,7 +145,7 @@ func (c *Client) Exited() bool {
 // Returns a builder implementation that is communicating over this
 // client. If the client hasn't been started, this will start it.
 func (c *Client) Builder() (packer.Builder, error) {
-	client, err := c.packrpcClient()
+	client, err := c.componentClient(packrpc.ComponentBuilder)
 	if err != nil {
 		return nil, err
 	}
@@ -153,7 +167,7 @@ func (c *Client) Hook() (packer.Hook, error) {
 // Returns a post-processor implementation that is communicating over
 // this client. If the client hasn't been started, this will start it.
 func (c *Client) PostProcessor() (packer.PostProcessor, error) {
-	client, err := c.packrpcClient()
+	client, err := c.componentClient(packrpc.ComponentPostProcessor)
 	if err != nil {
 		return nil, err
 	}
@@ -164,7 +178,7 @@ func (c *Client) PostProcessor() (packer.PostProcessor, error) {
 // Returns a provisioner implementation that is communicating over this
 // client. If the client hasn't been started, this will start it.
 func (c *Client) Provisioner() (packer.Provisioner, error) {
-	client, err := c.packrpcClient()
+	client, err := c.componentClient(packrpc.ComponentProvisioner)
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +223,8 @@ func (c *Client) Start() (addr net.Addr, err error) {
 
 	env := []string{
 		fmt.Sprintf("%s=%s", MagicCookieKey, MagicCookieValue),
+		fmt.Sprintf("%s=%s", APIVersionKey, APIVersion),
+		fmt.Sprintf("%s=%s", CapabilitiesKey, strings.Join(Capabilities, ",")),
 		fmt.Sprintf("PACKER_PLUGIN_MIN_PORT=%d", c.config.MinPort),
 		fmt.Sprintf("PACKER_PLUGIN_MAX_PORT=%d", c.config.MaxPort),
 	}
@@ -309,21 +325,32 @@ func (c *Client) Start() (addr net.Addr, err error) {
 		err = errors.New("plugin exited before we could connect")
 	case lineBytes := <-linesCh:
 		// Trim the line and split by "|" in order to get the parts of
-		// the output.
+		// the output: the API version, the address and, since API
+		// version 5, the capabilities of the plugin.
 		line := strings.TrimSpace(string(lineBytes))
-		parts := strings.SplitN(line, "|", 3)
+		parts := strings.SplitN(line, "|", 4)
 		if len(parts) < 3 {
 			err = fmt.Errorf("Unrecognized remote plugin message: %s", line)
 			return
 		}
 
 		// Test the API version
-		if parts[0] != APIVersion {
-			err = fmt.Errorf("Incompatible API version with plugin. "+
-				"Plugin version: %s, Ours: %s", parts[0], APIVersion)
+		if !supportedAPIVersion(parts[0]) {
+			err = fmt.Errorf(
+				"The plugin %s speaks plugin API version %s, but this version "+
+					"of Packer (%s) speaks versions %s to %s. Please use a "+
+					"version of the plugin that is built for this version of Packer.",
+				filepath.Base(cmd.Path), parts[0], version.FormattedVersion(),
+				MinAPIVersion, APIVersion)
 			return
 		}
 
+		// Plugins of API version 4 don't announce any capabilities
+		if len(parts) > 3 {
+			c.capabilities = negotiateCapabilities(strings.Split(parts[3], ","))
+		}
+		log.Printf("%s: plugin capabilities: %v", filepath.Base(cmd.Path), c.capabilities)
+
 		switch parts[1] {
 		case "tcp":
 			addr, err = net.ResolveTCPAddr("tcp", parts[2])
@@ -338,6 +365,68 @@ func (c *Client) Start() (addr net.Addr, err error) {
 	return
 }
 
+// Capabilities returns the capabilities that both the plugin and this
+// version of Packer support. It is empty until the client is started.
+func (c *Client) Capabilities() []string {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	return c.capabilities
+}
+
+// HasCapability returns true if both the plugin and this version of Packer
+// support the given capability.
+func (c *Client) HasCapability(name string) bool {
+	for _, capability := range c.Capabilities() {
+		if capability == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cancelSupported returns true if the plugin can be cancelled. If it
+// can't, a cancellation is ignored and the plugin left to finish.
+func (c *Client) cancelSupported() bool {
+	if c.HasCapability(CapabilityCancel) {
+		return true
+	}
+
+	log.Printf("%s: plugin doesn't support cancellation, ignoring cancel",
+		filepath.Base(c.config.Cmd.Path))
+	return false
+}
+
+// supportedAPIVersion returns true if Packer core can use plugins of the
+// given API version.
+func supportedAPIVersion(v string) bool {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return false
+	}
+
+	min, _ := strconv.Atoi(MinAPIVersion)
+	max, _ := strconv.Atoi(APIVersion)
+	return n >= min && n <= max
+}
+
+// negotiateCapabilities returns the capabilities in the given list that are
+// supported by this version of Packer.
+func negotiateCapabilities(theirs []string) []string {
+	var result []string
+	for _, ours := range Capabilities {
+		for _, capability := range theirs {
+			if capability == ours {
+				result = append(result, ours)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
 func (c *Client) logStderr(r io.Reader) {
 	bufR := bufio.NewReader(r)
 	for {
@@ -346,7 +435,11 @@ func (c *Client) logStderr(r io.Reader) {
 			c.config.Stderr.Write([]byte(line))
 
 			line = strings.TrimRightFunc(line, unicode.IsSpace)
-			log.Printf("%s: %s", filepath.Base(c.config.Cmd.Path), line)
+			if c.config.LogTag != "" {
+				log.Printf("%s: %s: %s", c.config.LogTag, filepath.Base(c.config.Cmd.Path), line)
+			} else {
+				log.Printf("%s: %s", filepath.Base(c.config.Cmd.Path), line)
+			}
 		}
 
 		if err == io.EOF {
@@ -358,6 +451,34 @@ func (c *Client) logStderr(r io.Reader) {
 	close(c.doneLogging)
 }
 
+// Components returns the components served by a plugin that serves a set
+// of components. If the client hasn't been started, this will start it.
+func (c *Client) Components() ([]packrpc.Component, error) {
+	client, err := c.packrpcClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return client.Components()
+}
+
+// componentClient returns an RPC client for the component of the given
+// kind. If the plugin serves a set of components, the configured component
+// is started first.
+func (c *Client) componentClient(kind string) (*packrpc.Client, error) {
+	client, err := c.packrpcClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config.Component == "" {
+		return client, nil
+	}
+
+	return client.Component(kind, c.config.Component)
+}
+
 func (c *Client) packrpcClient() (*packrpc.Client, error) {
 	addr, err := c.Start()
 	if err != nil {
@@ -379,6 +500,7 @@ func (c *Client) packrpcClient() (*packrpc.Client, error) {
 		conn.Close()
 		return nil, err
 	}
+	client.SetCapabilities(c.Capabilities())
 
 	return client, nil
 }
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	packrpc "github.com/hashicorp/packer/packer/rpc"
 )
 
 func TestClient(t *testing.T) {
@@ -56,6 +59,75 @@ func TestClientStart_badVersion(t *testing.T) {
 	}
 }
 
+func TestClientStart_oldVersion(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("old-version")})
+	defer c.Kill()
+
+	if _, err := c.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Plugins of API version 4 are used without any capabilities
+	if len(c.Capabilities()) != 0 {
+		t.Fatalf("bad: %#v", c.Capabilities())
+	}
+}
+
+func TestClientStart_tooOldVersion(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("too-old-version")})
+	defer c.Kill()
+
+	_, err := c.Start()
+	if err == nil {
+		t.Fatal("err should not be nil")
+	}
+
+	expected := "speaks plugin API version 3, but this version of Packer"
+	if !strings.Contains(err.Error(), expected) {
+		t.Fatalf("bad: %s", err)
+	}
+	if !strings.Contains(err.Error(), "speaks versions 4 to 5") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestClientStart_capabilities(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("capabilities")})
+	defer c.Kill()
+
+	if _, err := c.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{CapabilityCancel}
+	if !reflect.DeepEqual(c.Capabilities(), expected) {
+		t.Fatalf("bad: %#v", c.Capabilities())
+	}
+
+	if !c.HasCapability(CapabilityCancel) {
+		t.Fatal("should have cancel capability")
+	}
+	if c.HasCapability(CapabilityProgress) {
+		t.Fatal("should not have progress capability")
+	}
+	if c.HasCapability("unknown") {
+		t.Fatal("should not have unknown capability")
+	}
+}
+
+func TestClientStart_noCapabilities(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("mock")})
+	defer c.Kill()
+
+	if _, err := c.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(c.Capabilities()) != 0 {
+		t.Fatalf("bad: %#v", c.Capabilities())
+	}
+}
+
 func TestClient_Start_Timeout(t *testing.T) {
 	config := &ClientConfig{
 		Cmd:          helperProcess("start-timeout"),
@@ -143,3 +215,50 @@ func TestClient_Stdin(t *testing.T) {
 		t.Fatal("process didn't exit cleanly")
 	}
 }
+
+func TestClient_Components(t *testing.T) {
+	c := NewClient(&ClientConfig{Cmd: helperProcess("component-set")})
+	defer c.Kill()
+
+	components, err := c.Components()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []packrpc.Component{
+		{Kind: packrpc.ComponentBuilder, Name: "foo"},
+		{Kind: packrpc.ComponentProvisioner, Name: "bar"},
+	}
+	if !reflect.DeepEqual(components, expected) {
+		t.Fatalf("bad: %#v", components)
+	}
+}
+
+func TestClient_Component(t *testing.T) {
+	c := NewClient(&ClientConfig{
+		Cmd:       helperProcess("component-set"),
+		Component: "foo",
+	})
+	defer c.Kill()
+
+	b, err := c.Builder()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := b.Prepare(42); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestClient_ComponentUnknown(t *testing.T) {
+	c := NewClient(&ClientConfig{
+		Cmd:       helperProcess("component-set"),
+		Component: "bar",
+	})
+	defer c.Kill()
+
+	if _, err := c.Builder(); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -26,6 +26,10 @@ func (c *cmdHook) Cancel() {
 		c.checkExit(r, nil)
 	}()
 
+	if !c.client.cancelSupported() {
+		return
+	}
+
 	c.hook.Cancel()
 }
 
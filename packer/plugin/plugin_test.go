@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/packer/packer"
+	packrpc "github.com/hashicorp/packer/packer/rpc"
 )
 
 func helperProcess(s ...string) *exec.Cmd {
@@ -54,6 +55,9 @@ func TestHelperProcess(*testing.T) {
 	case "bad-version":
 		fmt.Printf("%s1|tcp|:1234\n", APIVersion)
 		<-make(chan int)
+	case "capabilities":
+		fmt.Printf("%s|tcp|:1234|%s,unknown\n", APIVersion, CapabilityCancel)
+		<-make(chan int)
 	case "builder":
 		server, err := Server()
 		if err != nil {
@@ -62,6 +66,21 @@ func TestHelperProcess(*testing.T) {
 		}
 		server.RegisterBuilder(new(packer.MockBuilder))
 		server.Serve()
+	case "component-set":
+		server, err := Server()
+		if err != nil {
+			log.Printf("[ERR] %s", err)
+			os.Exit(1)
+		}
+		server.RegisterComponentSet(&packrpc.ComponentSet{
+			Builders: map[string]packer.Builder{
+				"foo": new(packer.MockBuilder),
+			},
+			Provisioners: map[string]packer.Provisioner{
+				"bar": new(packer.MockProvisioner),
+			},
+		})
+		server.Serve()
 	case "hook":
 		server, err := Server()
 		if err != nil {
@@ -75,6 +94,12 @@ func TestHelperProcess(*testing.T) {
 	case "mock":
 		fmt.Printf("%s|tcp|:1234\n", APIVersion)
 		<-make(chan int)
+	case "old-version":
+		fmt.Printf("4|tcp|:1234\n")
+		<-make(chan int)
+	case "too-old-version":
+		fmt.Printf("3|tcp|:1234\n")
+		<-make(chan int)
 	case "post-processor":
 		server, err := Server()
 		if err != nil {
@@ -29,6 +29,18 @@ func (c *cmdPostProcessor) PostProcess(ui packer.Ui, a packer.Artifact) (packer.
 	return c.p.PostProcess(ui, a)
 }
 
+func (c *cmdPostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	defer func() {
+		r := recover()
+		c.checkExit(r, nil)
+	}()
+
+	if p, ok := c.p.(packer.PlanOutputter); ok {
+		return p.PlanOutputs()
+	}
+	return nil
+}
+
 func (c *cmdPostProcessor) checkExit(p interface{}, cb func()) {
 	if c.client.Exited() && cb != nil {
 		cb()
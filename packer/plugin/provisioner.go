@@ -35,6 +35,10 @@ func (c *cmdProvisioner) Cancel() {
 		c.checkExit(r, nil)
 	}()
 
+	if !c.client.cancelSupported() {
+		return
+	}
+
 	c.p.Cancel()
 }
 
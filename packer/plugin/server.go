@@ -18,6 +18,7 @@ import (
 	"os/signal"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -35,8 +36,50 @@ const MagicCookieValue = "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d69
 
 // The APIVersion is outputted along with the RPC address. The plugin
 // client validates this API version and will show an error if it doesn't
-// know how to speak it.
-const APIVersion = "4"
+// know how to speak it. It must be changed whenever the RPC interfaces
+// change in a way that plugins built against an older version of Packer
+// can't speak. Optional features that the other end may lack are
+// negotiated as capabilities instead, and don't change the API version.
+const APIVersion = "5"
+
+// MinAPIVersion is the oldest API version of the plugins that Packer core
+// can still use. Plugins of API version 4 predate capabilities, and are
+// used without any. A plugin is incompatible if its API version is outside
+// of MinAPIVersion to APIVersion, which happens when Packer core drops the
+// RPC interfaces it speaks, or when the plugin is built against a newer
+// version of Packer.
+const MinAPIVersion = "4"
+
+// These are set in the environment of a plugin by the client, so that the
+// plugin knows which API version and capabilities Packer core speaks.
+const APIVersionKey = "PACKER_PLUGIN_API_VERSION"
+const CapabilitiesKey = "PACKER_PLUGIN_CAPABILITIES"
+
+// Capabilities are optional features of the plugin protocol. Both the
+// plugin and Packer core announce the capabilities they support, and a
+// capability is only used if both of them do.
+const (
+	// CapabilityCancel means that builders, provisioners and hooks can be
+	// cancelled while they run.
+	CapabilityCancel = "cancel"
+
+	// CapabilityProgress means that progress bars can be reported through
+	// the Ui. Without it, progress is reported as messages.
+	CapabilityProgress = packrpc.CapabilityProgress
+
+	// CapabilityChunkedTransfer means that communicators transfer files in
+	// chunks, which reports the progress of transfers and detects
+	// interrupted transfers. Without it, files are copied as one stream.
+	CapabilityChunkedTransfer = packrpc.CapabilityChunkedTransfer
+)
+
+// Capabilities are the capabilities supported by this version of Packer,
+// both as plugin and as Packer core.
+var Capabilities = []string{
+	CapabilityCancel,
+	CapabilityProgress,
+	CapabilityChunkedTransfer,
+}
 
 // Server waits for a connection to this plugin and returns a Packer
 // RPC server that you can use to register components and serve them.
@@ -46,6 +89,12 @@ func Server() (*packrpc.Server, error) {
 			"Please do not execute plugins directly. Packer will execute these for you.")
 	}
 
+	// Packer core refuses to talk to us if it is older than this plugin,
+	// but leave a note in the log for whoever is debugging this.
+	if v := os.Getenv(APIVersionKey); v != "" && olderAPIVersion(v) {
+		log.Printf("[WARN] Packer speaks plugin API version %s, but this plugin speaks version %s", v, APIVersion)
+	}
+
 	// If there is no explicit number of Go threads to use, then set it
 	if os.Getenv("GOMAXPROCS") == "" {
 		runtime.GOMAXPROCS(runtime.NumCPU())
@@ -73,10 +122,11 @@ func Server() (*packrpc.Server, error) {
 	// Output the address to stdout
 	log.Printf("Plugin address: %s %s\n",
 		listener.Addr().Network(), listener.Addr().String())
-	fmt.Printf("%s|%s|%s\n",
+	fmt.Printf("%s|%s|%s|%s\n",
 		APIVersion,
 		listener.Addr().Network(),
-		listener.Addr().String())
+		listener.Addr().String(),
+		strings.Join(Capabilities, ","))
 	os.Stdout.Sync()
 
 	// Accept a connection
@@ -101,7 +151,47 @@ func Server() (*packrpc.Server, error) {
 
 	// Serve a single connection
 	log.Println("Serving a plugin connection...")
-	return packrpc.NewServer(conn), nil
+	server := packrpc.NewServer(conn)
+	server.SetCapabilities(coreCapabilities())
+	return server, nil
+}
+
+// olderAPIVersion returns true if the given API version is older than the
+// one of this plugin.
+func olderAPIVersion(v string) bool {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return true
+	}
+
+	ours, _ := strconv.Atoi(APIVersion)
+	return n < ours
+}
+
+// CoreHasCapability returns true if Packer core, which started this plugin,
+// supports the given capability. This can be used by plugins to avoid
+// features that Packer core doesn't support.
+func CoreHasCapability(name string) bool {
+	for _, c := range strings.Split(os.Getenv(CapabilitiesKey), ",") {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// coreCapabilities returns the capabilities that both Packer core and this
+// plugin support.
+func coreCapabilities() []string {
+	var result []string
+	for _, c := range Capabilities {
+		if CoreHasCapability(c) {
+			result = append(result, c)
+		}
+	}
+
+	return result
 }
 
 func serverListener(minPort, maxPort int64) (net.Listener, error) {
@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"math/rand"
+	"os"
 	"testing"
 )
 
@@ -10,3 +11,30 @@ func TestPluginServerRandom(t *testing.T) {
 		t.Fatal("math.rand is not seeded properly")
 	}
 }
+
+func TestCoreHasCapability(t *testing.T) {
+	defer os.Setenv(CapabilitiesKey, os.Getenv(CapabilitiesKey))
+
+	os.Setenv(CapabilitiesKey, "")
+	if CoreHasCapability(CapabilityProgress) {
+		t.Fatal("should not have progress capability")
+	}
+
+	os.Setenv(CapabilitiesKey, CapabilityCancel+","+CapabilityProgress)
+	if !CoreHasCapability(CapabilityProgress) {
+		t.Fatal("should have progress capability")
+	}
+	if CoreHasCapability("unknown") {
+		t.Fatal("should not have unknown capability")
+	}
+}
+
+func TestCoreCapabilities(t *testing.T) {
+	defer os.Setenv(CapabilitiesKey, os.Getenv(CapabilitiesKey))
+
+	os.Setenv(CapabilitiesKey, CapabilityProgress+",unknown")
+	capabilities := coreCapabilities()
+	if len(capabilities) != 1 || capabilities[0] != CapabilityProgress {
+		t.Fatalf("bad: %#v", capabilities)
+	}
+}
@@ -1,5 +1,11 @@
 package packer
 
+import (
+	"context"
+	"sync"
+	"time"
+)
+
 // A PostProcessor is responsible for taking an artifact of a build
 // and doing some sort of post-processing to turn this into another
 // artifact. An example of a post-processor would be something that takes
@@ -16,3 +22,50 @@ type PostProcessor interface {
 	// is to true, then the previous artifact is forcibly kept.
 	PostProcess(Ui, Artifact) (a Artifact, keep bool, err error)
 }
+
+// RetriedPostProcessor is a PostProcessor implementation that runs the
+// post-processor again when it fails, up to MaxRetries more times. The
+// wait before each retry starts at RetryBackoff, but at least
+// minRetryBackoff, and doubles after every attempt.
+type RetriedPostProcessor struct {
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	PostProcessor PostProcessor
+
+	cancel context.CancelFunc
+	lock   sync.Mutex
+}
+
+func (p *RetriedPostProcessor) Configure(raws ...interface{}) error {
+	return p.PostProcessor.Configure(raws...)
+}
+
+func (p *RetriedPostProcessor) PostProcess(ui Ui, a Artifact) (Artifact, bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p.lock.Lock()
+	p.cancel = cancel
+	p.lock.Unlock()
+
+	var artifact Artifact
+	var keep bool
+	err := retry(ctx, ui, "Post-processor", p.MaxRetries, p.RetryBackoff, func() error {
+		var err error
+		artifact, keep, err = p.PostProcessor.PostProcess(ui, a)
+		return err
+	})
+
+	return artifact, keep, err
+}
+
+// Cancel stops waiting for the next retry, if the post-processor is
+// waiting for one. Post-processors can't be cancelled while they run.
+func (p *RetriedPostProcessor) Cancel() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
@@ -6,6 +6,7 @@ type MockPostProcessor struct {
 	ArtifactId string
 	Keep       bool
 	Error      error
+	OutputKeys []PlanOutputKey
 
 	ConfigureCalled  bool
 	ConfigureConfigs []interface{}
@@ -31,3 +32,7 @@ func (t *MockPostProcessor) PostProcess(ui Ui, a Artifact) (Artifact, bool, erro
 		IdValue: t.ArtifactId,
 	}, t.Keep, t.Error
 }
+
+func (t *MockPostProcessor) PlanOutputs() []PlanOutputKey {
+	return t.OutputKeys
+}
@@ -0,0 +1,118 @@
+package packer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingPostProcessor is a PostProcessor that fails a set number of
+// times before succeeding.
+type failingPostProcessor struct {
+	MockPostProcessor
+
+	Failures int
+	Attempts int
+}
+
+func (p *failingPostProcessor) PostProcess(ui Ui, a Artifact) (Artifact, bool, error) {
+	p.Attempts++
+	if p.Attempts <= p.Failures {
+		return nil, false, errors.New("failed")
+	}
+
+	return p.MockPostProcessor.PostProcess(ui, a)
+}
+
+func TestRetriedPostProcessor_impl(t *testing.T) {
+	var _ PostProcessor = new(RetriedPostProcessor)
+}
+
+func TestRetriedPostProcessorConfigure(t *testing.T) {
+	mock := new(MockPostProcessor)
+	pp := &RetriedPostProcessor{
+		PostProcessor: mock,
+	}
+
+	pp.Configure(42)
+	if !mock.ConfigureCalled {
+		t.Fatal("configure should be called")
+	}
+	if mock.ConfigureConfigs[0] != 42 {
+		t.Fatal("should have proper configs")
+	}
+}
+
+func TestRetriedPostProcessorPostProcess(t *testing.T) {
+	defer testMinRetryBackoff(time.Millisecond)()
+
+	mock := &failingPostProcessor{
+		MockPostProcessor: MockPostProcessor{ArtifactId: "pp"},
+		Failures:          2,
+	}
+	pp := &RetriedPostProcessor{
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		PostProcessor: mock,
+	}
+
+	artifact, _, err := pp.PostProcess(testUi(), new(MockArtifact))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if artifact.Id() != "pp" {
+		t.Fatalf("bad: %#v", artifact)
+	}
+	if mock.Attempts != 3 {
+		t.Fatalf("bad: %d", mock.Attempts)
+	}
+}
+
+func TestRetriedPostProcessorPostProcess_exhausted(t *testing.T) {
+	defer testMinRetryBackoff(time.Millisecond)()
+
+	mock := &failingPostProcessor{Failures: 3}
+	pp := &RetriedPostProcessor{
+		MaxRetries:    2,
+		PostProcessor: mock,
+	}
+
+	if _, _, err := pp.PostProcess(testUi(), new(MockArtifact)); err == nil {
+		t.Fatal("should error")
+	}
+	if mock.Attempts != 3 {
+		t.Fatalf("bad: %d", mock.Attempts)
+	}
+}
+
+func TestRetriedPostProcessorCancel(t *testing.T) {
+	mock := &failingPostProcessor{Failures: 5}
+	pp := &RetriedPostProcessor{
+		MaxRetries:    5,
+		RetryBackoff:  time.Hour,
+		PostProcessor: mock,
+	}
+
+	// Start post-processing, it waits to retry after the first attempt
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := pp.PostProcess(testUi(), new(MockArtifact))
+		errCh <- err
+	}()
+
+	// Cancel it while it waits to retry
+	time.Sleep(10 * time.Millisecond)
+	pp.Cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("should not wait for the backoff")
+	}
+	if mock.Attempts != 1 {
+		t.Fatalf("bad: %d", mock.Attempts)
+	}
+}
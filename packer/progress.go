@@ -0,0 +1,445 @@
+package packer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ProgressBar tracks the progress of a long running operation, such as a
+// file transfer, and reports it to the user. It is safe to be called from
+// multiple goroutines.
+type ProgressBar interface {
+	// Add moves the progress forward by n bytes.
+	Add(n int64)
+
+	// Set sets the progress to the given number of bytes.
+	Set(current int64)
+
+	// Close marks the operation as finished. The progress bar must not
+	// be used after it is closed.
+	Close() error
+}
+
+// ProgressUi is implemented by Ui implementations that know how to display
+// progress bars. It is optional; NewProgressBar should be used to get a
+// progress bar for any Ui.
+type ProgressUi interface {
+	// ProgressBar returns a progress bar with the given name, tracking
+	// an operation of total bytes. A total of zero or less means that the
+	// total is unknown.
+	ProgressBar(name string, total int64) ProgressBar
+}
+
+// The interval between progress lines for UIs that can't display a live
+// progress bar, and between updates of live and machine-readable progress.
+var (
+	progressLineInterval    = 5 * time.Second
+	progressRenderInterval  = 100 * time.Millisecond
+	progressMachineInterval = 1 * time.Second
+)
+
+// NewProgressBar returns a progress bar that reports through the given Ui.
+// If the Ui doesn't implement ProgressUi, the progress is periodically
+// reported as messages.
+func NewProgressBar(ui Ui, name string, total int64) ProgressBar {
+	if pu, ok := ui.(ProgressUi); ok {
+		return pu.ProgressBar(name, total)
+	}
+
+	return newLineProgressBar(ui.Message, name, total)
+}
+
+// TrackProgress wraps the reader so that the bytes read from it move a
+// progress bar reported through the given Ui. Closing the returned reader
+// closes the progress bar, but not r.
+func TrackProgress(ui Ui, name string, total int64, r io.Reader) io.ReadCloser {
+	return &progressReader{
+		Reader: r,
+		bar:    NewProgressBar(ui, name, total),
+	}
+}
+
+// TrackProgressWriter wraps the writer so that the bytes written to it move
+// a progress bar reported through the given Ui. Closing the returned writer
+// closes the progress bar, but not w.
+func TrackProgressWriter(ui Ui, name string, total int64, w io.Writer) io.WriteCloser {
+	return &progressWriter{
+		Writer: w,
+		bar:    NewProgressBar(ui, name, total),
+	}
+}
+
+type progressReader struct {
+	io.Reader
+	bar ProgressBar
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.bar.Add(int64(n))
+	return n, err
+}
+
+func (r *progressReader) Close() error {
+	return r.bar.Close()
+}
+
+type progressWriter struct {
+	io.Writer
+	bar ProgressBar
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.bar.Add(int64(n))
+	return n, err
+}
+
+func (w *progressWriter) Close() error {
+	return w.bar.Close()
+}
+
+// SetFileInfo passes the metadata of a downloaded file through to the
+// wrapped writer.
+func (w *progressWriter) SetFileInfo(fi os.FileInfo) {
+	SetDownloadFileInfo(w.Writer, fi)
+}
+
+// progressState is the state shared by all progress bar implementations.
+type progressState struct {
+	name    string
+	current int64
+	total   int64
+	l       sync.Mutex
+}
+
+// String returns a short, human readable description of the progress.
+func (s *progressState) String() string {
+	if s.total <= 0 {
+		return humanize.Bytes(uint64(s.current))
+	}
+
+	return fmt.Sprintf("%d%% (%s of %s)",
+		s.percent(), humanize.Bytes(uint64(s.current)), humanize.Bytes(uint64(s.total)))
+}
+
+func (s *progressState) percent() int {
+	if s.total <= 0 {
+		return 0
+	}
+
+	percent := int(float64(s.current) / float64(s.total) * 100)
+	if percent > 100 {
+		percent = 100
+	}
+
+	return percent
+}
+
+// lineProgressBar reports progress as a line at regular intervals. It is
+// used for UIs that can't display a live progress bar, such as output that
+// is not going to a terminal.
+type lineProgressBar struct {
+	progressState
+
+	say      func(string)
+	lastLine time.Time
+	reported bool
+}
+
+func newLineProgressBar(say func(string), name string, total int64) *lineProgressBar {
+	return &lineProgressBar{
+		progressState: progressState{name: name, total: total},
+		say:           say,
+		lastLine:      time.Now(),
+	}
+}
+
+func (b *lineProgressBar) Add(n int64) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.current += n
+	b.report(false)
+}
+
+func (b *lineProgressBar) Set(current int64) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.current = current
+	b.report(false)
+}
+
+func (b *lineProgressBar) Close() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	// Only report the final state if we've reported something before, to
+	// keep short operations quiet.
+	if b.reported {
+		b.report(true)
+	}
+
+	return nil
+}
+
+func (b *lineProgressBar) report(force bool) {
+	if !force && time.Since(b.lastLine) < progressLineInterval {
+		return
+	}
+
+	b.lastLine = time.Now()
+	b.reported = true
+	b.say(fmt.Sprintf("%s: %s", b.name, b.String()))
+}
+
+// machineProgressBar reports progress as machine-readable output of the
+// "progress" type, with the name, current and total bytes as arguments.
+// A final "progress-done" message with the name is sent when it is closed.
+type machineProgressBar struct {
+	progressState
+
+	ui         Ui
+	lastReport time.Time
+}
+
+func newMachineProgressBar(ui Ui, name string, total int64) *machineProgressBar {
+	b := &machineProgressBar{
+		progressState: progressState{name: name, total: total},
+		ui:            ui,
+	}
+	b.report(true)
+	return b
+}
+
+func (b *machineProgressBar) Add(n int64) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.current += n
+	b.report(false)
+}
+
+func (b *machineProgressBar) Set(current int64) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.current = current
+	b.report(false)
+}
+
+func (b *machineProgressBar) Close() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.report(true)
+	b.ui.Machine("progress-done", b.name)
+	return nil
+}
+
+func (b *machineProgressBar) report(force bool) {
+	if !force && time.Since(b.lastReport) < progressMachineInterval {
+		return
+	}
+
+	b.lastReport = time.Now()
+	b.ui.Machine("progress",
+		b.name,
+		strconv.FormatInt(b.current, 10),
+		strconv.FormatInt(b.total, 10))
+}
+
+// basicProgressBar is a live progress bar drawn by a BasicUi on a
+// terminal.
+type basicProgressBar struct {
+	progressState
+
+	ui         *BasicUi
+	lastRender time.Time
+}
+
+func (b *basicProgressBar) Add(n int64) {
+	b.l.Lock()
+	b.current += n
+	render := b.shouldRender()
+	b.l.Unlock()
+
+	if render {
+		b.ui.renderProgress()
+	}
+}
+
+func (b *basicProgressBar) Set(current int64) {
+	b.l.Lock()
+	b.current = current
+	render := b.shouldRender()
+	b.l.Unlock()
+
+	if render {
+		b.ui.renderProgress()
+	}
+}
+
+func (b *basicProgressBar) Close() error {
+	b.ui.closeProgress(b)
+	return nil
+}
+
+func (b *basicProgressBar) shouldRender() bool {
+	if time.Since(b.lastRender) < progressRenderInterval {
+		return false
+	}
+
+	b.lastRender = time.Now()
+	return true
+}
+
+// bar returns the rendered progress bar, fitting in width characters.
+func (b *basicProgressBar) bar(width int) string {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	status := b.String()
+	if b.total <= 0 {
+		return fmt.Sprintf("%s %s", b.name, status)
+	}
+
+	// Leave room for the name, the status and the brackets
+	barWidth := width - len(b.name) - len(status) - 4
+	if barWidth < 10 {
+		return fmt.Sprintf("%s %s", b.name, status)
+	}
+
+	filled := barWidth * b.percent() / 100
+	return fmt.Sprintf("%s [%s%s] %s",
+		b.name,
+		strings.Repeat("=", filled),
+		strings.Repeat(" ", barWidth-filled),
+		status)
+}
+
+// ProgressBar returns a live progress bar if the UI writes to a terminal,
+// and a progress bar that reports progress at regular intervals otherwise.
+func (rw *BasicUi) ProgressBar(name string, total int64) ProgressBar {
+	if !rw.isTerminal() {
+		return newLineProgressBar(rw.Message, name, total)
+	}
+
+	b := &basicProgressBar{
+		progressState: progressState{name: name, total: total},
+		ui:            rw,
+	}
+
+	rw.l.Lock()
+	rw.progressBars = append(rw.progressBars, b)
+	rw.l.Unlock()
+
+	rw.renderProgress()
+	return b
+}
+
+func (rw *BasicUi) isTerminal() bool {
+	f, ok := rw.Writer.(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
+
+// renderProgress draws the live progress bars on the current line,
+// replacing whatever was drawn before.
+func (rw *BasicUi) renderProgress() {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+
+	rw.drawProgress()
+}
+
+// drawProgress draws the live progress bars. The lock must be held.
+func (rw *BasicUi) drawProgress() {
+	if len(rw.progressBars) == 0 {
+		return
+	}
+
+	width := 80
+	if f, ok := rw.Writer.(*os.File); ok {
+		if w, _, err := terminal.GetSize(int(f.Fd())); err == nil && w > 0 {
+			width = w
+		}
+	}
+	// Never write into the last column, which makes some terminals wrap.
+	width--
+
+	// Share the line between all running progress bars
+	barWidth := (width - 3*(len(rw.progressBars)-1)) / len(rw.progressBars)
+	bars := make([]string, len(rw.progressBars))
+	for i, b := range rw.progressBars {
+		bars[i] = b.bar(barWidth)
+	}
+
+	line := strings.Join(bars, " | ")
+	if len(line) > width {
+		line = line[:width]
+	}
+
+	rw.clearProgress()
+	fmt.Fprint(rw.Writer, "\r"+line)
+	rw.progressWidth = len(line)
+}
+
+// clearProgress removes the drawn progress bars from the current line,
+// so that other output can be written. The lock must be held.
+func (rw *BasicUi) clearProgress() {
+	if rw.progressWidth == 0 {
+		return
+	}
+
+	fmt.Fprint(rw.Writer, "\r"+strings.Repeat(" ", rw.progressWidth)+"\r")
+	rw.progressWidth = 0
+}
+
+func (rw *BasicUi) closeProgress(b *basicProgressBar) {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+
+	for i, other := range rw.progressBars {
+		if other == b {
+			rw.progressBars = append(rw.progressBars[:i], rw.progressBars[i+1:]...)
+			break
+		}
+	}
+
+	rw.clearProgress()
+	rw.drawProgress()
+}
+
+// ProgressBar returns a progress bar that reports progress as
+// machine-readable output.
+func (u *MachineReadableUi) ProgressBar(name string, total int64) ProgressBar {
+	return newMachineProgressBar(u, name, total)
+}
+
+// ProgressBar passes the progress bar through to the wrapped UI. The
+// progress bar isn't colored.
+func (u *ColoredUi) ProgressBar(name string, total int64) ProgressBar {
+	return NewProgressBar(u.Ui, name, total)
+}
+
+// ProgressBar returns a progress bar of the wrapped UI, labelled with the
+// target. Machine-readable progress has the proper target set.
+func (u *TargetedUI) ProgressBar(name string, total int64) ProgressBar {
+	switch u.Ui.(type) {
+	case *MachineReadableUi:
+		return newMachineProgressBar(u, name, total)
+	case ProgressUi:
+		return NewProgressBar(u.Ui, u.prefixLines(false, name), total)
+	default:
+		return newLineProgressBar(u.Message, name, total)
+	}
+}
@@ -0,0 +1,120 @@
+package packer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBasicUi_ImplProgressUi(t *testing.T) {
+	var raw interface{}
+	raw = &BasicUi{}
+	if _, ok := raw.(ProgressUi); !ok {
+		t.Fatalf("BasicUi must implement ProgressUi")
+	}
+}
+
+func TestMachineReadableUi_ImplProgressUi(t *testing.T) {
+	var raw interface{}
+	raw = &MachineReadableUi{}
+	if _, ok := raw.(ProgressUi); !ok {
+		t.Fatalf("MachineReadableUi must implement ProgressUi")
+	}
+}
+
+func TestBasicUi_ProgressBarNoTerminal(t *testing.T) {
+	defer func(old time.Duration) { progressLineInterval = old }(progressLineInterval)
+	progressLineInterval = 0
+
+	bufferUi := testUi()
+	bar := bufferUi.ProgressBar("foo", 200)
+	bar.Add(50)
+	bar.Set(100)
+	if err := bar.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := "foo: 25% (50 B of 200 B)\n" +
+		"foo: 50% (100 B of 200 B)\n" +
+		"foo: 50% (100 B of 200 B)\n"
+	if actual := readWriter(bufferUi); actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestBasicUi_ProgressBarQuiet(t *testing.T) {
+	bufferUi := testUi()
+	bar := bufferUi.ProgressBar("foo", 200)
+	bar.Add(200)
+	bar.Close()
+
+	// Short operations shouldn't report anything
+	if actual := readWriter(bufferUi); actual != "" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestMachineReadableUi_ProgressBar(t *testing.T) {
+	buf := new(bytes.Buffer)
+	ui := &TargetedUI{
+		Target: "build",
+		Ui:     &MachineReadableUi{Writer: buf},
+	}
+
+	r := TrackProgress(ui, "foo", 3, strings.NewReader("abc"))
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		",build,progress,foo,0,3",
+		",build,progress,foo,3,3",
+		",build,progress-done,foo",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("bad: %#v", lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, expected[i]) {
+			t.Fatalf("bad line %d: %s", i, line)
+		}
+	}
+}
+
+func TestTargetedUI_ProgressBar(t *testing.T) {
+	defer func(old time.Duration) { progressLineInterval = old }(progressLineInterval)
+	progressLineInterval = 0
+
+	bufferUi := testUi()
+	ui := &TargetedUI{
+		Target: "build",
+		Ui:     bufferUi,
+	}
+
+	w := TrackProgressWriter(ui, "foo", 0, ioutil.Discard)
+	w.Write([]byte("hello"))
+	w.Close()
+
+	expected := "    build: foo: 5 B\n    build: foo: 5 B\n"
+	if actual := readWriter(bufferUi); actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestBasicProgressBar_bar(t *testing.T) {
+	b := &basicProgressBar{
+		progressState: progressState{name: "foo", current: 50, total: 100},
+	}
+
+	actual := b.bar(40)
+	expected := "foo [=======       ] 50% (50 B of 100 B)"
+	if actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+}
@@ -1,8 +1,10 @@
 package packer
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"reflect"
 	"sync"
 	"time"
 )
@@ -40,7 +42,21 @@ type ProvisionHook struct {
 	// be prepared (by calling Prepare) at some earlier stage.
 	Provisioners []*HookedProvisioner
 
+	// ErrorCleanupProvisioner, if set, is run when one of the provisioners
+	// fails, while the communicator is still connected. Its own failure is
+	// only logged; the original provisioner error is always returned.
+	ErrorCleanupProvisioner *HookedProvisioner
+
+	// Transcript, if set, records the provisioners that are run and the
+	// remote commands they run.
+	Transcript *Transcript
+
+	// spans, if set, are the spans of the builder steps that the spans of
+	// the provisioners are nested in.
+	spans *stepSpans
+
 	lock               sync.Mutex
+	cancelled          bool
 	runningProvisioner Provisioner
 }
 
@@ -65,17 +81,34 @@ func (h *ProvisionHook) Run(name string, ui Ui, comm Communicator, data interfac
 		h.runningProvisioner = nil
 	}()
 
-	for _, p := range h.Provisioners {
+	if h.Transcript != nil {
+		comm = &transcriptCommunicator{Communicator: comm, t: h.Transcript}
+	}
+
+	provisioners := h.Provisioners
+	if i, ok := provisionerIndex(data); ok {
+		if i < 0 || i >= len(provisioners) {
+			return fmt.Errorf("No provisioner with index %d", i)
+		}
+		provisioners = provisioners[i : i+1]
+	}
+
+	for _, p := range provisioners {
 		h.lock.Lock()
 		h.runningProvisioner = p.Provisioner
 		h.lock.Unlock()
 
-		ts := CheckpointReporter.AddSpan(p.TypeName, "provisioner", p.Config)
+		if h.Transcript != nil {
+			h.Transcript.Record("step", "provisioner "+p.TypeName)
+		}
+
+		ts := h.spans.AddSpan(p.TypeName, "provisioner", p.Config)
 
 		err := p.Provisioner.Provision(ui, comm)
 
 		ts.End(err)
 		if err != nil {
+			h.runCleanupProvisioner(ui, comm)
 			return err
 		}
 	}
@@ -83,11 +116,58 @@ func (h *ProvisionHook) Run(name string, ui Ui, comm Communicator, data interfac
 	return nil
 }
 
+// provisionerIndex returns the index of the only provisioner to run, if the
+// data of the hook has one. Integers can be of any type after going through
+// RPC.
+func provisionerIndex(data interface{}) (int, bool) {
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), true
+	}
+
+	return 0, false
+}
+
+// runCleanupProvisioner runs the error-cleanup-provisioner, if there is one
+// and the build hasn't been cancelled.
+func (h *ProvisionHook) runCleanupProvisioner(ui Ui, comm Communicator) {
+	p := h.ErrorCleanupProvisioner
+	if p == nil {
+		return
+	}
+
+	h.lock.Lock()
+	if h.cancelled {
+		h.lock.Unlock()
+		return
+	}
+	h.runningProvisioner = p.Provisioner
+	h.lock.Unlock()
+
+	if h.Transcript != nil {
+		h.Transcript.Record("step", "error-cleanup-provisioner "+p.TypeName)
+	}
+
+	ui.Say("Provisioning step had errors: Running the cleanup provisioner, if present...")
+
+	ts := h.spans.AddSpan(p.TypeName, "error-cleanup-provisioner", p.Config)
+	err := p.Provisioner.Provision(ui, comm)
+	ts.End(err)
+	if err != nil {
+		log.Printf("Error running error-cleanup-provisioner: %s", err)
+		ui.Error(fmt.Sprintf("Error running the cleanup provisioner: %s", err))
+	}
+}
+
 // Cancels the provisioners that are still running.
 func (h *ProvisionHook) Cancel() {
 	h.lock.Lock()
 	defer h.lock.Unlock()
 
+	h.cancelled = true
 	if h.runningProvisioner != nil {
 		h.runningProvisioner.Cancel()
 	}
@@ -170,6 +250,46 @@ func (p *PausedProvisioner) provision(result chan<- error, ui Ui, comm Communica
 	result <- p.Provisioner.Provision(ui, comm)
 }
 
+// RetriedProvisioner is a Provisioner implementation that runs the
+// provisioner again when it fails, up to MaxRetries more times. The wait
+// before each retry starts at RetryBackoff, but at least minRetryBackoff,
+// and doubles after every attempt.
+type RetriedProvisioner struct {
+	MaxRetries   int
+	RetryBackoff time.Duration
+	Provisioner  Provisioner
+
+	cancel context.CancelFunc
+	lock   sync.Mutex
+}
+
+func (p *RetriedProvisioner) Prepare(raws ...interface{}) error {
+	return p.Provisioner.Prepare(raws...)
+}
+
+func (p *RetriedProvisioner) Provision(ui Ui, comm Communicator) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p.lock.Lock()
+	p.cancel = cancel
+	p.lock.Unlock()
+
+	return retry(ctx, ui, "Provisioner", p.MaxRetries, p.RetryBackoff, func() error {
+		return p.Provisioner.Provision(ui, comm)
+	})
+}
+
+func (p *RetriedProvisioner) Cancel() {
+	p.lock.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.lock.Unlock()
+
+	p.Provisioner.Cancel()
+}
+
 // DebuggedProvisioner is a Provisioner implementation that waits until a key
 // press before the provisioner is actually run.
 type DebuggedProvisioner struct {
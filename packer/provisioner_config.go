@@ -0,0 +1,91 @@
+package packer
+
+import (
+	"encoding/json"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// ProvisionerConfig is the type and the configuration of a provisioner of
+// a build, as it is sent to the builder in ProvisionersConfigKey.
+type ProvisionerConfig struct {
+	Type   string                 `json:"type"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// provisionerConfigs returns the JSON encoded configurations of the
+// provisioners of the build, in order. The configuration of each
+// provisioner is merged with its overrides and rendered where possible.
+// Configurations that render differently every time, with the timestamp
+// function for example, never encode the same.
+func (b *coreBuild) provisionerConfigs() []byte {
+	ctx := &interpolate.Context{
+		BuildName:     b.name,
+		BuildType:     b.builderType,
+		Matrix:        b.matrix,
+		TemplatePath:  b.templatePath,
+		UserVariables: b.variables,
+	}
+	if len(b.upstream) > 0 {
+		ctx.UpstreamArtifacts = b.upstreamArtifacts()
+	}
+
+	result := make([]ProvisionerConfig, len(b.provisioners))
+	for i, p := range b.provisioners {
+		result[i] = ProvisionerConfig{
+			Type:   p.pType,
+			Config: provisionerConfig(ctx, p),
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// provisionerConfig returns the rendered configuration of the provisioner,
+// merged with its overrides.
+func provisionerConfig(ctx *interpolate.Context, p coreBuildProvisioner) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for _, raw := range p.config {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+
+	config := make(map[string]interface{}, len(merged))
+	for k, v := range merged {
+		config[k] = configRender(ctx, v)
+	}
+
+	return config
+}
+
+// configRender renders the template strings in the raw configuration value.
+func configRender(ctx *interpolate.Context, raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case string:
+		return planRender(ctx, v)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, inner := range v {
+			result[k] = configRender(ctx, inner)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, inner := range v {
+			result[i] = configRender(ctx, inner)
+		}
+		return result
+	default:
+		return raw
+	}
+}
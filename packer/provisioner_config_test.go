@@ -0,0 +1,46 @@
+package packer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBuild_provisionerConfigs(t *testing.T) {
+	build := testBuild()
+	build.variables = map[string]string{"pkg": "vim"}
+	build.provisioners = []coreBuildProvisioner{
+		{"shell", &MockProvisioner{}, []interface{}{
+			map[string]interface{}{"inline": []interface{}{"ls"}},
+			map[string]interface{}{
+				"inline":          []interface{}{"apt-get install {{user `pkg`}}"},
+				"execute_command": "sh {{ .Path }}",
+			},
+		}},
+		{"file", &MockProvisioner{}, []interface{}{
+			map[string]interface{}{"source": "a", "destination": "/tmp"},
+		}},
+	}
+
+	var result []ProvisionerConfig
+	if err := json.Unmarshal(build.provisionerConfigs(), &result); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []ProvisionerConfig{
+		{
+			Type: "shell",
+			Config: map[string]interface{}{
+				"inline":          []interface{}{"apt-get install vim"},
+				"execute_command": "sh {{ .Path }}",
+			},
+		},
+		{
+			Type:   "file",
+			Config: map[string]interface{}{"source": "a", "destination": "/tmp"},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
@@ -1,6 +1,7 @@
 package packer
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -40,6 +41,30 @@ func TestProvisionHook(t *testing.T) {
 	}
 }
 
+func TestProvisionHook_index(t *testing.T) {
+	pA := &MockProvisioner{}
+	pB := &MockProvisioner{}
+
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{pA, nil, ""},
+			{pB, nil, ""},
+		},
+	}
+
+	// Integers can be of any type after going through RPC
+	if err := hook.Run("foo", testUi(), new(MockCommunicator), int64(1)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if pA.ProvCalled || !pB.ProvCalled {
+		t.Fatal("only pB should be called")
+	}
+
+	if err := hook.Run("foo", testUi(), new(MockCommunicator), 2); err == nil {
+		t.Fatal("should error")
+	}
+}
+
 func TestProvisionHook_nilComm(t *testing.T) {
 	pA := &MockProvisioner{}
 	pB := &MockProvisioner{}
@@ -61,6 +86,60 @@ func TestProvisionHook_nilComm(t *testing.T) {
 	}
 }
 
+func TestProvisionHook_errorCleanup(t *testing.T) {
+	pA := &MockProvisioner{
+		ProvFunc: func() error {
+			return errors.New("failed")
+		},
+	}
+	pB := &MockProvisioner{}
+	cleanup := &MockProvisioner{
+		ProvFunc: func() error {
+			return errors.New("cleanup failed")
+		},
+	}
+
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{pA, nil, ""},
+			{pB, nil, ""},
+		},
+		ErrorCleanupProvisioner: &HookedProvisioner{cleanup, nil, ""},
+	}
+
+	err := hook.Run("foo", testUi(), new(MockCommunicator), nil)
+	if err == nil || err.Error() != "failed" {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	if pB.ProvCalled {
+		t.Error("provision should not be called on pB")
+	}
+	if !cleanup.ProvCalled {
+		t.Error("provision should be called on cleanup provisioner")
+	}
+}
+
+func TestProvisionHook_errorCleanupSuccess(t *testing.T) {
+	pA := &MockProvisioner{}
+	cleanup := &MockProvisioner{}
+
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{pA, nil, ""},
+		},
+		ErrorCleanupProvisioner: &HookedProvisioner{cleanup, nil, ""},
+	}
+
+	if err := hook.Run("foo", testUi(), new(MockCommunicator), nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if cleanup.ProvCalled {
+		t.Error("provision should not be called on cleanup provisioner")
+	}
+}
+
 func TestProvisionHook_cancel(t *testing.T) {
 	var lock sync.Mutex
 	order := make([]string, 0, 2)
@@ -198,6 +277,98 @@ func TestPausedProvisionerCancel(t *testing.T) {
 	}
 }
 
+func TestRetriedProvisioner_impl(t *testing.T) {
+	var _ Provisioner = new(RetriedProvisioner)
+}
+
+func TestRetriedProvisionerProvision(t *testing.T) {
+	defer testMinRetryBackoff(time.Millisecond)()
+
+	mock := new(MockProvisioner)
+	prov := &RetriedProvisioner{
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		Provisioner:  mock,
+	}
+
+	attempts := 0
+	mock.ProvFunc = func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	}
+
+	if err := prov.Provision(testUi(), new(MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("bad: %d", attempts)
+	}
+}
+
+func TestRetriedProvisionerProvision_exhausted(t *testing.T) {
+	defer testMinRetryBackoff(time.Millisecond)()
+
+	mock := new(MockProvisioner)
+	prov := &RetriedProvisioner{
+		MaxRetries:  1,
+		Provisioner: mock,
+	}
+
+	attempts := 0
+	mock.ProvFunc = func() error {
+		attempts++
+		return errors.New("failed")
+	}
+
+	if err := prov.Provision(testUi(), new(MockCommunicator)); err == nil {
+		t.Fatal("should error")
+	}
+	if attempts != 2 {
+		t.Fatalf("bad: %d", attempts)
+	}
+}
+
+func TestRetriedProvisionerCancel(t *testing.T) {
+	mock := new(MockProvisioner)
+	prov := &RetriedProvisioner{
+		MaxRetries:   5,
+		RetryBackoff: time.Hour,
+		Provisioner:  mock,
+	}
+
+	provCh := make(chan struct{})
+	mock.ProvFunc = func() error {
+		close(provCh)
+		return errors.New("failed")
+	}
+
+	// Start provisioning and wait for the first attempt to fail
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- prov.Provision(testUi(), new(MockCommunicator))
+	}()
+	<-provCh
+
+	// Cancel it while it waits to retry
+	time.Sleep(10 * time.Millisecond)
+	prov.Cancel()
+	if !mock.CancelCalled {
+		t.Fatal("cancel should be called")
+	}
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("should not wait for the backoff")
+	}
+}
+
 func TestDebuggedProvisioner_impl(t *testing.T) {
 	var _ Provisioner = new(DebuggedProvisioner)
 }
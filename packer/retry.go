@@ -0,0 +1,50 @@
+package packer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The minimum wait before retrying a provisioner or post-processor, so that
+// failures aren't retried in a tight loop.
+var minRetryBackoff = time.Second
+
+// retryBackoff returns the wait before the first retry for the configured
+// retry_backoff.
+func retryBackoff(backoff time.Duration) time.Duration {
+	if backoff < minRetryBackoff {
+		return minRetryBackoff
+	}
+
+	return backoff
+}
+
+// retry calls fn until it succeeds, up to max more times, and returns the
+// error of the last attempt. The failures are reported to the Ui on behalf
+// of the named component. The wait before each retry starts at backoff,
+// but at least minRetryBackoff, and doubles after every attempt. Once ctx
+// is done, nothing is retried anymore.
+func retry(ctx context.Context, ui Ui, name string, max int, backoff time.Duration, fn func() error) error {
+	backoff = retryBackoff(backoff)
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= max {
+			return err
+		}
+
+		ui.Error(fmt.Sprintf(
+			"%s failed (attempt %d of %d): %s",
+			name, attempt+1, max+1, err))
+		ui.Say(fmt.Sprintf("Retrying %s in %s...", strings.ToLower(name), backoff))
+
+		// Use a select to determine if we get cancelled during the wait
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,104 @@
+package packer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMinRetryBackoff lowers the minimum backoff of retries, so that the
+// tests don't wait for it.
+func testMinRetryBackoff(d time.Duration) func() {
+	old := minRetryBackoff
+	minRetryBackoff = d
+	return func() {
+		minRetryBackoff = old
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	if backoff := retryBackoff(0); backoff != minRetryBackoff {
+		t.Fatalf("bad: %s", backoff)
+	}
+	if backoff := retryBackoff(time.Minute); backoff != time.Minute {
+		t.Fatalf("bad: %s", backoff)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	defer testMinRetryBackoff(time.Millisecond)()
+
+	ui := testUi()
+	attempts := 0
+	err := retry(context.Background(), ui, "Provisioner", 2, time.Millisecond, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("bad: %d", attempts)
+	}
+
+	// The failures are reported on behalf of the component
+	errOutput := ui.ErrorWriter.(*bytes.Buffer).String()
+	if !strings.Contains(errOutput, "Provisioner failed (attempt 2 of 3): failed") {
+		t.Fatalf("bad: %s", errOutput)
+	}
+	output := ui.Writer.(*bytes.Buffer).String()
+	if !strings.Contains(output, "Retrying provisioner in 2ms...") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestRetry_exhausted(t *testing.T) {
+	defer testMinRetryBackoff(time.Millisecond)()
+
+	attempts := 0
+	err := retry(context.Background(), testUi(), "Provisioner", 1, 0, func() error {
+		attempts++
+		return errors.New("failed")
+	})
+	if err == nil || err.Error() != "failed" {
+		t.Fatalf("bad: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("bad: %d", attempts)
+	}
+}
+
+func TestRetry_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- retry(ctx, testUi(), "Provisioner", 5, time.Hour, func() error {
+			attempts++
+			return errors.New("failed")
+		})
+	}()
+
+	// Cancel it while it waits to retry
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("should not wait for the backoff")
+	}
+	if attempts != 1 {
+		t.Fatalf("bad: %d", attempts)
+	}
+}
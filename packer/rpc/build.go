@@ -86,6 +86,34 @@ func (b *build) SetOnError(val string) {
 	}
 }
 
+func (b *build) SetOnErrorScript(val string) {
+	if err := b.client.Call("Build.SetOnErrorScript", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) SetTranscript(val bool) {
+	if err := b.client.Call("Build.SetTranscript", val, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (b *build) SetUpstreamArtifacts(val map[string]packer.Artifact) {
+	streamIds := make(map[string]uint32, len(val))
+	for name, artifact := range val {
+		nextId := b.mux.NextId()
+		server := newServerWithMux(b.mux, nextId)
+		server.RegisterArtifact(artifact)
+		go server.Serve()
+
+		streamIds[name] = nextId
+	}
+
+	if err := b.client.Call("Build.SetUpstreamArtifacts", streamIds, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
 func (b *build) Cancel() {
 	if err := b.client.Call("Build.Cancel", new(interface{}), new(interface{})); err != nil {
 		panic(err)
@@ -146,6 +174,31 @@ func (b *BuildServer) SetOnError(val *string, reply *interface{}) error {
 	return nil
 }
 
+func (b *BuildServer) SetOnErrorScript(val *string, reply *interface{}) error {
+	b.build.SetOnErrorScript(*val)
+	return nil
+}
+
+func (b *BuildServer) SetTranscript(val *bool, reply *interface{}) error {
+	b.build.SetTranscript(*val)
+	return nil
+}
+
+func (b *BuildServer) SetUpstreamArtifacts(streamIds map[string]uint32, reply *interface{}) error {
+	artifacts := make(map[string]packer.Artifact, len(streamIds))
+	for name, streamId := range streamIds {
+		client, err := newClientWithMux(b.mux, streamId)
+		if err != nil {
+			return NewBasicError(err)
+		}
+
+		artifacts[name] = client.Artifact()
+	}
+
+	b.build.SetUpstreamArtifacts(artifacts)
+	return nil
+}
+
 func (b *BuildServer) Cancel(args *interface{}, reply *interface{}) error {
 	b.build.Cancel()
 	return nil
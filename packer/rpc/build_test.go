@@ -20,7 +20,10 @@ type testBuild struct {
 	setDebugCalled   bool
 	setForceCalled   bool
 	setOnErrorCalled bool
+	onErrorScript    string
+	transcript       bool
 	cancelCalled     bool
+	upstream         map[string]packer.Artifact
 
 	errRunResult bool
 }
@@ -59,6 +62,18 @@ func (b *testBuild) SetOnError(string) {
 	b.setOnErrorCalled = true
 }
 
+func (b *testBuild) SetOnErrorScript(val string) {
+	b.onErrorScript = val
+}
+
+func (b *testBuild) SetTranscript(val bool) {
+	b.transcript = val
+}
+
+func (b *testBuild) SetUpstreamArtifacts(val map[string]packer.Artifact) {
+	b.upstream = val
+}
+
 func (b *testBuild) Cancel() {
 	b.cancelCalled = true
 }
@@ -128,6 +143,29 @@ func TestBuild(t *testing.T) {
 		t.Fatal("should be called")
 	}
 
+	// Test SetOnErrorScript
+	bClient.SetOnErrorScript("/foo.sh")
+	if b.onErrorScript != "/foo.sh" {
+		t.Fatalf("bad: %s", b.onErrorScript)
+	}
+
+	// Test SetTranscript
+	bClient.SetTranscript(true)
+	if !b.transcript {
+		t.Fatal("should be set")
+	}
+
+	// Test SetUpstreamArtifacts
+	bClient.SetUpstreamArtifacts(map[string]packer.Artifact{
+		"base": testBuildArtifact,
+	})
+	if len(b.upstream) != 1 {
+		t.Fatalf("bad: %#v", b.upstream)
+	}
+	if b.upstream["base"].BuilderId() != "bid" {
+		t.Fatalf("bad: %#v", b.upstream)
+	}
+
 	// Test Cancel
 	bClient.Cancel()
 	if !b.cancelCalled {
@@ -75,6 +75,18 @@ func (b *builder) Cancel() {
 	}
 }
 
+// PlanOutputs returns the output keys of the builder on the other end, or
+// nothing if it can't tell them, or is too old to be asked.
+func (b *builder) PlanOutputs() []packer.PlanOutputKey {
+	var keys []packer.PlanOutputKey
+	if err := b.client.Call("Builder.PlanOutputs", new(interface{}), &keys); err != nil {
+		log.Printf("Error getting the plan outputs of the builder: %s", err)
+		return nil
+	}
+
+	return keys
+}
+
 func (b *BuilderServer) Prepare(args *BuilderPrepareArgs, reply *BuilderPrepareResponse) error {
 	warnings, err := b.builder.Prepare(args.Configs...)
 	*reply = BuilderPrepareResponse{
@@ -112,3 +124,10 @@ func (b *BuilderServer) Cancel(args *interface{}, reply *interface{}) error {
 	b.builder.Cancel()
 	return nil
 }
+
+func (b *BuilderServer) PlanOutputs(args *interface{}, reply *[]packer.PlanOutputKey) error {
+	if p, ok := b.builder.(packer.PlanOutputter); ok {
+		*reply = p.PlanOutputs()
+	}
+	return nil
+}
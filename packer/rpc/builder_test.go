@@ -4,7 +4,9 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
 )
 
 var testBuilderArtifact = &packer.MockArtifact{}
@@ -37,6 +39,50 @@ func TestBuilderPrepare(t *testing.T) {
 	}
 }
 
+// upstreamBuilder is a builder whose configuration references the
+// artifact of another build.
+type upstreamBuilder struct {
+	packer.MockBuilder
+
+	ImageId string `mapstructure:"image_id"`
+
+	ctx interpolate.Context
+}
+
+func (b *upstreamBuilder) Prepare(raws ...interface{}) ([]string, error) {
+	return nil, config.Decode(b, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &b.ctx,
+	}, raws...)
+}
+
+func TestBuilderPrepare_UpstreamArtifacts(t *testing.T) {
+	b := new(upstreamBuilder)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	// The packer configuration of a build that depends on another one
+	packerConfig := map[string]interface{}{
+		packer.BuildNameConfigKey: "app",
+		packer.UpstreamArtifactsConfigKey: map[string]map[string]string{
+			"base": {"id": "ami-1234"},
+		},
+	}
+	raw := map[string]interface{}{
+		"image_id": "{{ upstream `base` `id` }}",
+	}
+
+	if _, err := bClient.Prepare(raw, packerConfig); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b.ImageId != "ami-1234" {
+		t.Fatalf("bad: %s", b.ImageId)
+	}
+}
+
 func TestBuilderPrepare_Warnings(t *testing.T) {
 	b := new(packer.MockBuilder)
 	client, server := testClientServer(t)
@@ -142,6 +188,25 @@ func TestBuilderCancel(t *testing.T) {
 	}
 }
 
+func TestBuilderPlanOutputs(t *testing.T) {
+	b := new(packer.MockBuilder)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterBuilder(b)
+	bClient := client.Builder()
+
+	if keys := bClient.(packer.PlanOutputter).PlanOutputs(); len(keys) != 0 {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	b.OutputKeys = []packer.PlanOutputKey{{Key: "output_directory", Default: "output-{{build_name}}", Local: true}}
+	keys := bClient.(packer.PlanOutputter).PlanOutputs()
+	if !reflect.DeepEqual(keys, b.OutputKeys) {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
+
 func TestBuilder_ImplementsBuilder(t *testing.T) {
 	var _ packer.Builder = new(builder)
 }
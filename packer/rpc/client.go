@@ -67,6 +67,13 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// SetCapabilities sets the capabilities that both ends of the connection
+// support. Optional features of the protocol are only used if the other
+// end supports them.
+func (c *Client) SetCapabilities(capabilities []string) {
+	c.mux.SetCapabilities(capabilities)
+}
+
 func (c *Client) Artifact() packer.Artifact {
 	return &artifact{
 		client:   c.client,
@@ -125,6 +132,7 @@ func (c *Client) Provisioner() packer.Provisioner {
 func (c *Client) Ui() packer.Ui {
 	return &Ui{
 		client:   c.client,
+		mux:      c.mux,
 		endpoint: DefaultUiEndpoint,
 	}
 }
@@ -3,11 +3,16 @@ package rpc
 import (
 	"encoding/gob"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/rpc"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 
+	"github.com/hashicorp/packer/common"
 	"github.com/hashicorp/packer/packer"
 )
 
@@ -23,6 +28,67 @@ type communicator struct {
 type CommunicatorServer struct {
 	c   packer.Communicator
 	mux *muxBroker
+
+	// cancelCh is closed to abort the file transfers
+	cancelCh   chan struct{}
+	cancelOnce sync.Once
+}
+
+func newCommunicatorServer(c packer.Communicator, mux *muxBroker) *CommunicatorServer {
+	return &CommunicatorServer{
+		c:        c,
+		mux:      mux,
+		cancelCh: make(chan struct{}),
+	}
+}
+
+// cancel aborts the file transfers in progress, and those started
+// afterwards.
+func (c *CommunicatorServer) cancel() {
+	c.cancelOnce.Do(func() {
+		close(c.cancelCh)
+	})
+}
+
+// abortOnCancel calls abort if the transfers are cancelled before the
+// returned function is called.
+func (c *CommunicatorServer) abortOnCancel(abort func()) func() {
+	doneCh := make(chan struct{})
+	go func() {
+		select {
+		case <-c.cancelCh:
+			abort()
+		case <-doneCh:
+		}
+	}()
+
+	return func() {
+		close(doneCh)
+	}
+}
+
+// servedCommunicator is the communicator that is served to the other end
+// of the connection while a component runs there. Its transfers are
+// cancelled along with the component.
+type servedCommunicator struct {
+	l    sync.Mutex
+	comm *CommunicatorServer
+}
+
+func (s *servedCommunicator) set(comm *CommunicatorServer) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.comm = comm
+}
+
+func (s *servedCommunicator) cancel() {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.comm != nil {
+		s.comm.cancel()
+	}
 }
 
 type CommandFinished struct {
@@ -37,27 +103,45 @@ type CommunicatorStartArgs struct {
 	ResponseStreamId uint32
 }
 
+// The arguments sent to Communicator.StartShell
+type CommunicatorStartShellArgs struct {
+	CommunicatorStartArgs
+	Term   string
+	Width  int
+	Height int
+}
+
+// Chunked is set if the data is sent as a chunked transfer, which is only
+// done if both ends of the connection support it.
 type CommunicatorDownloadArgs struct {
 	Path           string
 	WriterStreamId uint32
+	Chunked        bool
 }
 
+// For chunked transfers, the metadata of the uploaded file is sent along
+// with its data on the reader stream instead of in FileInfo.
 type CommunicatorUploadArgs struct {
 	Path           string
 	ReaderStreamId uint32
 	FileInfo       *fileInfo
+	Chunked        bool
 }
 
 type CommunicatorUploadDirArgs struct {
-	Dst     string
-	Src     string
-	Exclude []string
+	Dst            string
+	Src            string
+	Exclude        []string
+	ReaderStreamId uint32
+	Chunked        bool
 }
 
 type CommunicatorDownloadDirArgs struct {
-	Dst     string
-	Src     string
-	Exclude []string
+	Dst            string
+	Src            string
+	Exclude        []string
+	WriterStreamId uint32
+	Chunked        bool
 }
 
 func Communicator(client *rpc.Client) *communicator {
@@ -67,7 +151,28 @@ func Communicator(client *rpc.Client) *communicator {
 func (c *communicator) Start(cmd *packer.RemoteCmd) (err error) {
 	var args CommunicatorStartArgs
 	args.Command = cmd.Command
+	c.serveCmd(cmd, &args)
 
+	err = c.client.Call("Communicator.Start", &args, new(interface{}))
+	return
+}
+
+// StartShell starts an interactive shell on the other end of the
+// connection, if the communicator there supports it.
+func (c *communicator) StartShell(cmd *packer.RemoteCmd, term string, width, height int) error {
+	var args CommunicatorStartShellArgs
+	args.Term = term
+	args.Width = width
+	args.Height = height
+	c.serveCmd(cmd, &args.CommunicatorStartArgs)
+
+	return c.client.Call("Communicator.StartShell", &args, new(interface{}))
+}
+
+// serveCmd serves the input and output of the command on streams of the
+// connection, and waits for the exit status on a response stream in the
+// background. The ids of the streams are set in the arguments.
+func (c *communicator) serveCmd(cmd *packer.RemoteCmd, args *CommunicatorStartArgs) {
 	var wg sync.WaitGroup
 
 	if cmd.Stdin != nil {
@@ -121,65 +226,227 @@ func (c *communicator) Start(cmd *packer.RemoteCmd) (err error) {
 		log.Printf("[INFO] RPC client: Communicator ended with: %d", finished.ExitStatus)
 		cmd.SetExited(finished.ExitStatus)
 	}()
+}
 
-	err = c.client.Call("Communicator.Start", &args, new(interface{}))
-	return
+func (c *communicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	return c.UploadWithProgress(path, r, fi, nil)
 }
 
-func (c *communicator) Upload(path string, r io.Reader, fi *os.FileInfo) (err error) {
-	// Pipe the reader through to the connection
+// UploadWithProgress uploads the file like Upload, and moves the progress
+// bar, if not nil, as the other end of the connection consumes the data.
+func (c *communicator) UploadWithProgress(path string, r io.Reader, fi *os.FileInfo, bar packer.ProgressBar) error {
+	var info *fileInfo
+	if fi != nil {
+		info = NewFileInfo(*fi)
+	}
+
+	if !c.mux.HasCapability(CapabilityChunkedTransfer) {
+		return c.uploadSingleCopy(path, r, info, bar)
+	}
+
+	// Stream the data to the connection in chunks
 	streamId := c.mux.NextId()
-	go serveSingleCopy("uploadData", c.mux, streamId, nil, r)
+	sendDone := make(chan error, 1)
+	go func() {
+		sendDone <- sendFile(c.mux, streamId, info, r, bar)
+	}()
 
 	args := CommunicatorUploadArgs{
 		Path:           path,
 		ReaderStreamId: streamId,
+		Chunked:        true,
 	}
 
-	if fi != nil {
-		args.FileInfo = NewFileInfo(*fi)
+	err := c.client.Call("Communicator.Upload", &args, new(interface{}))
+
+	// Wait until we're done reading before we return. The error of the
+	// RPC server is the one that counts, since it received the data.
+	sendErr := <-sendDone
+	if err == nil && sendErr != nil {
+		log.Printf("[WARN] Error sending upload data for %s: %s", path, sendErr)
 	}
 
-	err = c.client.Call("Communicator.Upload", &args, new(interface{}))
-	return
+	return err
+}
+
+// uploadSingleCopy uploads the file by piping the reader through to the
+// connection, for the other end doesn't support chunked transfers. The
+// progress bar moves as the data is read.
+func (c *communicator) uploadSingleCopy(path string, r io.Reader, info *fileInfo, bar packer.ProgressBar) error {
+	if bar != nil {
+		r = &progressBarReader{Reader: r, bar: bar}
+	}
+
+	streamId := c.mux.NextId()
+	go serveSingleCopy("uploadData", c.mux, streamId, nil, r)
+
+	args := CommunicatorUploadArgs{
+		Path:           path,
+		ReaderStreamId: streamId,
+		FileInfo:       info,
+	}
+
+	return c.client.Call("Communicator.Upload", &args, new(interface{}))
 }
 
 func (c *communicator) UploadDir(dst string, src string, exclude []string) error {
-	args := &CommunicatorUploadDirArgs{
+	return c.UploadDirWithProgress(dst, src, exclude, nil)
+}
+
+// UploadDirWithProgress uploads the directory like UploadDir. If the other
+// end of the connection supports chunked transfers, the directory is
+// streamed to it as a tar, and the progress bar, if not nil, moves as the
+// other end consumes the tar.
+func (c *communicator) UploadDirWithProgress(dst string, src string, exclude []string, bar packer.ProgressBar) error {
+	args := CommunicatorUploadDirArgs{
 		Dst:     dst,
 		Src:     src,
 		Exclude: exclude,
 	}
 
+	if !c.mux.HasCapability(CapabilityChunkedTransfer) {
+		var reply error
+		err := c.client.Call("Communicator.UploadDir", &args, &reply)
+		if err == nil {
+			err = reply
+		}
+
+		return err
+	}
+
+	// The whole directory is sent, the excludes are applied by the
+	// communicator on the other end, which uploads it from there
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(common.WriteDirTar(w, src, dirTransferBase(src), true))
+	}()
+
+	streamId := c.mux.NextId()
+	sendDone := make(chan error, 1)
+	go func() {
+		sendDone <- sendFile(c.mux, streamId, nil, r, bar)
+	}()
+
+	args.ReaderStreamId = streamId
+	args.Chunked = true
 	var reply error
-	err := c.client.Call("Communicator.UploadDir", args, &reply)
-	if err == nil {
-		err = reply
+	err := c.client.Call("Communicator.UploadDir", &args, &reply)
+
+	// Wait until we're done sending before we return, and stop writing
+	// the tar in case the transfer was aborted
+	sendErr := <-sendDone
+	r.Close()
+	if err == nil && sendErr != nil {
+		log.Printf("[WARN] Error sending upload data for %s: %s", src, sendErr)
 	}
 
 	return err
 }
 
 func (c *communicator) DownloadDir(src string, dst string, exclude []string) error {
-	args := &CommunicatorDownloadDirArgs{
+	return c.DownloadDirWithProgress(src, dst, exclude, nil)
+}
+
+// DownloadDirWithProgress downloads the directory like DownloadDir. If the
+// other end of the connection supports chunked transfers, the directory is
+// streamed from it as a tar, and the progress bar, if not nil, moves as
+// the tar is received.
+func (c *communicator) DownloadDirWithProgress(src string, dst string, exclude []string, bar packer.ProgressBar) error {
+	args := CommunicatorDownloadDirArgs{
 		Dst:     dst,
 		Src:     src,
 		Exclude: exclude,
 	}
 
+	if !c.mux.HasCapability(CapabilityChunkedTransfer) {
+		var reply error
+		err := c.client.Call("Communicator.DownloadDir", &args, &reply)
+		if err == nil {
+			err = reply
+		}
+
+		return err
+	}
+
+	r, w := io.Pipe()
+	streamId := c.mux.NextId()
+	receiveDone := make(chan error, 1)
+	go func() {
+		err := receiveFile(c.mux, streamId, w, bar)
+		w.CloseWithError(err)
+		receiveDone <- err
+	}()
+
+	extractDone := make(chan error, 1)
+	go func() {
+		err := extractDirTransfer(r, dirTransferBase(src)+"/", dst)
+		r.CloseWithError(err)
+		extractDone <- err
+	}()
+
+	args.WriterStreamId = streamId
+	args.Chunked = true
 	var reply error
-	err := c.client.Call("Communicator.DownloadDir", args, &reply)
+	err := c.client.Call("Communicator.DownloadDir", &args, &reply)
+
+	// Wait for the directory to be extracted before we return
+	receiveErr := <-receiveDone
+	extractErr := <-extractDone
+	if err == nil {
+		err = extractErr
+	}
 	if err == nil {
-		err = reply
+		err = receiveErr
 	}
 
 	return err
 }
 
-func (c *communicator) Download(path string, w io.Writer) (err error) {
-	// Serve a single connection and a single copy
+func (c *communicator) Download(path string, w io.Writer) error {
+	return c.DownloadWithProgress(path, w, nil)
+}
+
+// DownloadWithProgress downloads the file like Download, and moves the
+// progress bar, if not nil, as the data is written to the writer.
+func (c *communicator) DownloadWithProgress(path string, w io.Writer, bar packer.ProgressBar) error {
+	if !c.mux.HasCapability(CapabilityChunkedTransfer) {
+		return c.downloadSingleCopy(path, w, bar)
+	}
+
+	// Receive the data from the connection in chunks
 	streamId := c.mux.NextId()
+	receiveDone := make(chan error, 1)
+	go func() {
+		receiveDone <- receiveFile(c.mux, streamId, w, bar)
+	}()
+
+	args := CommunicatorDownloadArgs{
+		Path:           path,
+		WriterStreamId: streamId,
+		Chunked:        true,
+	}
+
+	// Start sending data to the RPC server
+	err := c.client.Call("Communicator.Download", &args, new(interface{}))
+
+	// Wait for all the data to be written before we return
+	receiveErr := <-receiveDone
+	if err == nil {
+		err = receiveErr
+	}
+
+	return err
+}
+
+// downloadSingleCopy downloads the file by copying the connection to the
+// writer, for the other end doesn't support chunked transfers.
+func (c *communicator) downloadSingleCopy(path string, w io.Writer, bar packer.ProgressBar) error {
+	if bar != nil {
+		w = &progressBarWriter{Writer: w, bar: bar}
+	}
 
+	// Serve a single connection and a single copy
+	streamId := c.mux.NextId()
 	waitServer := make(chan struct{})
 	go func() {
 		serveSingleCopy("downloadWriter", c.mux, streamId, w, nil)
@@ -192,15 +459,33 @@ func (c *communicator) Download(path string, w io.Writer) (err error) {
 	}
 
 	// Start sending data to the RPC server
-	err = c.client.Call("Communicator.Download", &args, new(interface{}))
+	err := c.client.Call("Communicator.Download", &args, new(interface{}))
 
 	// Wait for the RPC server to finish receiving the data before we return
 	<-waitServer
 
-	return
+	return err
 }
 
 func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface{}) error {
+	return c.start(args, c.c.Start)
+}
+
+func (c *CommunicatorServer) StartShell(args *CommunicatorStartShellArgs, reply *interface{}) error {
+	sc, ok := c.c.(packer.ShellCommunicator)
+	if !ok {
+		return NewBasicError(packer.ErrShellNotSupported)
+	}
+
+	return c.start(&args.CommunicatorStartArgs, func(cmd *packer.RemoteCmd) error {
+		return sc.StartShell(cmd, args.Term, args.Width, args.Height)
+	})
+}
+
+// start connects the input and output of a command to the streams of the
+// connection, starts it with the given function, and reports its exit
+// status on the response stream once it exits.
+func (c *CommunicatorServer) start(args *CommunicatorStartArgs, start func(*packer.RemoteCmd) error) error {
 	// Build the RemoteCmd on this side so that it all pipes over
 	// to the remote side.
 	var cmd packer.RemoteCmd
@@ -260,7 +545,7 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 	responseWriter := gob.NewEncoder(responseC)
 
 	// Start the actual command
-	err = c.c.Start(&cmd)
+	err = start(&cmd)
 	if err != nil {
 		close(doneCh)
 		return NewBasicError(err)
@@ -279,39 +564,162 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 	return nil
 }
 
-func (c *CommunicatorServer) Upload(args *CommunicatorUploadArgs, reply *interface{}) (err error) {
+func (c *CommunicatorServer) Upload(args *CommunicatorUploadArgs, reply *interface{}) error {
 	readerC, err := c.mux.Dial(args.ReaderStreamId)
 	if err != nil {
-		return
+		return err
 	}
 	defer readerC.Close()
 
+	if !args.Chunked {
+		var fi *os.FileInfo
+		if args.FileInfo != nil {
+			fi = new(os.FileInfo)
+			*fi = *args.FileInfo
+		}
+
+		defer c.abortOnCancel(func() { readerC.Close() })()
+		return c.c.Upload(args.Path, &cancelReader{Reader: readerC, cancelCh: c.cancelCh}, fi)
+	}
+
+	r, err := newChunkReader(readerC)
+	if err != nil {
+		return err
+	}
+	defer c.abortOnCancel(func() { r.abort(errTransferCancelled) })()
+
 	var fi *os.FileInfo
-	if args.FileInfo != nil {
+	if info := r.FileInfo(); info != nil {
 		fi = new(os.FileInfo)
-		*fi = *args.FileInfo
+		*fi = *info
 	}
-	err = c.c.Upload(args.Path, readerC, fi)
-	return
+
+	if err := c.c.Upload(args.Path, r, fi); err != nil {
+		// Stop the sender, it may still be waiting to send more
+		r.CloseWithError(err)
+		return err
+	}
+
+	return nil
 }
 
 func (c *CommunicatorServer) UploadDir(args *CommunicatorUploadDirArgs, reply *error) error {
-	return c.c.UploadDir(args.Dst, args.Src, args.Exclude)
+	if !args.Chunked {
+		return c.c.UploadDir(args.Dst, args.Src, args.Exclude)
+	}
+
+	readerC, err := c.mux.Dial(args.ReaderStreamId)
+	if err != nil {
+		return err
+	}
+	defer readerC.Close()
+
+	r, err := newChunkReader(readerC)
+	if err != nil {
+		return err
+	}
+	defer c.abortOnCancel(func() { r.abort(errTransferCancelled) })()
+
+	// The directory is extracted to a temporary directory with the same
+	// base name, and uploaded from there, so that the communicator
+	// uploads it the same way as the original
+	td, err := ioutil.TempDir("", "packer-upload-dir")
+	if err != nil {
+		r.CloseWithError(err)
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	base := dirTransferBase(args.Src)
+	if err := extractDirTransfer(r, base, td); err != nil {
+		r.CloseWithError(err)
+		return err
+	}
+
+	src := filepath.Join(td, base)
+	if strings.HasSuffix(args.Src, "/") {
+		src += "/"
+	}
+
+	return c.c.UploadDir(args.Dst, src, args.Exclude)
 }
 
-func (c *CommunicatorServer) DownloadDir(args *CommunicatorUploadDirArgs, reply *error) error {
-	return c.c.DownloadDir(args.Src, args.Dst, args.Exclude)
+func (c *CommunicatorServer) DownloadDir(args *CommunicatorDownloadDirArgs, reply *error) error {
+	if !args.Chunked {
+		return c.c.DownloadDir(args.Src, args.Dst, args.Exclude)
+	}
+
+	writerC, err := c.mux.Dial(args.WriterStreamId)
+	if err != nil {
+		return err
+	}
+	defer writerC.Close()
+
+	w, err := newChunkWriter(writerC, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer c.abortOnCancel(func() { w.abort(errTransferCancelled) })()
+
+	// The directory is downloaded to an empty temporary directory, and
+	// everything the communicator put there is sent, to be extracted to
+	// the destination as is
+	td, err := ioutil.TempDir("", "packer-download-dir")
+	if err != nil {
+		w.CloseWithError(err)
+		return err
+	}
+	defer os.RemoveAll(td)
+
+	if err := c.c.DownloadDir(args.Src, td, args.Exclude); err != nil {
+		w.CloseWithError(err)
+		return err
+	}
+
+	if err := common.WriteDirTar(w, td, dirTransferBase(args.Src), false); err != nil {
+		w.CloseWithError(err)
+		return err
+	}
+
+	// Wait for the receiver to have extracted everything
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return nil
 }
 
-func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *interface{}) (err error) {
+func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *interface{}) error {
 	writerC, err := c.mux.Dial(args.WriterStreamId)
 	if err != nil {
-		return
+		return err
 	}
 	defer writerC.Close()
 
-	err = c.c.Download(args.Path, writerC)
-	return
+	if !args.Chunked {
+		defer c.abortOnCancel(func() { writerC.Close() })()
+		return c.c.Download(args.Path, &cancelWriter{Writer: writerC, cancelCh: c.cancelCh})
+	}
+
+	// The communicator may pass the metadata of the file to the writer
+	// while it downloads it, so it is sent along with the end of the file
+	w, err := newChunkWriter(writerC, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer c.abortOnCancel(func() { w.abort(errTransferCancelled) })()
+
+	if err := c.c.Download(args.Path, w); err != nil {
+		w.CloseWithError(err)
+		return err
+	}
+
+	// Wait for the receiver to have written all the data
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func serveSingleCopy(name string, mux *muxBroker, id uint32, dst io.Writer, src io.Reader) {
@@ -338,3 +746,143 @@ func serveSingleCopy(name string, mux *muxBroker, id uint32, dst io.Writer, src
 		log.Printf("[ERR] '%s' copy error: %s", name, err)
 	}
 }
+
+// sendFile accepts a connection on the stream and sends the data of the
+// reader over it as a file transfer. A read error aborts the transfer.
+func sendFile(mux *muxBroker, id uint32, fi *fileInfo, r io.Reader, bar packer.ProgressBar) error {
+	conn, err := mux.Accept(id)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var progress func(int64)
+	if bar != nil {
+		progress = bar.Set
+	}
+
+	w, err := newChunkWriter(conn, fi, progress)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.CloseWithError(err)
+		return err
+	}
+
+	return w.Close()
+}
+
+// receiveFile accepts a connection on the stream and writes the data of
+// the file transfer on it to the writer. A write error aborts the
+// transfer.
+func receiveFile(mux *muxBroker, id uint32, w io.Writer, bar packer.ProgressBar) error {
+	conn, err := mux.Accept(id)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	r, err := newChunkReader(conn)
+	if err != nil {
+		return err
+	}
+
+	if bar != nil {
+		w = &progressBarWriter{Writer: w, bar: bar}
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		r.CloseWithError(err)
+		return err
+	}
+
+	// The metadata of a download comes along with the end of the file
+	if fi := r.FileInfo(); fi != nil {
+		packer.SetDownloadFileInfo(w, *fi)
+	}
+
+	return nil
+}
+
+// dirTransferBase returns the base name of the directory of a directory
+// transfer, at which the entries of the tar it is streamed as are rooted.
+func dirTransferBase(dir string) string {
+	return path.Base(strings.TrimRight(filepath.ToSlash(dir), "/"))
+}
+
+// extractDirTransfer extracts the tar of a directory transfer into dst,
+// like common.ExtractDirTar does. The rest of the stream is read too, so
+// that the sender gets to the end of the transfer.
+func extractDirTransfer(r io.Reader, src string, dst string) error {
+	if err := common.ExtractDirTar(r, src, dst, nil); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(ioutil.Discard, r)
+	return err
+}
+
+// cancelReader fails the reads of a transfer that is not chunked once the
+// transfers are cancelled, since its stream just ends when it is closed.
+type cancelReader struct {
+	io.Reader
+	cancelCh <-chan struct{}
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	select {
+	case <-r.cancelCh:
+		return n, errTransferCancelled
+	default:
+	}
+	return n, err
+}
+
+// cancelWriter fails the writes of a transfer that is not chunked once the
+// transfers are cancelled.
+type cancelWriter struct {
+	io.Writer
+	cancelCh <-chan struct{}
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.cancelCh:
+		return 0, errTransferCancelled
+	default:
+	}
+	return w.Writer.Write(p)
+}
+
+// progressBarReader moves a progress bar by the bytes read through it.
+type progressBarReader struct {
+	io.Reader
+	bar packer.ProgressBar
+}
+
+func (r *progressBarReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.bar.Add(int64(n))
+	return n, err
+}
+
+// progressBarWriter moves a progress bar by the bytes written through it.
+type progressBarWriter struct {
+	io.Writer
+	bar packer.ProgressBar
+}
+
+func (w *progressBarWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.bar.Add(int64(n))
+	return n, err
+}
+
+// SetFileInfo passes the metadata of a downloaded file through to the
+// wrapped writer.
+func (w *progressBarWriter) SetFileInfo(fi os.FileInfo) {
+	packer.SetDownloadFileInfo(w.Writer, fi)
+}
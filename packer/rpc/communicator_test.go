@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/packer/packer"
@@ -164,3 +165,80 @@ func TestCommunicator_ImplementsCommunicator(t *testing.T) {
 		t.Fatal("should be a Communicator")
 	}
 }
+
+// testShellCommunicator is a communicator that supports interactive
+// shells, echoing their input.
+type testShellCommunicator struct {
+	packer.MockCommunicator
+
+	term   string
+	width  int
+	height int
+}
+
+func (c *testShellCommunicator) StartShell(cmd *packer.RemoteCmd, term string, width, height int) error {
+	c.term = term
+	c.width = width
+	c.height = height
+
+	go func() {
+		io.Copy(cmd.Stdout, cmd.Stdin)
+		cmd.SetExited(7)
+	}()
+	return nil
+}
+
+func TestCommunicatorRPC_startShell(t *testing.T) {
+	c := new(testShellCommunicator)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+
+	remote, ok := client.Communicator().(packer.ShellCommunicator)
+	if !ok {
+		t.Fatal("should be a ShellCommunicator")
+	}
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	cmd := &packer.RemoteCmd{
+		Stdin:  stdinR,
+		Stdout: stdoutW,
+	}
+	if err := remote.StartShell(cmd, "xterm", 100, 50); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.term != "xterm" || c.width != 100 || c.height != 50 {
+		t.Fatalf("bad: %#v", c)
+	}
+
+	go stdinW.Write([]byte("ls\n"))
+	data, err := bufio.NewReader(stdoutR).ReadString('\n')
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if data != "ls\n" {
+		t.Fatalf("bad: %q", data)
+	}
+
+	stdinW.Close()
+	cmd.Wait()
+	if cmd.ExitStatus != 7 {
+		t.Fatalf("bad: %d", cmd.ExitStatus)
+	}
+}
+
+func TestCommunicatorRPC_startShellUnsupported(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(new(packer.MockCommunicator))
+
+	remote := client.Communicator().(packer.ShellCommunicator)
+	err := remote.StartShell(new(packer.RemoteCmd), "xterm", 80, 24)
+	if err == nil || !strings.Contains(err.Error(), "interactive shells") {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package rpc
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// The kinds of components that can be served by a ComponentSet.
+const (
+	ComponentBuilder       = "builder"
+	ComponentPostProcessor = "post-processor"
+	ComponentProvisioner   = "provisioner"
+)
+
+// A ComponentSet is a set of named components that are served together,
+// so that a single plugin binary can provide several builders,
+// provisioners and post-processors.
+type ComponentSet struct {
+	Builders       map[string]packer.Builder
+	PostProcessors map[string]packer.PostProcessor
+	Provisioners   map[string]packer.Provisioner
+}
+
+// Component describes a single component of a ComponentSet.
+type Component struct {
+	Kind string
+	Name string
+}
+
+// ComponentSetServer wraps a ComponentSet and makes it exportable as part
+// of a Golang RPC server. Each component is served on its own stream of
+// the mux broker when it is started.
+type ComponentSetServer struct {
+	set *ComponentSet
+	mux *muxBroker
+}
+
+// The arguments sent to ComponentSet.Start
+type ComponentSetStartArgs struct {
+	Kind string
+	Name string
+}
+
+// Components returns the components of the set served on the other end of
+// the connection, sorted by kind and name.
+func (c *Client) Components() ([]Component, error) {
+	var result []Component
+	err := c.client.Call("ComponentSet.Components", new(interface{}), &result)
+	return result, err
+}
+
+// Component starts the component of the given kind and name of the set
+// served on the other end of the connection, and returns a client for it.
+// The component is available from the client as if it were served on its
+// own, for example with Builder.
+func (c *Client) Component(kind, name string) (*Client, error) {
+	args := &ComponentSetStartArgs{
+		Kind: kind,
+		Name: name,
+	}
+
+	var streamId uint32
+	if err := c.client.Call("ComponentSet.Start", args, &streamId); err != nil {
+		return nil, err
+	}
+
+	return newClientWithMux(c.mux, streamId)
+}
+
+func (s *ComponentSetServer) Components(args interface{}, reply *[]Component) error {
+	var result []Component
+	for name := range s.set.Builders {
+		result = append(result, Component{Kind: ComponentBuilder, Name: name})
+	}
+	for name := range s.set.PostProcessors {
+		result = append(result, Component{Kind: ComponentPostProcessor, Name: name})
+	}
+	for name := range s.set.Provisioners {
+		result = append(result, Component{Kind: ComponentProvisioner, Name: name})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	*reply = result
+	return nil
+}
+
+func (s *ComponentSetServer) Start(args *ComponentSetStartArgs, reply *uint32) error {
+	server := newServerWithMux(s.mux, s.mux.NextId())
+
+	found := false
+	switch args.Kind {
+	case ComponentBuilder:
+		if b, ok := s.set.Builders[args.Name]; ok {
+			server.RegisterBuilder(b)
+			found = true
+		}
+	case ComponentPostProcessor:
+		if p, ok := s.set.PostProcessors[args.Name]; ok {
+			server.RegisterPostProcessor(p)
+			found = true
+		}
+	case ComponentProvisioner:
+		if p, ok := s.set.Provisioners[args.Name]; ok {
+			server.RegisterProvisioner(p)
+			found = true
+		}
+	default:
+		return NewBasicError(fmt.Errorf("Unknown component kind: %s", args.Kind))
+	}
+
+	if !found {
+		return NewBasicError(fmt.Errorf(
+			"The plugin has no %s named '%s'", args.Kind, args.Name))
+	}
+
+	go server.Serve()
+
+	*reply = server.streamId
+	return nil
+}
@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testComponentSet() *ComponentSet {
+	return &ComponentSet{
+		Builders: map[string]packer.Builder{
+			"foo": new(packer.MockBuilder),
+			"bar": new(packer.MockBuilder),
+		},
+		Provisioners: map[string]packer.Provisioner{
+			"foo": new(packer.MockProvisioner),
+		},
+	}
+}
+
+func TestComponentSet_components(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterComponentSet(testComponentSet())
+
+	components, err := client.Components()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []Component{
+		{Kind: ComponentBuilder, Name: "bar"},
+		{Kind: ComponentBuilder, Name: "foo"},
+		{Kind: ComponentProvisioner, Name: "foo"},
+	}
+	if !reflect.DeepEqual(components, expected) {
+		t.Fatalf("bad: %#v", components)
+	}
+}
+
+func TestComponentSet_start(t *testing.T) {
+	set := testComponentSet()
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterComponentSet(set)
+
+	bClient, err := client.Component(ComponentBuilder, "bar")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := bClient.Builder().Prepare(42); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !set.Builders["bar"].(*packer.MockBuilder).PrepareCalled {
+		t.Fatal("bar should be prepared")
+	}
+	if set.Builders["foo"].(*packer.MockBuilder).PrepareCalled {
+		t.Fatal("foo should not be prepared")
+	}
+
+	pClient, err := client.Component(ComponentProvisioner, "foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := pClient.Provisioner().Prepare(42); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !set.Provisioners["foo"].(*packer.MockProvisioner).PrepCalled {
+		t.Fatal("provisioner should be prepared")
+	}
+}
+
+func TestComponentSet_startUnknown(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterComponentSet(testComponentSet())
+
+	if _, err := client.Component(ComponentPostProcessor, "foo"); err == nil {
+		t.Fatal("should error")
+	}
+
+	if _, err := client.Component("nope", "foo"); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -12,6 +12,7 @@ import (
 type hook struct {
 	client *rpc.Client
 	mux    *muxBroker
+	comm   servedCommunicator
 }
 
 // HookServer wraps a packer.Hook implementation and makes it exportable
@@ -30,7 +31,8 @@ type HookRunArgs struct {
 func (h *hook) Run(name string, ui packer.Ui, comm packer.Communicator, data interface{}) error {
 	nextId := h.mux.NextId()
 	server := newServerWithMux(h.mux, nextId)
-	server.RegisterCommunicator(comm)
+	h.comm.set(server.registerCommunicator(comm))
+	defer h.comm.set(nil)
 	server.RegisterUi(ui)
 	go server.Serve()
 
@@ -44,6 +46,9 @@ func (h *hook) Run(name string, ui packer.Ui, comm packer.Communicator, data int
 }
 
 func (h *hook) Cancel() {
+	// Abort the file transfers of the hook, which it may be stuck in
+	h.comm.cancel()
+
 	err := h.client.Call("Hook.Cancel", new(interface{}), new(interface{}))
 	if err != nil {
 		log.Printf("Hook.Cancel error: %s", err)
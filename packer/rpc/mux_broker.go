@@ -18,9 +18,10 @@ import (
 // or accept a connection from, and the broker handles the details of
 // holding these channels open while they're being negotiated.
 type muxBroker struct {
-	nextId  uint32
-	session *yamux.Session
-	streams map[uint32]*muxBrokerPending
+	nextId       uint32
+	session      *yamux.Session
+	streams      map[uint32]*muxBrokerPending
+	capabilities map[string]bool
 
 	sync.Mutex
 }
@@ -114,6 +115,27 @@ func (m *muxBroker) Dial(id uint32) (net.Conn, error) {
 	return stream, nil
 }
 
+// SetCapabilities sets the capabilities supported by both ends of the
+// connection.
+func (m *muxBroker) SetCapabilities(capabilities []string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.capabilities = make(map[string]bool)
+	for _, c := range capabilities {
+		m.capabilities[c] = true
+	}
+}
+
+// HasCapability returns true if both ends of the connection support the
+// given capability.
+func (m *muxBroker) HasCapability(name string) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.capabilities[name]
+}
+
 // NextId returns a unique ID to use next.
 func (m *muxBroker) NextId() uint32 {
 	return atomic.AddUint32(&m.nextId, 1)
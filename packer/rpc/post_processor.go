@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"log"
 	"net/rpc"
 
 	"github.com/hashicorp/packer/packer"
@@ -67,6 +68,18 @@ func (p *postProcessor) PostProcess(ui packer.Ui, a packer.Artifact) (packer.Art
 	return client.Artifact(), response.Keep, nil
 }
 
+// PlanOutputs returns the output keys of the post-processor on the other
+// end, or nothing if it can't tell them, or is too old to be asked.
+func (p *postProcessor) PlanOutputs() []packer.PlanOutputKey {
+	var keys []packer.PlanOutputKey
+	if err := p.client.Call("PostProcessor.PlanOutputs", new(interface{}), &keys); err != nil {
+		log.Printf("Error getting the plan outputs of the post-processor: %s", err)
+		return nil
+	}
+
+	return keys
+}
+
 func (p *PostProcessorServer) Configure(args *PostProcessorConfigureArgs, reply *interface{}) error {
 	err := p.p.Configure(args.Configs...)
 	return err
@@ -96,3 +109,10 @@ func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorP
 
 	return nil
 }
+
+func (p *PostProcessorServer) PlanOutputs(args *interface{}, reply *[]packer.PlanOutputKey) error {
+	if o, ok := p.p.(packer.PlanOutputter); ok {
+		*reply = o.PlanOutputs()
+	}
+	return nil
+}
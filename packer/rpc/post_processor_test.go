@@ -90,3 +90,27 @@ func TestPostProcessor_Implements(t *testing.T) {
 		t.Fatal("not a postprocessor")
 	}
 }
+
+func TestPostProcessorPlanOutputs(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterPostProcessor(new(TestPostProcessor))
+	ppClient := client.PostProcessor()
+
+	// Post-processors that can't tell their outputs have none
+	if keys := ppClient.(packer.PlanOutputter).PlanOutputs(); len(keys) != 0 {
+		t.Fatalf("bad: %#v", keys)
+	}
+
+	client2, server2 := testClientServer(t)
+	defer client2.Close()
+	defer server2.Close()
+	expected := []packer.PlanOutputKey{{Key: "output", Default: "packer_{{.BuildName}}", Local: true}}
+	server2.RegisterPostProcessor(&packer.MockPostProcessor{OutputKeys: expected})
+
+	keys := client2.PostProcessor().(packer.PlanOutputter).PlanOutputs()
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("bad: %#v", keys)
+	}
+}
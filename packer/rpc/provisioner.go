@@ -12,6 +12,7 @@ import (
 type provisioner struct {
 	client *rpc.Client
 	mux    *muxBroker
+	comm   servedCommunicator
 }
 
 // ProvisionerServer wraps a packer.Provisioner implementation and makes it
@@ -37,7 +38,8 @@ func (p *provisioner) Prepare(configs ...interface{}) (err error) {
 func (p *provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	nextId := p.mux.NextId()
 	server := newServerWithMux(p.mux, nextId)
-	server.RegisterCommunicator(comm)
+	p.comm.set(server.registerCommunicator(comm))
+	defer p.comm.set(nil)
 	server.RegisterUi(ui)
 	go server.Serve()
 
@@ -45,6 +47,9 @@ func (p *provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 }
 
 func (p *provisioner) Cancel() {
+	// Abort the file transfers of the provisioner, which it may be stuck in
+	p.comm.cancel()
+
 	err := p.client.Call("Provisioner.Cancel", new(interface{}), new(interface{}))
 	if err != nil {
 		log.Printf("Provisioner.Cancel err: %s", err)
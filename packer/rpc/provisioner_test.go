@@ -2,7 +2,9 @@ package rpc
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/packer"
 )
@@ -47,3 +49,38 @@ func TestProvisionerRPC(t *testing.T) {
 func TestProvisioner_Implements(t *testing.T) {
 	var _ packer.Provisioner = new(provisioner)
 }
+
+func TestProvisionerRPC_cancelUpload(t *testing.T) {
+	// The provisioner uploads more than it ever gets to finish
+	p := new(packer.MockProvisioner)
+	p.ProvFunc = func() error {
+		return p.ProvCommunicator.Upload("foo", endlessReader{}, nil)
+	}
+
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterProvisioner(p)
+	pClient := client.Provisioner()
+
+	comm := &endlessUploadCommunicator{startedCh: make(chan struct{})}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pClient.Provision(new(testUi), comm)
+	}()
+
+	<-comm.startedCh
+	pClient.Cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil || !strings.Contains(err.Error(), "cancelled") {
+			t.Fatalf("bad: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the upload should be interrupted")
+	}
+	if !p.CancelCalled {
+		t.Fatal("cancel should be called")
+	}
+}
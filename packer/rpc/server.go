@@ -16,6 +16,7 @@ const (
 	DefaultCacheEndpoint                = "Cache"
 	DefaultCommandEndpoint              = "Command"
 	DefaultCommunicatorEndpoint         = "Communicator"
+	DefaultComponentSetEndpoint         = "ComponentSet"
 	DefaultHookEndpoint                 = "Hook"
 	DefaultPostProcessorEndpoint        = "PostProcessor"
 	DefaultProvisionerEndpoint          = "Provisioner"
@@ -58,6 +59,13 @@ func (s *Server) Close() error {
 	return nil
 }
 
+// SetCapabilities sets the capabilities that both ends of the connection
+// support. Optional features of the protocol are only used if the other
+// end supports them.
+func (s *Server) SetCapabilities(capabilities []string) {
+	s.mux.SetCapabilities(capabilities)
+}
+
 func (s *Server) RegisterArtifact(a packer.Artifact) {
 	s.server.RegisterName(DefaultArtifactEndpoint, &ArtifactServer{
 		artifact: a,
@@ -85,8 +93,20 @@ func (s *Server) RegisterCache(c packer.Cache) {
 }
 
 func (s *Server) RegisterCommunicator(c packer.Communicator) {
-	s.server.RegisterName(DefaultCommunicatorEndpoint, &CommunicatorServer{
-		c:   c,
+	s.registerCommunicator(c)
+}
+
+// registerCommunicator registers the communicator, and returns its server
+// so that its transfers can be cancelled.
+func (s *Server) registerCommunicator(c packer.Communicator) *CommunicatorServer {
+	server := newCommunicatorServer(c, s.mux)
+	s.server.RegisterName(DefaultCommunicatorEndpoint, server)
+	return server
+}
+
+func (s *Server) RegisterComponentSet(set *ComponentSet) {
+	s.server.RegisterName(DefaultComponentSetEndpoint, &ComponentSetServer{
+		set: set,
 		mux: s.mux,
 	})
 }
@@ -114,7 +134,8 @@ func (s *Server) RegisterProvisioner(p packer.Provisioner) {
 
 func (s *Server) RegisterUi(ui packer.Ui) {
 	s.server.RegisterName(DefaultUiEndpoint, &UiServer{
-		ui: ui,
+		ui:  ui,
+		mux: s.mux,
 	})
 }
 
@@ -0,0 +1,441 @@
+package rpc
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files are transferred over a stream of the mux broker as a header with
+// the metadata of the file, followed by chunks of data and a final chunk
+// that marks the end of the file. The metadata of downloads is only known
+// once the communicator has downloaded the file, so it is sent in the
+// final chunk instead. The receiver acknowledges every chunk
+// once it has consumed it, which lets the sender report how far the
+// transfer has actually gotten and limits the data that is in flight.
+//
+// Either side can abort the transfer: the sender with an error chunk, and
+// the receiver with an error acknowledgement. The error is returned from
+// the reads or writes of the other side. A transfer that Packer core
+// serves is aborted this way when the component it serves is cancelled.
+// A stream that is closed before the end of the file, for example because
+// the plugin on the other side exited, is an error too, so a transfer is
+// never silently truncated.
+//
+// Chunked transfers are only used if both ends of the connection support
+// them. Otherwise the data is copied as is over the stream.
+
+// CapabilityChunkedTransfer is the capability of transferring files in
+// chunks.
+const CapabilityChunkedTransfer = "chunked-transfer"
+
+// The maximum size of a chunk, and the number of chunks that can be sent
+// before the receiver has to acknowledge them.
+var transferChunkSize = 256 * 1024
+
+const transferWindow = 4
+
+// How long an abort waits for the error to be sent before it closes the
+// stream anyway.
+const transferAbortTimeout = 5 * time.Second
+
+// errTransferInterrupted is returned when the stream of a transfer is
+// closed before the transfer is complete.
+var errTransferInterrupted = errors.New("file transfer was interrupted")
+
+// errTransferCancelled is returned when a transfer is cancelled.
+var errTransferCancelled = errors.New("file transfer was cancelled")
+
+type transferHeader struct {
+	FileInfo *fileInfo
+}
+
+type transferChunk struct {
+	Data     []byte
+	EOF      bool
+	Error    string
+	FileInfo *fileInfo
+}
+
+type transferAck struct {
+	Received int64
+	EOF      bool
+	Error    string
+}
+
+// chunkWriter is the sending side of a transfer. Writes are buffered into
+// chunks, and block while the receiver is behind by the whole window.
+type chunkWriter struct {
+	conn      io.ReadWriteCloser
+	enc       *gob.Encoder
+	buf       []byte
+	chunkSize int
+	progress  func(int64)
+	fileInfo  *fileInfo
+
+	window chan struct{}
+	done   chan struct{}
+
+	// These are set by the goroutine reading the acknowledgements before
+	// done is closed.
+	err error
+	eof bool
+
+	l        sync.Mutex
+	closed   bool
+	abortErr error
+
+	// Chunks are encoded by the writing goroutine, and by abort
+	encL sync.Mutex
+}
+
+// newChunkWriter starts a transfer of the file with the given metadata,
+// which may be nil, on the connection. The progress function, if not nil,
+// is called with the number of bytes the receiver has consumed so far.
+func newChunkWriter(conn io.ReadWriteCloser, fi *fileInfo, progress func(int64)) (*chunkWriter, error) {
+	w := &chunkWriter{
+		conn:      conn,
+		enc:       gob.NewEncoder(conn),
+		buf:       make([]byte, 0, transferChunkSize),
+		chunkSize: transferChunkSize,
+		progress:  progress,
+		window:    make(chan struct{}, transferWindow),
+		done:      make(chan struct{}),
+	}
+
+	if err := w.encode(&transferHeader{FileInfo: fi}); err != nil {
+		return nil, err
+	}
+
+	go w.readAcks()
+	return w, nil
+}
+
+func (w *chunkWriter) readAcks() {
+	defer close(w.done)
+
+	dec := gob.NewDecoder(w.conn)
+	for {
+		var ack transferAck
+		if err := dec.Decode(&ack); err != nil {
+			w.err = errTransferInterrupted
+			w.conn.Close()
+			return
+		}
+
+		// Close the stream, a chunk may be stuck waiting for the
+		// receiver to make room for it
+		if ack.Error != "" {
+			w.err = errors.New(ack.Error)
+			w.conn.Close()
+			return
+		}
+
+		if w.progress != nil {
+			w.progress(ack.Received)
+		}
+
+		if ack.EOF {
+			w.eof = true
+			return
+		}
+
+		<-w.window
+	}
+}
+
+// SetFileInfo sets the metadata of the file that is sent along with the
+// end of the file.
+func (w *chunkWriter) SetFileInfo(fi os.FileInfo) {
+	w.fileInfo = NewFileInfo(fi)
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := w.chunkSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		w.buf = append(w.buf, p[:n]...)
+		if len(w.buf) == w.chunkSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+
+		written += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// flush sends the buffered data as a chunk, once there is room for it in
+// the window.
+func (w *chunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	if err := w.aborted(); err != nil {
+		return err
+	}
+
+	// Don't keep sending once the receiver has gone away
+	select {
+	case <-w.done:
+		return w.doneErr()
+	default:
+	}
+
+	select {
+	case w.window <- struct{}{}:
+	case <-w.done:
+		return w.doneErr()
+	}
+
+	if err := w.encode(&transferChunk{Data: w.buf}); err != nil {
+		return w.sendErr(err)
+	}
+
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close sends the remaining data and the end of the file, and waits for
+// the receiver to acknowledge it. An error is returned if the receiver
+// aborted the transfer.
+func (w *chunkWriter) Close() error {
+	if !w.setClosed() {
+		return nil
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	if err := w.encode(&transferChunk{EOF: true, FileInfo: w.fileInfo}); err != nil {
+		return w.sendErr(err)
+	}
+
+	<-w.done
+	return w.doneErr()
+}
+
+// CloseWithError aborts the transfer. The error is returned from the reads
+// of the receiver.
+func (w *chunkWriter) CloseWithError(err error) error {
+	if !w.setClosed() {
+		return nil
+	}
+
+	return w.encode(&transferChunk{Error: err.Error()})
+}
+
+// abort aborts the transfer from any goroutine. The error is sent to the
+// receiver and returned from the writes, and the stream is closed so that
+// nothing keeps waiting on it.
+func (w *chunkWriter) abort(err error) {
+	w.l.Lock()
+	w.abortErr = err
+	w.l.Unlock()
+
+	abortStream(w.conn, func() {
+		w.encode(&transferChunk{Error: err.Error()})
+	})
+}
+
+func (w *chunkWriter) aborted() error {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	return w.abortErr
+}
+
+func (w *chunkWriter) encode(v interface{}) error {
+	w.encL.Lock()
+	defer w.encL.Unlock()
+
+	return w.enc.Encode(v)
+}
+
+func (w *chunkWriter) setClosed() bool {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.closed {
+		return false
+	}
+
+	w.closed = true
+	return true
+}
+
+// sendErr returns the error of a failed send. The stream is closed by then,
+// so the acknowledgements are done too, and the reason the receiver gave
+// for closing it is more useful than the error of the stream.
+func (w *chunkWriter) sendErr(err error) error {
+	<-w.done
+	if doneErr := w.doneErr(); doneErr != nil {
+		return doneErr
+	}
+
+	return err
+}
+
+func (w *chunkWriter) doneErr() error {
+	if err := w.aborted(); err != nil {
+		return err
+	}
+	if w.eof {
+		return nil
+	}
+
+	return w.err
+}
+
+// chunkReader is the receiving side of a transfer.
+type chunkReader struct {
+	conn     io.ReadWriteCloser
+	dec      *gob.Decoder
+	enc      *gob.Encoder
+	fileInfo *fileInfo
+
+	pending  []byte
+	received int64
+	err      error
+
+	l        sync.Mutex
+	abortErr error
+	encL     sync.Mutex
+}
+
+// newChunkReader accepts a transfer on the connection, reading the
+// metadata of the file.
+func newChunkReader(conn io.ReadWriteCloser) (*chunkReader, error) {
+	r := &chunkReader{
+		conn: conn,
+		dec:  gob.NewDecoder(conn),
+		enc:  gob.NewEncoder(conn),
+	}
+
+	var header transferHeader
+	if err := r.dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	r.fileInfo = header.FileInfo
+
+	return r, nil
+}
+
+// FileInfo returns the metadata of the file that is transferred, or nil if
+// the sender didn't send any. The metadata sent along with the end of the
+// file is only known once the file has been read.
+func (r *chunkReader) FileInfo() *fileInfo {
+	return r.fileInfo
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if err := r.aborted(); err != nil {
+			return 0, err
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		var chunk transferChunk
+		if err := r.dec.Decode(&chunk); err != nil {
+			if err := r.aborted(); err != nil {
+				return 0, err
+			}
+			r.err = errTransferInterrupted
+			return 0, r.err
+		}
+
+		switch {
+		case chunk.Error != "":
+			r.err = errors.New(chunk.Error)
+		case chunk.EOF:
+			r.err = io.EOF
+			if chunk.FileInfo != nil {
+				r.fileInfo = chunk.FileInfo
+			}
+			if err := r.ack(&transferAck{Received: r.received, EOF: true}); err != nil {
+				r.err = errTransferInterrupted
+			}
+		default:
+			r.pending = chunk.Data
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	r.received += int64(n)
+
+	// The chunk is consumed, make room for the next one
+	if len(r.pending) == 0 {
+		if err := r.ack(&transferAck{Received: r.received}); err != nil {
+			r.err = errTransferInterrupted
+		}
+	}
+
+	return n, nil
+}
+
+// CloseWithError aborts the transfer. The error is returned from the
+// writes of the sender.
+func (r *chunkReader) CloseWithError(err error) error {
+	return r.ack(&transferAck{Received: r.received, Error: err.Error()})
+}
+
+// abort aborts the transfer from any goroutine. The error is sent to the
+// sender and returned from the reads, and the stream is closed so that
+// nothing keeps waiting on it.
+func (r *chunkReader) abort(err error) {
+	r.l.Lock()
+	r.abortErr = err
+	r.l.Unlock()
+
+	abortStream(r.conn, func() {
+		r.ack(&transferAck{Error: err.Error()})
+	})
+}
+
+func (r *chunkReader) aborted() error {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	return r.abortErr
+}
+
+func (r *chunkReader) ack(ack *transferAck) error {
+	r.encL.Lock()
+	defer r.encL.Unlock()
+
+	return r.enc.Encode(ack)
+}
+
+// abortStream sends the error of an abort and closes the stream. A send
+// can be stuck while the other side isn't reading, so the stream is closed
+// after a while even if the error couldn't be sent, which also unblocks the
+// sends that are stuck.
+func abortStream(conn io.Closer, send func()) {
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		send()
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(transferAbortTimeout):
+	}
+
+	conn.Close()
+}
@@ -0,0 +1,658 @@
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// testTransferCommunicator records the uploaded file along with its
+// metadata, and fails transfers on demand.
+type testTransferCommunicator struct {
+	packer.MockCommunicator
+
+	uploadData     []byte
+	uploadFileInfo os.FileInfo
+	uploadErr      error
+	downloadErr    error
+
+	downloadFileInfo os.FileInfo
+}
+
+func (c *testTransferCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	if fi != nil {
+		c.uploadFileInfo = *fi
+	}
+
+	c.uploadData, c.uploadErr = ioutil.ReadAll(r)
+	return c.uploadErr
+}
+
+func (c *testTransferCommunicator) Download(path string, w io.Writer) error {
+	_, c.downloadErr = w.Write([]byte(c.DownloadData))
+	if c.downloadErr == nil && c.downloadFileInfo != nil {
+		packer.SetDownloadFileInfo(w, c.downloadFileInfo)
+	}
+	return c.downloadErr
+}
+
+// fileInfoBuffer is a download destination that takes the metadata of
+// the file.
+type fileInfoBuffer struct {
+	bytes.Buffer
+	fi os.FileInfo
+}
+
+func (b *fileInfoBuffer) SetFileInfo(fi os.FileInfo) {
+	b.fi = fi
+}
+
+// errReader returns its data, and then the error.
+type errReader struct {
+	data []byte
+	err  error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+// testChunkedClientServer returns a client and server that both support
+// chunked transfers.
+func testChunkedClientServer(t *testing.T) (*Client, *Server) {
+	client, server := testClientServer(t)
+	client.SetCapabilities([]string{CapabilityChunkedTransfer})
+	server.SetCapabilities([]string{CapabilityChunkedTransfer})
+	return client, server
+}
+
+func testTransferChunkSize(size int) func() {
+	old := transferChunkSize
+	transferChunkSize = size
+	return func() {
+		transferChunkSize = old
+	}
+}
+
+func TestCommunicatorRPC_uploadChunks(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	c := new(testTransferCommunicator)
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	data := strings.Repeat("0123456789", 10)
+	modTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	var fi os.FileInfo = &fileInfo{N: "foo", S: int64(len(data)), M: 0750, T: modTime}
+
+	bar := new(testProgressBar)
+	err := packer.UploadWithProgress(remote, "foo", strings.NewReader(data), &fi, bar)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(c.uploadData) != data {
+		t.Fatalf("bad: %s", c.uploadData)
+	}
+	if bar.current != int64(len(data)) {
+		t.Fatalf("bad: %d", bar.current)
+	}
+
+	if c.uploadFileInfo == nil {
+		t.Fatal("file info should be sent")
+	}
+	if c.uploadFileInfo.Mode() != 0750 {
+		t.Fatalf("bad: %s", c.uploadFileInfo.Mode())
+	}
+	if !c.uploadFileInfo.ModTime().Equal(modTime) {
+		t.Fatalf("bad: %s", c.uploadFileInfo.ModTime())
+	}
+	if c.uploadFileInfo.Size() != int64(len(data)) {
+		t.Fatalf("bad: %d", c.uploadFileInfo.Size())
+	}
+}
+
+func TestCommunicatorRPC_uploadReadError(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	c := new(testTransferCommunicator)
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	r := &errReader{data: []byte("0123456789"), err: errors.New("cancelled")}
+	err := remote.Upload("foo", r, nil)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The communicator must not see a complete file
+	if c.uploadErr == nil {
+		t.Fatal("upload should fail on the other end")
+	}
+}
+
+func TestCommunicatorRPC_uploadRemoteError(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	c := new(testTransferCommunicator)
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(&failingUploadCommunicator{c})
+	remote := client.Communicator()
+
+	data := strings.NewReader(strings.Repeat("0123456789", 100))
+	err := remote.Upload("foo", data, nil)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+// failingUploadCommunicator fails uploads after reading the first bytes,
+// without reading the rest.
+type failingUploadCommunicator struct {
+	*testTransferCommunicator
+}
+
+func (c *failingUploadCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	r.Read(make([]byte, 2))
+	return errors.New("disk full")
+}
+
+func TestCommunicatorRPC_downloadChunks(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	c := new(testTransferCommunicator)
+	c.DownloadData = strings.Repeat("0123456789", 10)
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	var buf bytes.Buffer
+	bar := new(testProgressBar)
+	if err := packer.DownloadWithProgress(remote, "foo", &buf, bar); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if buf.String() != c.DownloadData {
+		t.Fatalf("bad: %s", buf.String())
+	}
+	if bar.current != int64(len(c.DownloadData)) {
+		t.Fatalf("bad: %d", bar.current)
+	}
+}
+
+func TestCommunicatorRPC_downloadFileInfo(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	modTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := new(testTransferCommunicator)
+	c.DownloadData = strings.Repeat("0123456789", 10)
+	c.downloadFileInfo = &fileInfo{N: "foo", S: 100, M: 0750, T: modTime}
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	buf := new(fileInfoBuffer)
+	if err := packer.DownloadWithProgress(remote, "foo", buf, new(testProgressBar)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if buf.String() != c.DownloadData {
+		t.Fatalf("bad: %s", buf.String())
+	}
+	if buf.fi == nil {
+		t.Fatal("file info should be sent")
+	}
+	if buf.fi.Mode() != 0750 || !buf.fi.ModTime().Equal(modTime) {
+		t.Fatalf("bad: %s %s", buf.fi.Mode(), buf.fi.ModTime())
+	}
+}
+
+func TestCommunicatorRPC_downloadWriteError(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	c := new(testTransferCommunicator)
+	c.DownloadData = strings.Repeat("0123456789", 10)
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	err := remote.Download("foo", errWriter{errors.New("no space left")})
+	if err == nil || !strings.Contains(err.Error(), "no space left") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The communicator is told to stop
+	if c.downloadErr == nil || !strings.Contains(c.downloadErr.Error(), "no space left") {
+		t.Fatalf("bad: %v", c.downloadErr)
+	}
+}
+
+func TestCommunicatorRPC_uploadSingleCopy(t *testing.T) {
+	c := new(testTransferCommunicator)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	data := strings.Repeat("0123456789", 10)
+	var fi os.FileInfo = &fileInfo{N: "foo", S: int64(len(data)), M: 0750}
+
+	bar := new(testProgressBar)
+	err := packer.UploadWithProgress(remote, "foo", strings.NewReader(data), &fi, bar)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(c.uploadData) != data {
+		t.Fatalf("bad: %s", c.uploadData)
+	}
+	if bar.current != int64(len(data)) {
+		t.Fatalf("bad: %d", bar.current)
+	}
+	if c.uploadFileInfo == nil || c.uploadFileInfo.Mode() != 0750 {
+		t.Fatalf("bad: %#v", c.uploadFileInfo)
+	}
+}
+
+func TestCommunicatorRPC_downloadSingleCopy(t *testing.T) {
+	c := new(testTransferCommunicator)
+	c.DownloadData = strings.Repeat("0123456789", 10)
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	var buf bytes.Buffer
+	bar := new(testProgressBar)
+	if err := packer.DownloadWithProgress(remote, "foo", &buf, bar); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if buf.String() != c.DownloadData {
+		t.Fatalf("bad: %s", buf.String())
+	}
+	if bar.current != int64(len(c.DownloadData)) {
+		t.Fatalf("bad: %d", bar.current)
+	}
+}
+
+func TestChunkReader_interrupted(t *testing.T) {
+	senderConn, receiverConn := net.Pipe()
+
+	go func() {
+		w, err := newChunkWriter(senderConn, nil, nil)
+		if err != nil {
+			return
+		}
+
+		// Send some data, and go away without finishing the file
+		w.Write([]byte("foo"))
+		w.flush()
+		senderConn.Close()
+	}()
+
+	r, err := newChunkReader(receiverConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != errTransferInterrupted {
+		t.Fatalf("bad: %v", err)
+	}
+	if string(data) != "foo" {
+		t.Fatalf("bad: %s", data)
+	}
+}
+
+// endlessReader reads zeros forever, like a file that is too large to
+// wait for.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// endlessUploadCommunicator signals once an upload has started, and then
+// reads it until it fails.
+type endlessUploadCommunicator struct {
+	packer.MockCommunicator
+
+	startedCh chan struct{}
+	uploadErr error
+}
+
+func (c *endlessUploadCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	if _, err := io.ReadFull(r, make([]byte, 1024)); err != nil {
+		return err
+	}
+	close(c.startedCh)
+
+	_, c.uploadErr = io.Copy(ioutil.Discard, r)
+	return c.uploadErr
+}
+
+func TestCommunicatorRPC_uploadCancel(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		c := &endlessUploadCommunicator{startedCh: make(chan struct{})}
+		client, server := testClientServer(t)
+		if chunked {
+			client.SetCapabilities([]string{CapabilityChunkedTransfer})
+			server.SetCapabilities([]string{CapabilityChunkedTransfer})
+		}
+		commServer := server.registerCommunicator(c)
+		remote := client.Communicator()
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- remote.Upload("foo", endlessReader{}, nil)
+		}()
+
+		<-c.startedCh
+		commServer.cancel()
+
+		select {
+		case err := <-errCh:
+			if err == nil || !strings.Contains(err.Error(), "cancelled") {
+				t.Fatalf("chunked %t: bad: %v", chunked, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("chunked %t: the upload should be interrupted", chunked)
+		}
+		if c.uploadErr != errTransferCancelled {
+			t.Fatalf("chunked %t: bad: %v", chunked, c.uploadErr)
+		}
+
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestCommunicatorRPC_downloadCancel(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	// The communicator keeps writing until the writes fail
+	c := new(testTransferCommunicator)
+	c.DownloadData = strings.Repeat("0123456789", 10)
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	commServer := server.registerCommunicator(c)
+	remote := client.Communicator()
+
+	// Cancel the transfer once the first chunk arrives
+	w := &cancellingWriter{cancel: commServer.cancel}
+	err := remote.Download("foo", w)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("bad: %v", err)
+	}
+	if c.downloadErr != errTransferCancelled {
+		t.Fatalf("bad: %v", c.downloadErr)
+	}
+}
+
+// cancellingWriter cancels the transfers on its first write, and then
+// waits a moment for the cancellation to reach the sender.
+type cancellingWriter struct {
+	cancel func()
+	once   bool
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	if !w.once {
+		w.once = true
+		w.cancel()
+		time.Sleep(50 * time.Millisecond)
+	}
+	return len(p), nil
+}
+
+// dirTransferCommunicator records the directories that are uploaded, and
+// downloads a directory with a file in it.
+type dirTransferCommunicator struct {
+	packer.MockCommunicator
+
+	uploadDst     string
+	uploadSrc     string
+	uploadExclude []string
+	uploadFiles   map[string]string
+	uploadModes   map[string]os.FileMode
+}
+
+func (c *dirTransferCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	c.uploadDst = dst
+	c.uploadSrc = src
+	c.uploadExclude = exclude
+	c.uploadFiles = make(map[string]string)
+	c.uploadModes = make(map[string]os.FileMode)
+
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		c.uploadModes[rel] = info.Mode().Perm()
+		if info.Mode().IsRegular() {
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			c.uploadFiles[rel] = string(data)
+		}
+
+		return nil
+	})
+}
+
+func (c *dirTransferCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	dir := filepath.Join(dst, path.Base(src), "sub")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "foo"), []byte(c.DownloadData), 0640)
+}
+
+func TestCommunicatorRPC_uploadDir(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	dir := filepath.Join(td, "foo")
+	data := strings.Repeat("0123456789", 10)
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "bar"), []byte(data), 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chmod(dir, 0750); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, chunked := range []bool{false, true} {
+		for _, src := range []string{dir, dir + "/"} {
+			c := new(dirTransferCommunicator)
+			client, server := testClientServer(t)
+			if chunked {
+				client.SetCapabilities([]string{CapabilityChunkedTransfer})
+				server.SetCapabilities([]string{CapabilityChunkedTransfer})
+			}
+			server.RegisterCommunicator(c)
+			remote := client.Communicator()
+
+			bar := new(testProgressBar)
+			err := packer.UploadDirWithProgress(remote, "/dst", src, []string{"*.tmp"}, bar)
+			client.Close()
+			server.Close()
+			if err != nil {
+				t.Fatalf("chunked %t, %s: err: %s", chunked, src, err)
+			}
+
+			if c.uploadDst != "/dst" {
+				t.Fatalf("chunked %t, %s: bad: %s", chunked, src, c.uploadDst)
+			}
+			if !reflect.DeepEqual(c.uploadExclude, []string{"*.tmp"}) {
+				t.Fatalf("chunked %t, %s: bad: %#v", chunked, src, c.uploadExclude)
+			}
+
+			// The communicator uploads a directory with the same base name,
+			// and the same trailing slash
+			if filepath.Base(c.uploadSrc) != "foo" {
+				t.Fatalf("chunked %t, %s: bad: %s", chunked, src, c.uploadSrc)
+			}
+			if strings.HasSuffix(c.uploadSrc, "/") != strings.HasSuffix(src, "/") {
+				t.Fatalf("chunked %t, %s: bad: %s", chunked, src, c.uploadSrc)
+			}
+
+			if c.uploadFiles["sub/bar"] != data {
+				t.Fatalf("chunked %t, %s: bad: %#v", chunked, src, c.uploadFiles)
+			}
+			if c.uploadModes["sub/bar"] != 0600 || c.uploadModes["."] != 0750 {
+				t.Fatalf("chunked %t, %s: bad: %#v", chunked, src, c.uploadModes)
+			}
+
+			if !chunked {
+				continue
+			}
+
+			if bar.current == 0 {
+				t.Fatalf("%s: the progress bar should move", src)
+			}
+
+			// The extracted directory is removed
+			if _, err := os.Stat(c.uploadSrc); !os.IsNotExist(err) {
+				t.Fatalf("%s: the extracted directory should be removed: %v", src, err)
+			}
+		}
+	}
+}
+
+func TestCommunicatorRPC_downloadDir(t *testing.T) {
+	defer testTransferChunkSize(4)()
+
+	for _, chunked := range []bool{false, true} {
+		td, err := ioutil.TempDir("", "packer")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer os.RemoveAll(td)
+
+		c := new(dirTransferCommunicator)
+		c.DownloadData = strings.Repeat("0123456789", 10)
+		client, server := testClientServer(t)
+		if chunked {
+			client.SetCapabilities([]string{CapabilityChunkedTransfer})
+			server.SetCapabilities([]string{CapabilityChunkedTransfer})
+		}
+		server.RegisterCommunicator(c)
+		remote := client.Communicator()
+
+		bar := new(testProgressBar)
+		err = packer.DownloadDirWithProgress(remote, "/src/foo", td, nil, bar)
+		client.Close()
+		server.Close()
+		if err != nil {
+			t.Fatalf("chunked %t: err: %s", chunked, err)
+		}
+
+		// Everything the communicator downloaded ends up in the
+		// destination as is
+		p := filepath.Join(td, "foo", "sub", "foo")
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatalf("chunked %t: err: %s", chunked, err)
+		}
+		if string(data) != c.DownloadData {
+			t.Fatalf("chunked %t: bad: %s", chunked, data)
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("chunked %t: err: %s", chunked, err)
+		}
+		if info.Mode().Perm() != 0640 {
+			t.Fatalf("chunked %t: bad: %s", chunked, info.Mode())
+		}
+
+		if chunked && bar.current == 0 {
+			t.Fatal("the progress bar should move")
+		}
+	}
+}
+
+func TestCommunicatorRPC_downloadDirError(t *testing.T) {
+	c := new(dirTransferCommunicator)
+	client, server := testChunkedClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterCommunicator(c)
+	remote := client.Communicator()
+
+	// The destination can't be created
+	td, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	td.Close()
+	defer os.Remove(td.Name())
+
+	err = remote.DownloadDir("/src/foo", filepath.Join(td.Name(), "dst"), nil)
+	if err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("bad: %v", err)
+	}
+}
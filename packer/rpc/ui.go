@@ -1,8 +1,11 @@
 package rpc
 
 import (
+	"io"
 	"log"
 	"net/rpc"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/packer/packer"
 )
@@ -11,13 +14,28 @@ import (
 // over an RPC connection.
 type Ui struct {
 	client   *rpc.Client
+	mux      *muxBroker
 	endpoint string
 }
 
 // UiServer wraps a packer.Ui implementation and makes it exportable
 // as part of a Golang RPC server.
 type UiServer struct {
-	ui packer.Ui
+	ui  packer.Ui
+	mux *muxBroker
+
+	l            sync.Mutex
+	progressBars map[int]packer.ProgressBar
+	nextBarId    int
+	terminals    map[uint32]*serverTerminal
+}
+
+// serverTerminal is a terminal handed over to the client of a UiServer.
+type serverTerminal struct {
+	packer.Terminal
+
+	// Closed once all the output of the client is written
+	outputDone chan struct{}
 }
 
 // The arguments sent to Ui.Machine
@@ -26,6 +44,59 @@ type UiMachineArgs struct {
 	Args     []string
 }
 
+// The arguments sent to Ui.ProgressBarStart
+type UiProgressBarStartArgs struct {
+	Name  string
+	Total int64
+}
+
+// The arguments sent to Ui.ProgressBarSet and Ui.ProgressBarClose
+type UiProgressBarArgs struct {
+	Id      int
+	Current int64
+}
+
+// CapabilityProgress is the capability of drawing progress bars over the
+// connection.
+const CapabilityProgress = "progress"
+
+// The minimum interval between progress updates sent over RPC, so that
+// small reads don't turn into a flood of calls.
+var progressBarRPCInterval = 100 * time.Millisecond
+
+// The arguments sent to Ui.Terminal and Ui.TerminalClose
+type UiTerminalArgs struct {
+	StreamId uint32
+}
+
+// The reply of Ui.Terminal
+type UiTerminalReply struct {
+	Width  int
+	Height int
+}
+
+// terminal is an implementation of packer.Terminal where the terminal is
+// actually attached over a stream of an RPC connection.
+type terminal struct {
+	io.ReadWriteCloser
+
+	client   *rpc.Client
+	streamId uint32
+	width    int
+	height   int
+}
+
+// progressBar is an implementation of packer.ProgressBar where the
+// progress bar is actually drawn over an RPC connection.
+type progressBar struct {
+	client *rpc.Client
+	id     int
+
+	l        sync.Mutex
+	current  int64
+	lastSent time.Time
+}
+
 func (u *Ui) Ask(query string) (result string, err error) {
 	err = u.client.Call("Ui.Ask", query, &result)
 	return
@@ -48,6 +119,78 @@ func (u *Ui) Machine(t string, args ...string) {
 	}
 }
 
+// ProgressBar starts a progress bar on the remote UI. The progress is sent
+// over in batches. If the other end can't draw progress bars, the progress
+// is reported as messages instead.
+func (u *Ui) ProgressBar(name string, total int64) packer.ProgressBar {
+	if !u.mux.HasCapability(CapabilityProgress) {
+		// Hide the ProgressBar method of the Ui, so that a progress
+		// bar that reports through messages is used.
+		return packer.NewProgressBar(struct{ packer.Ui }{u}, name, total)
+	}
+
+	args := &UiProgressBarStartArgs{
+		Name:  name,
+		Total: total,
+	}
+
+	// If this fails, the progress bar id stays zero, which is ignored by
+	// the server, so the progress just isn't shown.
+	var id int
+	if err := u.client.Call("Ui.ProgressBarStart", args, &id); err != nil {
+		log.Printf("Error in Ui RPC call: %s", err)
+	}
+
+	return &progressBar{
+		client:   u.client,
+		id:       id,
+		lastSent: time.Now(),
+	}
+}
+
+func (u *Ui) IsTerminal() (result bool) {
+	if err := u.client.Call("Ui.IsTerminal", new(interface{}), &result); err != nil {
+		log.Printf("Error in Ui RPC call: %s", err)
+		return false
+	}
+
+	return
+}
+
+// Terminal hands over the terminal of the remote UI. The input and output
+// of the terminal are streamed over the connection.
+func (u *Ui) Terminal() (packer.Terminal, error) {
+	streamId := u.mux.NextId()
+	type accepted struct {
+		conn io.ReadWriteCloser
+		err  error
+	}
+	acceptCh := make(chan accepted, 1)
+	go func() {
+		conn, err := u.mux.Accept(streamId)
+		acceptCh <- accepted{conn, err}
+	}()
+
+	var reply UiTerminalReply
+	args := &UiTerminalArgs{StreamId: streamId}
+	if err := u.client.Call("Ui.Terminal", args, &reply); err != nil {
+		return nil, err
+	}
+
+	result := <-acceptCh
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	return &terminal{
+		ReadWriteCloser: result.conn,
+		client:          u.client,
+		streamId:        streamId,
+		width:           reply.Width,
+		height:          reply.Height,
+	}, nil
+}
+
 func (u *Ui) Message(message string) {
 	if err := u.client.Call("Ui.Message", message, new(interface{})); err != nil {
 		log.Printf("Error in Ui RPC call: %s", err)
@@ -91,3 +234,161 @@ func (u *UiServer) Say(message *string, reply *interface{}) error {
 	*reply = nil
 	return nil
 }
+
+func (u *UiServer) IsTerminal(args *interface{}, reply *bool) error {
+	*reply = packer.UiIsTerminal(u.ui)
+	return nil
+}
+
+func (u *UiServer) Terminal(args *UiTerminalArgs, reply *UiTerminalReply) error {
+	term, err := packer.UiTerminal(u.ui)
+	if err != nil {
+		return NewBasicError(err)
+	}
+
+	conn, err := u.mux.Dial(args.StreamId)
+	if err != nil {
+		term.Close()
+		return NewBasicError(err)
+	}
+
+	st := &serverTerminal{
+		Terminal:   term,
+		outputDone: make(chan struct{}),
+	}
+
+	u.l.Lock()
+	if u.terminals == nil {
+		u.terminals = make(map[uint32]*serverTerminal)
+	}
+	u.terminals[args.StreamId] = st
+	u.l.Unlock()
+
+	// The output is copied until the client closes the terminal, and the
+	// input until the terminal is given back.
+	go func() {
+		defer conn.Close()
+		io.Copy(term, conn)
+		close(st.outputDone)
+		term.Close()
+	}()
+
+	go io.Copy(conn, term)
+
+	reply.Width, reply.Height = term.Size()
+	return nil
+}
+
+func (u *UiServer) TerminalClose(args *UiTerminalArgs, reply *interface{}) error {
+	u.l.Lock()
+	st, ok := u.terminals[args.StreamId]
+	delete(u.terminals, args.StreamId)
+	u.l.Unlock()
+
+	*reply = nil
+	if !ok {
+		return nil
+	}
+
+	<-st.outputDone
+	return st.Close()
+}
+
+func (u *UiServer) ProgressBarStart(args *UiProgressBarStartArgs, reply *int) error {
+	bar := packer.NewProgressBar(u.ui, args.Name, args.Total)
+
+	u.l.Lock()
+	defer u.l.Unlock()
+
+	if u.progressBars == nil {
+		u.progressBars = make(map[int]packer.ProgressBar)
+	}
+
+	u.nextBarId++
+	u.progressBars[u.nextBarId] = bar
+
+	*reply = u.nextBarId
+	return nil
+}
+
+func (u *UiServer) ProgressBarSet(args *UiProgressBarArgs, reply *interface{}) error {
+	u.l.Lock()
+	bar, ok := u.progressBars[args.Id]
+	u.l.Unlock()
+
+	if ok {
+		bar.Set(args.Current)
+	}
+
+	*reply = nil
+	return nil
+}
+
+func (u *UiServer) ProgressBarClose(args *UiProgressBarArgs, reply *interface{}) error {
+	u.l.Lock()
+	bar, ok := u.progressBars[args.Id]
+	delete(u.progressBars, args.Id)
+	u.l.Unlock()
+
+	*reply = nil
+	if !ok {
+		return nil
+	}
+
+	bar.Set(args.Current)
+	return bar.Close()
+}
+
+func (t *terminal) Size() (int, int) {
+	return t.width, t.height
+}
+
+// Close closes the terminal, and waits for the remote UI to take it back.
+func (t *terminal) Close() error {
+	t.ReadWriteCloser.Close()
+
+	args := &UiTerminalArgs{StreamId: t.streamId}
+	return t.client.Call("Ui.TerminalClose", args, new(interface{}))
+}
+
+func (b *progressBar) Add(n int64) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.current += n
+	b.send()
+}
+
+func (b *progressBar) Set(current int64) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.current = current
+	b.send()
+}
+
+func (b *progressBar) Close() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	args := &UiProgressBarArgs{
+		Id:      b.id,
+		Current: b.current,
+	}
+	return b.client.Call("Ui.ProgressBarClose", args, new(interface{}))
+}
+
+func (b *progressBar) send() {
+	if time.Since(b.lastSent) < progressBarRPCInterval {
+		return
+	}
+
+	b.lastSent = time.Now()
+	args := &UiProgressBarArgs{
+		Id:      b.id,
+		Current: b.current,
+	}
+	if err := b.client.Call("Ui.ProgressBarSet", args, new(interface{})); err != nil {
+		log.Printf("Error in Ui RPC call: %s", err)
+	}
+}
@@ -1,8 +1,13 @@
 package rpc
 
 import (
+	"bytes"
+	"io"
 	"reflect"
+	"sync"
 	"testing"
+
+	"github.com/hashicorp/packer/packer"
 )
 
 type testUi struct {
@@ -102,3 +107,192 @@ func TestUiRPC(t *testing.T) {
 		t.Fatalf("bad: %#v", ui.machineArgs)
 	}
 }
+
+type testProgressUi struct {
+	testUi
+
+	bar *testProgressBar
+}
+
+type testProgressBar struct {
+	name    string
+	total   int64
+	current int64
+	closed  bool
+}
+
+func (u *testProgressUi) ProgressBar(name string, total int64) packer.ProgressBar {
+	u.bar = &testProgressBar{name: name, total: total}
+	return u.bar
+}
+
+func (b *testProgressBar) Add(n int64)       { b.current += n }
+func (b *testProgressBar) Set(current int64) { b.current = current }
+func (b *testProgressBar) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestUiRPC_progressBar(t *testing.T) {
+	ui := new(testProgressUi)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	client.SetCapabilities([]string{CapabilityProgress})
+	server.SetCapabilities([]string{CapabilityProgress})
+	server.RegisterUi(ui)
+
+	bar := packer.NewProgressBar(client.Ui(), "foo", 42)
+	if ui.bar == nil {
+		t.Fatal("progress bar should be started")
+	}
+	if ui.bar.name != "foo" || ui.bar.total != 42 {
+		t.Fatalf("bad: %#v", ui.bar)
+	}
+
+	bar.Add(20)
+	bar.Add(22)
+	if err := bar.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !ui.bar.closed {
+		t.Fatal("progress bar should be closed")
+	}
+	if ui.bar.current != 42 {
+		t.Fatalf("bad: %d", ui.bar.current)
+	}
+}
+
+func TestUiRPC_progressBarUnsupported(t *testing.T) {
+	ui := new(testProgressUi)
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	bar := packer.NewProgressBar(client.Ui(), "foo", 42)
+	bar.Add(42)
+	if err := bar.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if ui.bar != nil {
+		t.Fatal("progress bar should not be started on the other end")
+	}
+}
+
+type testTerminalUi struct {
+	testUi
+
+	terminal *testTerminal
+}
+
+// testTerminal reads its input from a pipe, and records its output.
+type testTerminal struct {
+	*io.PipeReader
+
+	l      sync.Mutex
+	output bytes.Buffer
+	closed chan struct{}
+}
+
+func (u *testTerminalUi) IsTerminal() bool { return true }
+func (u *testTerminalUi) Terminal() (packer.Terminal, error) {
+	return u.terminal, nil
+}
+
+func (t *testTerminal) Write(p []byte) (int, error) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	return t.output.Write(p)
+}
+
+func (t *testTerminal) Size() (int, int) { return 100, 50 }
+func (t *testTerminal) Close() error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	select {
+	case <-t.closed:
+	default:
+		close(t.closed)
+		t.PipeReader.Close()
+	}
+	return nil
+}
+
+func TestUiRPC_terminal(t *testing.T) {
+	inR, inW := io.Pipe()
+	ui := &testTerminalUi{
+		terminal: &testTerminal{PipeReader: inR, closed: make(chan struct{})},
+	}
+
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(ui)
+
+	uiClient := client.Ui()
+	if !packer.UiIsTerminal(uiClient) {
+		t.Fatal("should be a terminal")
+	}
+
+	term, err := packer.UiTerminal(uiClient)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if w, h := term.Size(); w != 100 || h != 50 {
+		t.Fatalf("bad: %d %d", w, h)
+	}
+
+	// Input is streamed to the client
+	go inW.Write([]byte("ls\n"))
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(term, buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(buf) != "ls\n" {
+		t.Fatalf("bad: %q", buf)
+	}
+
+	// Output is streamed to the terminal
+	if _, err := term.Write([]byte("foo")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := term.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	select {
+	case <-ui.terminal.closed:
+	default:
+		t.Fatal("terminal should be given back")
+	}
+
+	ui.terminal.l.Lock()
+	defer ui.terminal.l.Unlock()
+	if ui.terminal.output.String() != "foo" {
+		t.Fatalf("bad: %q", ui.terminal.output.String())
+	}
+}
+
+func TestUiRPC_noTerminal(t *testing.T) {
+	client, server := testClientServer(t)
+	defer client.Close()
+	defer server.Close()
+	server.RegisterUi(new(testUi))
+
+	uiClient := client.Ui()
+	if packer.UiIsTerminal(uiClient) {
+		t.Fatal("should not be a terminal")
+	}
+
+	if _, err := packer.UiTerminal(uiClient); err == nil {
+		t.Fatal("should error")
+	}
+}
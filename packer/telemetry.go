@@ -2,10 +2,14 @@ package packer
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	checkpoint "github.com/hashicorp/go-checkpoint"
@@ -28,6 +32,13 @@ type CheckpointTelemetry struct {
 	spans         []*TelemetrySpan
 	signatureFile string
 	startTime     time.Time
+
+	// noCheckpoint is set if the spans are only exported, and nothing is
+	// reported to checkpoint.
+	noCheckpoint bool
+	exporter     SpanExporter
+	root         *TelemetrySpan
+	l            sync.Mutex
 }
 
 func NewCheckpointReporter(disableSignature bool) *CheckpointTelemetry {
@@ -54,6 +65,40 @@ func NewCheckpointReporter(disableSignature bool) *CheckpointTelemetry {
 	}
 }
 
+// NewExportingReporter returns a reporter that doesn't report anything to
+// checkpoint, but only exports the spans with the given exporter.
+func NewExportingReporter(exporter SpanExporter) *CheckpointTelemetry {
+	return &CheckpointTelemetry{
+		startTime:    time.Now().UTC(),
+		noCheckpoint: true,
+		exporter:     exporter,
+	}
+}
+
+// SetExporter sets the exporter that all spans are exported with when the
+// report is finalized.
+func (c *CheckpointTelemetry) SetExporter(exporter SpanExporter) {
+	if c == nil {
+		return
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.exporter = exporter
+}
+
+// Exporting returns true if the spans are exported, which includes the
+// trace spans of the steps of the builds.
+func (c *CheckpointTelemetry) Exporting() bool {
+	if c == nil {
+		return false
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.exporter != nil
+}
+
 func (c *CheckpointTelemetry) baseParams(prefix string) *checkpoint.ReportParams {
 	version := packerVersion.Version
 	if packerVersion.VersionPrerelease != "" {
@@ -71,7 +116,7 @@ func (c *CheckpointTelemetry) baseParams(prefix string) *checkpoint.ReportParams
 }
 
 func (c *CheckpointTelemetry) ReportPanic(m string) error {
-	if c == nil {
+	if c == nil || c.noCheckpoint {
 		return nil
 	}
 	panicParams := c.baseParams(TelemetryPanicVersion)
@@ -86,6 +131,8 @@ func (c *CheckpointTelemetry) ReportPanic(m string) error {
 	return checkpoint.Report(ctx, panicParams)
 }
 
+// AddSpan starts a top level span for a component with the given name and
+// type, such as a builder.
 func (c *CheckpointTelemetry) AddSpan(name, pluginType string, options interface{}) *TelemetrySpan {
 	if c == nil {
 		return nil
@@ -98,20 +145,95 @@ func (c *CheckpointTelemetry) AddSpan(name, pluginType string, options interface
 		StartTime: time.Now().UTC(),
 		Type:      pluginType,
 	}
-	c.spans = append(c.spans, ts)
+	c.addSpan(c.rootSpan(), ts, true)
 	return ts
 }
 
+// AddTraceSpan starts a top level span that is only exported, but not
+// reported to checkpoint, such as the span of a whole build.
+func (c *CheckpointTelemetry) AddTraceSpan(name, spanType string) *TelemetrySpan {
+	if c == nil {
+		return nil
+	}
+
+	ts := &TelemetrySpan{
+		Name:      name,
+		StartTime: time.Now().UTC(),
+		Type:      spanType,
+	}
+	c.addSpan(c.rootSpan(), ts, false)
+	return ts
+}
+
+// rootSpan returns the span of the whole run, that all top level spans are
+// nested in.
+func (c *CheckpointTelemetry) rootSpan() *TelemetrySpan {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if c.root == nil {
+		traceID := strings.Replace(os.Getenv("PACKER_RUN_UUID"), "-", "", -1)
+		if _, err := hex.DecodeString(traceID); err != nil || len(traceID) != 32 {
+			traceID = randomHexID(16)
+		}
+
+		c.root = &TelemetrySpan{
+			Name:      "packer",
+			StartTime: c.startTime,
+			Type:      "command",
+			TraceID:   traceID,
+			SpanID:    randomHexID(8),
+			reporter:  c,
+		}
+	}
+
+	return c.root
+}
+
+func (c *CheckpointTelemetry) addSpan(parent, ts *TelemetrySpan, checkpoint bool) {
+	ts.TraceID = parent.TraceID
+	ts.SpanID = randomHexID(8)
+	ts.ParentSpanID = parent.SpanID
+	ts.checkpoint = checkpoint
+	ts.reporter = c
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.spans = append(c.spans, ts)
+}
+
 func (c *CheckpointTelemetry) Finalize(command string, errCode int, err error) error {
 	if c == nil {
 		return nil
 	}
 
+	c.l.Lock()
+	exporter := c.exporter
+	c.l.Unlock()
+
+	if exporter != nil {
+		c.export(exporter, command, err)
+	}
+
+	if c.noCheckpoint {
+		return nil
+	}
+
+	// Only the spans of the components are reported to checkpoint
+	c.l.Lock()
+	var spans []*TelemetrySpan
+	for _, s := range c.spans {
+		if s.checkpoint {
+			spans = append(spans, s)
+		}
+	}
+	c.l.Unlock()
+
 	params := c.baseParams(TelemetryVersion)
 	params.EndTime = time.Now().UTC()
 
 	extra := &PackerReport{
-		Spans:    c.spans,
+		Spans:    spans,
 		ExitCode: errCode,
 		Command:  command,
 	}
@@ -129,6 +251,34 @@ func (c *CheckpointTelemetry) Finalize(command string, errCode int, err error) e
 	return checkpoint.Report(ctx, params)
 }
 
+// export exports the span of the whole run, and all spans nested in it.
+// Spans that haven't ended yet, for example because the run was
+// interrupted, end now.
+func (c *CheckpointTelemetry) export(exporter SpanExporter, command string, err error) {
+	root := c.rootSpan()
+	if command != "" {
+		root.Name = "packer " + command
+	}
+	root.End(err)
+
+	c.l.Lock()
+	spans := make([]*TelemetrySpan, 0, len(c.spans)+1)
+	spans = append(spans, root)
+	spans = append(spans, c.spans...)
+	c.l.Unlock()
+
+	for _, s := range spans {
+		if s.EndTime.IsZero() {
+			s.EndTime = root.EndTime
+		}
+	}
+
+	log.Printf("[INFO] (telemetry) Exporting %d spans.", len(spans))
+	if err := exporter.ExportSpans(spans); err != nil {
+		log.Printf("[WARN] (telemetry) Error exporting spans: %s", err)
+	}
+}
+
 type TelemetrySpan struct {
 	EndTime   time.Time `json:"end_time"`
 	Error     string    `json:"error"`
@@ -136,6 +286,50 @@ type TelemetrySpan struct {
 	Options   []string  `json:"options"`
 	StartTime time.Time `json:"start_time"`
 	Type      string    `json:"type"`
+
+	// The identifiers of the span, the trace it is part of and the span
+	// it is nested in, as hex strings. They are only exported, but not
+	// reported to checkpoint.
+	TraceID      string `json:"-"`
+	SpanID       string `json:"-"`
+	ParentSpanID string `json:"-"`
+
+	checkpoint bool
+	reporter   *CheckpointTelemetry
+}
+
+// AddSpan starts a span for a component with the given name and type,
+// nested in this span.
+func (s *TelemetrySpan) AddSpan(name, pluginType string, options interface{}) *TelemetrySpan {
+	if s == nil {
+		return nil
+	}
+	log.Printf("[INFO] (telemetry) Starting %s %s", pluginType, name)
+
+	ts := &TelemetrySpan{
+		Name:      name,
+		Options:   flattenConfigKeys(options),
+		StartTime: time.Now().UTC(),
+		Type:      pluginType,
+	}
+	s.reporter.addSpan(s, ts, true)
+	return ts
+}
+
+// AddTraceSpan starts a span nested in this span that is only exported,
+// but not reported to checkpoint.
+func (s *TelemetrySpan) AddTraceSpan(name, spanType string) *TelemetrySpan {
+	if s == nil {
+		return nil
+	}
+
+	ts := &TelemetrySpan{
+		Name:      name,
+		StartTime: time.Now().UTC(),
+		Type:      spanType,
+	}
+	s.reporter.addSpan(s, ts, false)
+	return ts
 }
 
 func (s *TelemetrySpan) End(err error) {
@@ -149,6 +343,15 @@ func (s *TelemetrySpan) End(err error) {
 	}
 }
 
+// randomHexID returns a random identifier of n bytes as a hex string.
+func randomHexID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[WARN] (telemetry) Error generating span ID: %s", err)
+	}
+	return hex.EncodeToString(b)
+}
+
 func flattenConfigKeys(options interface{}) []string {
 	var flatten func(string, interface{}) []string
 
@@ -172,3 +375,77 @@ func flattenConfigKeys(options interface{}) []string {
 	sort.Strings(flattened)
 	return flattened
 }
+
+// stepSpans keeps track of the spans of the steps of a builder, based on
+// the machine-readable "step" messages the builder outputs before running
+// and before cleaning up each of its steps. Steps run one at a time, so a
+// step span ends when the next one starts.
+type stepSpans struct {
+	parent *TelemetrySpan
+
+	l       sync.Mutex
+	current *TelemetrySpan
+}
+
+// step ends the span of the running step and starts the span of the
+// given step.
+func (s *stepSpans) step(phase, name string) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.current.End(nil)
+
+	spanType := "step"
+	if phase == "cleanup" {
+		spanType = "step-cleanup"
+	}
+	s.current = s.parent.AddTraceSpan(name, spanType)
+}
+
+// End ends the span of the running step, if any.
+func (s *stepSpans) End() {
+	if s == nil {
+		return
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.current.End(nil)
+	s.current = nil
+}
+
+// AddSpan starts a span nested in the span of the running step, or in the
+// parent span if no step is running.
+func (s *stepSpans) AddSpan(name, pluginType string, options interface{}) *TelemetrySpan {
+	if s == nil {
+		return CheckpointReporter.AddSpan(name, pluginType, options)
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.current != nil {
+		return s.current.AddSpan(name, pluginType, options)
+	}
+	return s.parent.AddSpan(name, pluginType, options)
+}
+
+// stepSpanUi is a UI that tracks the step spans of the builder it is
+// given to.
+type stepSpanUi struct {
+	Ui
+	spans *stepSpans
+}
+
+func (u *stepSpanUi) Machine(t string, args ...string) {
+	if t == "step" && len(args) >= 2 {
+		u.spans.step(args[0], args[1])
+	}
+
+	u.Ui.Machine(t, args...)
+}
+
+func (u *stepSpanUi) ProgressBar(name string, total int64) ProgressBar {
+	return NewProgressBar(u.Ui, name, total)
+}
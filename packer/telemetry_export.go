@@ -0,0 +1,304 @@
+package packer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	packerVersion "github.com/hashicorp/packer/version"
+)
+
+// These are the environmental variables that configure where the spans
+// of a run are exported to. The OTEL_ ones are the standard OpenTelemetry
+// exporter variables.
+const (
+	// EnvTraceFile is the path of a file that the spans are appended to,
+	// as a line of OTLP/JSON.
+	EnvTraceFile = "PACKER_OTLP_FILE"
+
+	// EnvOTLPEndpoint is the base URL of an OTLP/HTTP collector. The
+	// spans are sent to its /v1/traces path.
+	EnvOTLPEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+	// EnvOTLPTracesEndpoint is the full URL that the spans are sent to.
+	// It takes precedence over EnvOTLPEndpoint.
+	EnvOTLPTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+
+	// EnvOTLPHeaders are extra headers to send to the collector, as a
+	// comma-separated list of key=value pairs.
+	EnvOTLPHeaders = "OTEL_EXPORTER_OTLP_HEADERS"
+
+	// EnvOTLPServiceName overrides the service name of the spans, which
+	// defaults to "packer".
+	EnvOTLPServiceName = "OTEL_SERVICE_NAME"
+)
+
+// A SpanExporter exports the telemetry spans of a Packer run, for example
+// to a tracing system.
+type SpanExporter interface {
+	ExportSpans(spans []*TelemetrySpan) error
+}
+
+// SpanExporterFromEnv returns the span exporter configured by the
+// environment, or nil if there is none.
+func SpanExporterFromEnv() (SpanExporter, error) {
+	var exporters MultiSpanExporter
+
+	if path := os.Getenv(EnvTraceFile); path != "" {
+		exporters = append(exporters, &OTLPFileExporter{Path: path})
+	}
+
+	endpoint := os.Getenv(EnvOTLPTracesEndpoint)
+	if endpoint == "" {
+		if base := os.Getenv(EnvOTLPEndpoint); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint != "" {
+		headers, err := parseOTLPHeaders(os.Getenv(EnvOTLPHeaders))
+		if err != nil {
+			return nil, err
+		}
+
+		exporters = append(exporters, &OTLPHTTPExporter{
+			Endpoint: endpoint,
+			Headers:  headers,
+		})
+	}
+
+	switch len(exporters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exporters[0], nil
+	default:
+		return exporters, nil
+	}
+}
+
+func parseOTLPHeaders(v string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("Invalid header in %s: %q", EnvOTLPHeaders, pair)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return headers, nil
+}
+
+// MultiSpanExporter exports the spans with every exporter in the list.
+type MultiSpanExporter []SpanExporter
+
+func (m MultiSpanExporter) ExportSpans(spans []*TelemetrySpan) error {
+	var errs []error
+	for _, e := range m {
+		if err := e.ExportSpans(spans); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &MultiError{errs}
+	}
+
+	return nil
+}
+
+// OTLPFileExporter appends the spans to a file, as a line of OTLP/JSON.
+type OTLPFileExporter struct {
+	Path string
+}
+
+func (e *OTLPFileExporter) ExportSpans(spans []*TelemetrySpan) error {
+	data, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(e.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// OTLPHTTPExporter sends the spans to an OTLP/HTTP collector, encoded as
+// JSON.
+type OTLPHTTPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+
+	// Client is the HTTP client to use. If nil, a client with a short
+	// timeout is used.
+	Client *http.Client
+}
+
+func (e *OTLPHTTPExporter) ExportSpans(spans []*TelemetrySpan) error {
+	data, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", e.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = cleanhttp.DefaultClient()
+		client.Timeout = 10 * time.Second
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error sending spans to %s: %s: %s",
+			e.Endpoint, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// The OTLP/JSON encoding of an ExportTraceServiceRequest. Only the fields
+// that Packer sets are included.
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Values of the OTLP enums used by Packer
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusCodeError  = 2
+)
+
+func otlpRequest(spans []*TelemetrySpan) *otlpTraceRequest {
+	serviceName := os.Getenv(EnvOTLPServiceName)
+	if serviceName == "" {
+		serviceName = "packer"
+	}
+
+	version := packerVersion.Version
+	if packerVersion.VersionPrerelease != "" {
+		version += "-" + packerVersion.VersionPrerelease
+	}
+
+	result := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		attrs := []otlpAttribute{
+			{Key: "packer.type", Value: otlpValue{s.Type}},
+		}
+		if len(s.Options) > 0 {
+			attrs = append(attrs, otlpAttribute{
+				Key: "packer.options", Value: otlpValue{strings.Join(s.Options, ",")},
+			})
+		}
+
+		result[i] = otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentSpanID,
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        attrs,
+		}
+		if s.Error != "" {
+			result[i].Status = &otlpStatus{
+				Code:    otlpStatusCodeError,
+				Message: s.Error,
+			}
+		}
+	}
+
+	return &otlpTraceRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{
+					{Key: "service.name", Value: otlpValue{serviceName}},
+					{Key: "service.version", Value: otlpValue{version}},
+				},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{
+					Name:    "packer",
+					Version: version,
+				},
+				Spans: result,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,208 @@
+package packer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type recordingExporter struct {
+	spans []*TelemetrySpan
+}
+
+func (e *recordingExporter) ExportSpans(spans []*TelemetrySpan) error {
+	e.spans = spans
+	return nil
+}
+
+// spanByName returns the exported span with the given name.
+func spanByName(t *testing.T, spans []*TelemetrySpan, name string) *TelemetrySpan {
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	t.Fatalf("span %q not found", name)
+	return nil
+}
+
+func TestCheckpointTelemetry_nestedSpans(t *testing.T) {
+	exporter := new(recordingExporter)
+	c := NewExportingReporter(exporter)
+
+	build := c.AddTraceSpan("my-build", "build")
+	builder := build.AddSpan("docker", "builder", map[string]interface{}{"image": "ubuntu"})
+	steps := &stepSpans{parent: builder}
+	ui := &stepSpanUi{Ui: testUi(), spans: steps}
+
+	ui.Machine("step", "run", "StepPull")
+	ui.Machine("step", "run", "StepProvision")
+	prov := steps.AddSpan("shell", "provisioner", nil)
+	prov.End(errors.New("failed"))
+	ui.Machine("step", "cleanup", "StepPull")
+	steps.End()
+	builder.End(nil)
+	build.End(nil)
+
+	if err := c.Finalize("build", 0, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	spans := exporter.spans
+	if len(spans) != 7 {
+		t.Fatalf("bad: %d spans", len(spans))
+	}
+
+	root := spanByName(t, spans, "packer build")
+	parents := map[string]*TelemetrySpan{
+		"my-build":      root,
+		"docker":        build,
+		"StepPull":      builder,
+		"StepProvision": builder,
+		"shell":         spanByName(t, spans, "StepProvision"),
+	}
+	for name, parent := range parents {
+		s := spanByName(t, spans, name)
+		if s.ParentSpanID != parent.SpanID {
+			t.Fatalf("bad parent of %s: %s", name, s.ParentSpanID)
+		}
+		if s.TraceID != root.TraceID {
+			t.Fatalf("bad trace of %s: %s", name, s.TraceID)
+		}
+		if s.EndTime.IsZero() {
+			t.Fatalf("span %s didn't end", name)
+		}
+	}
+
+	var cleanups int
+	for _, s := range spans {
+		if s.Type == "step-cleanup" {
+			cleanups++
+		}
+	}
+	if cleanups != 1 {
+		t.Fatalf("bad: %d cleanup spans", cleanups)
+	}
+}
+
+func TestCheckpointTelemetry_noReporter(t *testing.T) {
+	var c *CheckpointTelemetry
+	build := c.AddTraceSpan("my-build", "build")
+	if build != nil {
+		t.Fatal("should not start a span")
+	}
+	if s := build.AddSpan("docker", "builder", nil); s != nil {
+		t.Fatal("should not start a span")
+	}
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewExportingReporter(&OTLPFileExporter{Path: filepath.Join(dir, "trace.json")})
+	build := c.AddTraceSpan("my-build", "build")
+	build.End(errors.New("failed"))
+	if err := c.Finalize("build", 1, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "trace.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var req otlpTraceRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("bad: %#v", spans)
+	}
+	if spans[1].Name != "my-build" || spans[1].ParentSpanID != spans[0].SpanID {
+		t.Fatalf("bad: %#v", spans[1])
+	}
+	if spans[1].Status == nil || spans[1].Status.Code != otlpStatusCodeError || spans[1].Status.Message != "failed" {
+		t.Fatalf("bad status: %#v", spans[1].Status)
+	}
+	if len(spans[0].TraceID) != 32 || len(spans[0].SpanID) != 16 {
+		t.Fatalf("bad ids: %#v", spans[0])
+	}
+}
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	var req otlpTraceRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(404)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&req)
+	}))
+	defer server.Close()
+
+	defer os.Setenv(EnvOTLPEndpoint, os.Getenv(EnvOTLPEndpoint))
+	defer os.Setenv(EnvOTLPHeaders, os.Getenv(EnvOTLPHeaders))
+	os.Setenv(EnvOTLPEndpoint, server.URL+"/")
+	os.Setenv(EnvOTLPHeaders, "Authorization=Bearer foo")
+
+	exporter, err := SpanExporterFromEnv()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if exporter == nil {
+		t.Fatal("should have an exporter")
+	}
+
+	c := NewExportingReporter(exporter)
+	c.AddTraceSpan("my-build", "build").End(nil)
+	if err := c.Finalize("build", 0, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if auth != "Bearer foo" {
+		t.Fatalf("bad header: %q", auth)
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans[0].Spans) != 2 {
+		t.Fatalf("bad: %#v", req)
+	}
+}
+
+func TestSpanExporterFromEnv_none(t *testing.T) {
+	for _, k := range []string{EnvTraceFile, EnvOTLPEndpoint, EnvOTLPTracesEndpoint} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, "")
+	}
+
+	exporter, err := SpanExporterFromEnv()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if exporter != nil {
+		t.Fatalf("bad: %#v", exporter)
+	}
+}
+
+func TestSpanExporterFromEnv_badHeaders(t *testing.T) {
+	defer os.Setenv(EnvOTLPTracesEndpoint, os.Getenv(EnvOTLPTracesEndpoint))
+	defer os.Setenv(EnvOTLPHeaders, os.Getenv(EnvOTLPHeaders))
+	os.Setenv(EnvOTLPTracesEndpoint, "http://localhost:4318/v1/traces")
+	os.Setenv(EnvOTLPHeaders, "foo")
+
+	if _, err := SpanExporterFromEnv(); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -0,0 +1,96 @@
+package packer
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNoTerminal is returned when a terminal is requested from a Ui that
+// isn't attached to an interactive terminal.
+var ErrNoTerminal = errors.New("the UI is not attached to an interactive terminal")
+
+// Terminal is the interactive terminal of the user, handed over by a Ui
+// for an interactive session, such as a shell on the machine. The terminal
+// is in raw mode, so everything that is typed is read as is, and the
+// output isn't processed. Closing it gives the terminal back to the Ui; it
+// may be closed more than once.
+type Terminal interface {
+	io.ReadWriteCloser
+
+	// Size returns the width and height of the terminal.
+	Size() (width, height int)
+}
+
+// TerminalUi is implemented by Ui implementations that can be attached to
+// an interactive terminal. It is optional; UiIsTerminal and UiTerminal
+// should be used with any Ui.
+type TerminalUi interface {
+	// IsTerminal returns whether the Ui is attached to an interactive
+	// terminal.
+	IsTerminal() bool
+
+	// Terminal hands over the terminal for an interactive session. It
+	// returns an error if the Ui isn't attached to a terminal.
+	Terminal() (Terminal, error)
+}
+
+// UiIsTerminal returns whether the given Ui is attached to an interactive
+// terminal.
+func UiIsTerminal(ui Ui) bool {
+	if tu, ok := ui.(TerminalUi); ok {
+		return tu.IsTerminal()
+	}
+
+	return false
+}
+
+// UiTerminal returns the interactive terminal the given Ui is attached to.
+func UiTerminal(ui Ui) (Terminal, error) {
+	if tu, ok := ui.(TerminalUi); ok {
+		return tu.Terminal()
+	}
+
+	return nil, ErrNoTerminal
+}
+
+// IsTerminal passes through to the wrapped UI.
+func (u *ColoredUi) IsTerminal() bool {
+	return UiIsTerminal(u.Ui)
+}
+
+// Terminal passes through to the wrapped UI.
+func (u *ColoredUi) Terminal() (Terminal, error) {
+	return UiTerminal(u.Ui)
+}
+
+// IsTerminal passes through to the wrapped UI.
+func (u *TargetedUI) IsTerminal() bool {
+	return UiIsTerminal(u.Ui)
+}
+
+// Terminal passes through to the wrapped UI.
+func (u *TargetedUI) Terminal() (Terminal, error) {
+	return UiTerminal(u.Ui)
+}
+
+// IsTerminal passes through to the wrapped UI.
+func (u *TranscriptUi) IsTerminal() bool {
+	return UiIsTerminal(u.Ui)
+}
+
+// Terminal passes through to the wrapped UI. What happens in the terminal
+// isn't recorded.
+func (u *TranscriptUi) Terminal() (Terminal, error) {
+	u.Transcript.Record("ui", "Handing over the terminal for an interactive session")
+	return UiTerminal(u.Ui)
+}
+
+// IsTerminal passes through to the wrapped UI.
+func (u *stepSpanUi) IsTerminal() bool {
+	return UiIsTerminal(u.Ui)
+}
+
+// Terminal passes through to the wrapped UI.
+func (u *stepSpanUi) Terminal() (Terminal, error) {
+	return UiTerminal(u.Ui)
+}
@@ -0,0 +1,15 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package packer
+
+// IsTerminal always returns false, interactive terminals aren't supported
+// on this platform.
+func (rw *BasicUi) IsTerminal() bool {
+	return false
+}
+
+// Terminal always returns an error, interactive terminals aren't supported
+// on this platform.
+func (rw *BasicUi) Terminal() (Terminal, error) {
+	return nil, ErrNoTerminal
+}
@@ -0,0 +1,39 @@
+package packer
+
+import (
+	"testing"
+)
+
+func TestBasicUi_ImplTerminalUi(t *testing.T) {
+	var raw interface{}
+	raw = &BasicUi{}
+	if _, ok := raw.(TerminalUi); !ok {
+		t.Fatalf("BasicUi must implement TerminalUi")
+	}
+}
+
+func TestBasicUi_TerminalNotTerminal(t *testing.T) {
+	ui := &TargetedUI{
+		Target: "build",
+		Ui:     &ColoredUi{Ui: testUi()},
+	}
+
+	if UiIsTerminal(ui) {
+		t.Fatal("should not be a terminal")
+	}
+
+	if _, err := UiTerminal(ui); err != ErrNoTerminal {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestUiTerminal_notTerminalUi(t *testing.T) {
+	ui := &MachineReadableUi{}
+	if UiIsTerminal(ui) {
+		t.Fatal("should not be a terminal")
+	}
+
+	if _, err := UiTerminal(ui); err != ErrNoTerminal {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package packer
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/sys/unix"
+)
+
+// The interval at which a terminal checks whether it has been closed while
+// waiting for input.
+const terminalPollInterval = 100
+
+// IsTerminal returns whether both the reader and the writer of the UI are
+// a terminal.
+func (rw *BasicUi) IsTerminal() bool {
+	_, _, ok := rw.terminalFiles()
+	return ok
+}
+
+// Terminal puts the terminal of the UI in raw mode and hands it over.
+func (rw *BasicUi) Terminal() (Terminal, error) {
+	in, out, ok := rw.terminalFiles()
+	if !ok {
+		return nil, ErrNoTerminal
+	}
+
+	rw.l.Lock()
+	rw.clearProgress()
+	rw.l.Unlock()
+
+	state, err := terminal.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &basicTerminal{
+		in:     in,
+		out:    out,
+		state:  state,
+		closed: make(chan struct{}),
+	}, nil
+}
+
+func (rw *BasicUi) terminalFiles() (*os.File, *os.File, bool) {
+	in, ok := rw.Reader.(*os.File)
+	if !ok || !terminal.IsTerminal(int(in.Fd())) {
+		return nil, nil, false
+	}
+
+	out, ok := rw.Writer.(*os.File)
+	if !ok || !terminal.IsTerminal(int(out.Fd())) {
+		return nil, nil, false
+	}
+
+	return in, out, true
+}
+
+// basicTerminal is the terminal of a BasicUi.
+type basicTerminal struct {
+	in    *os.File
+	out   *os.File
+	state *terminal.State
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Read waits for input, until the terminal is closed. Reads that are still
+// waiting when the terminal is closed return io.EOF without consuming any
+// input, so that it is left to the UI.
+func (t *basicTerminal) Read(p []byte) (int, error) {
+	fds := []unix.PollFd{{Fd: int32(t.in.Fd()), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-t.closed:
+			return 0, io.EOF
+		default:
+		}
+
+		n, err := unix.Poll(fds, terminalPollInterval)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if n > 0 {
+			return t.in.Read(p)
+		}
+	}
+}
+
+func (t *basicTerminal) Write(p []byte) (int, error) {
+	return t.out.Write(p)
+}
+
+func (t *basicTerminal) Size() (int, int) {
+	width, height, err := terminal.GetSize(int(t.out.Fd()))
+	if err != nil {
+		return 80, 24
+	}
+
+	return width, height
+}
+
+// Close restores the terminal to the state it was in before it was handed
+// over.
+func (t *basicTerminal) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		err = terminal.Restore(int(t.in.Fd()), t.state)
+	})
+
+	return err
+}
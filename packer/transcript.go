@@ -0,0 +1,284 @@
+package packer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Transcript records everything that happens during a single build in
+// one place: the UI output, the logs of the plugins used by the build, the
+// complete output of the remote commands and the boundaries of the steps.
+// Every line is prefixed with a timestamp and the kind of line. It is safe
+// to be used from multiple goroutines.
+type Transcript struct {
+	// Name is the name of the build the transcript is for.
+	Name string
+
+	w io.WriteCloser
+	l sync.Mutex
+}
+
+// NewTranscript returns a transcript for the build with the given name,
+// written to w.
+func NewTranscript(name string, w io.WriteCloser) *Transcript {
+	return &Transcript{
+		Name: name,
+		w:    w,
+	}
+}
+
+// Record adds the message to the transcript. Every line of the message is
+// recorded separately.
+func (t *Transcript) Record(kind, message string) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	message = strings.TrimRight(message, "\r\n")
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if _, err := fmt.Fprintf(t.w, "%s [%s] %s\n", now, kind, line); err != nil {
+			log.Printf("[ERR] Failed to write to transcript: %s", err)
+			return
+		}
+	}
+}
+
+// Writer returns a writer that records every line written to it as the
+// given kind. Closing the writer records the last line if it isn't
+// terminated by a newline.
+func (t *Transcript) Writer(kind string) io.WriteCloser {
+	return &transcriptWriter{
+		t:    t,
+		kind: kind,
+	}
+}
+
+// Close closes the underlying writer of the transcript.
+func (t *Transcript) Close() error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.w.Close()
+}
+
+type transcriptWriter struct {
+	t    *Transcript
+	kind string
+
+	l   sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *transcriptWriter) Write(p []byte) (int, error) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	w.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+
+		line := w.buf.Next(idx + 1)
+		w.t.Record(w.kind, string(line))
+	}
+
+	return len(p), nil
+}
+
+func (w *transcriptWriter) Close() error {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.buf.Len() > 0 {
+		w.t.Record(w.kind, w.buf.String())
+		w.buf.Reset()
+	}
+
+	return nil
+}
+
+// TranscriptUi is a UI that records all output to a transcript before
+// passing it through to the wrapped UI. Machine-readable "step" messages
+// are recorded as step boundaries.
+type TranscriptUi struct {
+	Ui         Ui
+	Transcript *Transcript
+}
+
+func (u *TranscriptUi) Ask(query string) (string, error) {
+	u.Transcript.Record("ui", query)
+	result, err := u.Ui.Ask(query)
+	if err == nil {
+		u.Transcript.Record("ui", "Answer: "+result)
+	}
+
+	return result, err
+}
+
+func (u *TranscriptUi) Say(message string) {
+	u.Transcript.Record("ui", message)
+	u.Ui.Say(message)
+}
+
+func (u *TranscriptUi) Message(message string) {
+	u.Transcript.Record("ui", message)
+	u.Ui.Message(message)
+}
+
+func (u *TranscriptUi) Error(message string) {
+	u.Transcript.Record("ui error", message)
+	u.Ui.Error(message)
+}
+
+func (u *TranscriptUi) Machine(t string, args ...string) {
+	// Strip the target, if any; the transcript is for a single build.
+	category := t
+	if idx := strings.Index(category, ","); idx > -1 {
+		category = category[idx+1:]
+	}
+	if category == "step" {
+		u.Transcript.Record("step", strings.Join(args, " "))
+	}
+
+	u.Ui.Machine(t, args...)
+}
+
+func (u *TranscriptUi) ProgressBar(name string, total int64) ProgressBar {
+	return NewProgressBar(u.Ui, name, total)
+}
+
+// UiTranscript returns the transcript that the given UI records to, or nil
+// if it doesn't record to one.
+func UiTranscript(ui Ui) *Transcript {
+	if tu, ok := ui.(*TranscriptUi); ok {
+		return tu.Transcript
+	}
+
+	return nil
+}
+
+// transcriptCommunicator is a Communicator that records the remote
+// commands that are run and their complete output to a transcript.
+type transcriptCommunicator struct {
+	Communicator
+	t *Transcript
+}
+
+func (c *transcriptCommunicator) Start(cmd *RemoteCmd) error {
+	c.t.Record("cmd", "Starting remote command: "+cmd.Command)
+
+	stdout := c.t.Writer("stdout")
+	stderr := c.t.Writer("stderr")
+	if cmd.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, stdout)
+	} else {
+		cmd.Stdout = stdout
+	}
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	} else {
+		cmd.Stderr = stderr
+	}
+
+	if err := c.Communicator.Start(cmd); err != nil {
+		c.t.Record("cmd", fmt.Sprintf("Error starting remote command: %s", err))
+		return err
+	}
+
+	go func() {
+		cmd.Wait()
+		stdout.Close()
+		stderr.Close()
+		c.t.Record("cmd", fmt.Sprintf(
+			"Remote command exited with status %d: %s", cmd.ExitStatus, cmd.Command))
+	}()
+
+	return nil
+}
+
+// StartShell passes through to the wrapped communicator, if it supports
+// interactive shells. What happens in the shell isn't recorded.
+func (c *transcriptCommunicator) StartShell(cmd *RemoteCmd, term string, width, height int) error {
+	sc, ok := c.Communicator.(ShellCommunicator)
+	if !ok {
+		return ErrShellNotSupported
+	}
+
+	c.t.Record("cmd", "Starting interactive shell")
+	return sc.StartShell(cmd, term, width, height)
+}
+
+func (c *transcriptCommunicator) Upload(path string, r io.Reader, fi *os.FileInfo) error {
+	c.t.Record("cmd", "Uploading file to "+path)
+	return c.Communicator.Upload(path, r, fi)
+}
+
+func (c *transcriptCommunicator) UploadWithProgress(path string, r io.Reader, fi *os.FileInfo, bar ProgressBar) error {
+	c.t.Record("cmd", "Uploading file to "+path)
+	return UploadWithProgress(c.Communicator, path, r, fi, bar)
+}
+
+func (c *transcriptCommunicator) UploadDir(dst string, src string, exclude []string) error {
+	c.t.Record("cmd", fmt.Sprintf("Uploading directory %s to %s", src, dst))
+	return c.Communicator.UploadDir(dst, src, exclude)
+}
+
+func (c *transcriptCommunicator) UploadDirWithProgress(dst string, src string, exclude []string, bar ProgressBar) error {
+	c.t.Record("cmd", fmt.Sprintf("Uploading directory %s to %s", src, dst))
+	return UploadDirWithProgress(c.Communicator, dst, src, exclude, bar)
+}
+
+func (c *transcriptCommunicator) Download(path string, w io.Writer) error {
+	c.t.Record("cmd", "Downloading file from "+path)
+	return c.Communicator.Download(path, w)
+}
+
+func (c *transcriptCommunicator) DownloadWithProgress(path string, w io.Writer, bar ProgressBar) error {
+	c.t.Record("cmd", "Downloading file from "+path)
+	return DownloadWithProgress(c.Communicator, path, w, bar)
+}
+
+func (c *transcriptCommunicator) DownloadDir(src string, dst string, exclude []string) error {
+	c.t.Record("cmd", fmt.Sprintf("Downloading directory %s to %s", src, dst))
+	return c.Communicator.DownloadDir(src, dst, exclude)
+}
+
+func (c *transcriptCommunicator) DownloadDirWithProgress(src string, dst string, exclude []string, bar ProgressBar) error {
+	c.t.Record("cmd", fmt.Sprintf("Downloading directory %s to %s", src, dst))
+	return DownloadDirWithProgress(c.Communicator, src, dst, exclude, bar)
+}
+
+// The transcript that the logs of newly started plugins are recorded to.
+var pluginTranscript struct {
+	sync.Mutex
+	t *Transcript
+}
+
+// SetPluginTranscript sets the transcript that the logs of the plugins
+// started from now on are recorded to. The plugins of a build are started
+// when the build is created by Core.Build, so this should be set to the
+// build's transcript around that call, and reset to nil afterwards.
+func SetPluginTranscript(t *Transcript) {
+	pluginTranscript.Lock()
+	defer pluginTranscript.Unlock()
+
+	pluginTranscript.t = t
+}
+
+// PluginTranscript returns the transcript that the logs of newly started
+// plugins should be recorded to, or nil.
+func PluginTranscript() *Transcript {
+	pluginTranscript.Lock()
+	defer pluginTranscript.Unlock()
+
+	return pluginTranscript.t
+}
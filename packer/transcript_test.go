@@ -0,0 +1,130 @@
+package packer
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// transcriptLines returns the lines of the transcript without timestamps.
+func transcriptLines(buf *bytes.Buffer) []string {
+	re := regexp.MustCompile(`(?m)^\S+ `)
+	return strings.Split(strings.TrimSpace(re.ReplaceAllString(buf.String(), "")), "\n")
+}
+
+func TestTranscriptUi_ImplUi(t *testing.T) {
+	var raw interface{}
+	raw = &TranscriptUi{}
+	if _, ok := raw.(Ui); !ok {
+		t.Fatalf("TranscriptUi must implement Ui")
+	}
+}
+
+func TestTranscriptUi(t *testing.T) {
+	buf := new(bytes.Buffer)
+	transcript := NewTranscript("foo", nopWriteCloser{buf})
+	bufferUi := testUi()
+	ui := &TargetedUI{
+		Target: "foo",
+		Ui: &TranscriptUi{
+			Ui:         bufferUi,
+			Transcript: transcript,
+		},
+	}
+
+	ui.Say("hello\nworld")
+	ui.Error("bad")
+	ui.Machine("step", "run", "StepFoo")
+	ui.Machine("artifact", "0", "end")
+
+	expected := []string{
+		"[ui] ==> foo: hello",
+		"[ui] ==> foo: world",
+		"[ui error] ==> foo: bad",
+		"[step] run StepFoo",
+	}
+	if actual := transcriptLines(buf); strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if actual := readWriter(bufferUi); actual != "==> foo: hello\n==> foo: world\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+	if UiTranscript(ui.Ui) != transcript {
+		t.Fatal("should find the transcript")
+	}
+}
+
+func TestTranscript_Writer(t *testing.T) {
+	buf := new(bytes.Buffer)
+	transcript := NewTranscript("foo", nopWriteCloser{buf})
+
+	w := transcript.Writer("stdout")
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\nthree"))
+	w.Close()
+
+	expected := []string{
+		"[stdout] one",
+		"[stdout] two",
+		"[stdout] three",
+	}
+	if actual := transcriptLines(buf); strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestProvisionHook_transcript(t *testing.T) {
+	buf := new(bytes.Buffer)
+	transcript := NewTranscript("foo", nopWriteCloser{buf})
+
+	comm := &MockCommunicator{
+		StartStdout:     "out\n",
+		StartStderr:     "err\n",
+		StartExitStatus: 1,
+	}
+
+	p := &MockProvisioner{}
+	p.ProvFunc = func() error {
+		cmd := &RemoteCmd{Command: "echo hello"}
+		if err := p.ProvCommunicator.Start(cmd); err != nil {
+			return err
+		}
+		cmd.Wait()
+		return nil
+	}
+
+	hook := &ProvisionHook{
+		Provisioners: []*HookedProvisioner{
+			{p, nil, "shell"},
+		},
+		Transcript: transcript,
+	}
+
+	if err := hook.Run("foo", testUi(), comm, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The exit status is recorded in the background
+	transcript.l.Lock()
+	output := buf.String()
+	transcript.l.Unlock()
+
+	for _, expected := range []string{
+		"[step] provisioner shell",
+		"[cmd] Starting remote command: echo hello",
+		"[stdout] out",
+		"[stderr] err",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in transcript: %s", expected, output)
+		}
+	}
+}
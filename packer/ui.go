@@ -58,7 +58,8 @@ type TargetedUI struct {
 
 // The BasicUI is a UI that reads and writes from a standard Go reader
 // and writer. It is safe to be called from multiple goroutines. Machine
-// readable output is simply logged for this UI.
+// readable output is simply logged for this UI. If the writer is a
+// terminal, progress bars are drawn live below the other output.
 type BasicUi struct {
 	Reader      io.Reader
 	Writer      io.Writer
@@ -66,6 +67,9 @@ type BasicUi struct {
 	l           sync.Mutex
 	interrupted bool
 	scanner     *bufio.Scanner
+
+	progressBars  []*basicProgressBar
+	progressWidth int
 }
 
 // MachineReadableUi is a UI that only outputs machine-readable output
@@ -184,6 +188,7 @@ func (rw *BasicUi) Ask(query string) (string, error) {
 	defer signal.Stop(sigCh)
 
 	log.Printf("ui: ask: %s", query)
+	rw.clearProgress()
 	if query != "" {
 		if _, err := fmt.Fprint(rw.Writer, query+" "); err != nil {
 			return "", err
@@ -223,10 +228,12 @@ func (rw *BasicUi) Say(message string) {
 	defer rw.l.Unlock()
 
 	log.Printf("ui: %s", message)
+	rw.clearProgress()
 	_, err := fmt.Fprint(rw.Writer, message+"\n")
 	if err != nil {
 		log.Printf("[ERR] Failed to write to UI: %s", err)
 	}
+	rw.drawProgress()
 }
 
 func (rw *BasicUi) Message(message string) {
@@ -234,10 +241,12 @@ func (rw *BasicUi) Message(message string) {
 	defer rw.l.Unlock()
 
 	log.Printf("ui: %s", message)
+	rw.clearProgress()
 	_, err := fmt.Fprint(rw.Writer, message+"\n")
 	if err != nil {
 		log.Printf("[ERR] Failed to write to UI: %s", err)
 	}
+	rw.drawProgress()
 }
 
 func (rw *BasicUi) Error(message string) {
@@ -250,10 +259,12 @@ func (rw *BasicUi) Error(message string) {
 	}
 
 	log.Printf("ui error: %s", message)
+	rw.clearProgress()
 	_, err := fmt.Fprint(writer, message+"\n")
 	if err != nil {
 		log.Printf("[ERR] Failed to write to UI: %s", err)
 	}
+	rw.drawProgress()
 }
 
 func (rw *BasicUi) Machine(t string, args ...string) {
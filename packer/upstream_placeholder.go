@@ -0,0 +1,88 @@
+package packer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// upstreamPlaceholderPrefix starts every value of a placeholder artifact,
+// so that the errors caused by the placeholders can be recognized.
+const upstreamPlaceholderPrefix = "packer-upstream-placeholder"
+
+// UpstreamPlaceholders returns artifacts that stand in for the artifacts
+// of the given builds before those have run. Every property of such an
+// artifact is a placeholder value, so that the configuration of a build
+// that depends on other builds can be prepared to check it for errors.
+func UpstreamPlaceholders(builds []string) map[string]Artifact {
+	result := make(map[string]Artifact, len(builds))
+	for _, b := range builds {
+		result[b] = &placeholderArtifact{build: b}
+	}
+
+	return result
+}
+
+// WithoutUpstreamPlaceholderErrors removes from the given error the errors
+// that mention the value of a placeholder artifact. A configuration that
+// requires such a value to exist, like the path to a local file, can only
+// be checked once the real artifact is available. It returns nil if only
+// such errors are left.
+func WithoutUpstreamPlaceholderErrors(err error) error {
+	if err == nil || !strings.Contains(err.Error(), upstreamPlaceholderPrefix) {
+		return err
+	}
+
+	// The errors of a component that runs as a plugin lose their type, so
+	// the points of a MultiError are found in its message.
+	msg := err.Error()
+	idx := strings.Index(msg, "\n\n* ")
+	if idx < 0 {
+		return nil
+	}
+
+	var result *MultiError
+	for _, point := range strings.Split(msg[idx+len("\n\n* "):], "\n* ") {
+		if !strings.Contains(point, upstreamPlaceholderPrefix) {
+			result = MultiErrorAppend(result, errors.New(point))
+		}
+	}
+	if result == nil {
+		return nil
+	}
+
+	return result
+}
+
+// placeholderArtifact is an Artifact whose properties are placeholders.
+type placeholderArtifact struct {
+	build string
+}
+
+func (a *placeholderArtifact) value(key string) string {
+	return fmt.Sprintf("%s-%s-%s", upstreamPlaceholderPrefix, a.build, key)
+}
+
+func (a *placeholderArtifact) BuilderId() string {
+	return a.value("builder_id")
+}
+
+func (a *placeholderArtifact) Files() []string {
+	return []string{a.value("files")}
+}
+
+func (a *placeholderArtifact) Id() string {
+	return a.value("id")
+}
+
+func (a *placeholderArtifact) String() string {
+	return a.value("string")
+}
+
+func (a *placeholderArtifact) State(name string) interface{} {
+	return a.value("state." + name)
+}
+
+func (a *placeholderArtifact) Destroy() error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package packer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpstreamPlaceholders(t *testing.T) {
+	build := testBuild()
+	build.builderConfig = map[string]interface{}{
+		"image": "{{upstream `base` `state.disk`}}",
+	}
+	build.SetUpstreamArtifacts(UpstreamPlaceholders([]string{"base"}))
+
+	values := build.upstreamArtifacts()["base"]
+	for _, key := range []string{"builder_id", "files", "id", "string", "state.disk"} {
+		if !strings.HasPrefix(values[key], upstreamPlaceholderPrefix) {
+			t.Fatalf("bad %s: %#v", key, values[key])
+		}
+	}
+}
+
+func TestWithoutUpstreamPlaceholderErrors(t *testing.T) {
+	placeholder := UpstreamPlaceholders([]string{"base"})["base"].Files()[0]
+
+	if err := WithoutUpstreamPlaceholderErrors(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := errors.New("source_path is required")
+	if WithoutUpstreamPlaceholderErrors(err) != err {
+		t.Fatal("should keep an error without placeholders")
+	}
+
+	err = errors.New("Source file '" + placeholder + "' needs to exist")
+	if err := WithoutUpstreamPlaceholderErrors(err); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A MultiError that went through a plugin is only a message
+	err = errors.New(MultiErrorAppend(nil,
+		errors.New("Source file '"+placeholder+"' needs to exist"),
+		errors.New("ssh_username must be specified"),
+	).Error())
+	err = WithoutUpstreamPlaceholderErrors(err)
+	if err == nil {
+		t.Fatal("should keep the errors without placeholders")
+	}
+	if strings.Contains(err.Error(), placeholder) || !strings.Contains(err.Error(), "ssh_username") {
+		t.Fatalf("bad: %s", err)
+	}
+}
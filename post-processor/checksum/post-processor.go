@@ -95,6 +95,13 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 	return nil
 }
 
+// PlanOutputs tells build plans about the checksum file that the post-processor writes.
+func (p *PostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output", Default: "packer_{{.BuildName}}_{{.BuilderType}}_{{.ChecksumType}}.checksum", Local: true},
+	}
+}
+
 func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
 	files := artifact.Files()
 	var h hash.Hash
@@ -99,6 +99,13 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 	return nil
 }
 
+// PlanOutputs tells build plans about the archive that the post-processor writes.
+func (p *PostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output", Default: "packer_{{.BuildName}}_{{.BuilderType}}", Local: true},
+	}
+}
+
 func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
 
 	// These are extra variables that will be made available for interpolation.
@@ -160,13 +167,13 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 	switch p.config.Archive {
 	case "tar":
 		ui.Say(fmt.Sprintf("Tarring %s with %s", target, compression))
-		err = createTarArchive(artifact.Files(), output)
+		err = createTarArchive(ui, artifact.Files(), output)
 		if err != nil {
 			return nil, keep, fmt.Errorf("Error creating tar: %s", err)
 		}
 	case "zip":
 		ui.Say(fmt.Sprintf("Zipping %s", target))
-		err = createZipArchive(artifact.Files(), output)
+		err = createZipArchive(ui, artifact.Files(), output)
 		if err != nil {
 			return nil, keep, fmt.Errorf("Error creating zip: %s", err)
 		}
@@ -189,7 +196,16 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 		}
 		defer source.Close()
 
-		if _, err = io.Copy(output, source); err != nil {
+		fi, err := source.Stat()
+		if err != nil {
+			return nil, keep, fmt.Errorf(
+				"Failed to get fileinfo for %s: %s", archiveFile, err)
+		}
+
+		progress := packer.TrackProgress(ui, filepath.Base(archiveFile), fi.Size(), source)
+		_, err = io.Copy(output, progress)
+		progress.Close()
+		if err != nil {
 			return nil, keep, fmt.Errorf("Failed to compress %s: %s",
 				archiveFile, err)
 		}
@@ -282,7 +298,7 @@ func makePgzipWriter(output io.WriteCloser, compressionLevel int) (io.WriteClose
 	return gzipWriter, nil
 }
 
-func createTarArchive(files []string, output io.WriteCloser) error {
+func createTarArchive(ui packer.Ui, files []string, output io.WriteCloser) error {
 	archive := tar.NewWriter(output)
 	defer archive.Close()
 
@@ -310,14 +326,17 @@ func createTarArchive(files []string, output io.WriteCloser) error {
 			return fmt.Errorf("Failed to write tar header for %s: %s", path, err)
 		}
 
-		if _, err := io.Copy(archive, file); err != nil {
+		progress := packer.TrackProgress(ui, filepath.Base(path), fi.Size(), file)
+		_, err = io.Copy(archive, progress)
+		progress.Close()
+		if err != nil {
 			return fmt.Errorf("Failed to copy %s data to archive: %s", path, err)
 		}
 	}
 	return nil
 }
 
-func createZipArchive(files []string, output io.WriteCloser) error {
+func createZipArchive(ui packer.Ui, files []string, output io.WriteCloser) error {
 	archive := zip.NewWriter(output)
 	defer archive.Close()
 
@@ -330,12 +349,19 @@ func createZipArchive(files []string, output io.WriteCloser) error {
 		}
 		defer source.Close()
 
+		fi, err := source.Stat()
+		if err != nil {
+			return fmt.Errorf("Unable to get fileinfo for %s: %s", path, err)
+		}
+
 		target, err := archive.Create(path)
 		if err != nil {
 			return fmt.Errorf("Failed to add zip header for %s: %s", path, err)
 		}
 
-		_, err = io.Copy(target, source)
+		progress := packer.TrackProgress(ui, filepath.Base(path), fi.Size(), source)
+		_, err = io.Copy(target, progress)
+		progress.Close()
 		if err != nil {
 			return fmt.Errorf("Failed to copy %s data to archive: %s", path, err)
 		}
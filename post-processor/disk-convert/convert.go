@@ -0,0 +1,123 @@
+package diskconvert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A diskFormat is an output format, as qemu-img knows it.
+type diskFormat struct {
+	QemuFormat string
+	Extension  string
+	Options    []string
+}
+
+var diskFormats = map[string]diskFormat{
+	"qcow2": {QemuFormat: "qcow2", Extension: "qcow2"},
+	"raw":   {QemuFormat: "raw", Extension: "raw"},
+	"vdi":   {QemuFormat: "vdi", Extension: "vdi"},
+	"vhd":   {QemuFormat: "vpc", Extension: "vhd"},
+	"vhdx":  {QemuFormat: "vhdx", Extension: "vhdx"},
+	"vmdk": {
+		QemuFormat: "vmdk",
+		Extension:  "vmdk",
+		Options:    []string{"-o", "subformat=streamOptimized"},
+	},
+}
+
+// A conversion converts a disk image to one format.
+type conversion struct {
+	Source string
+	Target string
+	Format string
+}
+
+// targetPath returns the path of the disk image converted to the format in
+// the directory: its name without its disk image extension, if it has one,
+// with the extension of the format.
+func targetPath(dir string, source string, format string) string {
+	name := filepath.Base(source)
+	switch ext := filepath.Ext(name); strings.ToLower(ext) {
+	case ".img", ".qcow2", ".raw", ".vdi", ".vhd", ".vhdx", ".vmdk":
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	return filepath.Join(dir, name+"."+diskFormats[format].Extension)
+}
+
+func convertArgs(c conversion, compress bool) []string {
+	format := diskFormats[c.Format]
+
+	// Areas of zeroes of 4k are skipped rather than written, which keeps
+	// the converted image as sparse as its source.
+	args := []string{"convert", "-p", "-S", "4k", "-O", format.QemuFormat}
+	args = append(args, format.Options...)
+	if c.Format == "qcow2" && compress {
+		args = append(args, "-c")
+	}
+
+	return append(args, c.Source, c.Target)
+}
+
+// convert runs qemu-img, and reports its progress as a percentage.
+func convert(c conversion, compress bool, report func(percent float64)) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("qemu-img", convertArgs(c, compress)...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Error converting %s to %s: %s", c.Source, c.Format, err)
+	}
+	readProgress(stdout, report)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("Error converting %s to %s: %s\nStderr: %s",
+			c.Source, c.Format, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// The progress of qemu-img is a line like "    (42.00/100%)", which is
+// rewritten after a carriage return.
+var progressRe = regexp.MustCompile(`\(([0-9]+(\.[0-9]+)?)/100%\)`)
+
+func readProgress(r io.Reader, report func(percent float64)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanProgressLines)
+
+	for scanner.Scan() {
+		matches := progressRe.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		if percent, err := strconv.ParseFloat(matches[1], 64); err == nil {
+			report(percent)
+		}
+	}
+
+	// Drain the output if the scanner failed, so qemu-img doesn't block
+	io.Copy(ioutil.Discard, r)
+}
+
+// scanProgressLines splits on carriage returns as well as new lines.
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
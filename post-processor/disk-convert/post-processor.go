@@ -0,0 +1,206 @@
+package diskconvert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	DiskCompression   bool     `mapstructure:"disk_compression"`
+	Formats           []string `mapstructure:"formats"`
+	KeepInputArtifact bool     `mapstructure:"keep_input_artifact"`
+	OutputDir         string   `mapstructure:"output_directory"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{"output_directory"},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packer.MultiError)
+
+	if p.config.OutputDir == "" {
+		p.config.OutputDir = "packer_{{.BuildName}}_{{.BuilderType}}_disks"
+	}
+
+	validFormats := make([]string, 0, len(diskFormats))
+	for format := range diskFormats {
+		validFormats = append(validFormats, format)
+	}
+	sort.Strings(validFormats)
+
+	if len(p.config.Formats) == 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("formats must be specified"))
+	}
+	seen := make(map[string]bool)
+	for _, format := range p.config.Formats {
+		if _, ok := diskFormats[format]; !ok {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"Unrecognized format %s. Must be one of: %s", format, strings.Join(validFormats, ", ")))
+		}
+		if seen[format] {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("The format %s is repeated", format))
+		}
+		seen[format] = true
+	}
+
+	if err = interpolate.Validate(p.config.OutputDir, &p.config.ctx); err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error parsing output_directory template: %s", err))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// PlanOutputs tells build plans about the directory of the converted
+// disks, which -force deletes.
+func (p *PostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output_directory", Default: "packer_{{.BuildName}}_{{.BuilderType}}_disks", Local: true, Force: true},
+	}
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	disks := common.DiskImageFiles(artifact)
+	if len(disks) == 0 {
+		return nil, false, fmt.Errorf(
+			"The disk-convert post-processor can only be used with artifacts of disk images, "+
+				"but %s has none.", artifact.BuilderId())
+	}
+
+	p.config.ctx.Data = map[string]string{
+		"BuildName":   p.config.PackerBuildName,
+		"BuilderType": p.config.PackerBuilderType,
+	}
+	dir, err := interpolate.Render(p.config.OutputDir, &p.config.ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error interpolating output_directory: %s", err)
+	}
+
+	conversions, err := planConversions(dir, disks, p.config.Formats)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := common.PrepareOutputDir(dir, p.config.PackerForce, artifact.Files()); err != nil {
+		return nil, false, err
+	}
+
+	ui.Say(fmt.Sprintf("Converting %d disk image(s) to %s...",
+		len(disks), strings.Join(p.config.Formats, ", ")))
+	if err := p.convertAll(ui, conversions); err != nil {
+		for _, c := range conversions {
+			os.Remove(c.Target)
+		}
+		return nil, false, err
+	}
+
+	files := make([]string, 0, len(conversions))
+	for _, c := range conversions {
+		files = append(files, c.Target)
+	}
+
+	return &Artifact{files: files}, p.config.KeepInputArtifact, nil
+}
+
+// planConversions returns the conversions of every disk to every format.
+func planConversions(dir string, disks []string, formats []string) ([]conversion, error) {
+	var conversions []conversion
+	sources := make(map[string]string)
+	for _, disk := range disks {
+		for _, format := range formats {
+			target := targetPath(dir, disk, format)
+			if source, ok := sources[target]; ok {
+				return nil, fmt.Errorf(
+					"The disk images %s and %s would both be converted to %s", source, disk, target)
+			}
+			sources[target] = disk
+
+			conversions = append(conversions, conversion{
+				Source: disk,
+				Target: target,
+				Format: format,
+			})
+		}
+	}
+
+	return conversions, nil
+}
+
+// convertAll runs the conversions in parallel, as many at a time as there
+// are CPUs.
+func (p *PostProcessor) convertAll(ui packer.Ui, conversions []conversion) error {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	errs := new(packer.MultiError)
+	sem := make(chan struct{}, runtime.NumCPU())
+
+	for _, c := range conversions {
+		wg.Add(1)
+		go func(c conversion) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			err := p.convert(ui, c)
+			if err != nil {
+				lock.Lock()
+				errs = packer.MultiErrorAppend(errs, err)
+				lock.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// convert runs a conversion with a progress bar, which tracks how much of
+// the source disk image is converted.
+func (p *PostProcessor) convert(ui packer.Ui, c conversion) error {
+	fi, err := os.Stat(c.Source)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s to %s", filepath.Base(c.Source), c.Format)
+	bar := packer.NewProgressBar(ui, name, fi.Size())
+	defer bar.Close()
+
+	return convert(c, p.config.DiskCompression, func(percent float64) {
+		bar.Set(int64(percent / 100 * float64(fi.Size())))
+	})
+}
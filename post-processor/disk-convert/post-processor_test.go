@@ -0,0 +1,247 @@
+package diskconvert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// testQemuImg puts a fake qemu-img in the PATH, which reports its progress
+// and writes its arguments to the target.
+func testQemuImg(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "packer-disk-convert")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	script := `#!/bin/sh
+for dst; do :; done
+case "$dst" in
+	*.vdi) echo "failed" >&2; exit 1;;
+esac
+printf '    (0.00/100%%)\r    (42.50/100%%)\r    (100.00/100%%)\r\n'
+echo "$@" > "$dst"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "qemu-img"), []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(map[string]interface{}{}); err == nil {
+		t.Fatal("should error without formats")
+	}
+
+	p = PostProcessor{}
+	if err := p.Configure(map[string]interface{}{"formats": []string{"qcow2", "vhd"}}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := [][]string{
+		{"qcow2", "ova"},
+		{"raw", "raw"},
+	}
+	for _, formats := range cases {
+		p = PostProcessor{}
+		if err := p.Configure(map[string]interface{}{"formats": formats}); err == nil {
+			t.Fatalf("should error: %#v", formats)
+		}
+	}
+}
+
+func TestConvertArgs(t *testing.T) {
+	c := conversion{Source: "disk.raw", Target: "out/disk.qcow2", Format: "qcow2"}
+	args := convertArgs(c, true)
+	expected := []string{"convert", "-p", "-S", "4k", "-O", "qcow2", "-c", "disk.raw", "out/disk.qcow2"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	c = conversion{Source: "disk.raw", Target: "out/disk.vmdk", Format: "vmdk"}
+	args = convertArgs(c, true)
+	expected = []string{
+		"convert", "-p", "-S", "4k", "-O", "vmdk", "-o", "subformat=streamOptimized",
+		"disk.raw", "out/disk.vmdk",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad: %#v", args)
+	}
+}
+
+func TestPlanConversions(t *testing.T) {
+	conversions, err := planConversions("out", []string{"a/ubuntu-18.04", "b/data.img"}, []string{"vhd", "raw"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := []conversion{
+		{Source: "a/ubuntu-18.04", Target: "out/ubuntu-18.04.vhd", Format: "vhd"},
+		{Source: "a/ubuntu-18.04", Target: "out/ubuntu-18.04.raw", Format: "raw"},
+		{Source: "b/data.img", Target: "out/data.vhd", Format: "vhd"},
+		{Source: "b/data.img", Target: "out/data.raw", Format: "raw"},
+	}
+	if !reflect.DeepEqual(conversions, expected) {
+		t.Fatalf("bad: %#v", conversions)
+	}
+
+	// Disks with the same name would overwrite each other
+	_, err = planConversions("out", []string{"a/disk.img", "b/disk.qcow2"}, []string{"raw"})
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestReadProgress(t *testing.T) {
+	output := "    (0.00/100%)\r    (5.01/100%)\r    (12/100%)\r\n" +
+		"Warning\n    (100.00/100%)\r\n"
+
+	var reported []float64
+	readProgress(strings.NewReader(output), func(percent float64) {
+		reported = append(reported, percent)
+	})
+	if !reflect.DeepEqual(reported, []float64{0, 5.01, 12, 100}) {
+		t.Fatalf("bad: %#v", reported)
+	}
+}
+
+// testProgressUi records the last progress of its progress bars.
+type testProgressUi struct {
+	packer.Ui
+
+	l        sync.Mutex
+	progress map[string]int64
+}
+
+func (u *testProgressUi) ProgressBar(name string, total int64) packer.ProgressBar {
+	return &testProgressBar{ui: u, name: name}
+}
+
+type testProgressBar struct {
+	ui   *testProgressUi
+	name string
+}
+
+func (b *testProgressBar) Add(n int64) {}
+
+func (b *testProgressBar) Set(current int64) {
+	b.ui.l.Lock()
+	defer b.ui.l.Unlock()
+	b.ui.progress[b.name] = current
+}
+
+func (b *testProgressBar) Close() error { return nil }
+
+func testDisk(t *testing.T) (string, string) {
+	dir, err := ioutil.TempDir("", "packer-disk-convert")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	disk := filepath.Join(dir, "disk.qcow2")
+	ioutil.WriteFile(disk, []byte("qcow2"), 0644)
+	return dir, disk
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	defer testQemuImg(t)()
+	dir, disk := testDisk(t)
+	defer os.RemoveAll(dir)
+
+	var p PostProcessor
+	config := map[string]interface{}{
+		"formats":           []string{"qcow2", "vmdk", "vhd", "vhdx"},
+		"disk_compression":  true,
+		"output_directory":  filepath.Join(dir, "{{.BuildName}}"),
+		"packer_build_name": "qemu",
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := &testProgressUi{Ui: packer.TestUi(t), progress: make(map[string]int64)}
+	artifact, keep, err := p.PostProcess(ui, &packer.MockArtifact{FilesValue: []string{disk}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keep {
+		t.Fatal("should not keep the input artifact")
+	}
+
+	expected := []string{
+		filepath.Join(dir, "qemu", "disk.qcow2"),
+		filepath.Join(dir, "qemu", "disk.vmdk"),
+		filepath.Join(dir, "qemu", "disk.vhd"),
+		filepath.Join(dir, "qemu", "disk.vhdx"),
+	}
+	if !reflect.DeepEqual(artifact.Files(), expected) {
+		t.Fatalf("bad: %#v", artifact.Files())
+	}
+
+	data, err := ioutil.ReadFile(expected[2])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(data), "-O vpc "+disk) {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// The progress is reported for every conversion, in bytes of the source
+	expectedProgress := map[string]int64{
+		"disk.qcow2 to qcow2": 5,
+		"disk.qcow2 to vmdk":  5,
+		"disk.qcow2 to vhd":   5,
+		"disk.qcow2 to vhdx":  5,
+	}
+	if !reflect.DeepEqual(ui.progress, expectedProgress) {
+		t.Fatalf("bad: %#v", ui.progress)
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(expected[0]); !os.IsNotExist(err) {
+		t.Fatal("the disk should be deleted")
+	}
+}
+
+func TestPostProcessor_PostProcessError(t *testing.T) {
+	defer testQemuImg(t)()
+	dir, disk := testDisk(t)
+	defer os.RemoveAll(dir)
+
+	var p PostProcessor
+	config := map[string]interface{}{
+		"formats":          []string{"raw", "vdi"},
+		"output_directory": filepath.Join(dir, "output"),
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, _, err := p.PostProcess(packer.TestUi(t), &packer.MockArtifact{FilesValue: []string{disk}})
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The conversions that succeeded are deleted too
+	if _, err := os.Stat(filepath.Join(dir, "output", "disk.raw")); !os.IsNotExist(err) {
+		t.Fatal("the raw disk should be deleted")
+	}
+}
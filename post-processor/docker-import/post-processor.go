@@ -16,6 +16,7 @@ const BuilderId = "packer.post-processor.docker-import"
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
+	Driver     string `mapstructure:"driver"`
 	Repository string `mapstructure:"repository"`
 	Tag        string `mapstructure:"tag"`
 
@@ -38,10 +39,22 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return err
 	}
 
+	if err := docker.ValidateDriver(p.config.Driver); err != nil {
+		return err
+	}
+
 	return nil
 
 }
 
+// PlanOutputs tells build plans about the repository that the image is
+// imported to.
+func (p *PostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "repository"},
+	}
+}
+
 func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
 	switch artifact.BuilderId() {
 	case docker.BuilderId, artifice.BuilderId:
@@ -58,7 +71,10 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 		importRepo += ":" + p.config.Tag
 	}
 
-	driver := &docker.DockerDriver{Ctx: &p.config.ctx, Ui: ui}
+	driver, err := docker.NewDriver(p.config.Driver, ui, &p.config.ctx)
+	if err != nil {
+		return nil, false, err
+	}
 
 	ui.Message("Importing image: " + artifact.Id())
 	ui.Message("Repository: " + importRepo)
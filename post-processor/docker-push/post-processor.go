@@ -17,6 +17,7 @@ const BuilderIdImport = "packer.post-processor.docker-import"
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
+	Driver                 string `mapstructure:"driver"`
 	Login                  bool
 	LoginUsername          string `mapstructure:"login_username"`
 	LoginPassword          string `mapstructure:"login_password"`
@@ -45,6 +46,10 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return err
 	}
 
+	if err := docker.ValidateDriver(p.config.Driver); err != nil {
+		return err
+	}
+
 	if p.config.EcrLogin && p.config.LoginServer == "" {
 		return fmt.Errorf("ECR login requires login server to be provided.")
 	}
@@ -63,7 +68,11 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 	driver := p.Driver
 	if driver == nil {
 		// If no driver is set, then we use the real driver
-		driver = &docker.DockerDriver{Ctx: &p.config.ctx, Ui: ui}
+		var err error
+		driver, err = docker.NewDriver(p.config.Driver, ui, &p.config.ctx)
+		if err != nil {
+			return nil, false, err
+		}
 	}
 
 	if p.config.EcrLogin {
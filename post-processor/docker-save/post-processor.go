@@ -3,6 +3,7 @@ package dockersave
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/hashicorp/packer/builder/docker"
 	"github.com/hashicorp/packer/common"
@@ -18,7 +19,8 @@ const BuilderId = "packer.post-processor.docker-save"
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
-	Path string `mapstructure:"path"`
+	Driver string `mapstructure:"driver"`
+	Path   string `mapstructure:"path"`
 
 	ctx interpolate.Context
 }
@@ -41,10 +43,22 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return err
 	}
 
+	if err := docker.ValidateDriver(p.config.Driver); err != nil {
+		return err
+	}
+
 	return nil
 
 }
 
+// PlanOutputs tells build plans about the tarball that the image is saved
+// to.
+func (p *PostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "path", Local: true},
+	}
+}
+
 func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
 	if artifact.BuilderId() != dockerimport.BuilderId &&
 		artifact.BuilderId() != dockertag.BuilderId {
@@ -66,12 +80,20 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 	driver := p.Driver
 	if driver == nil {
 		// If no driver is set, then we use the real driver
-		driver = &docker.DockerDriver{Ctx: &p.config.ctx, Ui: ui}
+		var err error
+		driver, err = docker.NewDriver(p.config.Driver, ui, &p.config.ctx)
+		if err != nil {
+			return nil, false, err
+		}
 	}
 
 	ui.Message("Saving image: " + artifact.Id())
 
-	if err := driver.SaveImage(artifact.Id(), f); err != nil {
+	// The size of the image isn't known until it's saved
+	progress := packer.TrackProgressWriter(ui, filepath.Base(path), 0, f)
+	err = driver.SaveImage(artifact.Id(), progress)
+	progress.Close()
+	if err != nil {
 		f.Close()
 		os.Remove(f.Name())
 
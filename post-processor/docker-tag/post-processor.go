@@ -16,6 +16,7 @@ const BuilderId = "packer.post-processor.docker-tag"
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
+	Driver     string `mapstructure:"driver"`
 	Repository string `mapstructure:"repository"`
 	Tag        string `mapstructure:"tag"`
 	Force      bool
@@ -41,6 +42,10 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		return err
 	}
 
+	if err := docker.ValidateDriver(p.config.Driver); err != nil {
+		return err
+	}
+
 	return nil
 
 }
@@ -57,7 +62,11 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 	driver := p.Driver
 	if driver == nil {
 		// If no driver is set, then we use the real driver
-		driver = &docker.DockerDriver{Ctx: &p.config.ctx, Ui: ui}
+		var err error
+		driver, err = docker.NewDriver(p.config.Driver, ui, &p.config.ctx)
+		if err != nil {
+			return nil, false, err
+		}
 	}
 
 	importRepo := p.config.Repository
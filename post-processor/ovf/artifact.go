@@ -0,0 +1,44 @@
+package ovf
+
+import (
+	"fmt"
+	"os"
+)
+
+const BuilderId = "packer.post-processor.ovf"
+
+// Artifact is the OVF descriptor with its manifest and disks, or the OVA
+// that packs them.
+type Artifact struct {
+	dir   string
+	files []string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return a.files
+}
+
+func (*Artifact) Id() string {
+	return ""
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("OVF appliance in directory: %s", a.dir)
+}
+
+func (*Artifact) State(name string) interface{} {
+	return nil
+}
+
+func (a *Artifact) Destroy() error {
+	for _, f := range a.files {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
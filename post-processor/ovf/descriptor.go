@@ -0,0 +1,156 @@
+package ovf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"text/template"
+)
+
+// The CIM operating system types of the OVF OperatingSystemSection, by the
+// names they are configured with.
+var osTypes = map[string]int{
+	"other":      1,
+	"other_64":   102,
+	"linux":      36,
+	"linux_64":   101,
+	"centos":     106,
+	"centos_64":  107,
+	"debian":     95,
+	"debian_64":  96,
+	"freebsd":    42,
+	"freebsd_64": 78,
+	"rhel":       79,
+	"rhel_64":    80,
+	"ubuntu":     93,
+	"ubuntu_64":  94,
+}
+
+// A diskController is the hardware the disks are attached to, as a CIM
+// resource type and subtype.
+type diskController struct {
+	Name         string
+	ResourceType int
+	SubType      string
+	MaxDisks     int
+}
+
+var diskControllers = map[string]diskController{
+	"ide":  {Name: "IDE Controller", ResourceType: 5, SubType: "PIIX4", MaxDisks: 2},
+	"sata": {Name: "SATA Controller", ResourceType: 20, SubType: "AHCI", MaxDisks: 30},
+	"scsi": {Name: "SCSI Controller", ResourceType: 6, SubType: "lsilogic", MaxDisks: 7},
+}
+
+var networkAdapterTypes = []string{"E1000", "E1000e", "PCNet32", "VmxNet3"}
+
+type descriptorDisk struct {
+	Filename string
+	Size     int64
+	Capacity int64
+}
+
+type descriptor struct {
+	Name               string
+	OSTypeID           int
+	Cpus               int
+	Memory             int
+	Controller         diskController
+	Network            string
+	NetworkAdapterType string
+	Disks              []descriptorDisk
+}
+
+// The hardware items are numbered: the CPU, the memory and the controller
+// come first, then the disks and the network adapter. The elements of the
+// CIM settings are in alphabetical order, as their schemas require.
+var descriptorTemplate = template.Must(template.New("ovf").Funcs(template.FuncMap{
+	"xml": func(s string) string {
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	},
+	"add": func(a, b int) int { return a + b },
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<Envelope ovf:version="1.0" xml:lang="en-US" xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <References>
+{{- range $i, $disk := .Disks}}
+    <File ovf:href="{{xml $disk.Filename}}" ovf:id="file{{add $i 1}}" ovf:size="{{$disk.Size}}"/>
+{{- end}}
+  </References>
+  <DiskSection>
+    <Info>List of the virtual disks</Info>
+{{- range $i, $disk := .Disks}}
+    <Disk ovf:capacity="{{$disk.Capacity}}" ovf:capacityAllocationUnits="byte" ovf:diskId="vmdisk{{add $i 1}}" ovf:fileRef="file{{add $i 1}}" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized"/>
+{{- end}}
+  </DiskSection>
+  <NetworkSection>
+    <Info>The list of logical networks</Info>
+    <Network ovf:name="{{xml .Network}}">
+      <Description>The {{xml .Network}} network</Description>
+    </Network>
+  </NetworkSection>
+  <VirtualSystem ovf:id="{{xml .Name}}">
+    <Info>A virtual machine</Info>
+    <Name>{{xml .Name}}</Name>
+    <OperatingSystemSection ovf:id="{{.OSTypeID}}">
+      <Info>The kind of installed guest operating system</Info>
+    </OperatingSystemSection>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemIdentifier>{{xml .Name}}</vssd:VirtualSystemIdentifier>
+        <vssd:VirtualSystemType>vmx-07</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:AllocationUnits>hertz * 10^6</rasd:AllocationUnits>
+        <rasd:Description>Number of Virtual CPUs</rasd:Description>
+        <rasd:ElementName>{{.Cpus}} virtual CPU(s)</rasd:ElementName>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>{{.Cpus}}</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:Description>Memory Size</rasd:Description>
+        <rasd:ElementName>{{.Memory}}MB of memory</rasd:ElementName>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>{{.Memory}}</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:Address>0</rasd:Address>
+        <rasd:Description>{{.Controller.Name}}</rasd:Description>
+        <rasd:ElementName>{{.Controller.Name}} 0</rasd:ElementName>
+        <rasd:InstanceID>3</rasd:InstanceID>
+        <rasd:ResourceSubType>{{.Controller.SubType}}</rasd:ResourceSubType>
+        <rasd:ResourceType>{{.Controller.ResourceType}}</rasd:ResourceType>
+      </Item>
+{{- range $i, $disk := .Disks}}
+      <Item>
+        <rasd:AddressOnParent>{{$i}}</rasd:AddressOnParent>
+        <rasd:ElementName>Hard Disk {{add $i 1}}</rasd:ElementName>
+        <rasd:HostResource>ovf:/disk/vmdisk{{add $i 1}}</rasd:HostResource>
+        <rasd:InstanceID>{{add $i 4}}</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>17</rasd:ResourceType>
+      </Item>
+{{- end}}
+      <Item>
+        <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
+        <rasd:Connection>{{xml .Network}}</rasd:Connection>
+        <rasd:Description>{{.NetworkAdapterType}} ethernet adapter on {{xml .Network}}</rasd:Description>
+        <rasd:ElementName>Ethernet 1</rasd:ElementName>
+        <rasd:InstanceID>{{add (len .Disks) 4}}</rasd:InstanceID>
+        <rasd:ResourceSubType>{{.NetworkAdapterType}}</rasd:ResourceSubType>
+        <rasd:ResourceType>10</rasd:ResourceType>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
+`))
+
+func writeDescriptor(w io.Writer, d *descriptor) error {
+	return descriptorTemplate.Execute(w, d)
+}
@@ -0,0 +1,97 @@
+package ovf
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeManifest writes the SHA-256 digests of the files in the format of
+// OVF manifests.
+func writeManifest(path string, files []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, file := range files {
+		digest, err := sha256File(file)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(f, "SHA256(%s)= %x\n", filepath.Base(file), digest); err != nil {
+			return err
+		}
+	}
+
+	return f.Close()
+}
+
+func sha256File(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("Error computing the digest of %s: %s", path, err)
+	}
+	return h.Sum(nil), nil
+}
+
+// writeOVA packs the files in a tar, in the given order. The descriptor
+// has to come first and the manifest right after it.
+func writeOVA(path string, files []string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, file := range files {
+		if err := addToOVA(tw, file); err != nil {
+			return fmt.Errorf("Error adding %s to the OVA: %s", file, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func addToOVA(tw *tar.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// OVAs are ustar archives, which only have the name and the size
+	// that matter.
+	header := &tar.Header{
+		Name:     fi.Name(),
+		Mode:     0644,
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,245 @@
+package ovf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	Cpus               int    `mapstructure:"cpus"`
+	DiskAdapterType    string `mapstructure:"disk_adapter_type"`
+	Format             string `mapstructure:"format"`
+	KeepInputArtifact  bool   `mapstructure:"keep_input_artifact"`
+	Memory             int    `mapstructure:"memory"`
+	Network            string `mapstructure:"network"`
+	NetworkAdapterType string `mapstructure:"network_adapter_type"`
+	OSType             string `mapstructure:"os_type"`
+	OutputDir          string `mapstructure:"output_directory"`
+	VMName             string `mapstructure:"vm_name"`
+
+	osTypeID int
+	ctx      interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{"output_directory"},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	errs := new(packer.MultiError)
+
+	// Defaults
+	if p.config.Cpus == 0 {
+		p.config.Cpus = 1
+	}
+
+	if p.config.DiskAdapterType == "" {
+		p.config.DiskAdapterType = "scsi"
+	}
+
+	if p.config.Format == "" {
+		p.config.Format = "ovf"
+	}
+
+	if p.config.Memory == 0 {
+		p.config.Memory = 512
+	}
+
+	if p.config.Network == "" {
+		p.config.Network = "NAT"
+	}
+
+	if p.config.NetworkAdapterType == "" {
+		p.config.NetworkAdapterType = "E1000"
+	}
+
+	if p.config.OSType == "" {
+		p.config.OSType = "other"
+	}
+
+	if p.config.OutputDir == "" {
+		p.config.OutputDir = "packer_{{.BuildName}}_{{.BuilderType}}_ovf"
+	}
+
+	if p.config.VMName == "" {
+		p.config.VMName = fmt.Sprintf("packer-%s", p.config.PackerBuildName)
+	}
+
+	// Validation
+	if p.config.Cpus < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("cpus must be positive"))
+	}
+
+	if _, ok := diskControllers[p.config.DiskAdapterType]; !ok {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("Unrecognized disk_adapter_type: %s", p.config.DiskAdapterType))
+	}
+
+	if p.config.Format != "ovf" && p.config.Format != "ova" {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("format must be one of ova or ovf"))
+	}
+
+	if p.config.Memory < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("memory must be positive"))
+	}
+
+	validAdapter := false
+	for _, t := range networkAdapterTypes {
+		if p.config.NetworkAdapterType == t {
+			validAdapter = true
+		}
+	}
+	if !validAdapter {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("network_adapter_type must be one of: %s", strings.Join(networkAdapterTypes, ", ")))
+	}
+
+	// The OS type is either a name or a CIM operating system type
+	if id, ok := osTypes[p.config.OSType]; ok {
+		p.config.osTypeID = id
+	} else if id, err := strconv.Atoi(p.config.OSType); err == nil && id >= 0 {
+		p.config.osTypeID = id
+	} else {
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("Unrecognized os_type: %s", p.config.OSType))
+	}
+
+	if err = interpolate.Validate(p.config.OutputDir, &p.config.ctx); err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error parsing output_directory template: %s", err))
+	}
+
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// PlanOutputs tells build plans about the directory of the OVF, which
+// -force deletes.
+func (p *PostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output_directory", Default: "packer_{{.BuildName}}_{{.BuilderType}}_ovf", Local: true, Force: true},
+	}
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	disks := common.DiskImageFiles(artifact)
+	if len(disks) == 0 {
+		return nil, false, fmt.Errorf(
+			"The ovf post-processor can only be used with artifacts of disk images, "+
+				"but %s has none.", artifact.BuilderId())
+	}
+	controller := diskControllers[p.config.DiskAdapterType]
+	if len(disks) > controller.MaxDisks {
+		return nil, false, fmt.Errorf(
+			"A %s disk controller can only have %d disks", p.config.DiskAdapterType, controller.MaxDisks)
+	}
+
+	p.config.ctx.Data = map[string]string{
+		"BuildName":   p.config.PackerBuildName,
+		"BuilderType": p.config.PackerBuilderType,
+	}
+	dir, err := interpolate.Render(p.config.OutputDir, &p.config.ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error interpolating output_directory: %s", err)
+	}
+	if err := common.PrepareOutputDir(dir, p.config.PackerForce, artifact.Files()); err != nil {
+		return nil, false, err
+	}
+
+	d := &descriptor{
+		Name:               p.config.VMName,
+		OSTypeID:           p.config.osTypeID,
+		Cpus:               p.config.Cpus,
+		Memory:             p.config.Memory,
+		Controller:         controller,
+		Network:            p.config.Network,
+		NetworkAdapterType: p.config.NetworkAdapterType,
+	}
+
+	// Convert the disks
+	var vmdks []string
+	for i, disk := range disks {
+		vmdk := filepath.Join(dir, fmt.Sprintf("%s-disk%d.vmdk", p.config.VMName, i+1))
+		ui.Message(fmt.Sprintf("Converting %s to a stream-optimized vmdk...", disk))
+		if err := convertDisk(disk, vmdk); err != nil {
+			return nil, false, err
+		}
+
+		fi, err := os.Stat(vmdk)
+		if err != nil {
+			return nil, false, err
+		}
+		capacity, err := vmdkCapacity(vmdk)
+		if err != nil {
+			return nil, false, err
+		}
+
+		vmdks = append(vmdks, vmdk)
+		d.Disks = append(d.Disks, descriptorDisk{
+			Filename: filepath.Base(vmdk),
+			Size:     fi.Size(),
+			Capacity: capacity,
+		})
+	}
+
+	// Write the descriptor and its manifest
+	ovfPath := filepath.Join(dir, p.config.VMName+".ovf")
+	ui.Message(fmt.Sprintf("Writing the OVF descriptor: %s", ovfPath))
+	f, err := os.Create(ovfPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error creating the OVF descriptor: %s", err)
+	}
+	err = writeDescriptor(f, d)
+	f.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("Error writing the OVF descriptor: %s", err)
+	}
+
+	mfPath := filepath.Join(dir, p.config.VMName+".mf")
+	files := append([]string{ovfPath}, vmdks...)
+	if err := writeManifest(mfPath, files); err != nil {
+		return nil, false, fmt.Errorf("Error writing the manifest: %s", err)
+	}
+	files = append([]string{ovfPath, mfPath}, vmdks...)
+
+	if p.config.Format == "ova" {
+		ovaPath := filepath.Join(dir, p.config.VMName+".ova")
+		ui.Message(fmt.Sprintf("Packing the OVA: %s", ovaPath))
+		if err := writeOVA(ovaPath, files); err != nil {
+			return nil, false, err
+		}
+		for _, f := range files {
+			if err := os.Remove(f); err != nil {
+				return nil, false, err
+			}
+		}
+		files = []string{ovaPath}
+	}
+
+	return &Artifact{dir: dir, files: files}, p.config.KeepInputArtifact, nil
+}
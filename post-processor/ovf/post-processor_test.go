@@ -0,0 +1,321 @@
+package ovf
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// testQemuImg puts a fake qemu-img in the PATH, which writes the header
+// of a stream-optimized vmdk of 1MB.
+func testQemuImg(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "packer-ovf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	script := `#!/bin/sh
+for dst; do :; done
+printf 'KDMV\001\000\000\000\003\000\000\000\000\010\000\000\000\000\000\000' > "$dst"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "qemu-img"), []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure_defaults(t *testing.T) {
+	var p PostProcessor
+	c := testConfig()
+	c["packer_build_name"] = "qemu"
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.Cpus != 1 || p.config.Memory != 512 {
+		t.Fatalf("bad: %#v", p.config)
+	}
+	if p.config.Format != "ovf" || p.config.VMName != "packer-qemu" {
+		t.Fatalf("bad: %#v", p.config)
+	}
+	if p.config.osTypeID != 1 {
+		t.Fatalf("bad: %d", p.config.osTypeID)
+	}
+}
+
+func TestPostProcessorConfigure_osType(t *testing.T) {
+	cases := map[string]int{
+		"ubuntu_64": 94,
+		"110":       110,
+	}
+	for osType, id := range cases {
+		var p PostProcessor
+		c := testConfig()
+		c["os_type"] = osType
+		if err := p.Configure(c); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if p.config.osTypeID != id {
+			t.Fatalf("bad: %s %d", osType, p.config.osTypeID)
+		}
+	}
+
+	var p PostProcessor
+	c := testConfig()
+	c["os_type"] = "plan9"
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestPostProcessorConfigure_bad(t *testing.T) {
+	cases := []map[string]interface{}{
+		{"format": "vmx"},
+		{"disk_adapter_type": "nvme"},
+		{"network_adapter_type": "virtio"},
+		{"memory": -1},
+	}
+	for _, tc := range cases {
+		var p PostProcessor
+		if err := p.Configure(tc); err == nil {
+			t.Fatalf("should error: %#v", tc)
+		}
+	}
+}
+
+func testPostProcess(t *testing.T, config map[string]interface{}) (string, packer.Artifact) {
+	dir, err := ioutil.TempDir("", "packer-ovf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	disk := filepath.Join(dir, "disk.qcow2")
+	ioutil.WriteFile(disk, []byte("qcow2"), 0644)
+
+	var p PostProcessor
+	config["output_directory"] = filepath.Join(dir, "{{.BuildName}}")
+	config["packer_build_name"] = "qemu"
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact, keep, err := p.PostProcess(packer.TestUi(t), &packer.MockArtifact{FilesValue: []string{disk}})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if keep {
+		t.Fatal("should not keep the input artifact")
+	}
+	return dir, artifact
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	defer testQemuImg(t)()
+
+	dir, artifact := testPostProcess(t, map[string]interface{}{"cpus": 2})
+	defer os.RemoveAll(dir)
+
+	files := artifact.Files()
+	expected := []string{
+		filepath.Join(dir, "qemu", "packer-qemu.ovf"),
+		filepath.Join(dir, "qemu", "packer-qemu.mf"),
+		filepath.Join(dir, "qemu", "packer-qemu-disk1.vmdk"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	// The descriptor has the disk and the configured hardware
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var envelope testEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(envelope.Disks) != 1 || envelope.Disks[0].Capacity != "1048576" {
+		t.Fatalf("bad: %#v", envelope.Disks)
+	}
+	if envelope.VirtualSystem.Items[0].VirtualQuantity != "2" {
+		t.Fatalf("bad: %#v", envelope.VirtualSystem.Items[0])
+	}
+
+	// The manifest has the digests of the descriptor and the disk
+	manifest, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, f := range []string{files[0], files[2]} {
+		digest, _ := sha256File(f)
+		line := fmt.Sprintf("SHA256(%s)= %x\n", filepath.Base(f), digest)
+		if !strings.Contains(string(manifest), line) {
+			t.Fatalf("bad: %s", manifest)
+		}
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Fatal("the descriptor should be deleted")
+	}
+}
+
+func TestPostProcessor_PostProcessOVA(t *testing.T) {
+	defer testQemuImg(t)()
+
+	dir, artifact := testPostProcess(t, map[string]interface{}{"format": "ova"})
+	defer os.RemoveAll(dir)
+
+	files := artifact.Files()
+	if !reflect.DeepEqual(files, []string{filepath.Join(dir, "qemu", "packer-qemu.ova")}) {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	// The descriptor is first and the manifest follows it
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		names = append(names, header.Name)
+	}
+	expected := []string{"packer-qemu.ovf", "packer-qemu.mf", "packer-qemu-disk1.vmdk"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	// Only the OVA is left
+	entries, _ := ioutil.ReadDir(filepath.Join(dir, "qemu"))
+	if len(entries) != 1 {
+		t.Fatalf("bad: %d", len(entries))
+	}
+}
+
+func TestPostProcessor_PostProcessNoDisk(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, _, err := p.PostProcess(packer.TestUi(t), &packer.MockArtifact{FilesValue: []string{"package.txt"}})
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestVMDKCapacity(t *testing.T) {
+	f, err := ioutil.TempFile("", "packer-ovf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	f.Write([]byte("not a vmdk, but long enough"))
+	f.Close()
+
+	if _, err := vmdkCapacity(f.Name()); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestWriteDescriptor(t *testing.T) {
+	var buf bytes.Buffer
+	d := &descriptor{
+		Name:               "web & db",
+		OSTypeID:           94,
+		Cpus:               1,
+		Memory:             1024,
+		Controller:         diskControllers["sata"],
+		Network:            "NAT",
+		NetworkAdapterType: "E1000",
+		Disks: []descriptorDisk{
+			{Filename: "web-disk1.vmdk", Size: 100, Capacity: 1024},
+			{Filename: "web-disk2.vmdk", Size: 200, Capacity: 2048},
+		},
+	}
+	if err := writeDescriptor(&buf, d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var envelope testEnvelope
+	if err := xml.Unmarshal(buf.Bytes(), &envelope); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if envelope.VirtualSystem.Name != "web & db" || envelope.VirtualSystem.OS.ID != "94" {
+		t.Fatalf("bad: %#v", envelope.VirtualSystem)
+	}
+	if len(envelope.Files) != 2 || envelope.Files[1].Href != "web-disk2.vmdk" {
+		t.Fatalf("bad: %#v", envelope.Files)
+	}
+
+	// CPU, memory, controller, 2 disks and the network adapter
+	if len(envelope.VirtualSystem.Items) != 6 {
+		t.Fatalf("bad: %#v", envelope.VirtualSystem.Items)
+	}
+	for i, item := range envelope.VirtualSystem.Items {
+		if item.InstanceID != fmt.Sprint(i+1) {
+			t.Fatalf("bad: %#v", item)
+		}
+	}
+	if envelope.VirtualSystem.Items[2].ResourceType != "20" || envelope.VirtualSystem.Items[4].HostResource != "ovf:/disk/vmdisk2" {
+		t.Fatalf("bad: %#v", envelope.VirtualSystem.Items)
+	}
+}
+
+type testEnvelope struct {
+	Files []struct {
+		Href string `xml:"href,attr"`
+	} `xml:"References>File"`
+	Disks []struct {
+		Capacity string `xml:"capacity,attr"`
+	} `xml:"DiskSection>Disk"`
+	VirtualSystem struct {
+		Name string `xml:"Name"`
+		OS   struct {
+			ID string `xml:"id,attr"`
+		} `xml:"OperatingSystemSection"`
+		Items []struct {
+			HostResource    string `xml:"HostResource"`
+			InstanceID      string `xml:"InstanceID"`
+			ResourceType    string `xml:"ResourceType"`
+			VirtualQuantity string `xml:"VirtualQuantity"`
+		} `xml:"VirtualHardwareSection>Item"`
+	} `xml:"VirtualSystem"`
+}
@@ -0,0 +1,52 @@
+package ovf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// The magic number of sparse vmdk extents, "KDMV".
+const vmdkMagic = 0x564d444b
+
+// convertDisk converts a disk image of any format qemu-img reads to a
+// stream-optimized vmdk, which is the format of OVF disks.
+func convertDisk(src string, dst string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("qemu-img", "convert",
+		"-O", "vmdk", "-o", "subformat=streamOptimized", src, dst)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Error converting %s: %s\nStderr: %s", src, err, stderr.String())
+	}
+
+	return nil
+}
+
+// vmdkCapacity reads the capacity in bytes of the disk from the header of
+// a sparse vmdk extent, which stream-optimized vmdks are.
+func vmdkCapacity(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var header struct {
+		Magic    uint32
+		Version  uint32
+		Flags    uint32
+		Capacity uint64
+	}
+	if err := binary.Read(f, binary.LittleEndian, &header); err != nil {
+		return 0, fmt.Errorf("Error reading the header of %s: %s", path, err)
+	}
+	if header.Magic != vmdkMagic {
+		return 0, fmt.Errorf("%s is not a sparse vmdk", path)
+	}
+
+	// The capacity is a number of 512 bytes sectors
+	return int64(header.Capacity) * 512, nil
+}
@@ -157,6 +157,13 @@ func (p *PostProcessor) PostProcessProvider(name string, provider Provider, ui p
 	return NewArtifact(name, outputPath), provider.KeepInputArtifact(), nil
 }
 
+// PlanOutputs tells build plans about the box that the post-processor writes.
+func (p *PostProcessor) PlanOutputs() []packer.PlanOutputKey {
+	return []packer.PlanOutputKey{
+		{Key: "output", Default: "packer_{{ .BuildName }}_{{.Provider}}.box", Local: true},
+	}
+}
+
 func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
 
 	name, ok := builtins[artifact.BuilderId()]
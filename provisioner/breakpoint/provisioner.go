@@ -0,0 +1,85 @@
+package breakpoint
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer/common"
+	"github.com/hashicorp/packer/helper/config"
+	"github.com/hashicorp/packer/packer"
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	// A note that is shown when the build pauses at the breakpoint.
+	Note string `mapstructure:"note"`
+
+	// If true, the build doesn't pause at the breakpoint.
+	Disable bool `mapstructure:"disable"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config Config
+}
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	return config.Decode(&p.config, &config.DecodeOpts{
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+}
+
+func (p *Provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+	if p.config.Disable {
+		if p.config.Note != "" {
+			ui.Say(fmt.Sprintf("Skipping disabled breakpoint: %s", p.config.Note))
+		} else {
+			ui.Say("Skipping disabled breakpoint")
+		}
+		return nil
+	}
+
+	if p.config.Note != "" {
+		ui.Say(fmt.Sprintf("Pausing at breakpoint: %s", p.config.Note))
+	} else {
+		ui.Say("Pausing at breakpoint")
+	}
+
+	// Shells can only be offered on an interactive terminal
+	if !packer.UiIsTerminal(ui) {
+		if _, err := ui.Ask("Press enter to continue."); err != nil {
+			log.Printf("Error asking for input: %s", err)
+		}
+		return nil
+	}
+
+	for {
+		line, err := ui.Ask("Press enter to continue, or type 'shell' to open a shell on the machine:")
+		if err != nil {
+			return err
+		}
+
+		switch strings.TrimSpace(line) {
+		case "":
+			return nil
+		case "shell":
+			if err := common.InteractiveShell(ui, comm); err != nil {
+				return err
+			}
+		default:
+			ui.Error(fmt.Sprintf("Unknown answer: %s", line))
+		}
+	}
+}
+
+func (p *Provisioner) Cancel() {
+	// Just hard quit. The terminal is given back to Packer when the
+	// connection goes away.
+	os.Exit(0)
+}
@@ -0,0 +1,180 @@
+package breakpoint
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// testUi answers the questions it is asked from a list, and records the
+// output.
+type testUi struct {
+	answers  []string
+	asked    []string
+	output   bytes.Buffer
+	terminal *testTerminal
+}
+
+func (u *testUi) Ask(query string) (string, error) {
+	u.asked = append(u.asked, query)
+	if len(u.answers) == 0 {
+		return "", nil
+	}
+
+	answer := u.answers[0]
+	u.answers = u.answers[1:]
+	return answer, nil
+}
+
+func (u *testUi) Say(message string)                 { u.output.WriteString(message + "\n") }
+func (u *testUi) Message(message string)             { u.output.WriteString(message + "\n") }
+func (u *testUi) Error(message string)               { u.output.WriteString(message + "\n") }
+func (u *testUi) Machine(t string, args ...string)   {}
+func (u *testUi) IsTerminal() bool                   { return u.terminal != nil }
+func (u *testUi) Terminal() (packer.Terminal, error) { return u.terminal, nil }
+
+type testTerminal struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (t *testTerminal) Size() (int, int) { return 100, 50 }
+func (t *testTerminal) Close() error {
+	t.closed = true
+	return nil
+}
+
+// testShellCommunicator is a communicator that supports interactive
+// shells.
+type testShellCommunicator struct {
+	packer.MockCommunicator
+
+	shellTerm   string
+	shellWidth  int
+	shellHeight int
+}
+
+func (c *testShellCommunicator) StartShell(cmd *packer.RemoteCmd, term string, width, height int) error {
+	c.shellTerm = term
+	c.shellWidth = width
+	c.shellHeight = height
+
+	cmd.Stdout.Write([]byte("$ "))
+	cmd.SetExited(0)
+	return nil
+}
+
+func TestProvisioner_impl(t *testing.T) {
+	var _ packer.Provisioner = new(Provisioner)
+}
+
+func TestProvisionerPrepare(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(map[string]interface{}{"note": "foo"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.config.Note != "foo" {
+		t.Fatalf("bad: %s", p.config.Note)
+	}
+
+	p = Provisioner{}
+	if err := p.Prepare(map[string]interface{}{"unknown": "foo"}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestProvisionerProvision_disable(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(map[string]interface{}{"disable": true}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(testUi)
+	if err := p.Provision(ui, new(packer.MockCommunicator)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(ui.asked) != 0 {
+		t.Fatalf("bad: %#v", ui.asked)
+	}
+}
+
+func TestProvisionerProvision_noTerminal(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(map[string]interface{}{"note": "check the disk"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := &testUi{answers: []string{"shell"}}
+	comm := new(packer.MockCommunicator)
+	if err := p.Provision(ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Without a terminal, no shell is offered
+	if len(ui.asked) != 1 || strings.Contains(ui.asked[0], "shell") {
+		t.Fatalf("bad: %#v", ui.asked)
+	}
+	if !strings.Contains(ui.output.String(), "check the disk") {
+		t.Fatalf("bad: %s", ui.output.String())
+	}
+	if comm.StartCalled {
+		t.Fatal("nothing should run")
+	}
+}
+
+func TestProvisionerProvision_terminalShell(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	term := new(testTerminal)
+	ui := &testUi{answers: []string{"shell", ""}, terminal: term}
+	comm := new(testShellCommunicator)
+	if err := p.Provision(ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if comm.shellWidth != 100 || comm.shellHeight != 50 || comm.shellTerm == "" {
+		t.Fatalf("bad: %#v", comm)
+	}
+	if term.String() != "$ " {
+		t.Fatalf("bad: %q", term.String())
+	}
+	if !term.closed {
+		t.Fatal("terminal should be given back")
+	}
+	if len(ui.asked) != 2 {
+		t.Fatalf("bad: %#v", ui.asked)
+	}
+}
+
+func TestProvisionerProvision_commandLoop(t *testing.T) {
+	var p Provisioner
+	if err := p.Prepare(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The communicator doesn't support shells, so commands are run one at
+	// a time.
+	ui := &testUi{
+		answers:  []string{"shell", "ls /tmp", "exit", ""},
+		terminal: new(testTerminal),
+	}
+	comm := &packer.MockCommunicator{StartStdout: "foo\n"}
+	if err := p.Provision(ui, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !comm.StartCalled || comm.StartCmd.Command != "ls /tmp" {
+		t.Fatalf("bad: %#v", comm.StartCmd)
+	}
+	if !strings.Contains(ui.output.String(), "foo") {
+		t.Fatalf("bad: %s", ui.output.String())
+	}
+	if len(ui.asked) != 4 {
+		t.Fatalf("bad: %#v", ui.asked)
+	}
+}
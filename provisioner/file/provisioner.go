@@ -26,6 +26,9 @@ type Config struct {
 	// Direction
 	Direction string
 
+	// Patterns of the files to leave out when downloading a directory.
+	Exclude []string
+
 	// False if the sources have to exist.
 	Generated bool
 
@@ -58,6 +61,10 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		errs = packer.MultiErrorAppend(errs,
 			errors.New("Direction must be one of: download, upload."))
 	}
+	if p.config.Direction != "download" && len(p.config.Exclude) > 0 {
+		errs = packer.MultiErrorAppend(errs,
+			errors.New("Exclude is only supported for downloads."))
+	}
 	if p.config.Source != "" {
 		p.config.Sources = append(p.config.Sources, p.config.Source)
 	}
@@ -116,7 +123,10 @@ func (p *Provisioner) ProvisionDownload(ui packer.Ui, comm packer.Communicator)
 		}
 		// if the src was a dir, download the dir
 		if strings.HasSuffix(src, "/") || strings.ContainsAny(src, "*?[") {
-			return comm.DownloadDir(src, dst, nil)
+			bar := packer.NewProgressBar(ui, filepath.Base(src), 0)
+			err := packer.DownloadDirWithProgress(comm, src, dst, p.config.Exclude, bar)
+			bar.Close()
+			return err
 		}
 
 		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
@@ -125,11 +135,19 @@ func (p *Provisioner) ProvisionDownload(ui packer.Ui, comm packer.Communicator)
 		}
 		defer f.Close()
 
-		err = comm.Download(src, f)
+		// Track the progress of the download. The size isn't known upfront.
+		bar := packer.NewProgressBar(ui, filepath.Base(src), 0)
+		df := &downloadFile{File: f}
+		err = packer.DownloadWithProgress(comm, src, df, bar)
+		bar.Close()
 		if err != nil {
 			ui.Error(fmt.Sprintf("Download failed: %s", err))
 			return err
 		}
+
+		if err := df.applyFileInfo(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -147,7 +165,10 @@ func (p *Provisioner) ProvisionUpload(ui packer.Ui, comm packer.Communicator) er
 
 		// If we're uploading a directory, short circuit and do that
 		if info.IsDir() {
-			return comm.UploadDir(p.config.Destination, src, nil)
+			bar := packer.NewProgressBar(ui, filepath.Base(src), 0)
+			err := packer.UploadDirWithProgress(comm, p.config.Destination, src, nil, bar)
+			bar.Close()
+			return err
 		}
 
 		// We're uploading a file...
@@ -166,7 +187,10 @@ func (p *Provisioner) ProvisionUpload(ui packer.Ui, comm packer.Communicator) er
 			dst = filepath.Join(dst, filepath.Base(src))
 		}
 
-		err = comm.Upload(dst, f, &fi)
+		// Track the progress of the upload
+		bar := packer.NewProgressBar(ui, filepath.Base(src), fi.Size())
+		err = packer.UploadWithProgress(comm, dst, f, &fi, bar)
+		bar.Close()
 		if err != nil {
 			ui.Error(fmt.Sprintf("Upload failed: %s", err))
 			return err
@@ -180,3 +204,29 @@ func (p *Provisioner) Cancel() {
 	// running on the other side.
 	os.Exit(0)
 }
+
+// downloadFile is the destination of a download, which takes the mode and
+// modification time of the downloaded file if the communicator knows them.
+type downloadFile struct {
+	*os.File
+	fi os.FileInfo
+}
+
+func (f *downloadFile) SetFileInfo(fi os.FileInfo) {
+	f.fi = fi
+}
+
+func (f *downloadFile) applyFileInfo() error {
+	if f.fi == nil {
+		return nil
+	}
+
+	if err := f.Chmod(f.fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if t := f.fi.ModTime(); !t.IsZero() {
+		return os.Chtimes(f.Name(), t, t)
+	}
+
+	return nil
+}
@@ -1,11 +1,14 @@
 package file
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/packer/packer"
 )
@@ -99,6 +102,54 @@ func TestProvisionerPrepare_EmptyDestination(t *testing.T) {
 	}
 }
 
+func TestProvisionerPrepare_Exclude(t *testing.T) {
+	var p Provisioner
+
+	config := testConfig()
+	config["exclude"] = []string{"*.log"}
+	if err := p.Prepare(config); err == nil {
+		t.Fatal("should error with uploads")
+	}
+
+	p = Provisioner{}
+	config["direction"] = "download"
+	config["source"] = "/var/reports/"
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestProvisionerProvision_DownloadDirExclude(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "packer-file")
+	if err != nil {
+		t.Fatalf("error tempdir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var p Provisioner
+	config := map[string]interface{}{
+		"source":      "/var/reports/",
+		"destination": tmpDir + "/",
+		"direction":   "download",
+		"exclude":     []string{"*.log"},
+	}
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &packer.MockCommunicator{}
+	if err := p.Provision(&stubUi{}, comm); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if comm.DownloadDirSrc != "/var/reports/" {
+		t.Fatalf("bad: %s", comm.DownloadDirSrc)
+	}
+	if !reflect.DeepEqual(comm.DownloadDirExclude, []string{"*.log"}) {
+		t.Fatalf("bad: %#v", comm.DownloadDirExclude)
+	}
+}
+
 type stubUi struct {
 	sayMessages string
 }
@@ -221,3 +272,67 @@ func TestProvisionDownloadMkdirAll(t *testing.T) {
 		}
 	}
 }
+
+// fileInfoCommunicator reports the metadata of the downloaded file.
+type fileInfoCommunicator struct {
+	packer.MockCommunicator
+	fi os.FileInfo
+}
+
+func (c *fileInfoCommunicator) Download(path string, w io.Writer) error {
+	if err := c.MockCommunicator.Download(path, w); err != nil {
+		return err
+	}
+
+	packer.SetDownloadFileInfo(w, c.fi)
+	return nil
+}
+
+func TestProvisionDownload_FileInfo(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "packer-file")
+	if err != nil {
+		t.Fatalf("error tempdir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// The metadata of an existing local file stands in for the remote one
+	src := filepath.Join(tmpDir, "src")
+	if err := ioutil.WriteFile(src, []byte("foo"), 0700); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	modTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(src, modTime, modTime); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dst := filepath.Join(tmpDir, "dst")
+	var p Provisioner
+	config := map[string]interface{}{
+		"source":      "/remote/src",
+		"destination": dst,
+		"direction":   "download",
+	}
+	if err := p.Prepare(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	comm := &fileInfoCommunicator{MockCommunicator: packer.MockCommunicator{DownloadData: "foo"}, fi: fi}
+	if err := p.ProvisionDownload(&stubUi{}, comm); err != nil {
+		t.Fatalf("should successfully provision: %s", err)
+	}
+
+	dstFi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if dstFi.Mode().Perm() != 0700 {
+		t.Fatalf("bad: %s", dstFi.Mode())
+	}
+	if !dstFi.ModTime().Equal(modTime) {
+		t.Fatalf("bad: %s", dstFi.ModTime())
+	}
+}
@@ -29,10 +29,12 @@ var FuncGens = map[string]FuncGenerator{
 	"build_type":     funcGenBuildType,
 	"env":            funcGenEnv,
 	"isotime":        funcGenIsotime,
+	"matrix":         funcGenMatrix,
 	"pwd":            funcGenPwd,
 	"template_dir":   funcGenTemplateDir,
 	"timestamp":      funcGenTimestamp,
 	"uuid":           funcGenUuid,
+	"upstream":       funcGenUpstream,
 	"user":           funcGenUser,
 	"packer_version": funcGenPackerVersion,
 
@@ -106,6 +108,21 @@ func funcGenIsotime(ctx *Context) interface{} {
 	}
 }
 
+func funcGenMatrix(ctx *Context) interface{} {
+	return func(k string) (string, error) {
+		if ctx == nil || ctx.Matrix == nil {
+			return "", errors.New("matrix not available")
+		}
+
+		v, ok := ctx.Matrix[k]
+		if !ok {
+			return "", fmt.Errorf("matrix axis '%s' not found", k)
+		}
+
+		return v, nil
+	}
+}
+
 func funcGenPrimitive(value interface{}) FuncGenerator {
 	return func(ctx *Context) interface{} {
 		return value
@@ -149,6 +166,28 @@ func funcGenUser(ctx *Context) interface{} {
 	}
 }
 
+func funcGenUpstream(ctx *Context) interface{} {
+	return func(build, key string) (string, error) {
+		if ctx == nil || ctx.UpstreamArtifacts == nil {
+			return "", errors.New("upstream artifacts not available")
+		}
+
+		artifact, ok := ctx.UpstreamArtifacts[build]
+		if !ok {
+			return "", fmt.Errorf("no upstream artifact for build '%s'", build)
+		}
+
+		v, ok := artifact[key]
+		if !ok {
+			return "", fmt.Errorf(
+				"upstream artifact for build '%s' has no value for '%s'",
+				build, key)
+		}
+
+		return v, nil
+	}
+}
+
 func funcGenUuid(ctx *Context) interface{} {
 	return func() string {
 		return uuid.TimeOrderedUUID()
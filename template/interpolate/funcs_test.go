@@ -140,6 +140,31 @@ func TestFuncIsotime(t *testing.T) {
 	}
 }
 
+func TestFuncMatrix(t *testing.T) {
+	ctx := &Context{
+		Matrix: map[string]string{"region": "us-east-1"},
+	}
+
+	i := &I{Value: `{{matrix "region"}}`}
+	result, err := i.Render(ctx)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result != "us-east-1" {
+		t.Fatalf("bad: %s", result)
+	}
+
+	i = &I{Value: `{{matrix "os"}}`}
+	if _, err := i.Render(ctx); err == nil {
+		t.Fatal("should error on unknown axis")
+	}
+
+	i = &I{Value: `{{matrix "region"}}`}
+	if _, err := i.Render(&Context{}); err == nil {
+		t.Fatal("should error without a matrix")
+	}
+}
+
 func TestFuncPwd(t *testing.T) {
 	wd, err := os.Getwd()
 	if err != nil {
@@ -261,6 +286,54 @@ func TestFuncUser(t *testing.T) {
 	}
 }
 
+func TestFuncUpstream(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Output string
+		Err    bool
+	}{
+		{
+			`{{upstream "base" "id"}}`,
+			`image-id`,
+			false,
+		},
+
+		{
+			`{{upstream "base" "state.disk"}}`,
+			``,
+			true,
+		},
+
+		{
+			`{{upstream "other" "id"}}`,
+			``,
+			true,
+		},
+	}
+
+	ctx := &Context{
+		UpstreamArtifacts: map[string]map[string]string{
+			"base": {"id": "image-id"},
+		},
+	}
+	for _, tc := range cases {
+		i := &I{Value: tc.Input}
+		result, err := i.Render(ctx)
+		if (err != nil) != tc.Err {
+			t.Fatalf("Input: %s\n\nerr: %s", tc.Input, err)
+		}
+
+		if result != tc.Output {
+			t.Fatalf("Input: %s\n\nGot: %s", tc.Input, result)
+		}
+	}
+
+	i := &I{Value: `{{upstream "base" "id"}}`}
+	if _, err := i.Render(&Context{}); err == nil {
+		t.Fatal("should error without upstream artifacts")
+	}
+}
+
 func TestFuncPackerVersion(t *testing.T) {
 	template := `{{packer_version}}`
 
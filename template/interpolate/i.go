@@ -21,6 +21,15 @@ type Context struct {
 	// EnableEnv enables the env function
 	EnableEnv bool
 
+	// Matrix is the mapping of matrix axes to the values of the matrix
+	// combination being built. It is read by the "matrix" function.
+	Matrix map[string]string
+
+	// UpstreamArtifacts is the mapping of build names to the artifact
+	// properties of the builds this build depends on. It is read by the
+	// "upstream" function.
+	UpstreamArtifacts map[string]map[string]string
+
 	// All the fields below are used for built-in functions.
 	//
 	// BuildName and BuildType are the name and type, respectively,
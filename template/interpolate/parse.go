@@ -40,3 +40,127 @@ func functionsCalledWalk(raw parse.Node, r map[string]struct{}) {
 		panic(fmt.Sprintf("unknown type: %T", node))
 	}
 }
+
+// FunctionCalls returns the arguments of every call to the function with
+// the given name within the template string v. Only calls whose arguments
+// are all string literals are returned, since those are the only ones that
+// can be known before rendering. Strings that fail to parse are treated as
+// containing no calls; rendering them will report the error.
+func FunctionCalls(v string, name string) [][]string {
+	tpl, err := template.New("root").Funcs(Funcs(&Context{})).Parse(v)
+	if err != nil || tpl.Tree == nil {
+		return nil
+	}
+
+	var result [][]string
+	functionCallsWalk(tpl.Tree.Root, name, &result)
+	return result
+}
+
+func functionCallsWalk(raw parse.Node, name string, r *[][]string) {
+	switch node := raw.(type) {
+	case *parse.ActionNode:
+		functionCallsWalk(node.Pipe, name, r)
+	case *parse.BranchNode:
+		functionCallsWalk(node.Pipe, name, r)
+		functionCallsWalk(node.List, name, r)
+		functionCallsWalk(node.ElseList, name, r)
+	case *parse.IfNode:
+		functionCallsWalk(&node.BranchNode, name, r)
+	case *parse.RangeNode:
+		functionCallsWalk(&node.BranchNode, name, r)
+	case *parse.WithNode:
+		functionCallsWalk(&node.BranchNode, name, r)
+	case *parse.CommandNode:
+		if in, ok := node.Args[0].(*parse.IdentifierNode); ok && in.Ident == name {
+			args := make([]string, 0, len(node.Args)-1)
+			for _, n := range node.Args[1:] {
+				s, ok := n.(*parse.StringNode)
+				if !ok {
+					args = nil
+					break
+				}
+				args = append(args, s.Text)
+			}
+			if args != nil {
+				*r = append(*r, args)
+			}
+		}
+
+		for _, n := range node.Args[1:] {
+			functionCallsWalk(n, name, r)
+		}
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			functionCallsWalk(n, name, r)
+		}
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Cmds {
+			functionCallsWalk(n, name, r)
+		}
+	}
+}
+
+// ReferencesData reports whether the template string v reads from the data
+// of the template, such as "{{ .Path }}". Those values are only known to
+// the component that renders the string. Strings that fail to parse are
+// treated as not referencing any data.
+func ReferencesData(v string) bool {
+	tpl, err := template.New("root").Funcs(Funcs(&Context{})).Parse(v)
+	if err != nil || tpl.Tree == nil {
+		return false
+	}
+
+	return referencesDataWalk(tpl.Tree.Root)
+}
+
+func referencesDataWalk(raw parse.Node) bool {
+	switch node := raw.(type) {
+	case *parse.ChainNode, *parse.DotNode, *parse.FieldNode, *parse.VariableNode:
+		return true
+	case *parse.ActionNode:
+		return referencesDataWalk(node.Pipe)
+	case *parse.BranchNode:
+		return referencesDataWalk(node.Pipe) ||
+			referencesDataWalk(node.List) ||
+			referencesDataWalk(node.ElseList)
+	case *parse.IfNode:
+		return referencesDataWalk(&node.BranchNode)
+	case *parse.RangeNode:
+		return referencesDataWalk(&node.BranchNode)
+	case *parse.WithNode:
+		return referencesDataWalk(&node.BranchNode)
+	case *parse.CommandNode:
+		for _, n := range node.Args {
+			if referencesDataWalk(n) {
+				return true
+			}
+		}
+	case *parse.ListNode:
+		if node == nil {
+			return false
+		}
+		for _, n := range node.Nodes {
+			if referencesDataWalk(n) {
+				return true
+			}
+		}
+	case *parse.PipeNode:
+		if node == nil {
+			return false
+		}
+		for _, n := range node.Cmds {
+			if referencesDataWalk(n) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
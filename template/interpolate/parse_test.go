@@ -37,3 +37,59 @@ func TestFunctionsCalled(t *testing.T) {
 		}
 	}
 }
+
+func TestFunctionCalls(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Result [][]string
+	}{
+		{
+			"foo",
+			nil,
+		},
+
+		{
+			"{{upstream `base` `id`}} {{ .HTTPIP }}",
+			[][]string{{"base", "id"}},
+		},
+
+		{
+			"{{if true}}{{upstream `a` `state.disk`}}{{end}}{{upstream `b` (user `x`)}}",
+			[][]string{{"a", "state.disk"}},
+		},
+
+		{
+			"{{upstream",
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		actual := FunctionCalls(tc.Input, "upstream")
+		if !reflect.DeepEqual(actual, tc.Result) {
+			t.Fatalf("bad: %v\n\ngot: %#v", tc.Input, actual)
+		}
+	}
+}
+
+func TestReferencesData(t *testing.T) {
+	cases := []struct {
+		Input  string
+		Result bool
+	}{
+		{"foo", false},
+		{"{{user `foo`}}-{{timestamp}}", false},
+		{"{{ .Path }}", true},
+		{"chmod +x {{.Path}}; {{.Vars}} {{.Path}}", true},
+		{"{{if true}}{{ .Vars }}{{end}}", true},
+		{"{{upstream `base` (printf `%s` .Id)}}", true},
+		{"{{ .", false},
+	}
+
+	for _, tc := range cases {
+		actual := ReferencesData(tc.Input)
+		if actual != tc.Result {
+			t.Fatalf("bad: %v\n\ngot: %v", tc.Input, actual)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -24,11 +25,17 @@ type rawTemplate struct {
 	Description string
 
 	Builders       []map[string]interface{}
+	Matrix         map[string]interface{}
 	Push           map[string]interface{}
 	PostProcessors []interface{} `mapstructure:"post-processors"`
 	Provisioners   []map[string]interface{}
 	Variables      map[string]interface{}
 
+	SensitiveVariables []string `mapstructure:"sensitive-variables"`
+
+	ErrorCleanupProvisioner map[string]interface{}   `mapstructure:"error-cleanup-provisioner"`
+	RequiredPlugins         []map[string]interface{} `mapstructure:"required_plugins"`
+
 	RawContents []byte
 }
 
@@ -62,11 +69,13 @@ func (r *rawTemplate) Template() (*Template, error) {
 
 		result.Variables[k] = &v
 	}
+	result.SensitiveVariables = r.SensitiveVariables
 
 	// Let's start by gathering all the builders
 	if len(r.Builders) > 0 {
 		result.Builders = make(map[string]*Builder, len(r.Builders))
 	}
+	expanded := make(map[string]bool, len(r.Matrix))
 	for i, rawB := range r.Builders {
 		var b Builder
 		if err := mapstructure.WeakDecode(rawB, &b); err != nil {
@@ -77,6 +86,7 @@ func (r *rawTemplate) Template() (*Template, error) {
 
 		// Set the raw configuration and delete any special keys
 		b.Config = rawB
+		delete(b.Config, "depends_on")
 		delete(b.Config, "name")
 		delete(b.Config, "type")
 		if len(b.Config) == 0 {
@@ -95,16 +105,45 @@ func (r *rawTemplate) Template() (*Template, error) {
 			b.Name = b.Type
 		}
 
-		// If this builder already exists, it is an error
-		if _, ok := result.Builders[b.Name]; ok {
-			errs = multierror.Append(errs, fmt.Errorf(
-				"builder %d: builder with name '%s' already exists",
-				i+1, b.Name))
-			continue
+		// Expand the builder over its matrix, if it has one
+		builders := []*Builder{&b}
+		if rawM, ok := r.Matrix[b.Name]; ok {
+			expanded[b.Name] = true
+
+			var err error
+			builders, err = r.expandMatrix(&b, rawM)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf(
+					"builder %d: %s", i+1, err))
+				continue
+			}
+		}
+
+		for _, b := range builders {
+			// If this builder already exists, it is an error
+			if _, ok := result.Builders[b.Name]; ok {
+				errs = multierror.Append(errs, fmt.Errorf(
+					"builder %d: builder with name '%s' already exists",
+					i+1, b.Name))
+				continue
+			}
+
+			// Append the builders
+			result.Builders[b.Name] = b
 		}
+	}
 
-		// Append the builders
-		result.Builders[b.Name] = &b
+	// Every matrix has to expand a builder
+	matrixNames := make([]string, 0, len(r.Matrix))
+	for name := range r.Matrix {
+		matrixNames = append(matrixNames, name)
+	}
+	sort.Strings(matrixNames)
+	for _, name := range matrixNames {
+		if !expanded[name] {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"matrix: builder '%s' doesn't exist", name))
+		}
 	}
 
 	// Gather all the post-processors
@@ -141,6 +180,8 @@ func (r *rawTemplate) Template() (*Template, error) {
 			delete(c, "except")
 			delete(c, "only")
 			delete(c, "keep_input_artifact")
+			delete(c, "max_retries")
+			delete(c, "retry_backoff")
 			delete(c, "type")
 			if len(c) > 0 {
 				pp.Config = c
@@ -157,32 +198,57 @@ func (r *rawTemplate) Template() (*Template, error) {
 		result.Provisioners = make([]*Provisioner, 0, len(r.Provisioners))
 	}
 	for i, v := range r.Provisioners {
-		var p Provisioner
-		if err := r.decoder(&p, nil).Decode(v); err != nil {
+		p, err := r.parseProvisioner(v)
+		if err != nil {
 			errs = multierror.Append(errs, fmt.Errorf(
 				"provisioner %d: %s", i+1, err))
 			continue
 		}
 
-		// Type is required before any richer validation
-		if p.Type == "" {
+		// TODO: stuff
+		result.Provisioners = append(result.Provisioners, p)
+	}
+
+	// Gather the error-cleanup-provisioner
+	if len(r.ErrorCleanupProvisioner) > 0 {
+		p, err := r.parseProvisioner(r.ErrorCleanupProvisioner)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"error-cleanup-provisioner: %s", err))
+		}
+
+		result.ErrorCleanupProvisioner = p
+	}
+
+	// Gather the required plugins
+	for i, v := range r.RequiredPlugins {
+		var p RequiredPlugin
+		if err := r.decoder(&p, nil).Decode(v); err != nil {
 			errs = multierror.Append(errs, fmt.Errorf(
-				"provisioner %d: missing 'type'", i+1))
+				"required_plugins %d: %s", i+1, err))
 			continue
 		}
 
-		// Copy the configuration
-		delete(v, "except")
-		delete(v, "only")
-		delete(v, "override")
-		delete(v, "pause_before")
-		delete(v, "type")
-		if len(v) > 0 {
-			p.Config = v
+		if p.Name == "" {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"required_plugins %d: missing 'name'", i+1))
+			continue
 		}
 
-		// TODO: stuff
-		result.Provisioners = append(result.Provisioners, &p)
+		switch p.Type {
+		case "builder", "post-processor", "provisioner":
+		case "":
+			errs = multierror.Append(errs, fmt.Errorf(
+				"required_plugins %d: missing 'type'", i+1))
+			continue
+		default:
+			errs = multierror.Append(errs, fmt.Errorf(
+				"required_plugins %d: unknown type '%s', must be one of "+
+					"builder, post-processor or provisioner", i+1, p.Type))
+			continue
+		}
+
+		result.RequiredPlugins = append(result.RequiredPlugins, &p)
 	}
 
 	// Push
@@ -204,6 +270,113 @@ func (r *rawTemplate) Template() (*Template, error) {
 	return &result, nil
 }
 
+// parseProvisioner parses a single provisioner definition.
+func (r *rawTemplate) parseProvisioner(v map[string]interface{}) (*Provisioner, error) {
+	var p Provisioner
+	if err := r.decoder(&p, nil).Decode(v); err != nil {
+		return nil, err
+	}
+
+	// Type is required before any richer validation
+	if p.Type == "" {
+		return nil, errors.New("missing 'type'")
+	}
+
+	// Copy the configuration
+	delete(v, "except")
+	delete(v, "only")
+	delete(v, "override")
+	delete(v, "pause_before")
+	delete(v, "max_retries")
+	delete(v, "retry_backoff")
+	delete(v, "type")
+	if len(v) > 0 {
+		p.Config = v
+	}
+
+	return &p, nil
+}
+
+// expandMatrix expands the given builder into one builder for every
+// combination of the values of the matrix axes. The names of the resulting
+// builders are the name of the original builder followed by the values of
+// the combination, in the order of the sorted axis names.
+func (r *rawTemplate) expandMatrix(
+	b *Builder, raw interface{}) ([]*Builder, error) {
+	var axes map[string][]string
+	if err := mapstructure.WeakDecode(raw, &axes); err != nil {
+		return nil, fmt.Errorf("matrix: %s", err)
+	}
+	if len(axes) == 0 {
+		return nil, errors.New("matrix: at least one axis must be defined")
+	}
+
+	keys := make([]string, 0, len(axes))
+	for k, values := range axes {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix: axis '%s' has no values", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+	for _, k := range keys {
+		next := make([]map[string]string, 0, len(combinations)*len(axes[k]))
+		for _, c := range combinations {
+			for _, v := range axes[k] {
+				combination := make(map[string]string, len(c)+1)
+				for ck, cv := range c {
+					combination[ck] = cv
+				}
+				combination[k] = v
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+
+	result := make([]*Builder, 0, len(combinations))
+	for _, c := range combinations {
+		nameParts := make([]string, 0, len(keys)+1)
+		nameParts = append(nameParts, b.Name)
+		for _, k := range keys {
+			nameParts = append(nameParts, c[k])
+		}
+
+		expanded := *b
+		expanded.Name = strings.Join(nameParts, "-")
+		expanded.Matrix = c
+		if b.Config != nil {
+			expanded.Config = copyConfig(b.Config).(map[string]interface{})
+		}
+		result = append(result, &expanded)
+	}
+
+	return result, nil
+}
+
+// copyConfig deep copies a configuration decoded from JSON, so that
+// expanded builders never share nested values.
+func copyConfig(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, inner := range v {
+			result[k] = copyConfig(inner)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, inner := range v {
+			result[i] = copyConfig(inner)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
 func (r *rawTemplate) decoder(
 	result interface{},
 	md *mapstructure.Metadata) *mapstructure.Decoder {
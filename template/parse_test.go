@@ -39,6 +39,65 @@ func TestParse(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"parse-builder-matrix.json",
+			&Template{
+				Builders: map[string]*Builder{
+					"ubuntu-aws-1604": {
+						Name:   "ubuntu-aws-1604",
+						Type:   "something",
+						Config: map[string]interface{}{"foo": "{{matrix `cloud`}}"},
+						Matrix: map[string]string{"cloud": "aws", "version": "1604"},
+					},
+					"ubuntu-gcp-1604": {
+						Name:   "ubuntu-gcp-1604",
+						Type:   "something",
+						Config: map[string]interface{}{"foo": "{{matrix `cloud`}}"},
+						Matrix: map[string]string{"cloud": "gcp", "version": "1604"},
+					},
+					"ubuntu-aws-1804": {
+						Name:   "ubuntu-aws-1804",
+						Type:   "something",
+						Config: map[string]interface{}{"foo": "{{matrix `cloud`}}"},
+						Matrix: map[string]string{"cloud": "aws", "version": "1804"},
+					},
+					"ubuntu-gcp-1804": {
+						Name:   "ubuntu-gcp-1804",
+						Type:   "something",
+						Config: map[string]interface{}{"foo": "{{matrix `cloud`}}"},
+						Matrix: map[string]string{"cloud": "gcp", "version": "1804"},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"parse-builder-matrix-missing.json",
+			nil,
+			true,
+		},
+		{
+			"parse-builder-matrix-empty-axis.json",
+			nil,
+			true,
+		},
+		{
+			"parse-builder-depends-on.json",
+			&Template{
+				Builders: map[string]*Builder{
+					"base": {
+						Name: "base",
+						Type: "something",
+					},
+					"child": {
+						Name:      "child",
+						Type:      "something",
+						DependsOn: []string{"base"},
+					},
+				},
+			},
+			false,
+		},
 
 		/*
 		 * Provisioners
@@ -68,6 +127,70 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-provisioner-retry.json",
+			&Template{
+				Provisioners: []*Provisioner{
+					{
+						Type:         "something",
+						MaxRetries:   3,
+						RetryBackoff: 10 * time.Second,
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-error-cleanup-provisioner.json",
+			&Template{
+				ErrorCleanupProvisioner: &Provisioner{
+					Type: "something",
+					OnlyExcept: OnlyExcept{
+						Only: []string{"foo"},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-error-cleanup-provisioner-no-type.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-required-plugins.json",
+			&Template{
+				RequiredPlugins: []*RequiredPlugin{
+					{
+						Name: "acme-cloud",
+						Type: "builder",
+					},
+					{
+						Name:   "acme-agent",
+						Type:   "provisioner",
+						Source: "https://example.com/packer-plugin-acme.zip",
+						SHA256: "abcd",
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-required-plugins-no-type.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-required-plugins-bad-type.json",
+			nil,
+			true,
+		},
+
 		{
 			"parse-provisioner-only.json",
 			&Template{
@@ -143,6 +266,22 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-variable-sensitive.json",
+			&Template{
+				Variables: map[string]*Variable{
+					"foo": {
+						Default: "foo",
+					},
+					"password": {
+						Required: true,
+					},
+				},
+				SensitiveVariables: []string{"password"},
+			},
+			false,
+		},
+
 		{
 			"parse-pp-basic.json",
 			&Template{
@@ -175,6 +314,22 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-pp-retry.json",
+			&Template{
+				PostProcessors: [][]*PostProcessor{
+					{
+						{
+							Type:         "foo",
+							MaxRetries:   2,
+							RetryBackoff: 1 * time.Minute,
+						},
+					},
+				},
+			},
+			false,
+		},
+
 		{
 			"parse-pp-only.json",
 			&Template{
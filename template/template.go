@@ -3,6 +3,10 @@ package template
 import (
 	"errors"
 	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -24,15 +28,42 @@ type Template struct {
 	PostProcessors [][]*PostProcessor
 	Push           Push
 
+	// SensitiveVariables are the names of the variables whose values
+	// must not be shown, such as passwords.
+	SensitiveVariables []string
+
+	// ErrorCleanupProvisioner is run when any of the provisioners
+	// fails, before the machine is torn down.
+	ErrorCleanupProvisioner *Provisioner
+
+	// RequiredPlugins are the plugins that must be installed to use the
+	// template.
+	RequiredPlugins []*RequiredPlugin
+
 	// RawContents is just the raw data for this template
 	RawContents []byte
 }
 
 // Builder represents a builder configured in the template
 type Builder struct {
+	Name      string
+	Type      string
+	DependsOn []string `mapstructure:"depends_on"`
+	Config    map[string]interface{}
+
+	// Matrix holds the values of the matrix combination that this builder
+	// was expanded from, if any.
+	Matrix map[string]string
+}
+
+// RequiredPlugin is a builder, provisioner or post-processor that must be
+// installed as a plugin to use the template. Source and SHA256 tell where
+// the plugin can be installed from, if it is missing.
+type RequiredPlugin struct {
 	Name   string
 	Type   string
-	Config map[string]interface{}
+	Source string
+	SHA256 string `mapstructure:"sha256"`
 }
 
 // PostProcessor represents a post-processor within the template.
@@ -40,7 +71,9 @@ type PostProcessor struct {
 	OnlyExcept `mapstructure:",squash"`
 
 	Type              string
-	KeepInputArtifact bool `mapstructure:"keep_input_artifact"`
+	KeepInputArtifact bool          `mapstructure:"keep_input_artifact"`
+	MaxRetries        int           `mapstructure:"max_retries"`
+	RetryBackoff      time.Duration `mapstructure:"retry_backoff"`
 	Config            map[string]interface{}
 }
 
@@ -48,10 +81,12 @@ type PostProcessor struct {
 type Provisioner struct {
 	OnlyExcept `mapstructure:",squash"`
 
-	Type        string
-	Config      map[string]interface{}
-	Override    map[string]interface{}
-	PauseBefore time.Duration `mapstructure:"pause_before"`
+	Type         string
+	Config       map[string]interface{}
+	Override     map[string]interface{}
+	PauseBefore  time.Duration `mapstructure:"pause_before"`
+	MaxRetries   int           `mapstructure:"max_retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
 }
 
 // Push represents the configuration for pushing the template to Atlas.
@@ -96,22 +131,44 @@ func (t *Template) Validate() error {
 			"at least one builder must be defined"))
 	}
 
+	// Verify that builder dependencies exist and don't form a cycle
+	for _, n := range t.builderNames() {
+		for _, dep := range t.Builders[n].DependsOn {
+			if _, ok := t.Builders[dep]; !ok {
+				err = multierror.Append(err, fmt.Errorf(
+					"builder '%s': depends_on builder '%s' doesn't exist",
+					n, dep))
+			}
+		}
+	}
+	if err == nil {
+		if _, cerr := t.BuilderOrder(); cerr != nil {
+			err = multierror.Append(err, cerr)
+		}
+	}
+
+	// Verify that the sensitive variables exist
+	for _, n := range t.SensitiveVariables {
+		if _, ok := t.Variables[n]; !ok {
+			err = multierror.Append(err, fmt.Errorf(
+				"sensitive-variables: variable '%s' doesn't exist", n))
+		}
+	}
+
 	// Verify that the provisioner overrides target builders that exist
 	for i, p := range t.Provisioners {
-		// Validate only/except
-		if verr := p.OnlyExcept.Validate(t); verr != nil {
+		if verr := p.validate(t); verr != nil {
 			for _, e := range multierror.Append(verr).Errors {
 				err = multierror.Append(err, fmt.Errorf(
 					"provisioner %d: %s", i+1, e))
 			}
 		}
-
-		// Validate overrides
-		for name := range p.Override {
-			if _, ok := t.Builders[name]; !ok {
+	}
+	if p := t.ErrorCleanupProvisioner; p != nil {
+		if verr := p.validate(t); verr != nil {
+			for _, e := range multierror.Append(verr).Errors {
 				err = multierror.Append(err, fmt.Errorf(
-					"provisioner %d: override '%s' doesn't exist",
-					i+1, name))
+					"error-cleanup-provisioner: %s", e))
 			}
 		}
 	}
@@ -119,6 +176,13 @@ func (t *Template) Validate() error {
 	// Verify post-processors
 	for i, chain := range t.PostProcessors {
 		for j, p := range chain {
+			// Validate the retry policy
+			if p.MaxRetries < 0 {
+				err = multierror.Append(err, fmt.Errorf(
+					"post-processor %d.%d: max_retries must not be negative",
+					i+1, j+1))
+			}
+
 			// Validate only/except
 			if verr := p.OnlyExcept.Validate(t); verr != nil {
 				for _, e := range multierror.Append(verr).Errors {
@@ -132,11 +196,123 @@ func (t *Template) Validate() error {
 	return err
 }
 
+// Warnings returns the problems of the template that don't prevent it
+// from being used, such as only/except patterns that match no builder.
+func (t *Template) Warnings() []string {
+	var warns []string
+	for i, p := range t.Provisioners {
+		for _, warn := range p.OnlyExcept.Warnings(t) {
+			warns = append(warns, fmt.Sprintf("provisioner %d: %s", i+1, warn))
+		}
+	}
+	if p := t.ErrorCleanupProvisioner; p != nil {
+		for _, warn := range p.OnlyExcept.Warnings(t) {
+			warns = append(warns, fmt.Sprintf("error-cleanup-provisioner: %s", warn))
+		}
+	}
+	for i, chain := range t.PostProcessors {
+		for j, p := range chain {
+			for _, warn := range p.OnlyExcept.Warnings(t) {
+				warns = append(warns, fmt.Sprintf(
+					"post-processor %d.%d: %s", i+1, j+1, warn))
+			}
+		}
+	}
+
+	return warns
+}
+
+// validate validates the only/except settings, retry policy and overrides
+// of the provisioner.
+func (p *Provisioner) validate(t *Template) error {
+	err := p.OnlyExcept.Validate(t)
+
+	// Validate the retry policy
+	if p.MaxRetries < 0 {
+		err = multierror.Append(err, errors.New(
+			"max_retries must not be negative"))
+	}
+
+	// Validate overrides
+	for name := range p.Override {
+		if _, ok := t.Builders[name]; !ok {
+			err = multierror.Append(err, fmt.Errorf(
+				"override '%s' doesn't exist", name))
+		}
+	}
+
+	return err
+}
+
+// BuilderOrder returns the names of all the builders in an order in which
+// every builder comes after the builders it depends on. Builders without
+// any ordering constraint between them are sorted by name. An error is
+// returned if the dependencies form a cycle.
+func (t *Template) BuilderOrder() ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(t.Builders))
+	result := make([]string, 0, len(t.Builders))
+
+	var visit func(n string, path []string) error
+	visit = func(n string, path []string) error {
+		switch state[n] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf(
+				"builder dependency cycle detected: %s",
+				strings.Join(append(path, n), " -> "))
+		}
+
+		b, ok := t.Builders[n]
+		if !ok {
+			return nil
+		}
+
+		state[n] = visiting
+		deps := make([]string, len(b.DependsOn))
+		copy(deps, b.DependsOn)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, n)); err != nil {
+				return err
+			}
+		}
+		state[n] = visited
+
+		result = append(result, n)
+		return nil
+	}
+
+	for _, n := range t.builderNames() {
+		if err := visit(n, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// builderNames returns the sorted names of all the builders.
+func (t *Template) builderNames() []string {
+	result := make([]string, 0, len(t.Builders))
+	for n := range t.Builders {
+		result = append(result, n)
+	}
+	sort.Strings(result)
+	return result
+}
+
 // Skip says whether or not to skip the build with the given name.
 func (o *OnlyExcept) Skip(n string) bool {
 	if len(o.Only) > 0 {
 		for _, v := range o.Only {
-			if v == n {
+			if ok, _ := MatchBuildName(v, n); ok {
 				return false
 			}
 		}
@@ -146,7 +322,7 @@ func (o *OnlyExcept) Skip(n string) bool {
 
 	if len(o.Except) > 0 {
 		for _, v := range o.Except {
-			if v == n {
+			if ok, _ := MatchBuildName(v, n); ok {
 				return true
 			}
 		}
@@ -158,26 +334,92 @@ func (o *OnlyExcept) Skip(n string) bool {
 }
 
 // Validate validates that the OnlyExcept settings are correct for a thing.
+// Exact names have to name an existing builder, and patterns have to be
+// valid. Patterns that match no builder are reported by Warnings.
 func (o *OnlyExcept) Validate(t *Template) error {
 	if len(o.Only) > 0 && len(o.Except) > 0 {
 		return errors.New("only one of 'only' or 'except' may be specified")
 	}
 
 	var err error
-	for _, n := range o.Only {
-		if _, ok := t.Builders[n]; !ok {
-			err = multierror.Append(err, fmt.Errorf(
-				"'only' specified builder '%s' not found", n))
+	validate := func(key string, patterns []string) {
+		for _, p := range patterns {
+			if IsBuildNamePattern(p) {
+				if _, merr := MatchBuildName(p, ""); merr != nil {
+					err = multierror.Append(err, fmt.Errorf(
+						"'%s' pattern '%s' is invalid: %s", key, p, merr))
+				}
+				continue
+			}
+
+			if _, ok := t.Builders[p]; !ok {
+				err = multierror.Append(err, fmt.Errorf(
+					"'%s' specified builder '%s' not found", key, p))
+			}
 		}
 	}
-	for _, n := range o.Except {
-		if _, ok := t.Builders[n]; !ok {
-			err = multierror.Append(err, fmt.Errorf(
-				"'except' specified builder '%s' not found", n))
+	validate("only", o.Only)
+	validate("except", o.Except)
+
+	return err
+}
+
+// Warnings returns a warning for each of the valid only/except patterns
+// that match no builder of the template.
+func (o *OnlyExcept) Warnings(t *Template) []string {
+	var warns []string
+	warn := func(key string, patterns []string) {
+	PatternLoop:
+		for _, p := range patterns {
+			if !IsBuildNamePattern(p) {
+				continue
+			}
+
+			for n := range t.Builders {
+				ok, err := MatchBuildName(p, n)
+				if err != nil {
+					continue PatternLoop
+				}
+				if ok {
+					continue PatternLoop
+				}
+			}
+
+			warns = append(warns, fmt.Sprintf(
+				"'%s' pattern '%s' matches no builder", key, p))
 		}
 	}
+	warn("only", o.Only)
+	warn("except", o.Except)
 
-	return err
+	return warns
+}
+
+// IsBuildNamePattern reports whether p is a pattern rather than an exact
+// build name. See MatchBuildName.
+func IsBuildNamePattern(p string) bool {
+	return isRegexpPattern(p) || strings.ContainsAny(p, "*?[\\")
+}
+
+// MatchBuildName reports whether the build name n matches p. Patterns are
+// shell globs with the syntax of path.Match, such as "ubuntu-*". A pattern
+// surrounded by slashes, such as "/^ubuntu-(16|18)04-/", is a regular
+// expression instead. Anything else has to be equal to the name.
+func MatchBuildName(p, n string) (bool, error) {
+	if isRegexpPattern(p) {
+		re, err := regexp.Compile(p[1 : len(p)-1])
+		if err != nil {
+			return false, err
+		}
+
+		return re.MatchString(n), nil
+	}
+
+	return path.Match(p, n)
+}
+
+func isRegexpPattern(p string) bool {
+	return len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/")
 }
 
 //-------------------------------------------------------------------
@@ -3,6 +3,7 @@ package template
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -72,6 +73,46 @@ func TestTemplateValidate(t *testing.T) {
 			"validate-good-pp-except.json",
 			false,
 		},
+
+		{
+			"validate-good-prov-only-pattern.json",
+			false,
+		},
+
+		{
+			"validate-bad-prov-only-pattern.json",
+			true,
+		},
+
+		{
+			"validate-bad-prov-retry.json",
+			true,
+		},
+
+		{
+			"validate-bad-depends-on.json",
+			true,
+		},
+
+		{
+			"validate-good-depends-on.json",
+			false,
+		},
+
+		{
+			"validate-depends-on-cycle.json",
+			true,
+		},
+
+		{
+			"validate-good-sensitive-variables.json",
+			false,
+		},
+
+		{
+			"validate-bad-sensitive-variables.json",
+			true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -93,6 +134,54 @@ func TestTemplateValidate(t *testing.T) {
 	}
 }
 
+func TestTemplateWarnings(t *testing.T) {
+	f, err := os.Open(fixtureDir("validate-good-prov-only-pattern.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	tpl, err := Parse(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := tpl.Validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	warns := tpl.Warnings()
+	expected := []string{
+		"provisioner 2: 'except' pattern '*-windows' matches no builder",
+	}
+	if !reflect.DeepEqual(warns, expected) {
+		t.Fatalf("bad: %#v", warns)
+	}
+}
+
+func TestTemplateBuilderOrder(t *testing.T) {
+	f, err := os.Open(fixtureDir("validate-good-depends-on.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	tpl, err := Parse(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual, err := tpl.BuilderOrder()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{"foo", "bar", "baz"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestOnlyExceptSkip(t *testing.T) {
 	cases := []struct {
 		Only, Except []string
@@ -119,6 +208,41 @@ func TestOnlyExceptSkip(t *testing.T) {
 			"foo",
 			false,
 		},
+
+		{
+			[]string{"ubuntu-*"},
+			nil,
+			"ubuntu-1804-aws",
+			false,
+		},
+
+		{
+			[]string{"ubuntu-*"},
+			nil,
+			"centos-7-aws",
+			true,
+		},
+
+		{
+			nil,
+			[]string{"*-windows"},
+			"2016-windows",
+			true,
+		},
+
+		{
+			nil,
+			[]string{"/^ubuntu-(16|18)04-/"},
+			"ubuntu-1804-gcp",
+			true,
+		},
+
+		{
+			nil,
+			[]string{"/^ubuntu-(16|18)04-/"},
+			"ubuntu-1404-gcp",
+			false,
+		},
 	}
 
 	for _, tc := range cases {
@@ -135,3 +259,29 @@ func TestOnlyExceptSkip(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchBuildName(t *testing.T) {
+	cases := []struct {
+		Pattern, Name string
+		Result, Err   bool
+	}{
+		{"foo", "foo", true, false},
+		{"foo", "foobar", false, false},
+		{"foo*", "foobar", true, false},
+		{"f?o", "foo", true, false},
+		{"[", "foo", false, true},
+		{"/^fo+$/", "fooo", true, false},
+		{"/^fo+$/", "fooa", false, false},
+		{"/(/", "foo", false, true},
+	}
+
+	for _, tc := range cases {
+		actual, err := MatchBuildName(tc.Pattern, tc.Name)
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %s", tc.Pattern, err)
+		}
+		if actual != tc.Result {
+			t.Fatalf("%s: bad: %v", tc.Pattern, actual)
+		}
+	}
+}